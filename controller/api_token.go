@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// usingAPIToken 当前请求是否使用个人访问令牌认证, 令牌不能用来管理令牌
+func usingAPIToken(c *gin.Context) bool {
+	_, ok := c.Get(CtxScopesKey)
+	return ok
+}
+
+// AddAPIToken 创建个人访问令牌, 令牌明文只在创建时返回一次
+func AddAPIToken(c *gin.Context) {
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.ParamAPIToken)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	t, raw, err := logic.CreateAPIToken(userID, p)
+	if err != nil {
+		logger.L(c).Error("logic.CreateAPIToken failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "令牌创建成功, 请妥善保存, 之后无法再次查看", "success": true,
+		"data": gin.H{"token": raw, "info": t}})
+}
+
+// GetAPITokens 获取当前用户的个人访问令牌
+func GetAPITokens(c *gin.Context) {
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	tokens, err := logic.GetAPITokens(userID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": tokens})
+}
+
+// DeleteAPIToken 吊销个人访问令牌
+func DeleteAPIToken(c *gin.Context) {
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.RevokeAPIToken(userID, id); err != nil {
+		logger.L(c).Error("logic.RevokeAPIToken failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销", "success": true})
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 个人访问令牌不能管理账号本身: 个人资料、密码、两步验证和令牌
+func TestAPITokenCannotManageAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]gin.HandlerFunc{
+		"PUT /me":                     UpdateMeHandler,
+		"PUT /me/password":            ChangePasswordHandler,
+		"GET /me/2fa":                 GetTOTPStatusHandler,
+		"POST /me/2fa/enroll":         EnrollTOTPHandler,
+		"POST /me/2fa/activate":       ActivateTOTPHandler,
+		"POST /me/2fa/disable":        DisableTOTPHandler,
+		"POST /me/2fa/recovery_codes": RegenerateRecoveryCodesHandler,
+		"POST /api_token":             AddAPIToken,
+		"GET /api_token":              GetAPITokens,
+		"DELETE /api_token/1":         DeleteAPIToken,
+	}
+	body := `{"nickname":"n","oldPassword":"a","newPassword":"b","rePassword":"b","code":"123456","password":"a","name":"ci","scopes":["read"]}`
+	for route, h := range handlers {
+		parts := strings.SplitN(route, " ", 2)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(parts[0], parts[1], strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "id", Value: "1"}}
+		c.Set(CtxUserIDKey, int64(1))
+		c.Set(CtxScopesKey, []string{"read"})
+		h(c)
+
+		var res ResponseData
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Code != CodeNoPermission {
+			t.Errorf("%s: response = %s, want code %d", route, w.Body.String(), CodeNoPermission)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// jenkinsContext 返回请求 Jenkins 使用的 ctx, 携带请求 ID 和 requestNode 获取的节点 ID; 审计中间件开启时会记录 Jenkins 的响应状态码
+func jenkinsContext(c *gin.Context) context.Context {
+	ctx := logger.WithRequestID(context.Background(), logger.RequestID(c))
+	if s, ok := c.Get(CtxJenkinsStatusKey); ok {
+		ctx = logic.WithJenkinsStatus(ctx, s.(*logic.JenkinsStatus))
+	}
+	if id, ok := c.Get(CtxNodeIDKey); ok {
+		ctx = logic.WithJenkinsNode(ctx, id.(int))
+	}
+	return ctx
+}
+
+// jenkinsHTTPClient 返回直接请求 Jenkins 接口使用的 http.Client, 与 jenkinsContext 一样携带请求 ID 并记录状态码
+func jenkinsHTTPClient(c *gin.Context, timeout time.Duration) *http.Client {
+	return logic.JenkinsClient(jenkinsContext(c), &http.Client{Timeout: timeout})
+}
+
+// setAuditBuildNumber 记录操作涉及的构建编号, 写入审计日志
+func setAuditBuildNumber(c *gin.Context, number int64) {
+	c.Set(CtxBuildNumberKey, number)
+}
+
+// GetAuditLogs 分页查询审计日志
+func GetAuditLogs(c *gin.Context) {
+	p := new(models.ParamAuditQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	logs, total, err := logic.GetAuditLogs(p)
+	if err != nil {
+		logger.L(c).Error("logic.GetAuditLogs failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取审计日志失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": gin.H{
+		"list":  logs,
+		"total": total,
+		"page":  p.Page,
+		"size":  p.Size,
+	}})
+}
+
+// ExportAuditLogs 按查询条件导出审计日志, format 为 csv (默认) 或 json
+func ExportAuditLogs(c *gin.Context) {
+	p := new(models.ParamAuditQuery)
+	if err := c.ShouldBindQuery(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if p.Format == "" {
+		p.Format = "csv"
+	}
+	contentType := "text/csv; charset=utf-8"
+	if p.Format == "json" {
+		contentType = "application/json; charset=utf-8"
+	}
+	filename := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102150405"), p.Format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	if err := logic.ExportAuditLogs(p, c.Writer); err != nil {
+		logger.L(c).Error("logic.ExportAuditLogs failed", zap.Error(err))
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			c.JSON(http.StatusOK, gin.H{"success": false, "error": "导出审计日志失败"})
+		}
+	}
+}
@@ -16,6 +16,11 @@ const (
 	CodeInvalidUpdateNode
 	CodeInvalidDeleteNode
 	CodeInvalidGetNode
+	CodeNoPermission
+	CodeUserDisabled
+	CodeTooManyAttempts
+	CodeTwoFactorRequired
+	CodeInvalidTOTPCode
 )
 
 var codeMsgMap = map[ResCode]string{
@@ -32,6 +37,11 @@ var codeMsgMap = map[ResCode]string{
 	CodeInvalidNode:       "添加node失败",
 	CodeInvalidUpdateNode: "编辑node失败",
 	CodeInvalidDeleteNode: "删除node失败",
+	CodeNoPermission:      "没有权限",
+	CodeUserDisabled:      "用户已被禁用",
+	CodeTooManyAttempts:   "登录失败次数过多, 请稍后再试",
+	CodeTwoFactorRequired: "需要两步验证",
+	CodeInvalidTOTPCode:   "验证码错误",
 }
 
 func (c ResCode) Msg() string {
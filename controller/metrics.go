@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"bluebell/pkg/metrics"
+	"bluebell/setting"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var metricsHandler = metrics.Handler()
+
+// MetricsHandler 输出 Prometheus 指标
+// Prometheus 无法完成登录, 因此 /metrics 不使用 JWT 认证, 而是校验 Authorization: Bearer <metrics.token>
+// 指标中包含节点名称和地址, 没有配置 metrics.token 时不输出指标
+func MetricsHandler(c *gin.Context) {
+	cfg := setting.Conf.MetricsConfig
+	if cfg == nil || cfg.Token == "" {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	metricsHandler.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OIDCLoginHandler 发起 OIDC 单点登录, 重定向到 IdP 的授权页面
+func OIDCLoginHandler(c *gin.Context) {
+	u, state, err := logic.OIDCAuthURL()
+	if err != nil {
+		logger.L(c).Error("logic.OIDCAuthURL failed", zap.Error(err))
+		if errors.Is(err, logic.ErrorOIDCDisabled) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	// IdP 回调是跨站的顶级跳转, SameSite=Lax 的 cookie 仍会带上
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(logic.OIDCStateCookie, logic.OIDCStateBinding(state), logic.OIDCStateCookieMaxAge,
+		"/", "", logic.OIDCSecureCookie(), true)
+	c.Redirect(http.StatusFound, u)
+}
+
+// OIDCCallbackHandler IdP 授权后的回调
+// 配置了 frontend_url 时携带 token 跳转到前端, 否则与 /login 一样返回 JSON
+func OIDCCallbackHandler(c *gin.Context) {
+	if e := c.Query("error"); e != "" {
+		logger.L(c).Warn("oidc authorization failed", zap.String("error", e), zap.String("description", c.Query("error_description")))
+		ResponseErrorWithMsg(c, CodeNeedLogin, e)
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	// state cookie 只使用一次
+	binding, _ := c.Cookie(logic.OIDCStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(logic.OIDCStateCookie, "", -1, "/", "", logic.OIDCSecureCookie(), true)
+	user, tokens, err := logic.OIDCCallback(code, state, binding)
+	var twoFactor *logic.TwoFactorRequiredError
+	if errors.As(err, &twoFactor) {
+		// IdP 认证通过, 与密码登录一样使用 challenge 调用 /login/2fa 完成两步验证
+		if u := logic.OIDCFrontendChallengeRedirect(twoFactor); u != "" {
+			c.Redirect(http.StatusFound, u)
+			return
+		}
+		responseTwoFactor(c, twoFactor)
+		return
+	}
+	if err != nil {
+		logger.L(c).Error("logic.OIDCCallback failed", zap.Error(err))
+		switch {
+		case errors.Is(err, logic.ErrorOIDCDisabled), errors.Is(err, logic.ErrorInvalidOIDCState):
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		case errors.Is(err, logic.ErrorInvalidIDToken):
+			ResponseError(c, CodeInvalidToken)
+		case errors.Is(err, logic.ErrorUserDisabled):
+			ResponseError(c, CodeUserDisabled)
+		case errors.Is(err, logic.ErrorUserSourceConflict):
+			ResponseErrorWithMsg(c, CodeUserExist, err.Error())
+		default:
+			ResponseError(c, CodeServerBusy)
+		}
+		return
+	}
+	if u := logic.OIDCFrontendRedirect(tokens); u != "" {
+		c.Redirect(http.StatusFound, u)
+		return
+	}
+	responseLogin(c, user, tokens, nil)
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AddRole 新增角色
+func AddRole(c *gin.Context) {
+	r := new(models.Role)
+	if err := c.ShouldBindJSON(r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.AddRole(r); err != nil {
+		logger.L(c).Error("logic.AddRole failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "角色添加成功", "success": true, "data": r})
+}
+
+// GetRoles 获取角色列表
+func GetRoles(c *gin.Context) {
+	roles, err := logic.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取角色失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": roles})
+}
+
+// GetRole 获取单个角色
+func GetRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	r, err := logic.GetRole(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": r})
+}
+
+// UpdateRole 更新角色
+func UpdateRole(c *gin.Context) {
+	var r models.Role
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := logic.UpdateRole(r.ID, &r); err != nil {
+		logger.L(c).Error("logic.UpdateRole failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "角色更新成功", "success": true})
+}
+
+// DeleteRole 删除角色
+func DeleteRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteRole(id); err != nil {
+		logger.L(c).Error("logic.DeleteRole failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "角色删除成功", "success": true})
+}
+
+// SetUserRoles 设置用户的角色
+func SetUserRoles(c *gin.Context) {
+	p := new(models.ParamUserRoles)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.SetUserRoles(p); err != nil {
+		logger.L(c).Error("logic.SetUserRoles failed", zap.Int64("userId", p.UserID), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功", "success": true})
+}
+
+// GetUserRoles 获取用户的角色
+func GetUserRoles(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	roles, err := logic.GetUserRoles(userID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取角色失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": roles})
+}
@@ -6,7 +6,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-const CtxUserIDKey = "userID"
+const (
+	CtxUserIDKey = "userID"
+	CtxClaimsKey = "claims" // 当前请求的 JWT 声明 (*jwt.MyClaims)
+	CtxScopesKey = "scopes" // 使用个人访问令牌时令牌的权限范围 ([]string)
+
+	CtxJenkinsStatusKey = "jenkinsStatus" // 审计用, 记录 Jenkins 响应状态码 (*logic.JenkinsStatus)
+	CtxBuildNumberKey   = "buildNumber"   // 审计用, 操作涉及的构建编号 (int64)
+	CtxNodeIDKey        = "nodeID"        // 请求的节点 ID (int), 作为 Jenkins 指标的节点标签
+)
 
 var ErrorUserNotLogin = errors.New("用户未登录")
 
@@ -35,6 +35,8 @@ type UserData struct {
 	AccessToken  string   `json:"accessToken"`
 	RefreshToken string   `json:"refreshToken"`
 	Expires      string   `json:"expires"`
+	// 登录时绑定两步验证返回的恢复码, 只显示一次
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
 }
 
 func ResponseError(c *gin.Context, code ResCode) {
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"bluebell/logger"
+	"bluebell/logic"
 	"bluebell/models"
 	"context"
 	"fmt"
@@ -9,21 +11,23 @@ import (
 	"go.uber.org/zap"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 // 获取和删除指定目录下某个 Job 的最新构建
-func getAndDeleteLatestBuildInFolder(ctx context.Context, jenkins *gojenkins.Jenkins, folderName string, jobName string) error {
+// 返回被删除的构建编号
+func getAndDeleteLatestBuildInFolder(ctx context.Context, jenkins *gojenkins.Jenkins, folderName string, jobName string) (int64, error) {
 	// 获取指定目录 (Folder) 下的 Job
 	job, err := jenkins.GetJob(ctx, jobName, folderName)
 	if err != nil {
-		return fmt.Errorf("获取 Job [%s] 失败: %v", jobName, err)
+		return 0, fmt.Errorf("获取 Job [%s] 失败: %v", jobName, err)
 	}
 
 	// 获取最新构建
 	lastBuild, err := job.GetLastBuild(ctx)
 	if err != nil {
-		return fmt.Errorf("获取 Job [%s] 的最新构建失败: %v", jobName, err)
+		return 0, fmt.Errorf("获取 Job [%s] 的最新构建失败: %v", jobName, err)
 	}
 
 	// 打印最新构建信息
@@ -37,15 +41,15 @@ func getAndDeleteLatestBuildInFolder(ctx context.Context, jenkins *gojenkins.Jen
 	deleteURL := fmt.Sprintf("%s/%d/doDelete", job.Base, buildNumber)
 	resp, err := jenkins.Requester.Post(ctx, deleteURL, nil, nil, nil)
 	if err != nil {
-		return fmt.Errorf("删除 Job [%s] 的最新构建失败: %v", jobName, err)
+		return buildNumber, fmt.Errorf("删除 Job [%s] 的最新构建失败: %v", jobName, err)
 	}
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("删除 Job [%s] 的最新构建失败: HTTP 状态码 %d", jobName, resp.StatusCode)
+		return buildNumber, fmt.Errorf("删除 Job [%s] 的最新构建失败: HTTP 状态码 %d", jobName, resp.StatusCode)
 	}
 
 	fmt.Printf("✅ 成功删除 Job [%s] 的最新构建 (构建编号: %d)\n", jobName, buildNumber)
-	return nil
+	return buildNumber, nil
 }
 
 func ConsoleBuildDelete(c *gin.Context) {
@@ -54,19 +58,33 @@ func ConsoleBuildDelete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
-	jenkinsURL := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	ctx := jenkinsContext(c)
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURL, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURL, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
 		return
 	}
 
-	_ = getAndDeleteLatestBuildInFolder(ctx, jenkins, reqData.ViewID, reqData.JobName)
+	// 删除前先归档最新构建的日志, 归档失败不影响删除
+	if _, err := logic.ArchiveConsole(ctx, &models.ParamConsoleArchive{RequestJobData: reqData}); err != nil && err != logic.ErrorArchiveDisabled {
+		logger.L(c).Warn("archive console before delete failed", zap.String("viewId", reqData.ViewID), zap.String("jobName", reqData.JobName), zap.Error(err))
+	}
+	number, err := getAndDeleteLatestBuildInFolder(ctx, jenkins, reqData.ViewID, reqData.JobName)
+	setAuditBuildNumber(c, number)
+	if err != nil {
+		logger.L(c).Error("delete latest build failed", zap.String("viewId", reqData.ViewID), zap.String("jobName", reqData.JobName), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": "删除成功"})
 }
 
@@ -76,12 +94,16 @@ func ConsoleBuildPrevious(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
-	jenkinsURLT := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	ctx := jenkinsContext(c)
+	jenkinsURLT := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURLT, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURLT, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -107,16 +129,12 @@ func ConsoleBuildPrevious(c *gin.Context) {
 	// http://172.24.65.29:10001/job/test-job/8/api/json
 	buildNumber := lastBuild.GetBuildNumber()
 
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
 	// /job/GMB/job/GmbClient/lastSuccessfulBuild/pipeline-console/allSteps
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/%d/api/json", reqData.Host, reqData.Port, reqData.ViewID, buildNumber)
-
-	if reqData.JobName != "" {
-		jenkinsURL = fmt.Sprintf("http://%s:%s/job/%s/job/%s/%d/api/json", reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName, buildNumber)
-	}
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%s%d/api/json", node.Host, node.Port, logic.JobURLPath(reqData.ViewID, reqData.JobName), buildNumber)
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -124,7 +142,7 @@ func ConsoleBuildPrevious(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -145,7 +163,7 @@ func ConsoleBuildPrevious(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": string(body)})
 }
@@ -156,12 +174,16 @@ func ConsoleBuildNext(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
-	jenkinsURLT := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	ctx := jenkinsContext(c)
+	jenkinsURLT := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURLT, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURLT, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -187,16 +209,12 @@ func ConsoleBuildNext(c *gin.Context) {
 	// http://172.24.65.29:10001/job/test-job/8/api/json
 	buildNumber := lastBuild.GetBuildNumber()
 
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
 	// /job/GMB/job/GmbClient/lastSuccessfulBuild/pipeline-console/allSteps
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/%d/api/json", reqData.Host, reqData.Port, reqData.ViewID, buildNumber)
-
-	if reqData.JobName != "" {
-		jenkinsURL = fmt.Sprintf("http://%s:%s/job/%s/job/%s/%d/api/json", reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName, buildNumber)
-	}
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%s%d/api/json", node.Host, node.Port, logic.JobURLPath(reqData.ViewID, reqData.JobName), buildNumber)
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -204,7 +222,7 @@ func ConsoleBuildNext(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -225,7 +243,7 @@ func ConsoleBuildNext(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": string(body)})
 }
@@ -236,20 +254,20 @@ func GetConsolePipeConsole(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
 	// /job/GMB/job/GmbClient/lastSuccessfulBuild/pipeline-console/allSteps
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/lastSuccessfulBuild/pipeline-console/allSteps", reqData.Host, reqData.Port, reqData.ViewID)
-
-	if reqData.JobName != "" {
-		jenkinsURL = fmt.Sprintf("http://%s:%s/job/%s/job/%s/lastSuccessfulBuild/pipeline-console/allSteps", reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName)
-	}
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%slastSuccessfulBuild/pipeline-console/allSteps", node.Host, node.Port, logic.JobURLPath(reqData.ViewID, reqData.JobName))
 
 	// 构造 Jenkins API URL
 	//http://172.24.65.29:10001/job/GMB/job/GmbClient/lastBuild/consoleText
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -257,7 +275,7 @@ func GetConsolePipeConsole(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -278,7 +296,7 @@ func GetConsolePipeConsole(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": string(body)})
 }
@@ -289,19 +307,19 @@ func GetConsolePipeOverview(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
-
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/lastBuild/pipeline-graph/tree", reqData.Host, reqData.Port, reqData.ViewID)
-
-	if reqData.JobName != "" {
-		jenkinsURL = fmt.Sprintf("http://%s:%s/job/%s/job/%s/lastBuild/pipeline-graph/tree", reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName)
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
 	}
 
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%slastBuild/pipeline-graph/tree", node.Host, node.Port, logic.JobURLPath(reqData.ViewID, reqData.JobName))
+
 	// 构造 Jenkins API URL
 	//http://172.24.65.29:10001/job/GMB/job/GmbClient/lastBuild/consoleText
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -309,7 +327,7 @@ func GetConsolePipeOverview(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -330,7 +348,7 @@ func GetConsolePipeOverview(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": string(body)})
 }
@@ -341,21 +359,21 @@ func GetNodeConsole(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
-
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/lastBuild/consoleText", reqData.Host, reqData.Port, reqData.ViewID)
-
-	if reqData.JobName != "" {
-		jenkinsURL = fmt.Sprintf("http://%s:%s/job/%s/job/%s/lastBuild/consoleText", reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName)
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
 	}
 
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%slastBuild/consoleText", node.Host, node.Port, logic.JobURLPath(reqData.ViewID, reqData.JobName))
+
 	// 构造 Jenkins API URL
 
-	ctx := context.Background()
-	jenkinsURLT := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	ctx := jenkinsContext(c)
+	jenkinsURLT := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURLT, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURLT, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -364,6 +382,9 @@ func GetNodeConsole(c *gin.Context) {
 	// 获取指定目录 (Folder) 下的 Job
 	job, err := jenkins.GetJob(ctx, reqData.JobName, reqData.ViewID)
 	if err != nil {
+		if archivedConsoleResponse(c, &reqData) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
 		return
 	}
@@ -371,15 +392,19 @@ func GetNodeConsole(c *gin.Context) {
 	// 获取最新构建
 	lastBuild, err := job.GetLastBuild(ctx)
 	if err != nil {
+		if archivedConsoleResponse(c, &reqData) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
 		return
 	}
 
 	// 打印最新构建信息
 	buildNumber := lastBuild.GetBuildNumber()
+	logic.ObserveJenkinsBuild(node.ID, reqData.ViewID, reqData.JobName, lastBuild)
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -387,7 +412,7 @@ func GetNodeConsole(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -408,13 +433,45 @@ func GetNodeConsole(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
+
+	// 按输出模式渲染日志
+	data, err := logic.RenderConsole(jenkinsContext(c), &reqData, buildNumber, string(body))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
 
 	// 返回构建号和 body
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
 		"buildNumber": buildNumber,
-		"data":        string(body),
+		"data":        data,
 	})
 	//c.JSON(http.StatusOK, gin.H{"success": true, "data": string(body)})
 }
+
+// archivedConsoleResponse Jenkins 上已没有该 Job 或构建时, 返回最新一次归档的日志
+func archivedConsoleResponse(c *gin.Context, reqData *models.RequestJobData) bool {
+	nodeID, err := strconv.Atoi(reqData.NodeID)
+	if err != nil {
+		return false
+	}
+	text, buildNumber, err := logic.GetArchivedConsole(nodeID, reqData.ViewID, reqData.JobName, 0)
+	if err != nil {
+		return false
+	}
+	// 构建已不在 Jenkins 上, 无法获取时间戳
+	data, err := logic.RenderConsole(jenkinsContext(c), reqData, 0, text)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"buildNumber": buildNumber,
+		"data":        data,
+		"archived":    true,
+	})
+	return true
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ArchiveConsole 手动归档构建日志
+func ArchiveConsole(c *gin.Context) {
+	var p models.ParamConsoleArchive
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	a, err := logic.ArchiveConsole(jenkinsContext(c), &p)
+	if err != nil {
+		logger.L(c).Error("logic.ArchiveConsole failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "归档成功", "success": true, "data": a})
+}
+
+// GetConsoleArchives 获取归档列表, 支持 nodeId、viewId、jobName 筛选
+func GetConsoleArchives(c *gin.Context) {
+	nodeID, _ := strconv.Atoi(c.Query("nodeId"))
+	archives, err := logic.GetConsoleArchives(nodeID, c.Query("viewId"), c.Query("jobName"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取归档失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": archives})
+}
+
+// GetConsoleArchive 获取归档的日志内容
+func GetConsoleArchive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	a, text, err := logic.GetConsoleArchiveContent(id)
+	if err != nil {
+		logger.L(c).Error("logic.GetConsoleArchiveContent failed", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取归档失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "buildNumber": a.BuildNumber, "data": text})
+}
+
+// DeleteConsoleArchive 删除归档
+func DeleteConsoleArchive(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteConsoleArchive(id); err != nil {
+		logger.L(c).Error("logic.DeleteConsoleArchive failed", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "删除归档失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "归档删除成功", "success": true})
+}
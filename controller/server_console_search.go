@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SearchConsole 在构建日志中搜索, 返回匹配行、高亮位置和上下文
+func SearchConsole(c *gin.Context) {
+	var p models.ParamConsoleSearch
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	data, err := logic.SearchConsole(jenkinsContext(c), &p)
+	if err != nil {
+		logger.L(c).Error("logic.SearchConsole failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// GetConsoleProblems 从构建日志中提取错误和警告
+func GetConsoleProblems(c *gin.Context) {
+	var p models.ParamConsoleProblems
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	data, err := logic.ExtractConsoleProblems(jenkinsContext(c), &p)
+	if err != nil {
+		logger.L(c).Error("logic.ExtractConsoleProblems failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// GetConsoleRules 获取问题提取规则
+func GetConsoleRules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": logic.GetConsoleRules()})
+}
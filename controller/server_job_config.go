@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetJobConfig 获取 Job 的 config.xml
+func GetJobConfig(c *gin.Context) {
+	p := new(models.ParamJobConfig)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	version, err := logic.GetJobConfig(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.GetJobConfig failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": version})
+}
+
+// UpdateJobConfig 更新 Job 的 config.xml
+func UpdateJobConfig(c *gin.Context) {
+	p := new(models.ParamJobConfigUpdate)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	version, err := logic.UpdateJobConfig(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.UpdateJobConfig failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "配置更新成功", "data": version})
+}
+
+// GetJobConfigVersions 获取 Job 的配置版本列表
+func GetJobConfigVersions(c *gin.Context) {
+	p := new(models.ParamJobConfig)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	versions, err := logic.GetJobConfigVersions(p)
+	if err != nil {
+		logger.L(c).Error("logic.GetJobConfigVersions failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "获取版本列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": versions})
+}
+
+// GetJobConfigVersion 获取单个配置版本
+func GetJobConfigVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "ID无效"})
+		return
+	}
+	version, err := logic.GetJobConfigVersion(id)
+	if errors.Is(err, logic.ErrorConfigVersionNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.L(c).Error("logic.GetJobConfigVersion failed", zap.Int64("id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "获取版本失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": version})
+}
+
+// DiffJobConfig 对比两个配置版本
+func DiffJobConfig(c *gin.Context) {
+	p := new(models.ParamJobConfigDiff)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	d, err := logic.DiffJobConfig(p)
+	if errors.Is(err, logic.ErrorConfigVersionNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.L(c).Error("logic.DiffJobConfig failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "获取版本失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": d})
+}
+
+// RollbackJobConfig 回滚 Job 配置到指定版本
+func RollbackJobConfig(c *gin.Context) {
+	p := new(models.ParamJobConfigRollback)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	version, err := logic.RollbackJobConfig(jenkinsContext(c), p)
+	if errors.Is(err, logic.ErrorConfigVersionNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.L(c).Error("logic.RollbackJobConfig failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "回滚成功", "data": version})
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AddJobTemplate 新增模板
+func AddJobTemplate(c *gin.Context) {
+	t := new(models.JobTemplate)
+	if err := c.ShouldBindJSON(t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.AddJobTemplate(t); err != nil {
+		logger.L(c).Error("logic.AddJobTemplate failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "模板添加成功", "success": true, "data": t})
+}
+
+// GetJobTemplates 获取模板列表
+func GetJobTemplates(c *gin.Context) {
+	name := c.Query("name")
+	templates, err := logic.GetJobTemplates(name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取模板失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": templates})
+}
+
+// GetJobTemplate 获取单个模板
+func GetJobTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	t, err := logic.GetJobTemplate(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取模板失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": t})
+}
+
+// UpdateJobTemplate 更新模板
+func UpdateJobTemplate(c *gin.Context) {
+	var t models.JobTemplate
+	if err := c.ShouldBindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := logic.UpdateJobTemplate(t.ID, &t); err != nil {
+		logger.L(c).Error("logic.UpdateJobTemplate failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "模板更新成功", "success": true})
+}
+
+// DeleteJobTemplate 删除模板
+func DeleteJobTemplate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteJobTemplate(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "删除模板失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "模板删除成功", "success": true})
+}
+
+// RenderJobTemplate 预览模板渲染结果
+func RenderJobTemplate(c *gin.Context) {
+	p := new(models.ParamTemplateRender)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	config, err := logic.RenderJobTemplate(p)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": config})
+}
+
+// ApplyJobTemplate 将模板批量应用到多个节点
+func ApplyJobTemplate(c *gin.Context) {
+	p := new(models.ParamTemplateApply)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	results, err := logic.ApplyJobTemplate(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.ApplyJobTemplate failed", zap.Int64("templateId", p.TemplateID), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"bluebell/logger"
+	"bluebell/logic"
 	"bluebell/models"
+	"bluebell/pkg/metrics"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -153,14 +156,18 @@ func GetNodeJobsT(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
+	ctx := jenkinsContext(c)
 	// 创建 Jenkins 实例
-	jenkinsURL := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
 
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURL, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURL, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -185,13 +192,17 @@ func GetNodeJobs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
 	// 构造 Jenkins API 请求 URL
-	jenkinsURL := fmt.Sprintf("http://%s:%s/me/my-views/view/all/job/%s/api/json",
-		reqData.Host, reqData.Port, reqData.ViewID)
+	jenkinsURL := fmt.Sprintf("http://%s:%s/me/my-views/view/all/%sapi/json",
+		node.Host, node.Port, logic.JobURLPath(reqData.ViewID, ""))
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -199,7 +210,7 @@ func GetNodeJobs(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -233,16 +244,13 @@ func GetNodeJobs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": data.Jobs})
 }
 
-// 构建指定任务
-func buildJob(ctx context.Context, jenkins *gojenkins.Jenkins, name string) (n int64) {
-	var err error
-	n, err = jenkins.BuildJob(ctx, name, nil)
+// 构建指定任务, 返回队列 ID
+func buildJob(ctx context.Context, jenkins *gojenkins.Jenkins, name string) (int64, error) {
+	queueID, err := jenkins.BuildJob(ctx, name, nil)
 	if err != nil {
-		panic(err)
+		return 0, fmt.Errorf("触发 Job [%s] 的构建失败: %v", name, err)
 	}
-
-	fmt.Println("构建完成：", n) // n是序号
-	return
+	return queueID, nil
 }
 
 // 构建指定目录下的某个 Job
@@ -252,7 +260,6 @@ func buildJobInFolder(ctx context.Context, jenkins *gojenkins.Jenkins, folderNam
 	if err != nil {
 		return 0, fmt.Errorf("获取 Job [%s] 失败: %v", jobName, err)
 	}
-	fmt.Printf("成功获取 Job [%s] (URL: %s)\n", job.GetName(), job.GetDetails().URL)
 
 	// 触发构建
 	queueID, err := job.InvokeSimple(ctx, params)
@@ -269,24 +276,33 @@ func StartNodeJobsT(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
+	node, ok := requestNode(c, reqData.NodeId)
+	if !ok {
+		return
+	}
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
 
-	ctx := context.Background()
+	ctx := jenkinsContext(c)
 	// 创建 Jenkins 实例
-	jenkinsURL := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURL, reqData.Account, reqData.Password)
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURL, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
 		return
 	}
 	if reqData.ViewName != "" {
-		_, _ = buildJobInFolder(ctx, jenkins, reqData.ViewID, reqData.ViewName, map[string]string{})
+		_, err = buildJobInFolder(ctx, jenkins, reqData.ViewID, reqData.ViewName, map[string]string{})
 	} else {
-		buildJob(ctx, jenkins, reqData.ViewID)
+		_, err = buildJob(ctx, jenkins, reqData.ViewID)
+	}
+	metrics.BuildTriggered(node.ID, metrics.SourceManual, err)
+	if err != nil {
+		logger.L(c).Error("trigger build failed", zap.String("viewId", reqData.ViewID), zap.String("viewName", reqData.ViewName), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
 	}
 
-	time.Sleep(2 * time.Second)
 	// 请求已发起，立即返回成功响应
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "任务启动中，请稍后查看 Jenkins 构建状态"})
 }
@@ -312,46 +328,50 @@ func stopBuildByJobLatest(ctx context.Context, jenkins *gojenkins.Jenkins, name
 	fmt.Println("是否停止：", stopped)
 }
 
-// 取消指定 Job 的最新构建
-func cancelLatestBuild(ctx context.Context, jenkins *gojenkins.Jenkins, folderName string, jobName string) error {
+// 取消指定 Job 的最新构建, 返回构建编号
+func cancelLatestBuild(ctx context.Context, jenkins *gojenkins.Jenkins, folderName string, jobName string) (int64, error) {
 	// 获取指定目录 (Folder) 下的 Job
 	job, err := jenkins.GetJob(ctx, jobName, folderName)
 	if err != nil {
-		return fmt.Errorf("获取 Job [%s] 失败: %v", jobName, err)
+		return 0, fmt.Errorf("获取 Job [%s] 失败: %v", jobName, err)
 	}
 
 	// 获取最新构建
 	lastBuild, err := job.GetLastBuild(ctx)
 	if err != nil {
-		return fmt.Errorf("获取 Job [%s] 的最新构建失败: %v", jobName, err)
+		return 0, fmt.Errorf("获取 Job [%s] 的最新构建失败: %v", jobName, err)
 	}
 
 	// 停止构建
 	_, err = lastBuild.Stop(ctx)
 	if err != nil {
-		return fmt.Errorf("停止 Job [%s] 的构建失败: %v", jobName, err)
+		return lastBuild.GetBuildNumber(), fmt.Errorf("停止 Job [%s] 的构建失败: %v", jobName, err)
 	}
 
 	fmt.Printf("成功停止 Job [%s] 的最新构建 (构建编号: %d)\n", jobName, lastBuild.GetBuildNumber())
-	return nil
+	return lastBuild.GetBuildNumber(), nil
 }
 
 func StopNodeJobsT(c *gin.Context) {
 	var reqData models.StopJobRequest
 	if err := c.ShouldBindJSON(&reqData); err != nil {
-		zap.L().Error("err", zap.Error(err))
+		logger.L(c).Error("err", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
-	zap.L().Info("reqData", zap.Any("reqData", reqData))
+	node, ok := requestNode(c, reqData.NodeId)
+	if !ok {
+		return
+	}
+	logger.L(c).Info("reqData", zap.Any("reqData", reqData))
 
-	ctx := context.Background()
+	ctx := jenkinsContext(c)
 	// 创建 Jenkins 实例
-	jenkinsURL := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
 
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURL, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURL, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -359,7 +379,13 @@ func StopNodeJobsT(c *gin.Context) {
 	}
 
 	if reqData.ViewName != "" {
-		_ = cancelLatestBuild(ctx, jenkins, reqData.ViewID, reqData.ViewName)
+		number, err := cancelLatestBuild(ctx, jenkins, reqData.ViewID, reqData.ViewName)
+		setAuditBuildNumber(c, number)
+		if err != nil {
+			logger.L(c).Error("cancelLatestBuild failed", zap.String("viewId", reqData.ViewID), zap.String("viewName", reqData.ViewName), zap.Error(err))
+			c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+			return
+		}
 	} else {
 		stopBuildByJobLatest(ctx, jenkins, reqData.ViewID)
 	}
@@ -375,23 +401,27 @@ func StartNodeJobs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeId)
+	if !ok {
+		return
+	}
 
 	// 构造 Jenkins API 请求 URL
 	//jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/job/%s/build?delay=0sec",
-	//	reqData.Host, reqData.Port, reqData.ViewID, reqData.JobName)
-	jenkinsURL := fmt.Sprintf("http://%s:%s/job/%s/build",
-		reqData.Host, reqData.Port, reqData.ViewID)
+	//	node.Host, node.Port, reqData.ViewID, reqData.JobName)
+	jenkinsURL := fmt.Sprintf("http://%s:%s/%sbuild",
+		node.Host, node.Port, logic.JobURLPath(reqData.ViewID, ""))
 
 	fmt.Println("jenkinsURL===", jenkinsURL)
 
 	// 异步触发 Jenkins 构建
 	// 异步触发 Jenkins 构建
+	client := jenkinsHTTPClient(c, 5*time.Second)
 	go func() {
-		client := &http.Client{Timeout: 5 * time.Second}
 		req, _ := http.NewRequest("POST", jenkinsURL, nil) // ✅ 请求方法改为 POST
 
 		// 设置 Basic Auth 认证
-		req.SetBasicAuth(reqData.Account, reqData.Password)
+		req.SetBasicAuth(node.Account, node.Password)
 
 		// 发送请求 (不处理返回结果)
 		_, err := client.Do(req)
@@ -413,13 +443,17 @@ func StopNodeJobs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
 	// 构造 Jenkins API 请求 URL
-	jenkinsURL := fmt.Sprintf("http://%s:%s/me/my-views/view/all/job/%s/api/json",
-		reqData.Host, reqData.Port, reqData.ViewID)
+	jenkinsURL := fmt.Sprintf("http://%s:%s/me/my-views/view/all/%sapi/json",
+		node.Host, node.Port, logic.JobURLPath(reqData.ViewID, ""))
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -427,7 +461,7 @@ func StopNodeJobs(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -448,7 +482,7 @@ func StopNodeJobs(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	// 解析 JSON 数据
 	var data models.JenkinsResponse
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MultiSearchJobs 跨节点搜索 Job
+func MultiSearchJobs(c *gin.Context) {
+	p := new(models.ParamMultiSearch)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	res, err := logic.MultiSearchJobs(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.MultiSearchJobs failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": res})
+}
+
+// MultiBuildJobs 跨节点批量触发构建
+func MultiBuildJobs(c *gin.Context) {
+	p := new(models.ParamMultiBuild)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	results, err := logic.MultiBuildJobs(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.MultiBuildJobs failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
+
+// MultiStopJobs 跨节点批量停止构建
+func MultiStopJobs(c *gin.Context) {
+	p := new(models.ParamMultiStop)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	results, err := logic.MultiStopJobs(jenkinsContext(c), p)
+	if err != nil {
+		logger.L(c).Error("logic.MultiStopJobs failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
@@ -1,13 +1,33 @@
 package controller
 
 import (
+	"bluebell/dao/mysql"
+	"bluebell/logger"
 	"bluebell/logic"
 	"bluebell/models"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
 	"strconv"
 )
 
+// requestNode 获取请求中节点 ID 对应的节点, 失败时写入错误响应并返回 false
+func requestNode(c *gin.Context, id string) (*models.ServerNode, bool) {
+	node, err := logic.GetNode(id)
+	if err == nil {
+		c.Set(CtxNodeIDKey, node.ID)
+		return node, true
+	}
+	if errors.Is(err, mysql.ErrorNodeNotExist) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return nil, false
+	}
+	logger.L(c).Error("logic.GetNode failed", zap.String("nodeId", id), zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "获取节点失败"})
+	return nil, false
+}
+
 // AddServerNode 新增节点
 func AddServerNode(c *gin.Context) {
 	node := new(models.ServerNode)
@@ -34,23 +54,29 @@ func GetNameServerNodes(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": nodes})
 }
 
-// GetServerNodes 获取所有节点
+// GetServerNodes 获取当前用户有权查看的节点
 func GetServerNodes(c *gin.Context) {
-	name := c.Query("name") // 获取查询参数
-	if name == "" {
-		nodes, err := logic.GetAllNodes()
-		if err != nil {
-			ResponseError(c, CodeInvalidGetNode)
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": nodes})
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
 		return
 	}
-	nodes, err := logic.GetServerNodes(name)
+	var nodes []models.ServerNode
+	if name := c.Query("name"); name == "" {
+		nodes, err = logic.GetAllNodes()
+	} else {
+		nodes, err = logic.GetServerNodes(name)
+	}
 	if err != nil {
 		ResponseError(c, CodeInvalidGetNode)
 		return
 	}
+	nodes, err = logic.FilterVisibleNodes(userID, nodes)
+	if err != nil {
+		logger.L(c).Error("logic.FilterVisibleNodes failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": nodes})
 }
 
@@ -64,6 +90,10 @@ func UpdateServerNode(c *gin.Context) {
 
 	id := updatedNode.ID
 	err := logic.UpdateNode(id, updatedNode)
+	if errors.Is(err, logic.ErrorNodePasswordReenter) {
+		ResponseErrorWithMsg(c, CodeInvalidUpdateNode, err.Error())
+		return
+	}
 	if err != nil {
 		ResponseError(c, CodeInvalidUpdateNode)
 		return
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"bluebell/pkg/notify"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AddNotifyRule 新增通知规则
+func AddNotifyRule(c *gin.Context) {
+	r := new(models.NotifyRule)
+	if err := c.ShouldBindJSON(r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.AddNotifyRule(r); err != nil {
+		logger.L(c).Error("logic.AddNotifyRule failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "规则添加成功", "success": true, "data": r})
+}
+
+// GetNotifyRules 获取通知规则列表
+func GetNotifyRules(c *gin.Context) {
+	rules, err := logic.GetNotifyRules()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": rules})
+}
+
+// GetNotifyRule 获取单个通知规则
+func GetNotifyRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	r, err := logic.GetNotifyRule(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": r})
+}
+
+// UpdateNotifyRule 更新通知规则
+func UpdateNotifyRule(c *gin.Context) {
+	var r models.NotifyRule
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := logic.UpdateNotifyRule(r.ID, &r); err != nil {
+		logger.L(c).Error("logic.UpdateNotifyRule failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "规则更新成功", "success": true})
+}
+
+// DeleteNotifyRule 删除通知规则
+func DeleteNotifyRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteNotifyRule(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "删除规则失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "规则删除成功", "success": true})
+}
+
+// TestNotifyRule 按规则发送一条测试通知
+func TestNotifyRule(c *gin.Context) {
+	var p struct {
+		ID int64 `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.TestNotifyRule(p.ID); err != nil {
+		logger.L(c).Error("logic.TestNotifyRule failed", zap.Error(err))
+		// 不返回具体错误, 避免把通知地址的响应内容或内网探测结果带给调用方
+		msg := "发送测试通知失败, 请检查通知地址和配置"
+		if errors.Is(err, notify.ErrInternalTarget) {
+			msg = notify.ErrInternalTarget.Error()
+		}
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": msg})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "发送成功", "success": true})
+}
+
+// GetNotifyLogs 获取通知发送记录, 支持 ruleId 筛选
+func GetNotifyLogs(c *gin.Context) {
+	ruleID, _ := strconv.ParseInt(c.Query("ruleId"), 10, 64)
+	logs, err := logic.GetNotifyLogs(ruleID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取发送记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": logs})
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AddSchedule 新增定时构建计划
+func AddSchedule(c *gin.Context) {
+	s := new(models.BuildSchedule)
+	if err := c.ShouldBindJSON(s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.AddSchedule(s); err != nil {
+		logger.L(c).Error("logic.AddSchedule failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "计划添加成功", "success": true, "data": s})
+}
+
+// GetSchedules 获取定时构建计划列表, 支持 nodeId 筛选
+func GetSchedules(c *gin.Context) {
+	nodeID, _ := strconv.Atoi(c.Query("nodeId"))
+	schedules, err := logic.GetSchedules(nodeID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取计划失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": schedules})
+}
+
+// GetSchedule 获取单个定时构建计划
+func GetSchedule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	s, err := logic.GetSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取计划失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": s})
+}
+
+// UpdateSchedule 更新定时构建计划
+func UpdateSchedule(c *gin.Context) {
+	var s models.BuildSchedule
+	if err := c.ShouldBindJSON(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := logic.UpdateSchedule(s.ID, &s); err != nil {
+		logger.L(c).Error("logic.UpdateSchedule failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "计划更新成功", "success": true})
+}
+
+// DeleteSchedule 删除定时构建计划
+func DeleteSchedule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteSchedule(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "删除计划失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "计划删除成功", "success": true})
+}
@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"bluebell/logger"
+	"bluebell/logic"
 	"bluebell/models"
 	"context"
 	"encoding/json"
@@ -132,13 +134,17 @@ func GetNodeViewsT(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
+	ctx := jenkinsContext(c)
 	// 创建 Jenkins 实例
-	jenkinsURL := fmt.Sprintf("http://%s:%s", reqData.Host, reqData.Port)
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
 
 	// 创建 Jenkins 实例
-	jenkins := gojenkins.CreateJenkins(nil, jenkinsURL, reqData.Account, reqData.Password)
+	jenkins := gojenkins.CreateJenkins(logic.JenkinsClient(ctx, nil), jenkinsURL, node.Account, node.Password)
 	_, err := jenkins.Init(ctx)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "初始化 Jenkins 实例失败"})
@@ -165,13 +171,17 @@ func GetNodeViews(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid JSON data"})
 		return
 	}
+	node, ok := requestNode(c, reqData.NodeID)
+	if !ok {
+		return
+	}
 
 	// 构造 Jenkins API URL
 	jenkinsURL := fmt.Sprintf("http://%s:%s/api/json?tree=jobs[name,lastSuccessfulBuild[timestamp],lastFailedBuild[timestamp],lastBuild[duration]]",
-		reqData.Host, reqData.Port)
+		node.Host, node.Port)
 
 	// 构造 HTTP 请求
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := jenkinsHTTPClient(c, 10*time.Second)
 	req, err := http.NewRequest("GET", jenkinsURL, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "构造请求失败"})
@@ -179,7 +189,7 @@ func GetNodeViews(c *gin.Context) {
 	}
 
 	// 设置 Basic Auth 认证
-	req.SetBasicAuth(reqData.Account, reqData.Password)
+	req.SetBasicAuth(node.Account, node.Password)
 
 	// 执行请求
 	resp, err := client.Do(req)
@@ -200,7 +210,7 @@ func GetNodeViews(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "读取响应失败"})
 		return
 	}
-	zap.L().Info("body", zap.ByteString("body", body))
+	logger.L(c).Info("body", zap.ByteString("body", body))
 
 	// 解析 JSON 数据
 	var data struct {
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"bluebell/pkg/clientip"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// JenkinsWebhook 接收 Jenkins 推送的构建事件
+// 签名放在 X-Jenkins-Signature (或 X-Hub-Signature-256) 头中, 签名时使用的 Unix 时间戳放在 X-Webhook-Timestamp 头中;
+// 无法签名时可用 token 参数携带密钥
+func JenkinsWebhook(c *gin.Context) {
+	nodeID, err := strconv.Atoi(c.Param("nodeId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "节点ID无效"})
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "读取请求失败"})
+		return
+	}
+	signature := c.GetHeader("X-Jenkins-Signature")
+	if signature == "" {
+		signature = c.GetHeader("X-Hub-Signature-256")
+	}
+	token := c.GetHeader("X-Webhook-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+
+	timestamp := c.GetHeader("X-Webhook-Timestamp")
+
+	e, err := logic.HandleJenkinsWebhook(nodeID, body, signature, timestamp, token)
+	switch err {
+	case nil:
+	case logic.ErrorWebhookSecretNotSet, logic.ErrorWebhookSignature, logic.ErrorWebhookTimestamp:
+		logger.L(c).Warn("jenkins webhook rejected", zap.Int("nodeId", nodeID), zap.String("ip", clientip.FromRequest(c.Request)), zap.Error(err))
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": err.Error()})
+		return
+	case logic.ErrorWebhookReplay:
+		logger.L(c).Warn("jenkins webhook replayed", zap.Int("nodeId", nodeID), zap.String("ip", clientip.FromRequest(c.Request)))
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	case logic.ErrorWebhookPayload:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	default:
+		logger.L(c).Error("logic.HandleJenkinsWebhook failed", zap.Int("nodeId", nodeID), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "节点不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": e})
+}
+
+// GenerateWebhookSecret 为节点生成新的 Webhook 密钥, 密钥只在此处返回
+func GenerateWebhookSecret(c *gin.Context) {
+	var p models.ParamWebhookSecret
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	secret, err := logic.GenerateWebhookSecret(p.NodeID)
+	if err != nil {
+		logger.L(c).Error("logic.GenerateWebhookSecret failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "生成密钥失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "密钥生成成功", "success": true, "data": gin.H{"secret": secret}})
+}
+
+// BuildEventStream 以 Server-Sent Events 推送构建事件, 支持 nodeId 筛选
+// 只推送用户对其节点和 Job 拥有查看权限的事件
+func BuildEventStream(c *gin.Context) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	nodeID, _ := strconv.Atoi(c.Query("nodeId"))
+	events, cancel := logic.SubscribeBuildEvents()
+	defer cancel()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if nodeID != 0 && e.NodeID != nodeID {
+				return true
+			}
+			allowed, err := logic.CheckPermission(userID, models.PermView,
+				[]models.Resource{{NodeID: e.NodeID, ViewID: e.ViewID, JobName: e.JobName}})
+			if err != nil {
+				logger.L(c).Error("logic.CheckPermission failed", zap.Error(err))
+				return true
+			}
+			if allowed {
+				c.SSEvent("build", e)
+			}
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AddWorkflow 新增工作流
+func AddWorkflow(c *gin.Context) {
+	w := new(models.Workflow)
+	if err := c.ShouldBindJSON(w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.AddWorkflow(w); err != nil {
+		logger.L(c).Error("logic.AddWorkflow failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "工作流添加成功", "success": true, "data": w})
+}
+
+// GetWorkflows 获取工作流列表
+func GetWorkflows(c *gin.Context) {
+	name := c.Query("name")
+	workflows, err := logic.GetWorkflows(name)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取工作流失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": workflows})
+}
+
+// GetWorkflow 获取单个工作流
+func GetWorkflow(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	w, err := logic.GetWorkflow(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取工作流失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": w})
+}
+
+// UpdateWorkflow 更新工作流
+func UpdateWorkflow(c *gin.Context) {
+	var w models.Workflow
+	if err := c.ShouldBindJSON(&w); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := logic.UpdateWorkflow(w.ID, &w); err != nil {
+		logger.L(c).Error("logic.UpdateWorkflow failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "工作流更新成功", "success": true})
+}
+
+// DeleteWorkflow 删除工作流
+func DeleteWorkflow(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.DeleteWorkflow(id); err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "删除工作流失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "工作流删除成功", "success": true})
+}
+
+// StartWorkflow 启动工作流
+func StartWorkflow(c *gin.Context) {
+	p := new(models.ParamWorkflowStart)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	run, err := logic.StartWorkflow(p)
+	if err != nil {
+		logger.L(c).Error("logic.StartWorkflow failed", zap.Int64("workflowId", p.WorkflowID), zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "工作流已启动", "data": run})
+}
+
+// GetWorkflowRuns 获取运行记录, 支持 workflowId 筛选
+func GetWorkflowRuns(c *gin.Context) {
+	workflowID, _ := strconv.ParseInt(c.Query("workflowId"), 10, 64)
+	runs, err := logic.GetWorkflowRuns(workflowID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取运行记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": runs})
+}
+
+// GetWorkflowRun 获取单次运行
+func GetWorkflowRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	run, err := logic.GetWorkflowRun(id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取运行记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": run})
+}
+
+// workflowRunAction 暂停/恢复/取消运行的公共处理
+func workflowRunAction(c *gin.Context, action func(id int64) (*models.WorkflowRun, error)) {
+	p := new(models.ParamWorkflowRun)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	run, err := action(p.RunID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": run})
+}
+
+// PauseWorkflowRun 暂停运行
+func PauseWorkflowRun(c *gin.Context) {
+	workflowRunAction(c, logic.PauseWorkflowRun)
+}
+
+// ResumeWorkflowRun 恢复运行
+func ResumeWorkflowRun(c *gin.Context) {
+	workflowRunAction(c, logic.ResumeWorkflowRun)
+}
+
+// CancelWorkflowRun 取消运行
+func CancelWorkflowRun(c *gin.Context) {
+	workflowRunAction(c, logic.CancelWorkflowRun)
+}
+
+// ApproveWorkflowStep 审批人工步骤
+func ApproveWorkflowStep(c *gin.Context) {
+	p := new(models.ParamWorkflowApprove)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "参数绑定失败"})
+		return
+	}
+	userID, _ := getCurrentUser(c)
+	run, err := logic.ApproveWorkflowStep(p, userID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": run})
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"bluebell/pkg/clientip"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// responseTOTPError 两步验证相关的错误响应
+func responseTOTPError(c *gin.Context, err error) {
+	var locked *logic.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter/time.Second)+1))
+		ResponseErrorWithMsg(c, CodeTooManyAttempts, locked.Error())
+	case errors.Is(err, logic.ErrorInvalidTOTPCode):
+		ResponseError(c, CodeInvalidTOTPCode)
+	case errors.Is(err, logic.ErrorInvalidLoginChallenge):
+		ResponseErrorWithMsg(c, CodeNeedLogin, err.Error())
+	case errors.Is(err, logic.ErrorUserDisabled):
+		ResponseError(c, CodeUserDisabled)
+	case errors.Is(err, logic.ErrorTOTPAlreadyEnabled), errors.Is(err, logic.ErrorTOTPNotEnrolled),
+		errors.Is(err, logic.ErrorTOTPRequired):
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+	default:
+		ResponseError(c, CodeServerBusy)
+	}
+}
+
+// Login2FAHandler 登录第二步, 提交验证码或恢复码
+func Login2FAHandler(c *gin.Context) {
+	p := new(models.ParamLogin2FA)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	user, tokens, codes, err := logic.Login2FA(p, clientip.FromRequest(c.Request))
+	if err != nil {
+		logger.L(c).Warn("logic.Login2FA failed", zap.String("ip", clientip.FromRequest(c.Request)), zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	responseLogin(c, user, tokens, codes)
+}
+
+// LoginEnrollTOTPHandler 登录时绑定两步验证, 返回密钥和扫码地址
+func LoginEnrollTOTPHandler(c *gin.Context) {
+	p := new(models.ParamLogin2FA)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	enrollment, err := logic.LoginEnrollTOTP(p.Challenge)
+	if err != nil {
+		logger.L(c).Error("logic.LoginEnrollTOTP failed", zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	ResponseSuccess(c, enrollment)
+}
+
+// currentUserForTOTP 获取当前用户, 个人访问令牌不能管理两步验证
+func currentUserForTOTP(c *gin.Context) (int64, bool) {
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return 0, false
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return 0, false
+	}
+	return userID, true
+}
+
+// GetTOTPStatusHandler 获取两步验证状态
+func GetTOTPStatusHandler(c *gin.Context) {
+	userID, ok := currentUserForTOTP(c)
+	if !ok {
+		return
+	}
+	status, err := logic.GetTOTPStatus(userID)
+	if err != nil {
+		logger.L(c).Error("logic.GetTOTPStatus failed", zap.Int64("userId", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, status)
+}
+
+// EnrollTOTPHandler 绑定两步验证, 返回密钥和扫码地址
+func EnrollTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserForTOTP(c)
+	if !ok {
+		return
+	}
+	enrollment, err := logic.EnrollTOTP(userID)
+	if err != nil {
+		logger.L(c).Error("logic.EnrollTOTP failed", zap.Int64("userId", userID), zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	ResponseSuccess(c, enrollment)
+}
+
+// ActivateTOTPHandler 使用验证码激活两步验证, 返回恢复码
+func ActivateTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserForTOTP(c)
+	if !ok {
+		return
+	}
+	p := new(models.ParamTOTPCode)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	codes, err := logic.ActivateTOTP(userID, p.Code)
+	if err != nil {
+		logger.L(c).Error("logic.ActivateTOTP failed", zap.Int64("userId", userID), zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTOTPHandler 使用验证码或恢复码关闭两步验证
+func DisableTOTPHandler(c *gin.Context) {
+	userID, ok := currentUserForTOTP(c)
+	if !ok {
+		return
+	}
+	p := new(models.ParamTOTPCode)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.DisableTOTP(userID, p.Code); err != nil {
+		logger.L(c).Error("logic.DisableTOTP failed", zap.Int64("userId", userID), zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// RegenerateRecoveryCodesHandler 重新生成恢复码
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	userID, ok := currentUserForTOTP(c)
+	if !ok {
+		return
+	}
+	p := new(models.ParamTOTPCode)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	codes, err := logic.RegenerateRecoveryCodes(userID, p.Code)
+	if err != nil {
+		logger.L(c).Error("logic.RegenerateRecoveryCodes failed", zap.Int64("userId", userID), zap.Error(err))
+		responseTOTPError(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{"recoveryCodes": codes})
+}
+
+// ResetUserTOTP 管理员重置用户的两步验证
+func ResetUserTOTP(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	if err := logic.ResetUserTOTP(userID); err != nil {
+		logger.L(c).Error("logic.ResetUserTOTP failed", zap.Error(err))
+		if errors.Is(err, mysql.ErrorUserNotExist) {
+			c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "重置两步验证失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已重置", "success": true})
+}
+
+// SetRoleRequire2FA 设置角色是否要求两步验证
+func SetRoleRequire2FA(c *gin.Context) {
+	p := new(models.ParamRoleRequire2FA)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.SetRoleRequire2FA(p); err != nil {
+		logger.L(c).Error("logic.SetRoleRequire2FA failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功", "success": true})
+}
@@ -2,10 +2,15 @@ package controller
 
 import (
 	"bluebell/dao/mysql"
+	"bluebell/logger"
 	"bluebell/logic"
 	"bluebell/models"
+	"bluebell/pkg/clientip"
+	"bluebell/pkg/jwt"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
@@ -20,7 +25,7 @@ func SignUpHandler(c *gin.Context) {
 	p := new(models.ParamSignUp)
 	if err := c.ShouldBindJSON(p); err != nil {
 		// 请求参数有误，直接返回响应
-		zap.L().Error("SignUp with invalid param", zap.Error(err))
+		logger.L(c).Error("SignUp with invalid param", zap.Error(err))
 		// 判断err是不是validator.ValidationErrors 类型
 		errs, ok := err.(validator.ValidationErrors)
 		if !ok {
@@ -32,7 +37,7 @@ func SignUpHandler(c *gin.Context) {
 	}
 	// 2. 业务处理
 	if err := logic.SignUp(p); err != nil {
-		zap.L().Error("logic.SignUp failed", zap.Error(err))
+		logger.L(c).Error("logic.SignUp failed", zap.Error(err))
 		if errors.Is(err, mysql.ErrorUserExist) {
 			ResponseError(c, CodeUserExist)
 			return
@@ -49,7 +54,7 @@ func LoginHandler(c *gin.Context) {
 	p := new(models.ParamLogin)
 	if err := c.ShouldBindJSON(p); err != nil {
 		// 请求参数有误，直接返回响应
-		zap.L().Error("Login with invalid param", zap.Error(err))
+		logger.L(c).Error("Login with invalid param", zap.Error(err))
 		// 判断err是不是validator.ValidationErrors 类型
 		errs, ok := err.(validator.ValidationErrors)
 		if !ok {
@@ -60,30 +65,225 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 	// 2.业务逻辑处理
-	token, err := logic.Login(p)
+	user, tokens, err := logic.Login(p, clientip.FromRequest(c.Request))
 	if err != nil {
-		zap.L().Error("logic.Login failed", zap.String("username", p.Username), zap.Error(err))
-		if errors.Is(err, mysql.ErrorUserNotExist) {
-			ResponseError(c, CodeUserNotExist)
-			return
+		// 用户不存在和密码错误返回相同的响应, 避免枚举用户名; 登录失败由 logic 层记录
+		var locked *logic.LoginLockedError
+		var twoFactor *logic.TwoFactorRequiredError
+		switch {
+		case errors.As(err, &twoFactor):
+			// 密码正确, 使用 challenge 调用 /login/2fa 完成两步验证
+			responseTwoFactor(c, twoFactor)
+		case errors.As(err, &locked):
+			c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter/time.Second)+1))
+			ResponseErrorWithMsg(c, CodeTooManyAttempts, locked.Error())
+		case errors.Is(err, logic.ErrorUserDisabled):
+			ResponseError(c, CodeUserDisabled)
+		case errors.Is(err, mysql.ErrorUserNotExist), errors.Is(err, mysql.ErrorInvalidPassword):
+			ResponseError(c, CodeInvalidPassword)
+		default:
+			logger.L(c).Error("logic.Login failed", zap.String("username", p.Username), zap.Error(err))
+			ResponseError(c, CodeInvalidPassword)
 		}
-		ResponseError(c, CodeInvalidPassword)
 		return
 	}
-
 	// 3.返回响应
+	responseLogin(c, user, tokens, nil)
+	//ResponseSuccess(c, token)
+}
+
+// responseTwoFactor 返回两步验证的 challenge
+func responseTwoFactor(c *gin.Context, e *logic.TwoFactorRequiredError) {
+	c.JSON(http.StatusOK, &ResponseData{
+		Code: CodeTwoFactorRequired,
+		Msg:  e.Error(),
+		Data: gin.H{"challenge": e.Challenge, "enroll": e.Enroll},
+	})
+}
+
+// responseLogin 返回登录成功的用户信息和 token
+func responseLogin(c *gin.Context, user *models.User, tokens *models.TokenPair, recoveryCodes []string) {
+	roles, perms, err := logic.GetUserAuthorities(user.UserID)
+	if err != nil {
+		logger.L(c).Error("logic.GetUserAuthorities failed", zap.Int64("userId", user.UserID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+
 	c.JSON(http.StatusOK, LoginResponse{
 		Success: true,
 		Data: UserData{
-			Avatar:       "https://avatars.githubusercontent.com/u/52823142",
-			Username:     "admin",
-			Nickname:     "超级管理员",
-			Roles:        []string{"admin"},
-			Permissions:  []string{"*:*:*"},
-			AccessToken:  token,
-			RefreshToken: token,
-			Expires:      "2030/10/30 00:00:00",
+			Avatar:       userAvatar(user),
+			Username:     user.Username,
+			Nickname:     userNickname(user),
+			Roles:        roles,
+			Permissions:  perms,
+			AccessToken:  tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			Expires:      tokens.Expires,
+
+			RecoveryCodes: recoveryCodes,
 		},
 	})
-	//ResponseSuccess(c, token)
+}
+
+// defaultAvatar 未设置头像时使用的默认头像
+const defaultAvatar = "https://avatars.githubusercontent.com/u/52823142"
+
+func userAvatar(user *models.User) string {
+	if user.Avatar == "" {
+		return defaultAvatar
+	}
+	return user.Avatar
+}
+
+func userNickname(user *models.User) string {
+	if user.Nickname == "" {
+		return user.Username
+	}
+	return user.Nickname
+}
+
+// RefreshTokenHandler 使用 refresh token 换取新的 token
+func RefreshTokenHandler(c *gin.Context) {
+	p := new(models.ParamRefreshToken)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	tokens, err := logic.RefreshToken(p)
+	if err != nil {
+		logger.L(c).Error("logic.RefreshToken failed", zap.Error(err))
+		if errors.Is(err, logic.ErrorInvalidRefreshToken) || errors.Is(err, mysql.ErrorUserNotExist) {
+			ResponseError(c, CodeInvalidToken)
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tokens})
+}
+
+// LogoutHandler 注销当前登录
+func LogoutHandler(c *gin.Context) {
+	p := new(models.ParamLogout)
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(p); err != nil {
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	v, _ := c.Get(CtxClaimsKey)
+	claims, _ := v.(*jwt.MyClaims)
+	if err := logic.Logout(userID, claims, p); err != nil {
+		logger.L(c).Error("logic.Logout failed", zap.Int64("userId", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// GetMeHandler 获取当前登录用户的信息及角色和权限
+func GetMeHandler(c *gin.Context) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	user, err := logic.GetUser(userID)
+	if err != nil {
+		logger.L(c).Error("logic.GetUser failed", zap.Int64("userId", userID), zap.Error(err))
+		if errors.Is(err, mysql.ErrorUserNotExist) {
+			ResponseError(c, CodeUserNotExist)
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	roles, perms, err := logic.GetUserAuthorities(userID)
+	if err != nil {
+		logger.L(c).Error("logic.GetUserAuthorities failed", zap.Int64("userId", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	user.Avatar, user.Nickname = userAvatar(user), userNickname(user)
+	ResponseSuccess(c, gin.H{
+		"user":        user,
+		"roles":       roles,
+		"permissions": perms,
+	})
+}
+
+// UpdateMeHandler 修改当前登录用户的个人资料
+func UpdateMeHandler(c *gin.Context) {
+	// 个人访问令牌不能用来修改个人资料
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	p := new(models.ParamUpdateProfile)
+	if err := c.ShouldBindJSON(p); err != nil {
+		logger.L(c).Error("UpdateMe with invalid param", zap.Error(err))
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+		ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.UpdateProfile(userID, p); err != nil {
+		logger.L(c).Error("logic.UpdateProfile failed", zap.Int64("userId", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ChangePasswordHandler 使用原密码修改当前登录用户的密码
+func ChangePasswordHandler(c *gin.Context) {
+	p := new(models.ParamChangePassword)
+	if err := c.ShouldBindJSON(p); err != nil {
+		logger.L(c).Error("ChangePassword with invalid param", zap.Error(err))
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+		ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		return
+	}
+	// 个人访问令牌不能用来修改密码
+	if usingAPIToken(c) {
+		ResponseError(c, CodeNoPermission)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.ChangePassword(userID, p); err != nil {
+		logger.L(c).Error("logic.ChangePassword failed", zap.Int64("userId", userID), zap.Error(err))
+		switch {
+		case errors.Is(err, logic.ErrorWrongOldPassword):
+			ResponseErrorWithMsg(c, CodeInvalidPassword, err.Error())
+		case errors.Is(err, logic.ErrorNotLocalUser):
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		default:
+			ResponseError(c, CodeServerBusy)
+		}
+		return
+	}
+	ResponseSuccess(c, nil)
 }
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetUsers 获取用户列表
+func GetUsers(c *gin.Context) {
+	users, err := logic.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": "获取用户失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "查询成功", "success": true, "data": users})
+}
+
+// AddUser 新增本地用户
+func AddUser(c *gin.Context) {
+	p := new(models.ParamAddUser)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	user, err := logic.AddUser(p)
+	if err != nil {
+		logger.L(c).Error("logic.AddUser failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "用户添加成功", "success": true, "data": user})
+}
+
+// SetUserStatus 启用或禁用用户
+func SetUserStatus(c *gin.Context) {
+	p := new(models.ParamUserStatus)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	operatorID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.SetUserDisabled(operatorID, p); err != nil {
+		logger.L(c).Error("logic.SetUserDisabled failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	msg := "用户已启用"
+	if p.Disabled {
+		msg = "用户已禁用"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": msg, "success": true})
+}
+
+// ResetUserPassword 重置用户密码
+func ResetUserPassword(c *gin.Context) {
+	p := new(models.ParamResetPassword)
+	if err := c.ShouldBindJSON(p); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "请求参数错误"})
+		return
+	}
+	if err := logic.ResetPassword(p); err != nil {
+		logger.L(c).Error("logic.ResetPassword failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置", "success": true})
+}
+
+// DeleteUser 删除用户
+func DeleteUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID无效"})
+		return
+	}
+	operatorID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.DeleteUser(operatorID, userID); err != nil {
+		logger.L(c).Error("logic.DeleteUser failed", zap.Error(err))
+		c.JSON(http.StatusOK, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "用户删除成功", "success": true})
+}
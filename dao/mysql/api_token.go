@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddAPIToken 保存个人访问令牌
+func AddAPIToken(t *models.APIToken) (err error) {
+	t.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at, last_used_at, revoked, create_time)
+    VALUES (:user_id, :name, :token_hash, :prefix, :scopes, :expires_at, :last_used_at, :revoked, :create_time)
+    `
+
+	res, err := db.NamedExec(query, t)
+	if err != nil {
+		zap.L().Error("mysql.AddAPIToken failed", zap.Error(err))
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetAPITokenByHash 按哈希获取个人访问令牌, 不存在时返回 nil
+func GetAPITokenByHash(hash string) (*models.APIToken, error) {
+	var t models.APIToken
+	query := `SELECT * FROM api_tokens WHERE token_hash = ?`
+	err := db.Get(&t, query, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetAPITokenByHash failed", zap.Error(err))
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetAPITokens 获取用户的个人访问令牌
+func GetAPITokens(userID int64) ([]models.APIToken, error) {
+	tokens := make([]models.APIToken, 0)
+	query := `SELECT * FROM api_tokens WHERE user_id = ? ORDER BY id DESC`
+	if err := db.Select(&tokens, query, userID); err != nil {
+		zap.L().Error("mysql.GetAPITokens failed", zap.Error(err))
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken 吊销用户的个人访问令牌, 返回是否找到该令牌
+func RevokeAPIToken(userID, id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE api_tokens SET revoked = 1 WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		zap.L().Error("mysql.RevokeAPIToken failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateAPITokenLastUsed 更新个人访问令牌的最后使用时间
+func UpdateAPITokenLastUsed(id int64, lastUsed string) error {
+	_, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, lastUsed, id)
+	if err != nil {
+		zap.L().Error("mysql.UpdateAPITokenLastUsed failed", zap.Error(err))
+	}
+	return err
+}
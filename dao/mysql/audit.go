@@ -0,0 +1,94 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddAuditLog 记录审计日志
+func AddAuditLog(l *models.AuditLog) (err error) {
+	l.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO audit_logs (user_id, username, ip, method, path, action, node_id, view_id, job_name, build_number,
+                            outcome, status, jenkins_status, message, detail, create_time)
+    VALUES (:user_id, :username, :ip, :method, :path, :action, :node_id, :view_id, :job_name, :build_number,
+            :outcome, :status, :jenkins_status, :message, :detail, :create_time)
+    `
+
+	res, err := db.NamedExec(query, l)
+	if err != nil {
+		zap.L().Error("mysql.AddAuditLog failed", zap.Error(err))
+		return err
+	}
+	l.ID, err = res.LastInsertId()
+	return err
+}
+
+// auditWhere 根据查询条件拼接 WHERE 子句
+func auditWhere(p *models.ParamAuditQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+	if p.UserID != 0 {
+		add("user_id = ?", p.UserID)
+	}
+	if p.Username != "" {
+		add("username = ?", p.Username)
+	}
+	if strings.HasSuffix(p.Action, ".") {
+		add("action LIKE ?", p.Action+"%")
+	} else if p.Action != "" {
+		add("action = ?", p.Action)
+	}
+	if p.NodeID != 0 {
+		add("node_id = ?", p.NodeID)
+	}
+	if p.ViewID != "" {
+		add("view_id = ?", p.ViewID)
+	}
+	if p.JobName != "" {
+		add("job_name = ?", p.JobName)
+	}
+	if p.Outcome != "" {
+		add("outcome = ?", p.Outcome)
+	}
+	if p.IP != "" {
+		add("ip = ?", p.IP)
+	}
+	if p.Start != "" {
+		add("create_time >= ?", p.Start)
+	}
+	if p.End != "" {
+		add("create_time <= ?", p.End)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// GetAuditLogs 按条件分页查询审计日志, 按时间倒序, 同时返回总数
+func GetAuditLogs(p *models.ParamAuditQuery, offset, limit int) ([]models.AuditLog, int64, error) {
+	where, args := auditWhere(p)
+
+	var total int64
+	if err := db.Get(&total, `SELECT COUNT(*) FROM audit_logs`+where, args...); err != nil {
+		zap.L().Error("mysql.GetAuditLogs failed", zap.Error(err))
+		return nil, 0, err
+	}
+
+	logs := make([]models.AuditLog, 0)
+	query := `SELECT * FROM audit_logs` + where + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+	if err := db.Select(&logs, query, append(args, limit, offset)...); err != nil {
+		zap.L().Error("mysql.GetAuditLogs failed", zap.Error(err))
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
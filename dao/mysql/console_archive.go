@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddConsoleArchive 新增构建日志归档记录
+func AddConsoleArchive(a *models.ConsoleArchive) (err error) {
+	a.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO console_archives (node_id, view_id, job_name, build_number, result, storage, object_key, size,
+                                  original_size, create_time)
+    VALUES (:node_id, :view_id, :job_name, :build_number, :result, :storage, :object_key, :size,
+            :original_size, :create_time)
+    `
+
+	res, err := db.NamedExec(query, a)
+	if err != nil {
+		zap.L().Error("mysql.AddConsoleArchive failed", zap.Error(err))
+		return err
+	}
+	a.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetConsoleArchiveByID 获取单个归档记录
+func GetConsoleArchiveByID(id int64) (*models.ConsoleArchive, error) {
+	var a models.ConsoleArchive
+	query := `SELECT * FROM console_archives WHERE id = ?`
+	err := db.Get(&a, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetConsoleArchiveByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetConsoleArchive 获取指定构建的归档记录, buildNumber 为 0 时获取最新一次归档, 不存在时返回 nil
+func GetConsoleArchive(nodeID int, viewID, jobName string, buildNumber int64) (*models.ConsoleArchive, error) {
+	var a models.ConsoleArchive
+	query := `SELECT * FROM console_archives WHERE node_id = ? AND view_id = ? AND job_name = ? AND build_number = ?`
+	args := []interface{}{nodeID, viewID, jobName, buildNumber}
+	if buildNumber == 0 {
+		query = `SELECT * FROM console_archives WHERE node_id = ? AND view_id = ? AND job_name = ?
+                 ORDER BY build_number DESC LIMIT 1`
+		args = args[:3]
+	}
+	err := db.Get(&a, query, args...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetConsoleArchive failed", zap.Error(err))
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetConsoleArchives 获取归档列表, 参数为空时不作为筛选条件
+func GetConsoleArchives(nodeID int, viewID, jobName string) ([]models.ConsoleArchive, error) {
+	var archives []models.ConsoleArchive
+	query := `
+    SELECT * FROM console_archives
+    WHERE (? = 0 OR node_id = ?) AND (? = '' OR view_id = ?) AND (? = '' OR job_name = ?)
+    ORDER BY node_id, view_id, job_name, build_number DESC
+    `
+	err := db.Select(&archives, query, nodeID, nodeID, viewID, viewID, jobName, jobName)
+	if err != nil {
+		zap.L().Error("mysql.GetConsoleArchives failed", zap.Error(err))
+		return nil, err
+	}
+	return archives, nil
+}
+
+// DeleteConsoleArchive 删除归档记录
+func DeleteConsoleArchive(id int64) error {
+	query := `DELETE FROM console_archives WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		zap.L().Error("mysql.DeleteConsoleArchive failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddJobConfigVersion 保存一个 config.xml 版本
+func AddJobConfigVersion(v *models.JobConfigVersion) (err error) {
+	v.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO job_config_versions (node_id, view_id, job_name, config, hash, source, remark, create_time)
+    VALUES (:node_id, :view_id, :job_name, :config, :hash, :source, :remark, :create_time)
+    `
+
+	res, err := db.NamedExec(query, v)
+	if err != nil {
+		zap.L().Error("mysql.AddJobConfigVersion failed", zap.Error(err))
+		return err
+	}
+	v.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetJobConfigVersionByID 获取单个版本
+func GetJobConfigVersionByID(id int64) (*models.JobConfigVersion, error) {
+	var v models.JobConfigVersion
+	query := `SELECT * FROM job_config_versions WHERE id = ?`
+	err := db.Get(&v, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetJobConfigVersionByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetLatestJobConfigVersion 获取 Job 最近一次记录的版本, 没有记录时返回 nil
+func GetLatestJobConfigVersion(nodeID int, viewID, jobName string) (*models.JobConfigVersion, error) {
+	var v models.JobConfigVersion
+	query := `SELECT * FROM job_config_versions WHERE node_id = ? AND view_id = ? AND job_name = ? ORDER BY id DESC LIMIT 1`
+	err := db.Get(&v, query, nodeID, viewID, jobName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetLatestJobConfigVersion failed", zap.Error(err))
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetJobConfigVersions 获取 Job 的版本列表 (不含 config 内容)
+func GetJobConfigVersions(nodeID int, viewID, jobName string) ([]models.JobConfigVersion, error) {
+	var versions []models.JobConfigVersion
+	query := `
+    SELECT id, node_id, view_id, job_name, '' AS config, hash, source, remark, create_time
+    FROM job_config_versions
+    WHERE node_id = ? AND view_id = ? AND job_name = ?
+    ORDER BY id DESC
+    `
+	err := db.Select(&versions, query, nodeID, viewID, jobName)
+	if err != nil {
+		zap.L().Error("mysql.GetJobConfigVersions failed", zap.Error(err))
+		return nil, err
+	}
+	return versions, nil
+}
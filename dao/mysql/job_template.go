@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddJobTemplate 新增模板
+func AddJobTemplate(t *models.JobTemplate) (err error) {
+	t.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	t.UpdateTime = t.CreateTime
+
+	query := `
+    INSERT INTO job_templates (name, description, content, variables, create_time, update_time)
+    VALUES (:name, :description, :content, :variables, :create_time, :update_time)
+    `
+
+	res, err := db.NamedExec(query, t)
+	if err != nil {
+		zap.L().Error("mysql.AddJobTemplate failed", zap.Error(err))
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetJobTemplateByID 获取单个模板
+func GetJobTemplateByID(id int64) (*models.JobTemplate, error) {
+	var t models.JobTemplate
+	query := `SELECT * FROM job_templates WHERE id = ?`
+	err := db.Get(&t, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetJobTemplateByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetJobTemplates 获取模板列表, name 不为空时模糊匹配
+func GetJobTemplates(name string) ([]models.JobTemplate, error) {
+	var templates []models.JobTemplate
+	query := `SELECT * FROM job_templates WHERE TRIM(name) LIKE ? ORDER BY id DESC`
+	err := db.Select(&templates, query, "%"+name+"%")
+	if err != nil {
+		zap.L().Error("mysql.GetJobTemplates failed", zap.Error(err))
+		return nil, err
+	}
+	return templates, nil
+}
+
+// UpdateJobTemplate 更新模板
+func UpdateJobTemplate(id int64, t *models.JobTemplate) error {
+	query := `
+    UPDATE job_templates
+    SET name = :name, description = :description,
+        content = :content, variables = :variables
+    WHERE id = :id
+    `
+
+	t.ID = id
+	_, err := db.NamedExec(query, t)
+	if err != nil {
+		zap.L().Error("mysql.UpdateJobTemplate failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteJobTemplate 删除模板
+func DeleteJobTemplate(id int64) error {
+	query := `DELETE FROM job_templates WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		zap.L().Error("mysql.DeleteJobTemplate failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package mysql
+
+import (
+	"bluebell/pkg/metrics"
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+)
+
+// metricsConnector 包装数据库驱动, 统计每条语句的耗时
+// 查询语句只统计到返回第一批结果为止, 不包含读取结果集的时间
+type metricsConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *metricsConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &metricsConn{Conn: conn}, nil
+}
+
+func (c *metricsConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// sqlOperations 按语句的第一个关键字统计, 其他语句记为 other
+var sqlOperations = map[string]bool{
+	"select": true, "insert": true, "update": true, "delete": true, "replace": true,
+	"create": true, "alter": true, "drop": true, "pragma": true,
+}
+
+func sqlOperation(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	if i := strings.IndexAny(query, " \t\r\n("); i > 0 {
+		query = query[:i]
+	}
+	op := strings.ToLower(query)
+	if !sqlOperations[op] {
+		return "other"
+	}
+	return op
+}
+
+func observe(op string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	metrics.ObserveDB(op, time.Since(start), err)
+}
+
+type metricsConn struct {
+	driver.Conn
+}
+
+func (c *metricsConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *metricsConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &metricsStmt{Stmt: stmt, op: sqlOperation(query)}, nil
+}
+
+func (c *metricsConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *metricsConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	observe(sqlOperation(query), start, err)
+	return res, err
+}
+
+func (c *metricsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	observe(sqlOperation(query), start, err)
+	return rows, err
+}
+
+func (c *metricsConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+type metricsStmt struct {
+	driver.Stmt
+	op string
+}
+
+func (s *metricsStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+	observe(s.op, start, err)
+	return res, err
+}
+
+func (s *metricsStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	observe(s.op, start, err)
+	return rows, err
+}
+
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
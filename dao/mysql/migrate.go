@@ -0,0 +1,384 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// schema 建表语句, 与 models/create_table.sqlite 保持一致, 均可重复执行
+// 内置管理员角色只在不存在时写入
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS user (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    username TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT 'local',
+    nickname TEXT NOT NULL DEFAULT '',
+    avatar TEXT NOT NULL DEFAULT '',
+    email TEXT,
+    gender INTEGER NOT NULL DEFAULT 0,
+    disabled INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_user_time
+    AFTER UPDATE ON user
+    FOR EACH ROW
+BEGIN
+    UPDATE user SET update_time = datetime('now', 'localtime') WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS server_nodes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port TEXT NOT NULL,
+    account TEXT NOT NULL,
+    password TEXT NOT NULL,
+    status BOOLEAN NOT NULL,
+    remark TEXT,
+    webhook_secret TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_server_nodes_time
+    AFTER UPDATE ON server_nodes
+    FOR EACH ROW
+BEGIN
+    UPDATE server_nodes
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS job_config_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL,
+    view_id TEXT NOT NULL,
+    job_name TEXT NOT NULL DEFAULT '',
+    config TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    source TEXT NOT NULL,
+    remark TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_job_config_versions_job ON job_config_versions (node_id, view_id, job_name)`,
+	`CREATE TABLE IF NOT EXISTS job_templates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    variables TEXT NOT NULL DEFAULT '[]',
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_job_templates_time
+    AFTER UPDATE ON job_templates
+    FOR EACH ROW
+BEGIN
+    UPDATE job_templates
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS build_schedules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    node_id INTEGER NOT NULL,
+    view_id TEXT NOT NULL,
+    job_name TEXT NOT NULL DEFAULT '',
+    params TEXT NOT NULL DEFAULT '{}',
+    cron_expr TEXT NOT NULL DEFAULT '',
+    run_at TEXT NOT NULL DEFAULT '',
+    blackouts TEXT NOT NULL DEFAULT '[]',
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    next_run_time TEXT NOT NULL DEFAULT '',
+    last_run_time TEXT NOT NULL DEFAULT '',
+    last_result TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_build_schedules_time
+    AFTER UPDATE ON build_schedules
+    FOR EACH ROW
+BEGIN
+    UPDATE build_schedules
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS workflows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    steps TEXT NOT NULL DEFAULT '[]',
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_workflows_time
+    AFTER UPDATE ON workflows
+    FOR EACH ROW
+BEGIN
+    UPDATE workflows
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS workflow_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workflow_id INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    params TEXT NOT NULL DEFAULT '{}',
+    steps TEXT NOT NULL DEFAULT '[]',
+    states TEXT NOT NULL DEFAULT '{}',
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime')),
+    finish_time TEXT NOT NULL DEFAULT '',
+    lock_owner TEXT NOT NULL DEFAULT '',
+    lock_until TEXT NOT NULL DEFAULT ''
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_workflow_runs_workflow_id ON workflow_runs (workflow_id)`,
+	`CREATE TRIGGER IF NOT EXISTS update_workflow_runs_time
+    AFTER UPDATE ON workflow_runs
+    FOR EACH ROW
+BEGIN
+    UPDATE workflow_runs
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS notify_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    node_id INTEGER NOT NULL DEFAULT 0,
+    job_pattern TEXT NOT NULL DEFAULT '',
+    events TEXT NOT NULL DEFAULT '[]',
+    duration_threshold INTEGER NOT NULL DEFAULT 0,
+    channel TEXT NOT NULL,
+    target TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    template TEXT NOT NULL DEFAULT '',
+    enabled INTEGER NOT NULL DEFAULT 1,
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_notify_rules_time
+    AFTER UPDATE ON notify_rules
+    FOR EACH ROW
+BEGIN
+    UPDATE notify_rules
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS notify_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    node_id INTEGER NOT NULL DEFAULT 0,
+    job_path TEXT NOT NULL DEFAULT '',
+    build_number INTEGER NOT NULL DEFAULT 0,
+    channel TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_notify_logs_rule_id ON notify_logs (rule_id)`,
+	`CREATE TABLE IF NOT EXISTS job_build_status (
+    node_id INTEGER NOT NULL,
+    view_id TEXT NOT NULL,
+    job_name TEXT NOT NULL DEFAULT '',
+    build_number INTEGER NOT NULL,
+    result TEXT NOT NULL,
+    update_time TEXT DEFAULT (datetime('now', 'localtime')),
+    PRIMARY KEY (node_id, view_id, job_name)
+)`,
+	`CREATE TABLE IF NOT EXISTS console_archives (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL,
+    view_id TEXT NOT NULL,
+    job_name TEXT NOT NULL DEFAULT '',
+    build_number INTEGER NOT NULL,
+    result TEXT NOT NULL DEFAULT '',
+    storage TEXT NOT NULL,
+    object_key TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    original_size INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_console_archives_build ON console_archives (node_id, view_id, job_name, build_number)`,
+	`CREATE TABLE IF NOT EXISTS roles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    require_2fa INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    update_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE TRIGGER IF NOT EXISTS update_roles_time
+    AFTER UPDATE ON roles
+    FOR EACH ROW
+BEGIN
+    UPDATE roles
+    SET update_time = datetime('now', 'localtime')
+    WHERE id = OLD.id;
+END`,
+	`CREATE TABLE IF NOT EXISTS role_permissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INTEGER NOT NULL,
+    permission TEXT NOT NULL,
+    node_id INTEGER NOT NULL DEFAULT 0,
+    job_pattern TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_role_permissions_role_id ON role_permissions (role_id)`,
+	`CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL,
+    role_id INTEGER NOT NULL,
+    PRIMARY KEY (user_id, role_id)
+)`,
+	`INSERT OR IGNORE INTO roles (id, name, description) VALUES (1, 'admin', '超级管理员')`,
+	`INSERT INTO role_permissions (role_id, permission, node_id, job_pattern)
+SELECT 1, 'admin', 0, ''
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions WHERE role_id = 1)`,
+	`CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    family_id TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    revoked INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id)`,
+	`CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS oidc_states (
+    state TEXT PRIMARY KEY,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    create_time TEXT DEFAULT (datetime('now', 'localtime')),
+    UNIQUE (issuer, subject)
+)`,
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    expires_at TEXT NOT NULL,
+    last_used_at TEXT NOT NULL DEFAULT '',
+    revoked INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id)`,
+	`CREATE TABLE IF NOT EXISTS user_totp (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 0,
+    last_counter INTEGER NOT NULL DEFAULT 0,
+    create_time TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used INTEGER NOT NULL DEFAULT 0
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user_id ON user_recovery_codes (user_id)`,
+	`CREATE TABLE IF NOT EXISTS login_challenges (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    enroll INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TEXT NOT NULL
+)`,
+	`CREATE TABLE IF NOT EXISTS audit_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    username TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    method TEXT NOT NULL,
+    path TEXT NOT NULL,
+    action TEXT NOT NULL,
+    node_id INTEGER NOT NULL DEFAULT 0,
+    view_id TEXT NOT NULL DEFAULT '',
+    job_name TEXT NOT NULL DEFAULT '',
+    build_number INTEGER NOT NULL DEFAULT 0,
+    outcome TEXT NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    jenkins_status INTEGER NOT NULL DEFAULT 0,
+    message TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    create_time TEXT DEFAULT (datetime('now', 'localtime'))
+)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_logs_create_time ON audit_logs (create_time)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_logs_node_job ON audit_logs (node_id, view_id, job_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action)`,
+}
+
+// addedColumns 在已有表上新增的字段, 旧版本创建的数据库缺少这些字段时通过 ALTER TABLE 补齐
+// 新增字段必须带默认值
+var addedColumns = []struct {
+	Table      string
+	Column     string
+	Definition string
+}{
+	{"user", "source", "TEXT NOT NULL DEFAULT 'local'"},
+	{"user", "nickname", "TEXT NOT NULL DEFAULT ''"},
+	{"user", "avatar", "TEXT NOT NULL DEFAULT ''"},
+	{"user", "disabled", "INTEGER NOT NULL DEFAULT 0"},
+	{"server_nodes", "webhook_secret", "TEXT NOT NULL DEFAULT ''"},
+	{"roles", "require_2fa", "INTEGER NOT NULL DEFAULT 0"},
+	{"workflow_runs", "lock_owner", "TEXT NOT NULL DEFAULT ''"},
+	{"workflow_runs", "lock_until", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate 创建缺少的表、索引和触发器并补齐新增的字段, 在一个事务中执行, 可重复执行
+func migrate() (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	for _, stmt := range schema {
+		if _, err = tx.Exec(stmt); err != nil {
+			return fmt.Errorf("执行建表语句失败: %v\n%s", err, stmt)
+		}
+	}
+	for _, c := range addedColumns {
+		var exist bool
+		if exist, err = columnExists(tx, c.Table, c.Column); err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+		if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.Table, c.Column, c.Definition)); err != nil {
+			return fmt.Errorf("新增字段 %s.%s 失败: %v", c.Table, c.Column, err)
+		}
+	}
+	return nil
+}
+
+// columnExists 判断表中是否已有字段
+func columnExists(tx *sqlx.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	return count > 0, err
+}
@@ -2,11 +2,12 @@ package mysql
 
 import (
 	"bluebell/setting"
+	"database/sql"
 	"fmt"
 
 	//_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 var db *sqlx.DB
@@ -26,12 +27,18 @@ var db *sqlx.DB
 
 func Init(cfg *setting.MySQLConfig) (err error) {
 	dsn := fmt.Sprintf("%s", "./server_nodes.db")
-	db, err = sqlx.Connect("sqlite3", dsn)
-	if err != nil {
+	// 通过 metricsConnector 统计语句耗时, 驱动名仍为 sqlite3, sqlx 按此选择占位符
+	db = sqlx.NewDb(sql.OpenDB(&metricsConnector{dsn: dsn, driver: &sqlite3.SQLiteDriver{}}), "sqlite3")
+	if err = db.Ping(); err != nil {
+		db.Close()
 		return
 	}
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	if err = migrate(); err != nil {
+		db.Close()
+		return
+	}
 	return
 }
 
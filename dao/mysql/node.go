@@ -2,10 +2,15 @@ package mysql
 
 import (
 	"bluebell/models"
-	"fmt"
+	"database/sql"
+	"errors"
 	"time"
+
+	"go.uber.org/zap"
 )
 
+var ErrorNodeNotExist = errors.New("节点不存在")
+
 func AddNode(node *models.ServerNode) (err error) {
 	// 设置添加时间
 	node.CreateTime = time.Now().Format("2006-01-02 15:04:05")
@@ -17,7 +22,7 @@ func AddNode(node *models.ServerNode) (err error) {
 
 	_, err = db.NamedExec(query, node)
 	if err != nil {
-		fmt.Println("mysql.Node", err)
+		zap.L().Error("mysql.Node failed", zap.Error(err))
 		return err
 	}
 
@@ -29,8 +34,11 @@ func GetNodeByID(id int) (*models.ServerNode, error) {
 	var node models.ServerNode
 	query := `SELECT * FROM server_nodes WHERE id = ?`
 	err := db.Get(&node, query, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrorNodeNotExist
+	}
 	if err != nil {
-		fmt.Println("mysql.GetNodeByID", err)
+		zap.L().Error("mysql.GetNodeByID failed", zap.Error(err))
 		return nil, err
 	}
 	return &node, nil
@@ -46,7 +54,7 @@ func GetNodesByName(name string) ([]models.ServerNode, error) {
 	// 模糊匹配 (支持 '%关键字%' 格式)
 	err := db.Select(&nodes, query, "%"+name+"%")
 	if err != nil {
-		fmt.Println("mysql.GetNodesByName 错误:", err)
+		zap.L().Error("mysql.GetNodesByName failed", zap.Error(err))
 		return nil, err
 	}
 	return nodes, nil
@@ -58,7 +66,7 @@ func GetAllNodes() ([]models.ServerNode, error) {
 	query := `SELECT * FROM server_nodes`
 	err := db.Select(&nodes, query)
 	if err != nil {
-		fmt.Println("mysql.GetAllNodes", err)
+		zap.L().Error("mysql.GetAllNodes failed", zap.Error(err))
 		return nil, err
 	}
 	return nodes, nil
@@ -77,7 +85,7 @@ func UpdateNode(id int, node *models.ServerNode) error {
 	node.ID = id
 	_, err := db.NamedExec(query, node)
 	if err != nil {
-		fmt.Println("mysql.UpdateNode", err)
+		zap.L().Error("mysql.UpdateNode failed", zap.Error(err))
 		return err
 	}
 	return nil
@@ -88,9 +96,20 @@ func DeleteNode(id int) error {
 	query := `DELETE FROM server_nodes WHERE id = ?`
 	_, err := db.Exec(query, id)
 	if err != nil {
-		fmt.Println("mysql.DeleteNode", err)
+		zap.L().Error("mysql.DeleteNode failed", zap.Error(err))
 		return err
 	}
 
 	return nil
 }
+
+// UpdateNodeWebhookSecret 更新节点的 Webhook 密钥
+func UpdateNodeWebhookSecret(id int, secret string) error {
+	query := `UPDATE server_nodes SET webhook_secret = ? WHERE id = ?`
+	_, err := db.Exec(query, secret, id)
+	if err != nil {
+		zap.L().Error("mysql.UpdateNodeWebhookSecret failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddNotifyRule 新增通知规则
+func AddNotifyRule(r *models.NotifyRule) (err error) {
+	r.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	r.UpdateTime = r.CreateTime
+
+	query := `
+    INSERT INTO notify_rules (name, node_id, job_pattern, events, duration_threshold, channel, target, secret,
+                              template, enabled, create_time, update_time)
+    VALUES (:name, :node_id, :job_pattern, :events, :duration_threshold, :channel, :target, :secret,
+            :template, :enabled, :create_time, :update_time)
+    `
+
+	res, err := db.NamedExec(query, r)
+	if err != nil {
+		zap.L().Error("mysql.AddNotifyRule failed", zap.Error(err))
+		return err
+	}
+	r.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetNotifyRuleByID 获取单个通知规则
+func GetNotifyRuleByID(id int64) (*models.NotifyRule, error) {
+	var r models.NotifyRule
+	query := `SELECT * FROM notify_rules WHERE id = ?`
+	err := db.Get(&r, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetNotifyRuleByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetNotifyRules 获取通知规则列表
+func GetNotifyRules() ([]models.NotifyRule, error) {
+	var rules []models.NotifyRule
+	query := `SELECT * FROM notify_rules ORDER BY id DESC`
+	err := db.Select(&rules, query)
+	if err != nil {
+		zap.L().Error("mysql.GetNotifyRules failed", zap.Error(err))
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetEnabledNotifyRules 获取对指定节点生效的启用规则
+func GetEnabledNotifyRules(nodeID int) ([]models.NotifyRule, error) {
+	var rules []models.NotifyRule
+	query := `SELECT * FROM notify_rules WHERE enabled = 1 AND (node_id = 0 OR node_id = ?)`
+	err := db.Select(&rules, query, nodeID)
+	if err != nil {
+		zap.L().Error("mysql.GetEnabledNotifyRules failed", zap.Error(err))
+		return nil, err
+	}
+	return rules, nil
+}
+
+// UpdateNotifyRule 更新通知规则
+func UpdateNotifyRule(id int64, r *models.NotifyRule) error {
+	query := `
+    UPDATE notify_rules
+    SET name = :name, node_id = :node_id, job_pattern = :job_pattern, events = :events,
+        duration_threshold = :duration_threshold, channel = :channel, target = :target,
+        secret = :secret, template = :template, enabled = :enabled
+    WHERE id = :id
+    `
+
+	r.ID = id
+	_, err := db.NamedExec(query, r)
+	if err != nil {
+		zap.L().Error("mysql.UpdateNotifyRule failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteNotifyRule 删除通知规则
+func DeleteNotifyRule(id int64) error {
+	query := `DELETE FROM notify_rules WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		zap.L().Error("mysql.DeleteNotifyRule failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// AddNotifyLog 记录一次通知发送
+func AddNotifyLog(l *models.NotifyLog) (err error) {
+	l.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO notify_logs (rule_id, event, node_id, job_path, build_number, channel, status, attempts, error, content, create_time)
+    VALUES (:rule_id, :event, :node_id, :job_path, :build_number, :channel, :status, :attempts, :error, :content, :create_time)
+    `
+
+	res, err := db.NamedExec(query, l)
+	if err != nil {
+		zap.L().Error("mysql.AddNotifyLog failed", zap.Error(err))
+		return err
+	}
+	l.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetNotifyLogs 获取通知发送记录, ruleID 为 0 时返回全部
+func GetNotifyLogs(ruleID int64, limit int) ([]models.NotifyLog, error) {
+	var logs []models.NotifyLog
+	query := `SELECT * FROM notify_logs WHERE ? = 0 OR rule_id = ? ORDER BY id DESC LIMIT ?`
+	err := db.Select(&logs, query, ruleID, ruleID, limit)
+	if err != nil {
+		zap.L().Error("mysql.GetNotifyLogs failed", zap.Error(err))
+		return nil, err
+	}
+	return logs, nil
+}
+
+// GetJobBuildStatus 获取 Job 最近记录的构建结果, 没有记录时返回 nil
+func GetJobBuildStatus(nodeID int, viewID, jobName string) (*models.JobBuildStatus, error) {
+	var s models.JobBuildStatus
+	query := `SELECT * FROM job_build_status WHERE node_id = ? AND view_id = ? AND job_name = ?`
+	err := db.Get(&s, query, nodeID, viewID, jobName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetJobBuildStatus failed", zap.Error(err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveJobBuildStatus 保存 Job 最近一次构建结果, exists 表示记录是否已存在
+func SaveJobBuildStatus(s *models.JobBuildStatus, exists bool) error {
+	s.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	query := `
+    INSERT INTO job_build_status (node_id, view_id, job_name, build_number, result, update_time)
+    VALUES (:node_id, :view_id, :job_name, :build_number, :result, :update_time)
+    `
+	if exists {
+		query = `
+        UPDATE job_build_status
+        SET build_number = :build_number, result = :result, update_time = :update_time
+        WHERE node_id = :node_id AND view_id = :view_id AND job_name = :job_name
+        `
+	}
+	_, err := db.NamedExec(query, s)
+	if err != nil {
+		zap.L().Error("mysql.SaveJobBuildStatus failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
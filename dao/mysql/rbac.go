@@ -0,0 +1,295 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// AddRole 新增角色及其授权
+func AddRole(r *models.Role) (err error) {
+	r.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	r.UpdateTime = r.CreateTime
+
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.AddRole failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `INSERT INTO roles (name, description, require_2fa, create_time, update_time) VALUES (:name, :description, :require_2fa, :create_time, :update_time)`
+	res, err := tx.NamedExec(query, r)
+	if err != nil {
+		zap.L().Error("mysql.AddRole failed", zap.Error(err))
+		return err
+	}
+	if r.ID, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	if err = insertRolePermissions(tx, r.ID, r.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertRolePermissions 写入角色的授权
+func insertRolePermissions(tx *sqlx.Tx, roleID int64, perms []models.RolePermission) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	query := `
+    INSERT INTO role_permissions (role_id, permission, node_id, job_pattern, create_time)
+    VALUES (:role_id, :permission, :node_id, :job_pattern, :create_time)
+    `
+	for i := range perms {
+		perms[i].RoleID = roleID
+		perms[i].CreateTime = now
+		res, err := tx.NamedExec(query, &perms[i])
+		if err != nil {
+			zap.L().Error("mysql.insertRolePermissions failed", zap.Error(err))
+			return err
+		}
+		if perms[i].ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRoleByID 获取单个角色及其授权, 不存在时返回 nil
+func GetRoleByID(id int64) (*models.Role, error) {
+	var r models.Role
+	query := `SELECT id, name, description, require_2fa, create_time, update_time FROM roles WHERE id = ?`
+	err := db.Get(&r, query, id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetRoleByID failed", zap.Error(err))
+		return nil, err
+	}
+	if r.Permissions, err = GetRolePermissions(id); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetRoleByName 按名称获取角色 (不含授权), 不存在时返回 nil
+func GetRoleByName(name string) (*models.Role, error) {
+	var r models.Role
+	query := `SELECT id, name, description, require_2fa, create_time, update_time FROM roles WHERE name = ?`
+	err := db.Get(&r, query, name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetRoleByName failed", zap.Error(err))
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetRoles 获取角色列表及其授权
+func GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	query := `SELECT id, name, description, require_2fa, create_time, update_time FROM roles ORDER BY id`
+	err := db.Select(&roles, query)
+	if err != nil {
+		zap.L().Error("mysql.GetRoles failed", zap.Error(err))
+		return nil, err
+	}
+	for i := range roles {
+		if roles[i].Permissions, err = GetRolePermissions(roles[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// GetRolePermissions 获取角色的授权
+func GetRolePermissions(roleID int64) ([]models.RolePermission, error) {
+	perms := make([]models.RolePermission, 0)
+	query := `SELECT * FROM role_permissions WHERE role_id = ? ORDER BY id`
+	err := db.Select(&perms, query, roleID)
+	if err != nil {
+		zap.L().Error("mysql.GetRolePermissions failed", zap.Error(err))
+		return nil, err
+	}
+	return perms, nil
+}
+
+// UpdateRole 更新角色, 授权整体替换
+func UpdateRole(id int64, r *models.Role) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.UpdateRole failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	r.ID = id
+	query := `UPDATE roles SET name = :name, description = :description, require_2fa = :require_2fa WHERE id = :id`
+	if _, err = tx.NamedExec(query, r); err != nil {
+		zap.L().Error("mysql.UpdateRole failed", zap.Error(err))
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM role_permissions WHERE role_id = ?`, id); err != nil {
+		zap.L().Error("mysql.UpdateRole failed", zap.Error(err))
+		return err
+	}
+	if err = insertRolePermissions(tx, id, r.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRole 删除角色及其授权和用户关联
+func DeleteRole(id int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.DeleteRole failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, query := range []string{
+		`DELETE FROM role_permissions WHERE role_id = ?`,
+		`DELETE FROM user_roles WHERE role_id = ?`,
+		`DELETE FROM roles WHERE id = ?`,
+	} {
+		if _, err = tx.Exec(query, id); err != nil {
+			zap.L().Error("mysql.DeleteRole failed", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetUserRoles 设置用户的角色, 整体替换
+func SetUserRoles(userID int64, roleIDs []int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.SetUserRoles failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM user_roles WHERE user_id = ?`, userID); err != nil {
+		zap.L().Error("mysql.SetUserRoles failed", zap.Error(err))
+		return err
+	}
+	for _, roleID := range roleIDs {
+		if _, err = tx.Exec(`INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)`, userID, roleID); err != nil {
+			zap.L().Error("mysql.SetUserRoles failed", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddUserRole 为用户增加一个角色, 已拥有时忽略
+func AddUserRole(userID, roleID int64) error {
+	_, err := db.Exec(`INSERT OR IGNORE INTO user_roles (user_id, role_id) VALUES (?, ?)`, userID, roleID)
+	if err != nil {
+		zap.L().Error("mysql.AddUserRole failed", zap.Error(err))
+	}
+	return err
+}
+
+// SetRoleRequire2FA 设置角色是否要求两步验证
+func SetRoleRequire2FA(id int64, require bool) error {
+	_, err := db.Exec(`UPDATE roles SET require_2fa = ? WHERE id = ?`, require, id)
+	if err != nil {
+		zap.L().Error("mysql.SetRoleRequire2FA failed", zap.Error(err))
+	}
+	return err
+}
+
+// UserRequires2FA 用户是否拥有要求两步验证的角色
+func UserRequires2FA(userID int64) (bool, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = ? AND r.require_2fa = 1`
+	if err := db.Get(&count, query, userID); err != nil {
+		zap.L().Error("mysql.UserRequires2FA failed", zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetUserRoles 获取用户的角色 (不含授权)
+func GetUserRoles(userID int64) ([]models.Role, error) {
+	roles := make([]models.Role, 0)
+	query := `
+    SELECT r.id, r.name, r.description, r.require_2fa, r.create_time, r.update_time
+    FROM roles r JOIN user_roles ur ON ur.role_id = r.id
+    WHERE ur.user_id = ? ORDER BY r.id
+    `
+	err := db.Select(&roles, query, userID)
+	if err != nil {
+		zap.L().Error("mysql.GetUserRoles failed", zap.Error(err))
+		return nil, err
+	}
+	return roles, nil
+}
+
+// GetUserGrants 获取用户通过所有角色获得的授权
+func GetUserGrants(userID int64) ([]models.Grant, error) {
+	var grants []models.Grant
+	query := `
+    SELECT rp.permission, rp.node_id, rp.job_pattern
+    FROM role_permissions rp JOIN user_roles ur ON ur.role_id = rp.role_id
+    WHERE ur.user_id = ?
+    `
+	err := db.Select(&grants, query, userID)
+	if err != nil {
+		zap.L().Error("mysql.GetUserGrants failed", zap.Error(err))
+		return nil, err
+	}
+	return grants, nil
+}
+
+// CountUsersWithPermission 统计拥有全局 permission 授权且未被禁用的用户数
+func CountUsersWithPermission(permission string) (int64, error) {
+	var count int64
+	query := `
+    SELECT COUNT(DISTINCT ur.user_id)
+    FROM user_roles ur
+        JOIN role_permissions rp ON rp.role_id = ur.role_id
+        JOIN user u ON u.user_id = ur.user_id
+    WHERE rp.permission = ? AND rp.node_id = 0 AND rp.job_pattern IN ('', '*') AND u.disabled = 0
+    `
+	err := db.Get(&count, query, permission)
+	if err != nil {
+		zap.L().Error("mysql.CountUsersWithPermission failed", zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+// CheckUserIDExist 检查用户是否存在
+func CheckUserIDExist(userID int64) (bool, error) {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(user_id) FROM user WHERE user_id = ?`, userID); err != nil {
+		zap.L().Error("mysql.CheckUserIDExist failed", zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
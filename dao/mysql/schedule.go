@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddSchedule 新增定时构建计划
+func AddSchedule(s *models.BuildSchedule) (err error) {
+	s.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	s.UpdateTime = s.CreateTime
+
+	query := `
+    INSERT INTO build_schedules (name, node_id, view_id, job_name, params, cron_expr, run_at, blackouts,
+                                 enabled, next_run_time, last_run_time, last_result, create_time, update_time)
+    VALUES (:name, :node_id, :view_id, :job_name, :params, :cron_expr, :run_at, :blackouts,
+            :enabled, :next_run_time, :last_run_time, :last_result, :create_time, :update_time)
+    `
+
+	res, err := db.NamedExec(query, s)
+	if err != nil {
+		zap.L().Error("mysql.AddSchedule failed", zap.Error(err))
+		return err
+	}
+	s.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetScheduleByID 获取单个计划
+func GetScheduleByID(id int64) (*models.BuildSchedule, error) {
+	var s models.BuildSchedule
+	query := `SELECT * FROM build_schedules WHERE id = ?`
+	err := db.Get(&s, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetScheduleByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSchedules 获取计划列表, nodeID 为 0 时返回全部
+func GetSchedules(nodeID int) ([]models.BuildSchedule, error) {
+	var schedules []models.BuildSchedule
+	query := `SELECT * FROM build_schedules WHERE ? = 0 OR node_id = ? ORDER BY id DESC`
+	err := db.Select(&schedules, query, nodeID, nodeID)
+	if err != nil {
+		zap.L().Error("mysql.GetSchedules failed", zap.Error(err))
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// GetDueSchedules 获取已到触发时间的启用计划
+func GetDueSchedules(now string) ([]models.BuildSchedule, error) {
+	var schedules []models.BuildSchedule
+	query := `SELECT * FROM build_schedules WHERE enabled = 1 AND next_run_time != '' AND next_run_time <= ?`
+	err := db.Select(&schedules, query, now)
+	if err != nil {
+		zap.L().Error("mysql.GetDueSchedules failed", zap.Error(err))
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule 更新计划的定义
+func UpdateSchedule(id int64, s *models.BuildSchedule) error {
+	query := `
+    UPDATE build_schedules
+    SET name = :name, node_id = :node_id, view_id = :view_id, job_name = :job_name,
+        params = :params, cron_expr = :cron_expr, run_at = :run_at, blackouts = :blackouts,
+        enabled = :enabled, next_run_time = :next_run_time
+    WHERE id = :id
+    `
+
+	s.ID = id
+	_, err := db.NamedExec(query, s)
+	if err != nil {
+		zap.L().Error("mysql.UpdateSchedule failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// ClaimScheduleRun 将计划的下次触发时间从 prev 推进到 next, 并记录本次执行结果
+// 仅当 next_run_time 仍为 prev 时更新成功, 多个实例同时执行时只有一个能抢到
+func ClaimScheduleRun(id int64, prev, next, runTime, result string, enabled bool) (bool, error) {
+	query := `
+    UPDATE build_schedules
+    SET next_run_time = ?, last_run_time = ?, last_result = ?, enabled = ?
+    WHERE id = ? AND next_run_time = ?
+    `
+	res, err := db.Exec(query, next, runTime, result, enabled, id, prev)
+	if err != nil {
+		zap.L().Error("mysql.ClaimScheduleRun failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UpdateScheduleResult 更新计划最近一次执行结果
+func UpdateScheduleResult(id int64, result string) error {
+	query := `UPDATE build_schedules SET last_result = ? WHERE id = ?`
+	_, err := db.Exec(query, result, id)
+	if err != nil {
+		zap.L().Error("mysql.UpdateScheduleResult failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteSchedule 删除计划
+func DeleteSchedule(id int64) error {
+	query := `DELETE FROM build_schedules WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		zap.L().Error("mysql.DeleteSchedule failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
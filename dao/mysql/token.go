@@ -0,0 +1,139 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddRefreshToken 保存 refresh token
+func AddRefreshToken(t *models.RefreshToken) (err error) {
+	t.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	query := `
+    INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, revoked, create_time)
+    VALUES (:user_id, :token_hash, :family_id, :expires_at, :revoked, :create_time)
+    `
+
+	res, err := db.NamedExec(query, t)
+	if err != nil {
+		zap.L().Error("mysql.AddRefreshToken failed", zap.Error(err))
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetRefreshTokenByHash 按哈希获取 refresh token, 不存在时返回 nil
+func GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = ?`
+	err := db.Get(&t, query, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetRefreshTokenByHash failed", zap.Error(err))
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RevokeRefreshToken 吊销单个 refresh token, 返回是否由本次调用吊销, 用于保证同一 token 只能轮换一次
+func RevokeRefreshToken(id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE id = ? AND revoked = 0`, id)
+	if err != nil {
+		zap.L().Error("mysql.RevokeRefreshToken failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RevokeRefreshTokenFamily 吊销同一次登录轮换出的所有 refresh token
+func RevokeRefreshTokenFamily(familyID string) error {
+	_, err := db.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ?`, familyID)
+	if err != nil {
+		zap.L().Error("mysql.RevokeRefreshTokenFamily failed", zap.Error(err))
+	}
+	return err
+}
+
+// RevokeUserRefreshTokens 吊销用户的所有 refresh token
+func RevokeUserRefreshTokens(userID int64) error {
+	_, err := db.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE user_id = ?`, userID)
+	if err != nil {
+		zap.L().Error("mysql.RevokeUserRefreshTokens failed", zap.Error(err))
+	}
+	return err
+}
+
+// DeleteExpiredTokens 清理已过期的 refresh token, 吊销记录, OIDC 登录状态和两步验证登录
+func DeleteExpiredTokens(now string) error {
+	for _, query := range []string{
+		`DELETE FROM refresh_tokens WHERE expires_at < ?`,
+		`DELETE FROM revoked_tokens WHERE expires_at < ?`,
+		`DELETE FROM oidc_states WHERE expires_at < ?`,
+		`DELETE FROM login_challenges WHERE expires_at < ?`,
+	} {
+		if _, err := db.Exec(query, now); err != nil {
+			zap.L().Error("mysql.DeleteExpiredTokens failed", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// AddRevokedToken 记录被吊销的 access token, 保留到其过期时间
+func AddRevokedToken(jti, expiresAt string) error {
+	_, err := db.Exec(`INSERT INTO revoked_tokens (jti, expires_at) VALUES (?, ?)`, jti, expiresAt)
+	if err != nil {
+		zap.L().Error("mysql.AddRevokedToken failed", zap.Error(err))
+	}
+	return err
+}
+
+// IsTokenRevoked 判断 access token 是否已被吊销
+func IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	if err := db.Get(&count, `SELECT COUNT(jti) FROM revoked_tokens WHERE jti = ?`, jti); err != nil {
+		zap.L().Error("mysql.IsTokenRevoked failed", zap.Error(err))
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddOIDCState 保存 OIDC 登录状态
+func AddOIDCState(s *models.OIDCState) error {
+	query := `INSERT INTO oidc_states (state, nonce, code_verifier, expires_at) VALUES (?, ?, ?, ?)`
+	_, err := db.Exec(query, s.State, s.Nonce, s.CodeVerifier, s.ExpiresAt)
+	if err != nil {
+		zap.L().Error("mysql.AddOIDCState failed", zap.Error(err))
+	}
+	return err
+}
+
+// TakeOIDCState 取出并删除 OIDC 登录状态, 不存在或已被使用时返回 nil
+func TakeOIDCState(state string) (*models.OIDCState, error) {
+	var s models.OIDCState
+	err := db.Get(&s, `SELECT state, nonce, code_verifier, expires_at FROM oidc_states WHERE state = ?`, state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.TakeOIDCState failed", zap.Error(err))
+		return nil, err
+	}
+	// 并发回调时只有删除成功的一方可以使用
+	res, err := db.Exec(`DELETE FROM oidc_states WHERE state = ?`, state)
+	if err != nil {
+		zap.L().Error("mysql.TakeOIDCState failed", zap.Error(err))
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return &s, nil
+}
@@ -0,0 +1,180 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"database/sql"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GetUserTOTP 获取用户的两步验证, 未绑定时返回 nil
+func GetUserTOTP(userID int64) (*models.UserTOTP, error) {
+	var t models.UserTOTP
+	err := db.Get(&t, `SELECT * FROM user_totp WHERE user_id = ?`, userID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetUserTOTP failed", zap.Error(err))
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveUserTOTP 保存待验证的两步验证密钥, 覆盖之前未验证的密钥
+func SaveUserTOTP(userID int64, secret string) error {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.SaveUserTOTP failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		zap.L().Error("mysql.SaveUserTOTP failed", zap.Error(err))
+		return err
+	}
+	query := `INSERT INTO user_totp (user_id, secret, enabled, last_counter, create_time) VALUES (?, ?, 0, 0, ?)`
+	if _, err = tx.Exec(query, userID, secret, now); err != nil {
+		zap.L().Error("mysql.SaveUserTOTP failed", zap.Error(err))
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPCounter 记录已使用的时间步并启用两步验证, 时间步不大于上次使用的时返回 false
+func UseTOTPCounter(userID, counter int64) (bool, error) {
+	query := `UPDATE user_totp SET last_counter = ?, enabled = 1 WHERE user_id = ? AND last_counter < ?`
+	res, err := db.Exec(query, counter, userID, counter)
+	if err != nil {
+		zap.L().Error("mysql.UseTOTPCounter failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteUserTOTP 关闭两步验证, 同时删除恢复码
+func DeleteUserTOTP(userID int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.DeleteUserTOTP failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	for _, query := range []string{
+		`DELETE FROM user_totp WHERE user_id = ?`,
+		`DELETE FROM user_recovery_codes WHERE user_id = ?`,
+	} {
+		if _, err = tx.Exec(query, userID); err != nil {
+			zap.L().Error("mysql.DeleteUserTOTP failed", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes 替换用户的恢复码
+func ReplaceRecoveryCodes(userID int64, hashes []string) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.ReplaceRecoveryCodes failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		zap.L().Error("mysql.ReplaceRecoveryCodes failed", zap.Error(err))
+		return err
+	}
+	for _, h := range hashes {
+		if _, err = tx.Exec(`INSERT INTO user_recovery_codes (user_id, code_hash, used) VALUES (?, ?, 0)`, userID, h); err != nil {
+			zap.L().Error("mysql.ReplaceRecoveryCodes failed", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode 使用一个恢复码, 不存在或已使用时返回 false
+func UseRecoveryCode(userID int64, hash string) (bool, error) {
+	res, err := db.Exec(`UPDATE user_recovery_codes SET used = 1 WHERE user_id = ? AND code_hash = ? AND used = 0`, userID, hash)
+	if err != nil {
+		zap.L().Error("mysql.UseRecoveryCode failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes 统计用户未使用的恢复码
+func CountRecoveryCodes(userID int64) (int, error) {
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = ? AND used = 0`, userID); err != nil {
+		zap.L().Error("mysql.CountRecoveryCodes failed", zap.Error(err))
+		return 0, err
+	}
+	return count, nil
+}
+
+// AddLoginChallenge 保存等待两步验证的登录
+func AddLoginChallenge(c *models.LoginChallenge) error {
+	query := `
+    INSERT INTO login_challenges (token_hash, user_id, enroll, attempts, expires_at)
+    VALUES (:token_hash, :user_id, :enroll, :attempts, :expires_at)
+    `
+	_, err := db.NamedExec(query, c)
+	if err != nil {
+		zap.L().Error("mysql.AddLoginChallenge failed", zap.Error(err))
+	}
+	return err
+}
+
+// GetLoginChallenge 获取等待两步验证的登录, 不存在时返回 nil
+func GetLoginChallenge(hash string) (*models.LoginChallenge, error) {
+	var c models.LoginChallenge
+	err := db.Get(&c, `SELECT * FROM login_challenges WHERE token_hash = ?`, hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetLoginChallenge failed", zap.Error(err))
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ClaimLoginChallengeAttempt 占用一次验证机会, 已达到 max 次时返回 false
+// 先加一再比较, 并发提交时不会超过上限
+func ClaimLoginChallengeAttempt(hash string, max int) (bool, error) {
+	res, err := db.Exec(`UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ? AND attempts < ?`, hash, max)
+	if err != nil {
+		zap.L().Error("mysql.ClaimLoginChallengeAttempt failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteLoginChallenge 删除等待两步验证的登录, 返回是否删除成功 (并发提交时只有一方成功)
+func DeleteLoginChallenge(hash string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM login_challenges WHERE token_hash = ?`, hash)
+	if err != nil {
+		zap.L().Error("mysql.DeleteLoginChallenge failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -2,16 +2,18 @@ package mysql
 
 import (
 	"bluebell/models"
-	"crypto/md5"
 	"database/sql"
-	"encoding/hex"
 	"errors"
+
+	"go.uber.org/zap"
 )
 
 // 把每一步数据库操作封装成函数
 // 待logic层根据业务需求调用
 
-const secret = "liwenzhou.com"
+// userColumns 查询用户时的字段, email 和时间可能为 NULL
+const userColumns = `user_id, username, password, source, nickname, avatar, COALESCE(email, '') AS email, gender,
+    disabled, COALESCE(create_time, '') AS create_time, COALESCE(update_time, '') AS update_time`
 
 var (
 	ErrorUserExist       = errors.New("用户已存在")
@@ -32,39 +34,149 @@ func CheckUserExist(username string) (err error) {
 	return
 }
 
-// InsertUser 想数据库中插入一条新的用户记录
+// InsertUser 想数据库中插入一条新的用户记录, 密码需要在调用前完成哈希
 func InsertUser(user *models.User) (err error) {
-	// 对密码进行加密
-	user.Password = encryptPassword(user.Password)
 	// 执行SQL语句入库
-	sqlStr := `insert into user(user_id, username, password) values(?,?,?)`
-	_, err = db.Exec(sqlStr, user.UserID, user.Username, user.Password)
+	if user.Source == "" {
+		user.Source = models.UserSourceLocal
+	}
+	sqlStr := `insert into user(user_id, username, password, source, nickname, avatar, email, gender) values(?,?,?,?,?,?,?,?)`
+	_, err = db.Exec(sqlStr, user.UserID, user.Username, user.Password, user.Source,
+		user.Nickname, user.Avatar, user.Email, user.Gender)
 	return
 }
 
-// encryptPassword 密码加密
-func encryptPassword(oPassword string) string {
-	h := md5.New()
-	h.Write([]byte(secret))
-	return hex.EncodeToString(h.Sum([]byte(oPassword)))
+// GetUserByUsername 按用户名查询用户, 包含密码哈希
+func GetUserByUsername(username string) (*models.User, error) {
+	user := new(models.User)
+	sqlStr := `select ` + userColumns + ` from user where username=?`
+	err := db.Get(user, sqlStr, username)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	if err != nil {
+		// 查询数据库失败
+		return nil, err
+	}
+	return user, nil
 }
 
-func Login(user *models.User) (err error) {
-	oPassword := user.Password // 用户登录的密码
-	sqlStr := `select user_id, username, password from user where username=?`
-	err = db.Get(user, sqlStr, user.Username)
+// GetUserByID 按用户ID查询用户
+func GetUserByID(userID int64) (*models.User, error) {
+	user := new(models.User)
+	sqlStr := `select ` + userColumns + ` from user where user_id=?`
+	err := db.Get(user, sqlStr, userID)
 	if err == sql.ErrNoRows {
-		return ErrorUserNotExist
+		return nil, ErrorUserNotExist
 	}
 	if err != nil {
-		// 查询数据库失败
-		return err
+		return nil, err
 	}
-	// 判断密码是否正确
-	//password := encryptPassword(oPassword)
-	password := oPassword
-	if password != user.Password {
-		return ErrorInvalidPassword
+	return user, nil
+}
+
+// UpdateUserPassword 更新用户的密码哈希
+func UpdateUserPassword(userID int64, hash string) (err error) {
+	sqlStr := `update user set password = ? where user_id = ?`
+	_, err = db.Exec(sqlStr, hash, userID)
+	return
+}
+
+// GetUsers 获取所有用户
+func GetUsers() ([]models.User, error) {
+	users := make([]models.User, 0)
+	sqlStr := `select ` + userColumns + ` from user order by id`
+	if err := db.Select(&users, sqlStr); err != nil {
+		zap.L().Error("mysql.GetUsers failed", zap.Error(err))
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateUserProfile 更新用户的个人资料
+func UpdateUserProfile(userID int64, p *models.ParamUpdateProfile) (err error) {
+	sqlStr := `update user set nickname = ?, avatar = ?, email = ?, gender = ? where user_id = ?`
+	_, err = db.Exec(sqlStr, p.Nickname, p.Avatar, p.Email, p.Gender, userID)
+	if err != nil {
+		zap.L().Error("mysql.UpdateUserProfile failed", zap.Error(err))
+	}
+	return
+}
+
+// SetUserDisabled 启用或禁用用户
+func SetUserDisabled(userID int64, disabled bool) (err error) {
+	_, err = db.Exec(`update user set disabled = ? where user_id = ?`, disabled, userID)
+	if err != nil {
+		zap.L().Error("mysql.SetUserDisabled failed", zap.Error(err))
 	}
 	return
 }
+
+// DeleteUser 删除用户及其角色和令牌
+func DeleteUser(userID int64) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		zap.L().Error("mysql.DeleteUser failed", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, query := range []string{
+		`DELETE FROM user_roles WHERE user_id = ?`,
+		`DELETE FROM refresh_tokens WHERE user_id = ?`,
+		`DELETE FROM api_tokens WHERE user_id = ?`,
+		`DELETE FROM user_totp WHERE user_id = ?`,
+		`DELETE FROM user_recovery_codes WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM user WHERE user_id = ?`,
+	} {
+		if _, err = tx.Exec(query, userID); err != nil {
+			zap.L().Error("mysql.DeleteUser failed", zap.Error(err))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetUserByIdentity 按外部身份 (issuer + subject) 查询关联的用户, 未关联时返回 ErrorUserNotExist
+func GetUserByIdentity(issuer, subject string) (*models.User, error) {
+	user := new(models.User)
+	sqlStr := `select ` + userColumns + ` from user where user_id = (
+    select user_id from user_identities where issuer = ? and subject = ?)`
+	err := db.Get(user, sqlStr, issuer, subject)
+	if err == sql.ErrNoRows {
+		return nil, ErrorUserNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// InsertUserWithIdentity 创建用户并关联外部身份, 在一个事务中执行
+func InsertUserWithIdentity(user *models.User, issuer, subject string) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	sqlStr := `insert into user(user_id, username, password, source, nickname, avatar, email, gender) values(?,?,?,?,?,?,?,?)`
+	if _, err = tx.Exec(sqlStr, user.UserID, user.Username, user.Password, user.Source,
+		user.Nickname, user.Avatar, user.Email, user.Gender); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`INSERT INTO user_identities (user_id, issuer, subject) VALUES (?, ?, ?)`,
+		user.UserID, issuer, subject); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package mysql
+
+import (
+	"bluebell/models"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AddWorkflow 新增工作流
+func AddWorkflow(w *models.Workflow) (err error) {
+	w.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	w.UpdateTime = w.CreateTime
+
+	query := `
+    INSERT INTO workflows (name, description, steps, create_time, update_time)
+    VALUES (:name, :description, :steps, :create_time, :update_time)
+    `
+
+	res, err := db.NamedExec(query, w)
+	if err != nil {
+		zap.L().Error("mysql.AddWorkflow failed", zap.Error(err))
+		return err
+	}
+	w.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetWorkflowByID 获取单个工作流
+func GetWorkflowByID(id int64) (*models.Workflow, error) {
+	var w models.Workflow
+	query := `SELECT * FROM workflows WHERE id = ?`
+	err := db.Get(&w, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetWorkflowByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &w, nil
+}
+
+// GetWorkflows 获取工作流列表, name 不为空时模糊匹配
+func GetWorkflows(name string) ([]models.Workflow, error) {
+	var workflows []models.Workflow
+	query := `SELECT * FROM workflows WHERE TRIM(name) LIKE ? ORDER BY id DESC`
+	err := db.Select(&workflows, query, "%"+name+"%")
+	if err != nil {
+		zap.L().Error("mysql.GetWorkflows failed", zap.Error(err))
+		return nil, err
+	}
+	return workflows, nil
+}
+
+// UpdateWorkflow 更新工作流
+func UpdateWorkflow(id int64, w *models.Workflow) error {
+	query := `
+    UPDATE workflows
+    SET name = :name, description = :description, steps = :steps
+    WHERE id = :id
+    `
+
+	w.ID = id
+	_, err := db.NamedExec(query, w)
+	if err != nil {
+		zap.L().Error("mysql.UpdateWorkflow failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// DeleteWorkflow 删除工作流
+func DeleteWorkflow(id int64) error {
+	query := `DELETE FROM workflows WHERE id = ?`
+	_, err := db.Exec(query, id)
+	if err != nil {
+		zap.L().Error("mysql.DeleteWorkflow failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// AddWorkflowRun 新增一次运行
+func AddWorkflowRun(r *models.WorkflowRun) (err error) {
+	r.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	r.UpdateTime = r.CreateTime
+
+	query := `
+    INSERT INTO workflow_runs (workflow_id, status, params, steps, states, create_time, update_time, finish_time)
+    VALUES (:workflow_id, :status, :params, :steps, :states, :create_time, :update_time, :finish_time)
+    `
+
+	res, err := db.NamedExec(query, r)
+	if err != nil {
+		zap.L().Error("mysql.AddWorkflowRun failed", zap.Error(err))
+		return err
+	}
+	r.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetWorkflowRunByID 获取单次运行
+func GetWorkflowRunByID(id int64) (*models.WorkflowRun, error) {
+	var r models.WorkflowRun
+	query := `SELECT * FROM workflow_runs WHERE id = ?`
+	err := db.Get(&r, query, id)
+	if err != nil {
+		zap.L().Error("mysql.GetWorkflowRunByID failed", zap.Error(err))
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetWorkflowRuns 获取工作流的运行记录, workflowID 为 0 时返回全部
+func GetWorkflowRuns(workflowID int64) ([]models.WorkflowRun, error) {
+	var runs []models.WorkflowRun
+	query := `SELECT * FROM workflow_runs WHERE ? = 0 OR workflow_id = ? ORDER BY id DESC`
+	err := db.Select(&runs, query, workflowID, workflowID)
+	if err != nil {
+		zap.L().Error("mysql.GetWorkflowRuns failed", zap.Error(err))
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetActiveWorkflowRuns 获取未结束的运行
+func GetActiveWorkflowRuns() ([]models.WorkflowRun, error) {
+	var runs []models.WorkflowRun
+	query := `SELECT * FROM workflow_runs WHERE status IN (?, ?)`
+	err := db.Select(&runs, query, models.WorkflowStatusRunning, models.WorkflowStatusPaused)
+	if err != nil {
+		zap.L().Error("mysql.GetActiveWorkflowRuns failed", zap.Error(err))
+		return nil, err
+	}
+	return runs, nil
+}
+
+// UpdateWorkflowRun 保存运行状态
+func UpdateWorkflowRun(r *models.WorkflowRun) error {
+	query := `
+    UPDATE workflow_runs
+    SET status = :status, states = :states, finish_time = :finish_time
+    WHERE id = :id
+    `
+
+	_, err := db.NamedExec(query, r)
+	if err != nil {
+		zap.L().Error("mysql.UpdateWorkflowRun failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// LockWorkflowRun 抢占运行锁, 仅当运行未被锁定或锁已在 now 之前过期时成功, 锁在 until 过期
+// 与 ClaimScheduleRun 相同通过条件更新实现, 多个实例同时抢占时只有一个能成功
+func LockWorkflowRun(id int64, owner, now, until string) (bool, error) {
+	query := `
+    UPDATE workflow_runs
+    SET lock_owner = ?, lock_until = ?
+    WHERE id = ? AND (lock_owner = '' OR lock_until < ?)
+    `
+	res, err := db.Exec(query, owner, until, id, now)
+	if err != nil {
+		zap.L().Error("mysql.LockWorkflowRun failed", zap.Error(err))
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UnlockWorkflowRun 释放运行锁, 锁已过期并被其他持有者抢占时不做修改
+func UnlockWorkflowRun(id int64, owner string) error {
+	query := `UPDATE workflow_runs SET lock_owner = '', lock_until = '' WHERE id = ? AND lock_owner = ?`
+	_, err := db.Exec(query, id, owner)
+	if err != nil {
+		zap.L().Error("mysql.UnlockWorkflowRun failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
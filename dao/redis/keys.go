@@ -0,0 +1,17 @@
+package redis
+
+// redis key
+
+// redis key注意使用命名空间的方式,方便查询和拆分
+const (
+	KeyPrefix                = "bluebell:"
+	KeyScheduleLockPrefix    = "schedule:lock:"    // 定时构建触发锁 参数是计划ID和触发时间
+	KeyLoginFailPrefix       = "login:fail:"       // 登录失败次数 参数是 user:用户名 或 ip:地址
+	KeyLoginLockPrefix       = "login:lock:"       // 登录锁定 参数同上
+	KeyWebhookDeliveryPrefix = "webhook:delivery:" // 已处理的 Webhook 投递 参数是节点ID和签名
+)
+
+// getRedisKey 给redis key加上前缀
+func getRedisKey(key string) string {
+	return KeyPrefix + key
+}
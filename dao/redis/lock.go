@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+)
+
+// Enabled 是否配置并初始化了 Redis
+func Enabled() bool {
+	return client != nil
+}
+
+// TryLockSchedule 为计划的某一次触发加锁, 多个实例中只有一个能加锁成功
+func TryLockSchedule(scheduleID int64, runTime string, ttl time.Duration) (bool, error) {
+	key := getRedisKey(KeyScheduleLockPrefix + fmt.Sprintf("%d:%s", scheduleID, runTime))
+	return client.SetNX(key, 1, ttl).Result()
+}
@@ -0,0 +1,34 @@
+package redis
+
+import "time"
+
+// LoginLockTTL 获取登录锁定的剩余时间, 未锁定时返回 0
+func LoginLockTTL(key string) (time.Duration, error) {
+	ttl, err := client.PTTL(getRedisKey(KeyLoginLockPrefix + key)).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// IncrLoginFailures 登录失败次数加一, 计数在最后一次失败 window 时间后过期
+func IncrLoginFailures(key string, window time.Duration) (int64, error) {
+	k := getRedisKey(KeyLoginFailPrefix + key)
+	pipe := client.TxPipeline()
+	incr := pipe.Incr(k)
+	pipe.Expire(k, window)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// LockLogin 锁定登录 d 时间
+func LockLogin(key string, d time.Duration) error {
+	return client.Set(getRedisKey(KeyLoginLockPrefix+key), 1, d).Err()
+}
+
+// ResetLoginFailures 登录成功后清除失败次数和锁定
+func ResetLoginFailures(key string) error {
+	return client.Del(getRedisKey(KeyLoginFailPrefix+key), getRedisKey(KeyLoginLockPrefix+key)).Err()
+}
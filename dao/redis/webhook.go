@@ -0,0 +1,8 @@
+package redis
+
+import "time"
+
+// ClaimWebhookDelivery 记录一次 Webhook 投递, 在 ttl 内重复的投递返回 false
+func ClaimWebhookDelivery(id string, ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyWebhookDeliveryPrefix+id), 1, ttl).Result()
+}
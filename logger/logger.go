@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"bluebell/pkg/clientip"
 	"bluebell/setting"
 	"net"
 	"net/http"
@@ -27,16 +28,19 @@ func Init(cfg *setting.LogConfig, mode string) (err error) {
 	if err != nil {
 		return
 	}
+	// 每个输出单独脱敏, 保证各自的日志级别生效
+	r := newRedactor(cfg.RedactKeys)
+	defaultRedactor = r
 	var core zapcore.Core
 	if mode == "dev" {
 		// 进入开发模式，日志输出到终端
 		consoleEncoder := zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
 		core = zapcore.NewTee(
-			zapcore.NewCore(encoder, writeSyncer, l),
-			zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), zapcore.DebugLevel),
+			newRedactCore(zapcore.NewCore(encoder, writeSyncer, l), r),
+			newRedactCore(zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), zapcore.DebugLevel), r),
 		)
 	} else {
-		core = zapcore.NewCore(encoder, writeSyncer, l)
+		core = newRedactCore(zapcore.NewCore(encoder, writeSyncer, l), r)
 	}
 
 	lg = zap.New(core, zap.AddCaller())
@@ -75,12 +79,12 @@ func GinLogger() gin.HandlerFunc {
 		c.Next()
 
 		cost := time.Since(start)
-		lg.Info(path,
+		L(c).Info(path,
 			zap.Int("status", c.Writer.Status()),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
-			zap.String("ip", c.ClientIP()),
+			zap.String("ip", clientip.FromRequest(c.Request)),
 			zap.String("user-agent", c.Request.UserAgent()),
 			zap.String("errors", c.Errors.ByType(gin.ErrorTypePrivate).String()),
 			zap.Duration("cost", cost),
@@ -106,7 +110,7 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 
 				httpRequest, _ := httputil.DumpRequest(c.Request, false)
 				if brokenPipe {
-					lg.Error(c.Request.URL.Path,
+					L(c).Error(c.Request.URL.Path,
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 					)
@@ -117,13 +121,13 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 				}
 
 				if stack {
-					lg.Error("[Recovery from panic]",
+					L(c).Error("[Recovery from panic]",
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 						zap.String("stack", string(debug.Stack())),
 					)
 				} else {
-					lg.Error("[Recovery from panic]",
+					L(c).Error("[Recovery from panic]",
 						zap.Any("error", err),
 						zap.String("request", string(httpRequest)),
 					)
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// HeaderRequestID 请求 ID 的请求头和响应头, 调用 Jenkins 时同样携带
+const HeaderRequestID = "X-Request-ID"
+
+const (
+	ctxRequestIDKey = "requestID"
+	ctxLoggerKey    = "logger"
+	maxRequestIDLen = 128
+)
+
+type requestIDKey struct{}
+
+// GinRequestID 读取请求头中的 X-Request-ID, 没有或格式不合法时生成新的 ID
+// ID 保存在 gin.Context 和 Request 的 context 中, 并写入响应头; 通过 L(c) 获取带有该 ID 的 logger
+func GinRequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(ctxRequestIDKey, id)
+		c.Set(ctxLoggerKey, zap.L().With(zap.String("request_id", id)))
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+// validRequestID 只接受长度有限的字母、数字和 - _ . : 组成的 ID, 避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case ch == '-', ch == '_', ch == '.', ch == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// RequestID 获取当前请求的 ID
+func RequestID(c *gin.Context) string {
+	return c.GetString(ctxRequestIDKey)
+}
+
+// L 返回当前请求的 logger, 日志中带有 request_id; 没有经过 GinRequestID 时返回全局 logger
+func L(c *gin.Context) *zap.Logger {
+	if l, ok := c.Get(ctxLoggerKey); ok {
+		return l.(*zap.Logger)
+	}
+	return zap.L()
+}
+
+// WithRequestID 返回携带请求 ID 的 ctx
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 获取 ctx 中的请求 ID, 没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Ctx 返回带有 ctx 中请求 ID 的 logger, 用于没有 gin.Context 的 logic 层
+func Ctx(ctx context.Context) *zap.Logger {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return zap.L().With(zap.String("request_id", id))
+	}
+	return zap.L()
+}
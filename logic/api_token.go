@@ -0,0 +1,115 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// apiTokenPrefix 个人访问令牌的前缀, 用于与 JWT 区分
+	apiTokenPrefix = "bbt_"
+	// apiTokenTouchInterval 最后使用时间的更新间隔, 避免每次请求都写数据库
+	apiTokenTouchInterval = time.Minute
+)
+
+var (
+	ErrorAPITokenNotExist = errors.New("令牌不存在")
+	ErrorInvalidAPIToken  = errors.New("令牌无效, 已过期或已吊销")
+)
+
+// IsAPIToken 判断 Authorization 中携带的是否为个人访问令牌
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// CreateAPIToken 创建个人访问令牌, 明文只在创建时返回一次
+func CreateAPIToken(userID int64, p *models.ParamAPIToken) (*models.APIToken, string, error) {
+	scopes := make(models.StringList, 0, len(p.Scopes))
+	seen := make(map[string]bool)
+	for _, s := range p.Scopes {
+		if !validPermissions[s] {
+			return nil, "", fmt.Errorf("不支持的权限 [%s]", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	raw, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw = apiTokenPrefix + raw
+	t := &models.APIToken{
+		UserID:    userID,
+		Name:      p.Name,
+		TokenHash: hashToken(raw),
+		Prefix:    raw[:len(apiTokenPrefix)+6],
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, p.ExpiresIn).Format(timeLayout),
+	}
+	if err := mysql.AddAPIToken(t); err != nil {
+		return nil, "", err
+	}
+	return t, raw, nil
+}
+
+// GetAPITokens 获取用户的个人访问令牌
+func GetAPITokens(userID int64) ([]models.APIToken, error) {
+	return mysql.GetAPITokens(userID)
+}
+
+// RevokeAPIToken 吊销用户自己的个人访问令牌
+func RevokeAPIToken(userID, id int64) error {
+	ok, err := mysql.RevokeAPIToken(userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorAPITokenNotExist
+	}
+	return nil
+}
+
+// AuthenticateAPIToken 校验个人访问令牌, 成功时返回令牌及其所属用户
+func AuthenticateAPIToken(raw string) (*models.APIToken, error) {
+	t, err := mysql.GetAPITokenByHash(hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Format(timeLayout)
+	if t == nil || t.Revoked || t.ExpiresAt < now {
+		return nil, ErrorInvalidAPIToken
+	}
+	// 用户被删除或禁用后令牌随之失效
+	active, err := IsUserActive(t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrorInvalidAPIToken
+	}
+	if t.LastUsedAt < time.Now().Add(-apiTokenTouchInterval).Format(timeLayout) {
+		if err := mysql.UpdateAPITokenLastUsed(t.ID, now); err != nil {
+			zap.L().Warn("update api token last used failed", zap.Int64("id", t.ID), zap.Error(err))
+		}
+		t.LastUsedAt = now
+	}
+	return t, nil
+}
+
+// ScopeAllows 判断令牌范围是否包含 perm, admin 范围包含全部权限
+func ScopeAllows(scopes []string, perm string) bool {
+	for _, s := range scopes {
+		if s == perm || s == models.PermAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	auditPageSize     = 20
+	auditExportLimit  = 10000 // 单次导出的最大条数
+	auditMessageLimit = 512
+	auditDetailLimit  = 4096
+)
+
+// RecordAudit 记录审计日志, 写入失败只记录错误, 不影响请求
+func RecordAudit(l *models.AuditLog) {
+	if l.Username == "" && l.UserID != 0 {
+		if user, err := mysql.GetUserByID(l.UserID); err == nil {
+			l.Username = user.Username
+		}
+	}
+	l.Message = truncateUTF8(l.Message, auditMessageLimit)
+	l.Detail = truncateUTF8(l.Detail, auditDetailLimit)
+	if err := mysql.AddAuditLog(l); err != nil {
+		zap.L().Error("mysql.AddAuditLog failed",
+			zap.String("action", l.Action), zap.Int64("userId", l.UserID), zap.String("outcome", l.Outcome), zap.Error(err))
+	}
+}
+
+// GetAuditLogs 分页查询审计日志
+func GetAuditLogs(p *models.ParamAuditQuery) ([]models.AuditLog, int64, error) {
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 {
+		p.Size = auditPageSize
+	}
+	return mysql.GetAuditLogs(p, (p.Page-1)*p.Size, p.Size)
+}
+
+// ExportAuditLogs 按查询条件导出审计日志 (csv / json), 最多导出 auditExportLimit 条
+func ExportAuditLogs(p *models.ParamAuditQuery, w io.Writer) error {
+	logs, _, err := mysql.GetAuditLogs(p, 0, auditExportLimit)
+	if err != nil {
+		return err
+	}
+	if p.Format == "json" {
+		return json.NewEncoder(w).Encode(logs)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "create_time", "user_id", "username", "ip", "method", "path", "action",
+		"node_id", "view_id", "job_name", "build_number", "outcome", "status", "jenkins_status", "message", "detail"})
+	for _, l := range logs {
+		cw.Write([]string{
+			strconv.FormatInt(l.ID, 10), l.CreateTime, strconv.FormatInt(l.UserID, 10), csvCell(l.Username), csvCell(l.IP),
+			csvCell(l.Method), csvCell(l.Path), csvCell(l.Action), strconv.Itoa(l.NodeID), csvCell(l.ViewID), csvCell(l.JobName),
+			strconv.FormatInt(l.BuildNumber, 10), csvCell(l.Outcome), strconv.Itoa(l.Status), strconv.Itoa(l.JenkinsStatus),
+			csvCell(l.Message), csvCell(l.Detail),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell 防止 CSV 注入: 以公式字符开头的内容前加单引号, 避免在表格软件中被当作公式执行
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// truncateUTF8 按字节截断字符串, 不截断多字节字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
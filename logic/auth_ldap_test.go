@@ -0,0 +1,270 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/snowflake"
+	"bluebell/setting"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testLDAPBaseDN       = "ou=people,dc=example,dc=com"
+	testLDAPBindDN       = "cn=svc,dc=example,dc=com"
+	testLDAPBindPassword = "svc-secret"
+)
+
+type fakeLDAPEntry struct {
+	uid      string
+	password string
+	groups   []string
+}
+
+func (e fakeLDAPEntry) dn() string {
+	return fmt.Sprintf("uid=%s,%s", e.uid, testLDAPBaseDN)
+}
+
+// fakeLDAP 进程内的 LDAP 服务替身, 只实现认证用到的 Bind / Search / Close
+// Search 按编译后的过滤器匹配, 支持 (attr=value) 和 (attr=*), 其他过滤器返回错误
+type fakeLDAP struct {
+	ldap.Client
+	entries []fakeLDAPEntry
+	binds   []string
+	filters []string
+}
+
+func (f *fakeLDAP) Close() {}
+
+func (f *fakeLDAP) Bind(dn, password string) error {
+	f.binds = append(f.binds, dn)
+	if dn == testLDAPBindDN && password == testLDAPBindPassword {
+		return nil
+	}
+	for _, e := range f.entries {
+		if e.dn() == dn && e.password == password {
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (f *fakeLDAP) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	f.filters = append(f.filters, req.Filter)
+	packet, err := ldap.CompileFilter(req.Filter)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, err)
+	}
+	match := func(e fakeLDAPEntry) bool { return false }
+	switch packet.Tag {
+	case ldap.FilterEqualityMatch:
+		attr, value := packet.Children[0].Data.String(), packet.Children[1].Data.String()
+		match = func(e fakeLDAPEntry) bool { return attr == "uid" && e.uid == value }
+	case ldap.FilterPresent:
+		attr := packet.Data.String()
+		match = func(e fakeLDAPEntry) bool { return attr == "uid" }
+	default:
+		return nil, ldap.NewError(ldap.LDAPResultUnwillingToPerform, fmt.Errorf("unsupported filter %s", req.Filter))
+	}
+
+	res := &ldap.SearchResult{}
+	for _, e := range f.entries {
+		if match(e) {
+			res.Entries = append(res.Entries, ldap.NewEntry(e.dn(), map[string][]string{"memberOf": e.groups}))
+		}
+	}
+	return res, nil
+}
+
+// useFakeLDAP 将 LDAP 连接替换为 fakeLDAP, 并启用 LDAP 认证
+func useFakeLDAP(t *testing.T, groupRoles []setting.GroupRole, entries ...fakeLDAPEntry) *fakeLDAP {
+	t.Helper()
+	f := &fakeLDAP{entries: entries}
+	old := dialLDAP
+	dialLDAP = func(*setting.LDAPConfig) (ldap.Client, error) { return f, nil }
+	t.Cleanup(func() { dialLDAP = old })
+	setAuthConfig(t, &setting.AuthConfig{
+		Providers: []string{ProviderLDAP},
+		LDAPConfig: &setting.LDAPConfig{
+			URL:          "ldap://ldap.example.com",
+			BindDN:       testLDAPBindDN,
+			BindPassword: testLDAPBindPassword,
+			BaseDN:       testLDAPBaseDN,
+			GroupRoles:   groupRoles,
+		},
+	})
+	return f
+}
+
+func TestLDAPBind(t *testing.T) {
+	alice := fakeLDAPEntry{uid: uniqueName("alice"), password: "alice-pw"}
+	f := useFakeLDAP(t, nil, alice)
+
+	user, err := authenticate(alice.uid, "alice-pw")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if user.Username != alice.uid || user.Source != models.UserSourceLDAP {
+		t.Errorf("user = %s (%s), want %s (ldap)", user.Username, user.Source, alice.uid)
+	}
+	// 先以服务账号绑定查询, 再以用户的 DN 绑定校验密码
+	if want := []string{testLDAPBindDN, alice.dn()}; !reflect.DeepEqual(f.binds, want) {
+		t.Errorf("binds = %v, want %v", f.binds, want)
+	}
+
+	if _, err := authenticate(alice.uid, "wrong"); err != mysql.ErrorInvalidPassword {
+		t.Errorf("wrong password: err = %v, want ErrorInvalidPassword", err)
+	}
+
+	// 空密码会被 LDAP 当作匿名绑定, 不能连接服务
+	f.binds = nil
+	if _, err := authenticate(alice.uid, ""); err != mysql.ErrorInvalidPassword {
+		t.Errorf("empty password: err = %v, want ErrorInvalidPassword", err)
+	}
+	if len(f.binds) != 0 {
+		t.Errorf("empty password should not bind, binds = %v", f.binds)
+	}
+
+	if _, err := authenticate(uniqueName("nobody"), "pw"); err != mysql.ErrorUserNotExist {
+		t.Errorf("unknown user: err = %v, want ErrorUserNotExist", err)
+	}
+}
+
+func TestLDAPServiceBindFailed(t *testing.T) {
+	alice := fakeLDAPEntry{uid: uniqueName("alice"), password: "alice-pw"}
+	useFakeLDAP(t, nil, alice)
+	setting.Conf.AuthConfig.LDAPConfig.BindPassword = "wrong"
+
+	_, err := authenticate(alice.uid, "alice-pw")
+	if err == nil || err == mysql.ErrorInvalidPassword {
+		t.Fatalf("err = %v, want service bind error", err)
+	}
+}
+
+func TestLDAPFilterEscaping(t *testing.T) {
+	alice := fakeLDAPEntry{uid: uniqueName("alice"), password: "pw"}
+	bob := fakeLDAPEntry{uid: uniqueName("bob"), password: "pw"}
+	tests := []struct {
+		username string
+		filter   string
+	}{
+		{"*", `(uid=\2a)`},
+		{alice.uid + ")(uid=*", `(uid=` + alice.uid + `\29\28uid=\2a)`},
+		{alice.uid[:3] + "*", `(uid=` + alice.uid[:3] + `\2a)`},
+		{`\`, `(uid=\5c)`},
+		{"a\x00b", `(uid=a\00b)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f := useFakeLDAP(t, nil, alice, bob)
+			_, err := authenticate(tt.username, "pw")
+			if err != mysql.ErrorUserNotExist {
+				t.Errorf("err = %v, want ErrorUserNotExist", err)
+			}
+			if len(f.filters) != 1 || f.filters[0] != tt.filter {
+				t.Errorf("filters = %q, want %q", f.filters, tt.filter)
+			}
+		})
+	}
+}
+
+func TestLDAPGroupRoles(t *testing.T) {
+	dev := addTestRole(t, uniqueName("dev"), models.PermBuild)
+	ops := addTestRole(t, uniqueName("ops"), models.PermStop)
+	groupRoles := []setting.GroupRole{
+		{Group: "cn=Developers,ou=groups,dc=example,dc=com", Role: dev.Name}, // 完整的 DN
+		{Group: "ops", Role: ops.Name},                                       // 组的 CN
+		{Group: "ops", Role: ops.Name},                                       // 重复的映射只分配一次
+		{Group: "qa", Role: uniqueName("missing")},                           // 角色不存在时忽略
+	}
+	carol := fakeLDAPEntry{
+		uid:      uniqueName("carol"),
+		password: "pw",
+		groups: []string{
+			"CN=developers,OU=Groups,DC=example,DC=com",
+			"cn=Ops,ou=groups,dc=example,dc=com",
+			"cn=qa,ou=groups,dc=example,dc=com",
+		},
+	}
+	f := useFakeLDAP(t, groupRoles, carol)
+
+	user, err := authenticate(carol.uid, "pw")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	got := userRoleNames(t, user.UserID)
+	want := []string{dev.Name, ops.Name}
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("roles = %v, want %v", got, want)
+	}
+
+	// 每次登录按组重新同步, 移出的组对应的角色被收回
+	f.entries[0].groups = []string{"cn=ops,ou=groups,dc=example,dc=com"}
+	if _, err := authenticate(carol.uid, "pw"); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if got := userRoleNames(t, user.UserID); !reflect.DeepEqual(got, []string{ops.Name}) {
+		t.Errorf("roles after regroup = %v, want [%s]", got, ops.Name)
+	}
+}
+
+func TestLDAPDisabledUser(t *testing.T) {
+	dave := fakeLDAPEntry{uid: uniqueName("dave"), password: "pw"}
+	useFakeLDAP(t, nil, dave)
+	p := &models.ParamLogin{Username: dave.uid, Password: "pw"}
+
+	user, tokens, err := Login(p, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if tokens.AccessToken == "" {
+		t.Error("Login returned empty access token")
+	}
+	if err := mysql.SetUserDisabled(user.UserID, true); err != nil {
+		t.Fatal(err)
+	}
+	// LDAP 中的密码仍然正确, 但本地已禁用的用户不能登录
+	if _, _, err := Login(p, "192.0.2.1"); err != ErrorUserDisabled {
+		t.Errorf("err = %v, want ErrorUserDisabled", err)
+	}
+}
+
+// 用户名与本地用户相同时不能接管本地账号
+func TestLDAPSourceConflict(t *testing.T) {
+	name := uniqueName("local")
+	if err := mysql.InsertUser(&models.User{UserID: snowflake.GenID(), Username: name, Password: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	useFakeLDAP(t, nil, fakeLDAPEntry{uid: name, password: "pw"})
+	if _, err := authenticate(name, "pw"); !errors.Is(err, ErrorUserSourceConflict) {
+		t.Errorf("err = %v, want ErrorUserSourceConflict", err)
+	}
+}
+
+func TestInLDAPGroups(t *testing.T) {
+	groups := []string{"cn=Developers,ou=groups,dc=example,dc=com", "not a dn"}
+	tests := []struct {
+		group string
+		want  bool
+	}{
+		{"developers", true},
+		{"CN=developers,OU=groups,DC=example,DC=com", true},
+		{"cn=developers,ou=groups,dc=example,dc=com", true},
+		{"groups", false}, // 只比较第一段 RDN 的 CN
+		{"example", false},
+		{"not a dn", true},
+		{"ops", false},
+	}
+	for _, tt := range tests {
+		if got := inLDAPGroups(tt.group, groups); got != tt.want {
+			t.Errorf("inLDAPGroups(%q) = %v, want %v", tt.group, got, tt.want)
+		}
+	}
+}
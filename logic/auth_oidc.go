@@ -0,0 +1,518 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/snowflake"
+	"bluebell/setting"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcStateExpire      = 10 * time.Minute
+	oidcClockSkew        = time.Minute
+	defaultOIDCTimeout   = 10 * time.Second
+	defaultUsernameClaim = "preferred_username"
+	defaultGroupsClaim   = "groups"
+)
+
+var (
+	ErrorOIDCDisabled     = errors.New("未启用 OIDC 单点登录")
+	ErrorInvalidOIDCState = errors.New("OIDC 登录状态无效或已过期")
+	ErrorInvalidIDToken   = errors.New("ID token 无效")
+)
+
+// oidcProvider IdP 的发现文档和签名公钥, 按 issuer 缓存
+// 公钥在遇到未知的 kid 时重新获取, 以支持 IdP 轮换密钥
+type oidcProvider struct {
+	issuer        string
+	authEndpoint  string
+	tokenEndpoint string
+	jwksURI       string
+
+	mu   sync.Mutex
+	keys map[string]interface{}
+}
+
+var (
+	oidcMu     sync.Mutex
+	oidcCached *oidcProvider
+)
+
+func oidcConfig() (*setting.OIDCConfig, error) {
+	if setting.Conf == nil || setting.Conf.AuthConfig == nil || setting.Conf.AuthConfig.OIDCConfig == nil {
+		return nil, ErrorOIDCDisabled
+	}
+	conf := setting.Conf.AuthConfig.OIDCConfig
+	if conf.Issuer == "" || conf.ClientID == "" || conf.RedirectURL == "" {
+		return nil, ErrorOIDCDisabled
+	}
+	return conf, nil
+}
+
+func oidcClient(conf *setting.OIDCConfig) *http.Client {
+	timeout := defaultOIDCTimeout
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// getOIDCProvider 获取 IdP 的发现文档, 首次使用时请求 /.well-known/openid-configuration
+func getOIDCProvider(conf *setting.OIDCConfig) (*oidcProvider, error) {
+	issuer := strings.TrimSuffix(conf.Issuer, "/")
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcCached != nil && oidcCached.issuer == issuer {
+		return oidcCached, nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JwksURI               string `json:"jwks_uri"`
+	}
+	if err := oidcGetJSON(conf, issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("获取 OIDC 发现文档失败: %v", err)
+	}
+	// 发现文档中的 issuer 必须与配置一致, 防止被替换为其他 IdP
+	if strings.TrimSuffix(doc.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC 发现文档的 issuer [%s] 与配置不一致", doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JwksURI == "" {
+		return nil, errors.New("OIDC 发现文档缺少必要的端点")
+	}
+	oidcCached = &oidcProvider{
+		issuer:        doc.Issuer,
+		authEndpoint:  doc.AuthorizationEndpoint,
+		tokenEndpoint: doc.TokenEndpoint,
+		jwksURI:       doc.JwksURI,
+	}
+	return oidcCached, nil
+}
+
+func oidcGetJSON(conf *setting.OIDCConfig, u string, v interface{}) error {
+	resp, err := oidcClient(conf).Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// key 按 kid 获取签名公钥, 未知的 kid 重新获取一次 JWKS
+func (p *oidcProvider) key(conf *setting.OIDCConfig, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	keys, err := fetchJWKS(conf, p.jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown kid [%s]", kid)
+}
+
+// fetchJWKS 获取 IdP 的签名公钥, 支持 RSA 和 EC 密钥
+func fetchJWKS(conf *setting.OIDCConfig, jwksURI string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := oidcGetJSON(conf, jwksURI, &set); err != nil {
+		return nil, fmt.Errorf("获取 OIDC 签名公钥失败: %v", err)
+	}
+
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := decodeBigInt(k.N)
+			e, err2 := decodeBigInt(k.E)
+			if err1 != nil || err2 != nil || !e.IsInt64() {
+				zap.L().Warn("invalid oidc rsa key", zap.String("kid", k.Kid))
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err1 := decodeBigInt(k.X)
+			y, err2 := decodeBigInt(k.Y)
+			if err1 != nil || err2 != nil || !curve.IsOnCurve(x, y) {
+				zap.L().Warn("invalid oidc ec key", zap.String("kid", k.Kid))
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// OIDCStateCookie 保存 state 哈希的 cookie, 回调时校验 state 来自发起登录的同一个浏览器, 防止登录 CSRF
+const OIDCStateCookie = "oidc_state"
+
+// OIDCStateCookieMaxAge state cookie 的有效期 (秒), 与 state 的有效期一致
+const OIDCStateCookieMaxAge = int(oidcStateExpire / time.Second)
+
+// OIDCStateBinding 写入 state cookie 的值
+func OIDCStateBinding(state string) string {
+	return hashToken(state)
+}
+
+// OIDCSecureCookie 回调地址使用 https 时 state cookie 只通过 https 发送
+func OIDCSecureCookie() bool {
+	conf, err := oidcConfig()
+	return err == nil && strings.HasPrefix(strings.ToLower(conf.RedirectURL), "https://")
+}
+
+// OIDCAuthURL 发起 OIDC 登录, 保存 state / nonce / PKCE code_verifier 并返回 IdP 的授权地址和 state
+// 调用方需要将 OIDCStateBinding(state) 写入浏览器的 cookie, 回调时一起传给 OIDCCallback
+func OIDCAuthURL() (authURL, state string, err error) {
+	conf, err := oidcConfig()
+	if err != nil {
+		return "", "", err
+	}
+	p, err := getOIDCProvider(conf)
+	if err != nil {
+		return "", "", err
+	}
+	s := &models.OIDCState{ExpiresAt: time.Now().Add(oidcStateExpire).Format(timeLayout)}
+	for _, v := range []*string{&s.State, &s.Nonce, &s.CodeVerifier} {
+		if *v, err = randomToken(); err != nil {
+			return "", "", err
+		}
+	}
+	if err := mysql.AddOIDCState(s); err != nil {
+		return "", "", err
+	}
+
+	scopes := conf.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	challenge := sha256.Sum256([]byte(s.CodeVerifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", conf.ClientID)
+	q.Set("redirect_uri", conf.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", s.State)
+	q.Set("nonce", s.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.authEndpoint, "?") {
+		sep = "&"
+	}
+	return p.authEndpoint + sep + q.Encode(), s.State, nil
+}
+
+// OIDCCallback 处理 IdP 的回调: 校验 state 及其与浏览器 cookie (binding) 的绑定, 用授权码和 code_verifier 换取 ID token,
+// 校验 ID token 后按 iss + sub 映射本地用户和角色, 并签发本服务的 token
+func OIDCCallback(code, state, binding string) (*models.User, *models.TokenPair, error) {
+	conf, err := oidcConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	if binding == "" || subtle.ConstantTimeCompare([]byte(binding), []byte(OIDCStateBinding(state))) != 1 {
+		return nil, nil, ErrorInvalidOIDCState
+	}
+	s, err := mysql.TakeOIDCState(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil || s.ExpiresAt < time.Now().Format(timeLayout) {
+		return nil, nil, ErrorInvalidOIDCState
+	}
+	p, err := getOIDCProvider(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawIDToken, err := exchangeOIDCCode(conf, p, code, s.CodeVerifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	claims, err := verifyIDToken(conf, p, rawIDToken, s.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := provisionOIDCUser(conf, p.issuer, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	username := user.Username
+	if user.Disabled {
+		return nil, nil, ErrorUserDisabled
+	}
+
+	groupsClaim := conf.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+	groups := claimStrings(claims[groupsClaim])
+	match := func(group string) bool {
+		for _, g := range groups {
+			if strings.EqualFold(g, group) {
+				return true
+			}
+		}
+		return false
+	}
+	if err := syncGroupRoles(user.UserID, conf.GroupRoles, match); err != nil {
+		zap.L().Warn("sync oidc roles failed", zap.String("username", username), zap.Error(err))
+	}
+
+	// 与密码登录相同, 开启了两步验证的用户完成验证后才签发 token
+	if err := requireSecondFactor(user); err != nil {
+		return nil, nil, err
+	}
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// provisionOIDCUser 按 iss + sub 查找关联的本地用户, 首次登录时创建用户并关联
+// username_claim (默认 preferred_username) 可以被 IdP 的用户自行修改, 只用作新用户的用户名和昵称, 不用于匹配已有用户
+func provisionOIDCUser(conf *setting.OIDCConfig, issuer string, claims jwt.MapClaims) (*models.User, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, ErrorInvalidIDToken
+	}
+	user, err := mysql.GetUserByIdentity(issuer, sub)
+	if err == nil {
+		return user, nil
+	}
+	if err != mysql.ErrorUserNotExist {
+		return nil, err
+	}
+
+	usernameClaim := conf.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = defaultUsernameClaim
+	}
+	display, _ := claims[usernameClaim].(string)
+	if display == "" {
+		return nil, fmt.Errorf("ID token 缺少声明 [%s]", usernameClaim)
+	}
+	// 用户名已被其他用户 (本地用户或其他 IdP 账号) 占用时加上由 iss + sub 生成的后缀, 不会关联到已有用户
+	username := display
+	if err := mysql.CheckUserExist(username); err != nil {
+		if err != mysql.ErrorUserExist {
+			return nil, err
+		}
+		username = display + "-" + hashToken(issuer + "\x00" + sub)[:8]
+	}
+	user = &models.User{
+		UserID:   snowflake.GenID(),
+		Username: username,
+		Nickname: display,
+		Source:   models.UserSourceOIDC,
+	}
+	if err := mysql.InsertUserWithIdentity(user, issuer, sub); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// exchangeOIDCCode 使用授权码换取 token, 返回 ID token
+func exchangeOIDCCode(conf *setting.OIDCConfig, p *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", conf.RedirectURL)
+	form.Set("client_id", conf.ClientID)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequest(http.MethodPost, p.tokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if conf.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(conf.ClientID), url.QueryEscape(conf.ClientSecret))
+	}
+	resp, err := oidcClient(conf).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", fmt.Errorf("解析 token 响应失败: %s", resp.Status)
+	}
+	if res.Error != "" {
+		return "", fmt.Errorf("换取 token 失败: %s %s", res.Error, res.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || res.IDToken == "" {
+		return "", fmt.Errorf("换取 token 失败: %s", resp.Status)
+	}
+	return res.IDToken, nil
+}
+
+// verifyIDToken 校验 ID token 的签名, iss, aud, exp 和 nonce
+func verifyIDToken(conf *setting.OIDCConfig, p *oidcProvider, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		// 只接受非对称签名, 拒绝 none 和 HMAC
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method [%s]", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(conf, kid)
+	})
+	if err != nil {
+		zap.L().Warn("parse id token failed", zap.Error(err))
+		return nil, ErrorInvalidIDToken
+	}
+
+	now := time.Now()
+	if iss, _ := claims["iss"].(string); iss != p.issuer {
+		return nil, ErrorInvalidIDToken
+	}
+	aud := claimStrings(claims["aud"])
+	audOK := false
+	for _, a := range aud {
+		if a == conf.ClientID {
+			audOK = true
+		}
+	}
+	if !audOK {
+		return nil, ErrorInvalidIDToken
+	}
+	// 存在多个 aud 时 azp 必须是本客户端
+	if azp, ok := claims["azp"].(string); ok && len(aud) > 1 && azp != conf.ClientID {
+		return nil, ErrorInvalidIDToken
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-oidcClockSkew).Unix() > int64(exp) {
+		return nil, ErrorInvalidIDToken
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Unix() < int64(nbf) {
+		return nil, ErrorInvalidIDToken
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, ErrorInvalidIDToken
+	}
+	return claims, nil
+}
+
+// claimStrings 将字符串或字符串数组类型的声明转换为 []string
+func claimStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		res := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+// OIDCFrontendRedirect 登录成功后跳转的前端地址, token 放在 fragment 中不会发送到服务端; 未配置 frontend_url 时返回空字符串
+func OIDCFrontendRedirect(tokens *models.TokenPair) string {
+	q := url.Values{}
+	q.Set("accessToken", tokens.AccessToken)
+	q.Set("refreshToken", tokens.RefreshToken)
+	q.Set("expires", tokens.Expires)
+	return oidcFrontendURL(q)
+}
+
+// OIDCFrontendChallengeRedirect 需要两步验证时跳转到前端的地址, 前端使用 challenge 调用 /login/2fa 完成登录
+// 没有配置 frontend_url 时返回空字符串
+func OIDCFrontendChallengeRedirect(e *TwoFactorRequiredError) string {
+	q := url.Values{}
+	q.Set("challenge", e.Challenge)
+	q.Set("enroll", strconv.FormatBool(e.Enroll))
+	return oidcFrontendURL(q)
+}
+
+func oidcFrontendURL(q url.Values) string {
+	conf, err := oidcConfig()
+	if err != nil || conf.FrontendURL == "" {
+		return ""
+	}
+	// 前端使用 hash 路由时地址中已经有 fragment, 参数追加在 fragment 内
+	if strings.Contains(conf.FrontendURL, "#") {
+		return conf.FrontendURL + "?" + q.Encode()
+	}
+	return conf.FrontendURL + "#" + q.Encode()
+}
@@ -0,0 +1,478 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/snowflake"
+	"bluebell/setting"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testOIDCClientID = "bluebell"
+
+var (
+	testRSAKeysOnce sync.Once
+	testRSAKeys     [3]*rsa.PrivateKey
+)
+
+// testRSAKey 测试用的 RSA 密钥, 只生成一次
+func testRSAKey(t *testing.T, i int) *rsa.PrivateKey {
+	t.Helper()
+	testRSAKeysOnce.Do(func() {
+		for i := range testRSAKeys {
+			k, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				panic(err)
+			}
+			testRSAKeys[i] = k
+		}
+	})
+	return testRSAKeys[i]
+}
+
+type mockIdPCode struct {
+	nonce     string
+	challenge string
+}
+
+// mockIdP 进程内的 OIDC IdP, 提供发现文档、JWKS 和 token 端点
+// token 端点校验 PKCE, 授权码只能使用一次, 返回 idToken 生成的 ID token
+type mockIdP struct {
+	*httptest.Server
+	t *testing.T
+
+	mu       sync.Mutex
+	jwks     map[string]*rsa.PrivateKey // JWKS 中发布的密钥
+	codes    map[string]mockIdPCode
+	jwksHits int
+	idToken  func(nonce string) string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	idp := &mockIdP{
+		t:     t,
+		jwks:  map[string]*rsa.PrivateKey{"k1": testRSAKey(t, 0)},
+		codes: make(map[string]mockIdPCode),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		idp.jwksHits++
+		keys := make([]map[string]string, 0, len(idp.jwks))
+		for kid, k := range idp.jwks {
+			keys = append(keys, map[string]string{
+				"kid": kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		c, ok := idp.codes[r.PostForm.Get("code")]
+		delete(idp.codes, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != c.challenge ||
+			r.PostForm.Get("client_id") != testOIDCClientID {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.idToken(c.nonce)})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	setAuthConfig(t, &setting.AuthConfig{OIDCConfig: &setting.OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    testOIDCClientID,
+		RedirectURL: "http://bluebell.example.com/oidc/callback",
+	}})
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcCached = nil
+		oidcMu.Unlock()
+	})
+	return idp
+}
+
+// authorize 模拟用户在 IdP 完成登录, 返回授权码和 state
+func (idp *mockIdP) authorize(authURL string) (code, state string) {
+	idp.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testOIDCClientID {
+		idp.t.Fatalf("unexpected authorize request: %s", authURL)
+	}
+	code = uniqueName("code")
+	idp.mu.Lock()
+	idp.codes[code] = mockIdPCode{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	idp.mu.Unlock()
+	return code, q.Get("state")
+}
+
+// claims 有效的 ID token 声明
+func (idp *mockIdP) claims(nonce, username string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                idp.URL,
+		"sub":                username,
+		"aud":                testOIDCClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              nonce,
+		"preferred_username": username,
+	}
+}
+
+// sign 使用 RSA 密钥按 RS256 签名, kid 写入头部
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// login 走完一次 OIDC 登录, idToken 根据 IdP 收到的 nonce 生成 ID token
+func (idp *mockIdP) login(idToken func(nonce string) string) (*models.User, *models.TokenPair, error) {
+	idp.t.Helper()
+	authURL, state, err := OIDCAuthURL()
+	if err != nil {
+		idp.t.Fatalf("OIDCAuthURL: %v", err)
+	}
+	code, got := idp.authorize(authURL)
+	if got != state {
+		idp.t.Fatalf("state in auth url = %q, want %q", got, state)
+	}
+	idp.idToken = idToken
+	return OIDCCallback(code, state, OIDCStateBinding(state))
+}
+
+// validToken 使用 k1 签名的有效 ID token, mutate 用于修改声明
+func (idp *mockIdP) validToken(username string, mutate func(jwt.MapClaims)) func(nonce string) string {
+	return func(nonce string) string {
+		claims := idp.claims(nonce, username)
+		if mutate != nil {
+			mutate(claims)
+		}
+		return sign(idp.t, testRSAKey(idp.t, 0), "k1", claims)
+	}
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("erin")
+
+	user, tokens, err := idp.login(idp.validToken(username, nil))
+	if err != nil {
+		t.Fatalf("OIDCCallback: %v", err)
+	}
+	if user.Username != username || user.Source != models.UserSourceOIDC {
+		t.Errorf("user = %s (%s), want %s (oidc)", user.Username, user.Source, username)
+	}
+	if tokens == nil || tokens.AccessToken == "" {
+		t.Error("OIDCCallback returned empty tokens")
+	}
+
+	// 再次登录使用同一个本地用户
+	again, _, err := idp.login(idp.validToken(username, nil))
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.UserID != user.UserID {
+		t.Errorf("second login user = %d, want %d", again.UserID, user.UserID)
+	}
+}
+
+func TestOIDCStateReuse(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("frank")
+
+	authURL, state, err := OIDCAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := idp.authorize(authURL)
+	idp.idToken = idp.validToken(username, nil)
+	if _, _, err := OIDCCallback(code, state, OIDCStateBinding(state)); err != nil {
+		t.Fatalf("first callback: %v", err)
+	}
+
+	// state 使用后立即作废, 重放回调 (即使带上新的授权码) 也会失败
+	idp.mu.Lock()
+	idp.codes["replayed"] = mockIdPCode{}
+	idp.mu.Unlock()
+	if _, _, err := OIDCCallback("replayed", state, OIDCStateBinding(state)); !errors.Is(err, ErrorInvalidOIDCState) {
+		t.Errorf("reused state: err = %v, want ErrorInvalidOIDCState", err)
+	}
+	unknown := uniqueName("state")
+	if _, _, err := OIDCCallback(code, unknown, OIDCStateBinding(unknown)); !errors.Is(err, ErrorInvalidOIDCState) {
+		t.Errorf("unknown state: err = %v, want ErrorInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCStateBinding(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("fiona")
+
+	authURL, state, err := OIDCAuthURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := idp.authorize(authURL)
+	idp.idToken = idp.validToken(username, nil)
+
+	// 攻击者把自己的回调地址发给受害者时, 受害者的浏览器没有对应的 state cookie
+	other := uniqueName("state")
+	for name, binding := range map[string]string{
+		"missing cookie":        "",
+		"cookie of other state": OIDCStateBinding(other),
+		"raw state as cookie":   state,
+	} {
+		if _, _, err := OIDCCallback(code, state, binding); !errors.Is(err, ErrorInvalidOIDCState) {
+			t.Errorf("%s: err = %v, want ErrorInvalidOIDCState", name, err)
+		}
+	}
+	if _, err := mysql.GetUserByUsername(username); err != mysql.ErrorUserNotExist {
+		t.Errorf("user created without a bound state: err = %v", err)
+	}
+
+	// 绑定校验失败不会消耗 state, 发起登录的浏览器仍然可以完成登录
+	if _, _, err := OIDCCallback(code, state, OIDCStateBinding(state)); err != nil {
+		t.Fatalf("callback with bound state: %v", err)
+	}
+}
+
+func TestOIDCLinksBySubject(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("gabe")
+	withSub := func(sub, name string) func(nonce string) string {
+		return idp.validToken(name, func(c jwt.MapClaims) { c["sub"] = sub })
+	}
+
+	owner, _, err := idp.login(withSub("sub-owner-"+username, username))
+	if err != nil {
+		t.Fatalf("owner login: %v", err)
+	}
+	if owner.Username != username || owner.Nickname != username {
+		t.Errorf("owner = %s (%s), want %s", owner.Username, owner.Nickname, username)
+	}
+
+	// 另一个 IdP 账号把 preferred_username 改成相同的值, 不会登录到已有用户
+	other, _, err := idp.login(withSub("sub-other-"+username, username))
+	if err != nil {
+		t.Fatalf("other login: %v", err)
+	}
+	if other.UserID == owner.UserID {
+		t.Fatal("login with a different sub was linked to the existing user")
+	}
+	if other.Username == username || other.Nickname != username {
+		t.Errorf("other = %s (%s), want a suffixed username with nickname %s", other.Username, other.Nickname, username)
+	}
+
+	// 同一个 sub 修改 preferred_username 后仍然是原来的用户
+	renamed, _, err := idp.login(withSub("sub-owner-"+username, uniqueName("renamed")))
+	if err != nil {
+		t.Fatalf("renamed login: %v", err)
+	}
+	if renamed.UserID != owner.UserID || renamed.Username != username {
+		t.Errorf("renamed user = %d (%s), want %d (%s)", renamed.UserID, renamed.Username, owner.UserID, username)
+	}
+
+	// 同名的本地用户也不会被关联
+	local := uniqueName("hana")
+	if err := mysql.InsertUser(&models.User{UserID: snowflake.GenID(), Username: local, Password: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	user, _, err := idp.login(withSub("sub-"+local, local))
+	if err != nil {
+		t.Fatalf("login as local username: %v", err)
+	}
+	if user.Username == local || user.Source != models.UserSourceOIDC {
+		t.Errorf("user = %s (%s), want a new oidc user", user.Username, user.Source)
+	}
+
+	// 缺少 sub 的 ID token 无效
+	if _, _, err := idp.login(idp.validToken(uniqueName("ivan"), func(c jwt.MapClaims) { delete(c, "sub") })); !errors.Is(err, ErrorInvalidIDToken) {
+		t.Errorf("missing sub: err = %v, want ErrorInvalidIDToken", err)
+	}
+}
+
+func TestOIDCExpiredState(t *testing.T) {
+	idp := newMockIdP(t)
+	s := &models.OIDCState{
+		State:        uniqueName("state"),
+		Nonce:        "n",
+		CodeVerifier: "v",
+		ExpiresAt:    time.Now().Add(-time.Second).Format(timeLayout),
+	}
+	if err := mysql.AddOIDCState(s); err != nil {
+		t.Fatal(err)
+	}
+	idp.idToken = idp.validToken(uniqueName("gina"), nil)
+	if _, _, err := OIDCCallback("code", s.State, OIDCStateBinding(s.State)); !errors.Is(err, ErrorInvalidOIDCState) {
+		t.Errorf("err = %v, want ErrorInvalidOIDCState", err)
+	}
+}
+
+func TestOIDCInvalidIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("henry")
+	tests := []struct {
+		name    string
+		idToken func(nonce string) string
+	}{
+		{"wrong nonce", idp.validToken(username, func(c jwt.MapClaims) { c["nonce"] = "other" })},
+		{"missing nonce", idp.validToken(username, func(c jwt.MapClaims) { delete(c, "nonce") })},
+		{"wrong aud", idp.validToken(username, func(c jwt.MapClaims) { c["aud"] = "other-client" })},
+		{"aud list without client", idp.validToken(username, func(c jwt.MapClaims) { c["aud"] = []string{"a", "b"} })},
+		{"azp of other client", idp.validToken(username, func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "other-client"}
+			c["azp"] = "other-client"
+		})},
+		{"wrong iss", idp.validToken(username, func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" })},
+		{"expired", idp.validToken(username, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() })},
+		{"missing exp", idp.validToken(username, func(c jwt.MapClaims) { delete(c, "exp") })},
+		{"not yet valid", idp.validToken(username, func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() })},
+		{"alg none", func(nonce string) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, idp.claims(nonce, username))
+			token.Header["kid"] = "k1"
+			s, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+		{"HS256 with public key", func(nonce string) string {
+			// 以 IdP 公钥作为 HMAC 密钥签名 (算法混淆攻击)
+			pub, err := x509.MarshalPKIXPublicKey(&testRSAKey(t, 0).PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims(nonce, username))
+			token.Header["kid"] = "k1"
+			s, err := token.SignedString(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+		{"signed by unpublished key", func(nonce string) string {
+			return sign(t, testRSAKey(t, 1), "k1", idp.claims(nonce, username))
+		}},
+		{"unknown kid", func(nonce string) string {
+			return sign(t, testRSAKey(t, 1), "k9", idp.claims(nonce, username))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := idp.login(tt.idToken); !errors.Is(err, ErrorInvalidIDToken) {
+				t.Errorf("err = %v, want ErrorInvalidIDToken", err)
+			}
+		})
+	}
+	// 校验失败时不创建本地用户
+	if _, err := mysql.GetUserByUsername(username); err != mysql.ErrorUserNotExist {
+		t.Errorf("GetUserByUsername: err = %v, want ErrorUserNotExist", err)
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	idp := newMockIdP(t)
+	username := uniqueName("iris")
+	if _, _, err := idp.login(idp.validToken(username, nil)); err != nil {
+		t.Fatalf("login with k1: %v", err)
+	}
+	if _, _, err := idp.login(idp.validToken(username, nil)); err != nil {
+		t.Fatalf("login with k1 again: %v", err)
+	}
+	if idp.jwksHits != 1 {
+		t.Errorf("jwks fetched %d times, want 1 (cached)", idp.jwksHits)
+	}
+
+	// IdP 轮换到 k2 并下线 k1, 遇到未知的 kid 时重新获取 JWKS
+	idp.mu.Lock()
+	idp.jwks = map[string]*rsa.PrivateKey{"k2": testRSAKey(t, 2)}
+	idp.mu.Unlock()
+	_, _, err := idp.login(func(nonce string) string {
+		return sign(t, testRSAKey(t, 2), "k2", idp.claims(nonce, username))
+	})
+	if err != nil {
+		t.Fatalf("login with rotated k2: %v", err)
+	}
+	if idp.jwksHits != 2 {
+		t.Errorf("jwks fetched %d times, want 2", idp.jwksHits)
+	}
+
+	// 已下线的 k1 不再被接受
+	if _, _, err := idp.login(idp.validToken(username, nil)); !errors.Is(err, ErrorInvalidIDToken) {
+		t.Errorf("login with retired k1: err = %v, want ErrorInvalidIDToken", err)
+	}
+}
+
+func TestOIDCIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	// 发现文档中的 issuer 与配置不一致时拒绝使用该 IdP
+	setting.Conf.AuthConfig.OIDCConfig.Issuer = idp.URL + "/realms/other"
+	if _, _, err := OIDCAuthURL(); err == nil {
+		t.Error("OIDCAuthURL succeeded with mismatched issuer")
+	}
+}
+
+func TestOIDCRequiresSecondFactor(t *testing.T) {
+	idp := newMockIdP(t)
+	role := addTestRole(t, uniqueName("2fa"), models.PermView)
+	if err := mysql.SetRoleRequire2FA(role.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	setting.Conf.AuthConfig.OIDCConfig.GroupRoles = []setting.GroupRole{{Group: "secure", Role: role.Name}}
+
+	username := uniqueName("jack")
+	_, tokens, err := idp.login(idp.validToken(username, func(c jwt.MapClaims) { c["groups"] = []string{"secure"} }))
+	var twoFactor *TwoFactorRequiredError
+	if !errors.As(err, &twoFactor) {
+		t.Fatalf("err = %v, want TwoFactorRequiredError", err)
+	}
+	if !twoFactor.Enroll || twoFactor.Challenge == "" {
+		t.Errorf("TwoFactorRequiredError = %+v, want enroll challenge", twoFactor)
+	}
+	if tokens != nil {
+		t.Error("tokens issued before the second factor")
+	}
+}
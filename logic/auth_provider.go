@@ -0,0 +1,183 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/password"
+	"bluebell/pkg/snowflake"
+	"bluebell/setting"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 登录认证方式
+const (
+	ProviderLocal = "local"
+	ProviderLDAP  = "ldap"
+)
+
+var ErrorUserSourceConflict = errors.New("用户名已被其他来源的用户占用")
+
+// AuthProvider 登录认证方式, 认证成功返回本地用户 (需要时自动创建)
+// 用户不属于该认证方式时返回 mysql.ErrorUserNotExist, 交给下一个认证方式处理
+type AuthProvider interface {
+	Name() string
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// authProviders 按配置的顺序返回认证方式, 未配置时只使用本地用户
+func authProviders() ([]AuthProvider, error) {
+	names := []string{ProviderLocal}
+	var ldapConf *setting.LDAPConfig
+	if setting.Conf != nil && setting.Conf.AuthConfig != nil {
+		if len(setting.Conf.AuthConfig.Providers) > 0 {
+			names = setting.Conf.AuthConfig.Providers
+		}
+		ldapConf = setting.Conf.AuthConfig.LDAPConfig
+	}
+
+	providers := make([]AuthProvider, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case ProviderLocal:
+			providers = append(providers, localProvider{})
+		case ProviderLDAP:
+			if ldapConf == nil || ldapConf.URL == "" {
+				return nil, fmt.Errorf("认证方式 [%s] 缺少配置", name)
+			}
+			providers = append(providers, &ldapProvider{conf: ldapConf})
+		default:
+			return nil, fmt.Errorf("不支持的认证方式 [%s]", name)
+		}
+	}
+	return providers, nil
+}
+
+// authenticate 依次尝试各认证方式
+// 密码错误时立即返回; 用户不存在或认证服务不可用时继续尝试下一个, 全部失败时返回最后一个错误
+func authenticate(username, plain string) (*models.User, error) {
+	providers, err := authProviders()
+	if err != nil {
+		return nil, err
+	}
+	lastErr := mysql.ErrorUserNotExist
+	for _, p := range providers {
+		user, err := p.Authenticate(username, plain)
+		if err == nil {
+			return user, nil
+		}
+		if err == mysql.ErrorInvalidPassword {
+			return nil, err
+		}
+		if err != mysql.ErrorUserNotExist {
+			zap.L().Warn("auth provider failed", zap.String("provider", p.Name()), zap.String("username", username), zap.Error(err))
+		}
+		if err != mysql.ErrorUserNotExist || lastErr == mysql.ErrorUserNotExist {
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
+
+// localProvider 本地用户, 校验数据库中保存的密码哈希
+type localProvider struct{}
+
+func (localProvider) Name() string { return ProviderLocal }
+
+func (localProvider) Authenticate(username, plain string) (*models.User, error) {
+	user, err := mysql.GetUserByUsername(username)
+	if err == nil && user.Source != models.UserSourceLocal {
+		// 外部认证方式创建的用户本地没有密码, 不能通过本地认证登录
+		err = mysql.ErrorUserNotExist
+	}
+	if err != nil {
+		if err == mysql.ErrorUserNotExist {
+			// 用户不存在时同样计算一次哈希, 避免通过响应时间判断用户名是否存在
+			dummyVerify(plain)
+		}
+		return nil, err
+	}
+	// 校验密码
+	ok, rehash, err := password.Verify(plain, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, mysql.ErrorInvalidPassword
+	}
+	// 早期的明文/MD5 密码或参数过期的哈希, 登录成功后按当前算法重新哈希
+	if rehash {
+		if err := upgradePasswordHash(user.UserID, plain); err != nil {
+			zap.L().Warn("upgrade password hash failed", zap.Int64("userId", user.UserID), zap.Error(err))
+		}
+	}
+	return user, nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func dummyVerify(plain string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = password.Hash("dummy password")
+	})
+	password.Verify(plain, dummyHash)
+}
+
+// provisionExternalUser 获取外部认证方式对应的本地用户, 首次登录时自动创建
+// 同名的其他来源用户不能被接管
+func provisionExternalUser(username, source string) (*models.User, error) {
+	user, err := mysql.GetUserByUsername(username)
+	if err == nil {
+		if user.Source != source {
+			return nil, ErrorUserSourceConflict
+		}
+		return user, nil
+	}
+	if err != mysql.ErrorUserNotExist {
+		return nil, err
+	}
+	user = &models.User{
+		UserID:   snowflake.GenID(),
+		Username: username,
+		Source:   source,
+	}
+	if err := mysql.InsertUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncGroupRoles 按外部认证方式返回的组同步用户的角色, match 判断用户是否属于某个组
+// 未配置组映射时保留本地分配的角色
+func syncGroupRoles(userID int64, groupRoles []setting.GroupRole, match func(group string) bool) error {
+	if len(groupRoles) == 0 {
+		return nil
+	}
+
+	roleIDs := make([]int64, 0)
+	seen := make(map[int64]bool)
+	for _, gr := range groupRoles {
+		if !match(gr.Group) {
+			continue
+		}
+		role, err := mysql.GetRoleByName(gr.Role)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			zap.L().Warn("group role not exist", zap.String("group", gr.Group), zap.String("role", gr.Role))
+			continue
+		}
+		if !seen[role.ID] {
+			seen[role.ID] = true
+			roleIDs = append(roleIDs, role.ID)
+		}
+	}
+	return mysql.SetUserRoles(userID, roleIDs)
+}
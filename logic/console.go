@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"bluebell/models"
+	"bluebell/pkg/console"
+	"bluebell/setting"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consoleTimeout 拉取构建日志的超时时间, 日志可能有数 MB
+const consoleTimeout = 60 * time.Second
+
+// getConsoleText 获取构建日志, buildNumber 为 0 时获取最新构建
+// Jenkins 上已没有该 Job 或构建时, 从归档中读取
+func getConsoleText(ctx context.Context, p *models.RequestJobData, buildNumber int64) (string, int64, error) {
+	node, err := GetNode(p.NodeID)
+	if err != nil {
+		return "", 0, err
+	}
+	jenkins, err := newJenkins(ctx, node, &http.Client{Timeout: consoleTimeout})
+	if err != nil {
+		return "", 0, err
+	}
+	job, err := getJob(ctx, jenkins, p.ViewID, p.JobName)
+	if err != nil {
+		return archivedConsoleFallback(p, buildNumber, fmt.Errorf("获取 Job [%s] 失败: %v", p.ViewID, err))
+	}
+	build, err := getJobBuild(ctx, job, buildNumber)
+	if err != nil {
+		return archivedConsoleFallback(p, buildNumber, fmt.Errorf("获取 Job [%s] 的构建失败: %v", job.GetName(), err))
+	}
+	return build.GetConsoleOutput(ctx), build.GetBuildNumber(), nil
+}
+
+// archivedConsoleFallback 从归档读取构建日志, 没有归档时返回 cause
+func archivedConsoleFallback(p *models.RequestJobData, buildNumber int64, cause error) (string, int64, error) {
+	nodeID, err := strconv.Atoi(p.NodeID)
+	if err != nil {
+		return "", 0, cause
+	}
+	text, number, err := GetArchivedConsole(nodeID, p.ViewID, p.JobName, buildNumber)
+	if err != nil {
+		return "", 0, cause
+	}
+	return text, number, nil
+}
+
+// consoleRules 返回内置规则和配置文件中的自定义规则, tools 不为空时只保留对应工具的规则
+func consoleRules(tools []string) []console.Rule {
+	rules := console.DefaultRules()
+	for _, r := range setting.Conf.ConsoleRules {
+		rules = append(rules, console.Rule{Name: r.Name, Tool: r.Tool, Level: r.Level, Pattern: r.Pattern})
+	}
+	if len(tools) == 0 {
+		return rules
+	}
+	filtered := make([]console.Rule, 0, len(rules))
+	for _, r := range rules {
+		for _, t := range tools {
+			if strings.EqualFold(r.Tool, t) {
+				filtered = append(filtered, r)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// GetConsoleRules 获取可用的问题提取规则
+func GetConsoleRules() []console.Rule {
+	return consoleRules(nil)
+}
+
+// SearchConsole 在构建日志中搜索
+func SearchConsole(ctx context.Context, p *models.ParamConsoleSearch) (*models.ConsoleSearchResult, error) {
+	text, number, err := getConsoleText(ctx, &p.RequestJobData, p.BuildNumber)
+	if err != nil {
+		return nil, err
+	}
+	matches, truncated, err := console.Search(text, console.SearchOptions{
+		Query:      p.Query,
+		Regex:      p.Regex,
+		IgnoreCase: p.IgnoreCase,
+		Context:    p.Context,
+		MaxMatches: p.MaxMatches,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("搜索表达式无效: %v", err)
+	}
+	return &models.ConsoleSearchResult{
+		BuildNumber: number,
+		TotalLines:  countLines(text),
+		Truncated:   truncated,
+		Matches:     matches,
+	}, nil
+}
+
+// ExtractConsoleProblems 按规则从构建日志中提取错误和警告
+func ExtractConsoleProblems(ctx context.Context, p *models.ParamConsoleProblems) (*models.ConsoleProblemsResult, error) {
+	text, number, err := getConsoleText(ctx, &p.RequestJobData, p.BuildNumber)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := console.Extract(text, consoleRules(p.Tools), p.Context)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ConsoleProblemsResult{BuildNumber: number, Summary: summary}, nil
+}
+
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/archive"
+	"bluebell/setting"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bndr/gojenkins"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorArchiveDisabled = errors.New("未启用构建日志归档")
+	ErrorArchiveNotFound = errors.New("未找到归档的构建日志")
+	ErrorBuildRunning    = errors.New("构建尚未结束")
+	ErrorArchivePath     = errors.New("目录或 Job 名称不能包含 / 或 \\, 也不能为 . 或 ..")
+)
+
+// archiveStorage 归档存储, 为 nil 表示未启用归档
+var archiveStorage archive.Storage
+
+// InitArchive 根据配置初始化归档存储, 未配置 archive 时不启用
+func InitArchive() error {
+	cfg := setting.Conf.ArchiveConfig
+	if cfg == nil {
+		return nil
+	}
+	var err error
+	switch cfg.Storage {
+	case archive.StorageLocal, "":
+		archiveStorage, err = archive.NewLocalStorage(cfg.Dir)
+	case archive.StorageS3:
+		archiveStorage, err = archive.NewS3Storage(archive.S3Config{
+			Endpoint:  cfg.Endpoint,
+			Region:    cfg.Region,
+			Bucket:    cfg.Bucket,
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			Prefix:    cfg.Prefix,
+		})
+	default:
+		err = fmt.Errorf("不支持的归档存储类型 [%s]", cfg.Storage)
+	}
+	return err
+}
+
+// archiveKey 归档对象的 key: 节点ID/目录/Job/构建号.log.gz, 多级目录按 / 各占一段路径
+// 与 Job 的 URL 路径一致, 每一段都不能为空或是 . / .., 也不能包含 \\, 无法借此指向其他节点或 Job 的归档
+func archiveKey(nodeID int, viewID, jobName string, buildNumber int64) (string, error) {
+	if viewID == "" || ValidateJobPath(viewID, jobName) != nil || strings.Contains(jobPath(viewID, jobName), "\\") {
+		return "", ErrorArchivePath
+	}
+	return fmt.Sprintf("%d/%s/%d.log.gz", nodeID, jobPath(viewID, jobName), buildNumber), nil
+}
+
+// archiveBuild 归档已结束的构建日志, 已归档过的构建直接返回原记录
+func archiveBuild(ctx context.Context, nodeID int, viewID, jobName string, build *gojenkins.Build) (*models.ConsoleArchive, error) {
+	if archiveStorage == nil {
+		return nil, ErrorArchiveDisabled
+	}
+	if build.Raw.Building {
+		return nil, ErrorBuildRunning
+	}
+	number := build.GetBuildNumber()
+	key, err := archiveKey(nodeID, viewID, jobName, number)
+	if err != nil {
+		return nil, err
+	}
+	if a, err := mysql.GetConsoleArchive(nodeID, viewID, jobName, number); err != nil || a != nil {
+		return a, err
+	}
+
+	text := build.GetConsoleOutput(ctx)
+	data, err := archive.Compress([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	a := &models.ConsoleArchive{
+		NodeID:       nodeID,
+		ViewID:       viewID,
+		JobName:      jobName,
+		BuildNumber:  number,
+		Result:       build.GetResult(),
+		Storage:      archiveStorage.Type(),
+		ObjectKey:    key,
+		Size:         int64(len(data)),
+		OriginalSize: int64(len(text)),
+	}
+	if err := archiveStorage.Put(ctx, a.ObjectKey, data); err != nil {
+		return nil, fmt.Errorf("保存归档失败: %v", err)
+	}
+	if err := mysql.AddConsoleArchive(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// archiveObservedBuild 归档后端观察到的已结束构建, 在 ObserveBuild 中异步调用
+func archiveObservedBuild(e models.BuildEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), consoleTimeout)
+	defer cancel()
+	node, err := mysql.GetNodeByID(e.NodeID)
+	if err != nil {
+		return
+	}
+	jenkins, err := newJenkins(ctx, node, &http.Client{Timeout: consoleTimeout})
+	if err != nil {
+		zap.L().Error("archive build log failed", zap.String("job", e.JobPath), zap.Error(err))
+		return
+	}
+	job, err := getJob(ctx, jenkins, e.ViewID, e.JobName)
+	if err == nil {
+		var build *gojenkins.Build
+		if build, err = job.GetBuild(ctx, e.BuildNumber); err == nil {
+			_, err = archiveBuild(ctx, e.NodeID, e.ViewID, e.JobName, build)
+		}
+	}
+	if err != nil {
+		zap.L().Error("archive build log failed", zap.String("job", e.JobPath), zap.Int64("build", e.BuildNumber), zap.Error(err))
+	}
+}
+
+// ArchiveConsole 手动归档构建日志
+func ArchiveConsole(ctx context.Context, p *models.ParamConsoleArchive) (*models.ConsoleArchive, error) {
+	if archiveStorage == nil {
+		return nil, ErrorArchiveDisabled
+	}
+	node, err := GetNode(p.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	jenkins, err := newJenkins(ctx, node, &http.Client{Timeout: consoleTimeout})
+	if err != nil {
+		return nil, err
+	}
+	job, err := getJob(ctx, jenkins, p.ViewID, p.JobName)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 失败: %v", p.ViewID, err)
+	}
+	build, err := getJobBuild(ctx, job, p.BuildNumber)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 的构建失败: %v", job.GetName(), err)
+	}
+	return archiveBuild(ctx, node.ID, p.ViewID, p.JobName, build)
+}
+
+// GetArchivedConsole 从归档中读取构建日志, buildNumber 为 0 时读取最新一次归档
+func GetArchivedConsole(nodeID int, viewID, jobName string, buildNumber int64) (string, int64, error) {
+	if archiveStorage == nil {
+		return "", 0, ErrorArchiveDisabled
+	}
+	a, err := mysql.GetConsoleArchive(nodeID, viewID, jobName, buildNumber)
+	if err != nil {
+		return "", 0, err
+	}
+	if a == nil {
+		return "", 0, ErrorArchiveNotFound
+	}
+	text, err := readArchive(a)
+	if err != nil {
+		return "", 0, err
+	}
+	return text, a.BuildNumber, nil
+}
+
+// GetConsoleArchives 获取归档列表
+func GetConsoleArchives(nodeID int, viewID, jobName string) ([]models.ConsoleArchive, error) {
+	archives, err := mysql.GetConsoleArchives(nodeID, viewID, jobName)
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) == 0 {
+		return []models.ConsoleArchive{}, nil
+	}
+	return archives, nil
+}
+
+// GetConsoleArchiveContent 获取归档的日志内容
+func GetConsoleArchiveContent(id int64) (*models.ConsoleArchive, string, error) {
+	if archiveStorage == nil {
+		return nil, "", ErrorArchiveDisabled
+	}
+	a, err := mysql.GetConsoleArchiveByID(id)
+	if err != nil {
+		return nil, "", err
+	}
+	text, err := readArchive(a)
+	return a, text, err
+}
+
+// DeleteConsoleArchive 删除归档记录及存储中的对象
+func DeleteConsoleArchive(id int64) error {
+	if archiveStorage == nil {
+		return ErrorArchiveDisabled
+	}
+	a, err := mysql.GetConsoleArchiveByID(id)
+	if err != nil {
+		return err
+	}
+	if err := archiveStorage.Delete(context.Background(), a.ObjectKey); err != nil {
+		return err
+	}
+	return mysql.DeleteConsoleArchive(id)
+}
+
+func readArchive(a *models.ConsoleArchive) (string, error) {
+	if a.Storage != archiveStorage.Type() {
+		return "", fmt.Errorf("归档位于 %s 存储, 当前配置为 %s", a.Storage, archiveStorage.Type())
+	}
+	data, err := archiveStorage.Get(context.Background(), a.ObjectKey)
+	if err != nil {
+		return "", err
+	}
+	text, err := archive.Decompress(data)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
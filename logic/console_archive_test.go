@@ -0,0 +1,43 @@
+package logic
+
+import "testing"
+
+func TestArchiveKey(t *testing.T) {
+	tests := []struct {
+		viewID, jobName string
+		want            string
+	}{
+		{"app", "web", "1/app/web/12.log.gz"},
+		{"app", "", "1/app/12.log.gz"},
+		{"我的视图", "job with space", "1/我的视图/job with space/12.log.gz"},
+		{"a..b", "v1.2", "1/a..b/v1.2/12.log.gz"},
+		{"folder/sub", "web", "1/folder/sub/web/12.log.gz"},
+		{"folder", "", "1/folder/12.log.gz"},
+		{"folder/", "web", ""},
+		{"/folder", "web", ""},
+		{"folder//sub", "web", ""},
+		{"folder/./sub", "web", ""},
+		{"", "web", ""},
+		{".", "web", ""},
+		{"..", "web", ""},
+		{"app", ".", ""},
+		{"app", "..", ""},
+		{"../2/app", "web", ""},
+		{"app", "../../2/app/web", ""},
+		{"app", "web/../../other", ""},
+		{`app\..\2`, "web", ""},
+		{"app", `..\web`, ""},
+	}
+	for _, tt := range tests {
+		got, err := archiveKey(1, tt.viewID, tt.jobName, 12)
+		if tt.want == "" {
+			if err != ErrorArchivePath {
+				t.Errorf("archiveKey(%q, %q) = %q, %v, want ErrorArchivePath", tt.viewID, tt.jobName, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("archiveKey(%q, %q) = %q, %v, want %q", tt.viewID, tt.jobName, got, err, tt.want)
+		}
+	}
+}
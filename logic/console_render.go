@@ -0,0 +1,100 @@
+package logic
+
+import (
+	"bluebell/logger"
+	"bluebell/models"
+	"bluebell/pkg/console"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 构建日志输出模式
+const (
+	ConsoleModeText  = "text"  // 原文
+	ConsoleModePlain = "plain" // 去掉 ANSI 和 ConsoleNote 的纯文本
+	ConsoleModeSpans = "spans" // 按行解析为带样式的片段
+	ConsoleModeHTML  = "html"  // 转义后的 HTML
+)
+
+// timestampFormat Timestamper 插件输出的时间格式 (Java SimpleDateFormat)
+const timestampFormat = "yyyy-MM-dd HH:mm:ss.SSS"
+
+// RenderConsole 按请求的输出模式渲染构建日志
+// 需要时间戳且 buildNumber 不为 0 时, 从 Timestamper 插件获取带时间戳的日志, 获取失败则不附加时间戳
+func RenderConsole(ctx context.Context, p *models.RequestJobData, buildNumber int64, text string) (interface{}, error) {
+	switch p.Mode {
+	case "", ConsoleModeText:
+		return text, nil
+	case ConsoleModePlain, ConsoleModeSpans, ConsoleModeHTML:
+	default:
+		return nil, fmt.Errorf("不支持的输出模式 [%s]", p.Mode)
+	}
+
+	var timestamps []string
+	if p.Timestamps && buildNumber > 0 {
+		ctx, cancel := context.WithTimeout(ctx, consoleTimeout)
+		defer cancel()
+		stamped, ts, err := fetchConsoleTimestamps(ctx, p, buildNumber)
+		if err != nil {
+			logger.Ctx(ctx).Debug("fetch console timestamps failed", zap.String("viewId", p.ViewID), zap.Int64("build", buildNumber), zap.Error(err))
+		} else {
+			text, timestamps = stamped, ts
+		}
+	}
+
+	switch p.Mode {
+	case ConsoleModePlain:
+		return console.PlainText(text, timestamps), nil
+	case ConsoleModeSpans:
+		return console.Render(text, timestamps), nil
+	}
+	return console.RenderHTML(console.Render(text, timestamps)), nil
+}
+
+// fetchConsoleTimestamps 通过 Timestamper 插件的 timestamps?appendLog 接口获取日志和每行的时间戳
+// 接口每行格式为 "<时间戳>  <日志>", 没有时间戳的行以两个空格开头
+func fetchConsoleTimestamps(ctx context.Context, p *models.RequestJobData, buildNumber int64) (string, []string, error) {
+	node, err := GetNode(p.NodeID)
+	if err != nil {
+		return "", nil, err
+	}
+	q := url.Values{}
+	q.Set("time", timestampFormat)
+	q.Set("appendLog", "")
+	u := fmt.Sprintf("http://%s:%s/%s%d/timestamps/?%s", node.Host, node.Port, JobURLPath(p.ViewID, p.JobName), buildNumber, q.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(node.Account, node.Password)
+	resp, err := JenkinsClient(WithJenkinsNode(ctx, node.ID), &http.Client{Timeout: consoleTimeout}).Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("请求失败，状态码：%d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	timestamps := make([]string, len(lines))
+	for i, l := range lines {
+		if idx := strings.Index(l, "  "); idx >= 0 {
+			timestamps[i] = l[:idx]
+			lines[i] = l[idx+2:]
+		}
+	}
+	return strings.Join(lines, "\n"), timestamps, nil
+}
@@ -0,0 +1,269 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/logger"
+	"bluebell/models"
+	"bluebell/pkg/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bndr/gojenkins"
+)
+
+// JenkinsStatus 记录一次操作中 Jenkins 的响应状态码, 用于审计日志
+// 有写请求 (POST 等) 时取其中最大的状态码, 否则取最后一次读请求的状态码
+type JenkinsStatus struct {
+	mu    sync.Mutex
+	write int
+	last  int
+}
+
+func (s *JenkinsStatus) record(method string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if method == http.MethodGet || method == http.MethodHead {
+		s.last = code
+	} else if code > s.write {
+		s.write = code
+	}
+}
+
+// Code 返回记录的状态码, 没有请求过 Jenkins 时为 0
+func (s *JenkinsStatus) Code() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.write != 0 {
+		return s.write
+	}
+	return s.last
+}
+
+type (
+	jenkinsStatusKey struct{}
+	jenkinsNodeKey   struct{}
+)
+
+// WithJenkinsStatus 返回携带 s 的 ctx, 使用该 ctx 创建的 Jenkins 客户端会把响应状态码记录到 s
+func WithJenkinsStatus(ctx context.Context, s *JenkinsStatus) context.Context {
+	return context.WithValue(ctx, jenkinsStatusKey{}, s)
+}
+
+// WithJenkinsNode 返回携带节点 ID 的 ctx, 使用该 ctx 创建的 Jenkins 客户端以此作为指标中的节点标签
+func WithJenkinsNode(ctx context.Context, nodeID int) context.Context {
+	return context.WithValue(ctx, jenkinsNodeKey{}, nodeID)
+}
+
+// jenkinsTransport 请求 Jenkins 的 http.RoundTripper, 携带请求 ID, 记录响应状态码和请求耗时
+type jenkinsTransport struct {
+	base      http.RoundTripper
+	requestID string
+	status    *JenkinsStatus
+	nodeID    int
+}
+
+func (t *jenkinsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.requestID != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(logger.HeaderRequestID, t.requestID)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	var code int
+	if err == nil {
+		code = resp.StatusCode
+		if t.status != nil {
+			t.status.record(req.Method, code)
+		}
+	}
+	metrics.ObserveJenkins(t.nodeID, req.Method, req.URL.Path, code, time.Since(start), err)
+	return resp, err
+}
+
+// JenkinsClient 返回请求 Jenkins 使用的 http.Client, client 为 nil 时使用默认配置
+// gojenkins 的请求不感知 ctx, 因此通过 Transport 转发 ctx 中的请求 ID, 并记录状态码到 ctx 中的 JenkinsStatus
+// 所有请求 Jenkins 的客户端都应通过此函数创建, 并在 ctx 中携带节点 ID (WithJenkinsNode), 以便按节点统计请求耗时和错误
+func JenkinsClient(ctx context.Context, client *http.Client) *http.Client {
+	s, _ := ctx.Value(jenkinsStatusKey{}).(*JenkinsStatus)
+	nodeID, _ := ctx.Value(jenkinsNodeKey{}).(int)
+	id := logger.RequestIDFromContext(ctx)
+	c := &http.Client{}
+	if client != nil {
+		*c = *client
+	}
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	c.Transport = &jenkinsTransport{base: base, requestID: id, status: s, nodeID: nodeID}
+	return c
+}
+
+// newJenkins 根据节点信息创建并初始化 Jenkins 实例, client 为 nil 时使用默认的 http.Client
+func newJenkins(ctx context.Context, node *models.ServerNode, client *http.Client) (*gojenkins.Jenkins, error) {
+	jenkinsURL := fmt.Sprintf("http://%s:%s", node.Host, node.Port)
+	jenkins := gojenkins.CreateJenkins(JenkinsClient(WithJenkinsNode(ctx, node.ID), client), jenkinsURL, node.Account, node.Password)
+	if _, err := jenkins.Init(ctx); err != nil {
+		return nil, fmt.Errorf("初始化 Jenkins 实例失败: %v", err)
+	}
+	return jenkins, nil
+}
+
+// newJenkinsByNodeID 根据节点 ID 查库并创建 Jenkins 实例
+func newJenkinsByNodeID(ctx context.Context, nodeID int) (*gojenkins.Jenkins, error) {
+	node, err := mysql.GetNodeByID(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("获取节点 [%d] 失败: %v", nodeID, err)
+	}
+	return newJenkins(ctx, node, nil)
+}
+
+// ErrorJobNotFound Jenkins 上不存在该 Job
+var ErrorJobNotFound = errors.New("Jenkins Job 不存在")
+
+// ErrorInvalidJobPath 目录或 Job 名中含有空的、. 或 .. 路径段
+var ErrorInvalidJobPath = errors.New("目录或 Job 名无效")
+
+// ValidateJobPath 校验目录和 Job 名, 二者均可为空, 多级目录以 / 分隔
+// Jenkins 会规范化 URL 中的 . 和 .., 不拒绝时授权校验的路径与实际访问的 Job 可能不同
+func ValidateJobPath(viewID, jobName string) error {
+	for _, s := range []string{viewID, jobName} {
+		if s == "" {
+			continue
+		}
+		for _, seg := range strings.Split(s, "/") {
+			if seg == "" || seg == "." || seg == ".." {
+				return ErrorInvalidJobPath
+			}
+		}
+	}
+	return nil
+}
+
+// JobURLPath 返回 Job 的 URL 路径, 以 / 结尾, 如 job/folder/job/name/
+// 目录和 Job 名按 / 拆分为路径段并逐段转义, 与授权校验使用的 Job 路径一致
+func JobURLPath(viewID, jobName string) string {
+	var b strings.Builder
+	for _, seg := range strings.Split(jobPath(viewID, jobName), "/") {
+		b.WriteString("job/" + url.PathEscape(seg) + "/")
+	}
+	return b.String()
+}
+
+// getJob 获取 Job, jobName 为空时 viewID 即为顶层 Job, 否则为 viewID 目录下的 Job
+// gojenkins 的 GetJob 以 errors.New("404") 表示不存在, 这里按状态码返回 ErrorJobNotFound
+func getJob(ctx context.Context, jenkins *gojenkins.Jenkins, viewID, jobName string) (*gojenkins.Job, error) {
+	if viewID == "" {
+		return nil, ErrorInvalidJobPath
+	}
+	if err := ValidateJobPath(viewID, jobName); err != nil {
+		return nil, err
+	}
+	base := "/" + strings.TrimSuffix(JobURLPath(viewID, jobName), "/")
+	job := &gojenkins.Job{Jenkins: jenkins, Raw: new(gojenkins.JobResponse), Base: base}
+	status, err := job.Poll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		return job, nil
+	case http.StatusNotFound:
+		return nil, ErrorJobNotFound
+	}
+	return nil, fmt.Errorf("获取 Job 失败，状态码：%d", status)
+}
+
+// getJobBuild 获取 Job 的指定构建, number 为 0 时获取最新构建
+func getJobBuild(ctx context.Context, job *gojenkins.Job, number int64) (*gojenkins.Build, error) {
+	if number == 0 {
+		return job.GetLastBuild(ctx)
+	}
+	return job.GetBuild(ctx, number)
+}
+
+// triggerBuild 触发 Job 构建, 返回队列 ID; source 为触发来源, 用于统计构建触发次数
+func triggerBuild(ctx context.Context, jenkins *gojenkins.Jenkins, viewID, jobName string, params map[string]string, source string) (queueID int64, err error) {
+	defer func() {
+		metrics.BuildTriggered(jenkinsNode(jenkins), source, err)
+	}()
+	job, err := getJob(ctx, jenkins, viewID, jobName)
+	if err != nil {
+		return 0, fmt.Errorf("获取 Job [%s] 失败: %v", viewID, err)
+	}
+	if params == nil {
+		params = map[string]string{}
+	}
+	queueID, err = job.InvokeSimple(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("触发 Job [%s] 的构建失败: %v", job.GetName(), err)
+	}
+	return queueID, nil
+}
+
+// ErrorQueueItemNotFound 队列项不存在, Jenkins 只保留离开队列几分钟内的队列项
+var ErrorQueueItemNotFound = errors.New("Jenkins 队列项不存在")
+
+// queueItem Jenkins 队列项, 开始构建后 Executable 为对应的构建, 在队列中被取消时 Cancelled 为 true
+type queueItem struct {
+	ID         int64  `json:"id"`
+	Cancelled  bool   `json:"cancelled"`
+	Why        string `json:"why"`
+	Executable struct {
+		Number int64 `json:"number"`
+	} `json:"executable"`
+}
+
+// getQueueItem 查询队列项
+// gojenkins 的 GetQueueItem 不检查状态码, 队列项过期后返回空的任务, 无法与仍在排队区分
+func getQueueItem(ctx context.Context, jenkins *gojenkins.Jenkins, id int64) (*queueItem, error) {
+	item := new(queueItem)
+	resp, err := jenkins.Requester.GetJSON(ctx, fmt.Sprintf("/queue/item/%d", id), item, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return item, nil
+	case http.StatusNotFound:
+		return nil, ErrorQueueItemNotFound
+	}
+	return nil, fmt.Errorf("查询队列项 [%d] 失败，状态码：%d", id, resp.StatusCode)
+}
+
+// cancelQueueItem 取消排队中的构建
+func cancelQueueItem(ctx context.Context, jenkins *gojenkins.Jenkins, id int64) error {
+	_, err := jenkins.Requester.Post(ctx, "/queue/cancelItem", nil, nil, map[string]string{"id": strconv.FormatInt(id, 10)})
+	return err
+}
+
+// jenkinsNode 返回创建 Jenkins 实例时 ctx 中的节点 ID, 作为指标中的节点标签
+func jenkinsNode(jenkins *gojenkins.Jenkins) int {
+	if t, ok := jenkins.Requester.Client.Transport.(*jenkinsTransport); ok {
+		return t.nodeID
+	}
+	return 0
+}
+
+// stopLatestBuild 停止 Job 的最新构建, 返回构建编号
+func stopLatestBuild(ctx context.Context, jenkins *gojenkins.Jenkins, viewID, jobName string) (int64, error) {
+	job, err := getJob(ctx, jenkins, viewID, jobName)
+	if err != nil {
+		return 0, fmt.Errorf("获取 Job [%s] 失败: %v", viewID, err)
+	}
+	lastBuild, err := job.GetLastBuild(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取 Job [%s] 的最新构建失败: %v", job.GetName(), err)
+	}
+	if _, err := lastBuild.Stop(ctx); err != nil {
+		return 0, fmt.Errorf("停止 Job [%s] 的构建失败: %v", job.GetName(), err)
+	}
+	return lastBuild.GetBuildNumber(), nil
+}
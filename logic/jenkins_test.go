@@ -0,0 +1,183 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/logger"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bndr/gojenkins"
+)
+
+func TestValidateJobPath(t *testing.T) {
+	tests := []struct {
+		viewID, jobName string
+		valid           bool
+	}{
+		{"", "", true},
+		{"app", "", true},
+		{"teamA/sub", "web", true},
+		{"my view", "job 1", true},
+		{"..", "", false},
+		{"teamA/../../job/secret", "", false},
+		{"teamA", "..", false},
+		{"teamA", "./web", false},
+		{"teamA/", "", false},
+		{"teamA//web", "", false},
+		{"teamA", "/web", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateJobPath(tt.viewID, tt.jobName); (err == nil) != tt.valid {
+			t.Errorf("ValidateJobPath(%q, %q) = %v, want valid %v", tt.viewID, tt.jobName, err, tt.valid)
+		}
+	}
+}
+
+func TestJobURLPath(t *testing.T) {
+	tests := []struct {
+		viewID, jobName string
+		want            string
+	}{
+		{"app", "", "job/app/"},
+		{"GMB", "GmbClient", "job/GMB/job/GmbClient/"},
+		{"teamA/sub", "web", "job/teamA/job/sub/job/web/"},
+		{"my view", "a?b#c", "job/my%20view/job/a%3Fb%23c/"},
+		{"我的", "", "job/%E6%88%91%E7%9A%84/"},
+	}
+	for _, tt := range tests {
+		if got := JobURLPath(tt.viewID, tt.jobName); got != tt.want {
+			t.Errorf("JobURLPath(%q, %q) = %q, want %q", tt.viewID, tt.jobName, got, tt.want)
+		}
+	}
+}
+
+// getJob 请求的路径与授权校验的 Job 路径一致, 无效的路径不发送请求
+func TestGetJobPath(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	jenkins := gojenkins.CreateJenkins(nil, f.URL)
+	if _, err := getJob(context.Background(), jenkins, "app", ""); err != nil {
+		t.Fatalf("getJob app: %v", err)
+	}
+	if _, err := getJob(context.Background(), jenkins, "missing", "x"); err != ErrorJobNotFound {
+		t.Errorf("getJob missing: err = %v, want ErrorJobNotFound", err)
+	}
+	for _, viewID := range []string{"", "app/..", "x/../../job/app"} {
+		if _, err := getJob(context.Background(), jenkins, viewID, ""); err != ErrorInvalidJobPath {
+			t.Errorf("getJob(%q): err = %v, want ErrorInvalidJobPath", viewID, err)
+		}
+	}
+}
+
+// recordRequestIDs 包装 handler, 记录每个请求携带的请求 ID
+func recordRequestIDs(h http.Handler) (http.Handler, func() []string) {
+	var (
+		mu  sync.Mutex
+		ids []string
+	)
+	record := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ids = append(ids, r.Header.Get(logger.HeaderRequestID))
+		mu.Unlock()
+		h.ServeHTTP(w, r)
+	}
+	get := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ids...)
+	}
+	return http.HandlerFunc(record), get
+}
+
+func TestJenkinsRequestID(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	h, requestIDs := recordRequestIDs(f.Server.Config.Handler)
+	f.Server.Config.Handler = h
+	node, err := mysql.GetNodeByID(addTestNode(t, f.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 请求 ID 随 ctx 传给 gojenkins 发出的每一个请求, 包括 Init, 查询和触发构建
+	ctx := logger.WithRequestID(context.Background(), "req-123")
+	jenkins, err := newJenkins(ctx, node, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := triggerBuild(ctx, jenkins, "app", "", nil, "test"); err != nil {
+		t.Fatalf("triggerBuild: %v", err)
+	}
+	ids := requestIDs()
+	if len(ids) < 3 {
+		t.Fatalf("requests = %d, want at least 3", len(ids))
+	}
+	for i, id := range ids {
+		if id != "req-123" {
+			t.Errorf("request %d: %s = %q, want req-123", i, logger.HeaderRequestID, id)
+		}
+	}
+
+	// ctx 中没有请求 ID 时不携带请求头
+	if _, err := newJenkins(context.Background(), node, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ids = requestIDs(); ids[len(ids)-1] != "" {
+		t.Errorf("request without id: %s = %q, want empty", logger.HeaderRequestID, ids[len(ids)-1])
+	}
+}
+
+func TestJenkinsClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Got-Request-ID", r.Header.Get(logger.HeaderRequestID))
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	status := new(JenkinsStatus)
+	ctx := WithJenkinsStatus(logger.WithRequestID(context.Background(), "req-456"), status)
+	client := JenkinsClient(ctx, &http.Client{Timeout: time.Second})
+	if client.Timeout != time.Second {
+		t.Errorf("client timeout = %v, want the given client's", client.Timeout)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/missing", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Got-Request-ID"); got != "req-456" {
+		t.Errorf("request id sent = %q, want req-456", got)
+	}
+	// 调用方的请求不会被修改
+	if req.Header.Get(logger.HeaderRequestID) != "" {
+		t.Error("caller's request header was modified")
+	}
+	if status.Code() != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 from the last read", status.Code())
+	}
+
+	// 有写请求时记录写请求的状态码
+	resp, err = client.Post(ts.URL+"/build", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get(ts.URL + "/ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if status.Code() != http.StatusCreated {
+		t.Errorf("status = %d, want 201 from the write", status.Code())
+	}
+}
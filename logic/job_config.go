@@ -0,0 +1,162 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/diff"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// diffContextLines diff 输出的上下文行数
+const diffContextLines = 3
+
+// ErrorConfigVersionNotExist 配置版本不存在, 或不属于请求的 Job
+var ErrorConfigVersionNotExist = errors.New("配置版本不存在")
+
+func configHash(config string) string {
+	sum := sha256.Sum256([]byte(config))
+	return hex.EncodeToString(sum[:])
+}
+
+// saveConfigVersion 记录一个版本, 与最近一次记录内容相同且来源为 pull 时不重复记录
+func saveConfigVersion(nodeID int, viewID, jobName, config, source, remark string) (*models.JobConfigVersion, error) {
+	hash := configHash(config)
+	if source == models.ConfigSourcePull {
+		latest, err := mysql.GetLatestJobConfigVersion(nodeID, viewID, jobName)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil && latest.Hash == hash {
+			return latest, nil
+		}
+	}
+	v := &models.JobConfigVersion{
+		NodeID:  nodeID,
+		ViewID:  viewID,
+		JobName: jobName,
+		Config:  config,
+		Hash:    hash,
+		Source:  source,
+		Remark:  remark,
+	}
+	if err := mysql.AddJobConfigVersion(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetJobConfig 从 Jenkins 拉取 config.xml 并记录版本
+func GetJobConfig(ctx context.Context, p *models.ParamJobConfig) (*models.JobConfigVersion, error) {
+	jenkins, err := newJenkinsByNodeID(ctx, p.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := getJob(ctx, jenkins, p.ViewID, p.JobName)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 失败: %v", p.ViewID, err)
+	}
+	config, err := job.GetConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 的配置失败: %v", job.GetName(), err)
+	}
+	v, err := saveConfigVersion(p.NodeID, p.ViewID, p.JobName, config, models.ConfigSourcePull, "")
+	if err != nil {
+		return nil, err
+	}
+	v.Config = config
+	return v, nil
+}
+
+// pushJobConfig 推送 config.xml 到 Jenkins, 推送前先记录 Jenkins 上的当前版本
+func pushJobConfig(ctx context.Context, nodeID int, viewID, jobName, config, source, remark string) (*models.JobConfigVersion, error) {
+	jenkins, err := newJenkinsByNodeID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := getJob(ctx, jenkins, viewID, jobName)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 失败: %v", viewID, err)
+	}
+	current, err := job.GetConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取 Job [%s] 的配置失败: %v", job.GetName(), err)
+	}
+	if _, err := saveConfigVersion(nodeID, viewID, jobName, current, models.ConfigSourcePull, ""); err != nil {
+		return nil, err
+	}
+	if err := job.UpdateConfig(ctx, config); err != nil {
+		return nil, fmt.Errorf("更新 Job [%s] 的配置失败: %v", job.GetName(), err)
+	}
+	return saveConfigVersion(nodeID, viewID, jobName, config, source, remark)
+}
+
+// UpdateJobConfig 更新 Job 的 config.xml
+func UpdateJobConfig(ctx context.Context, p *models.ParamJobConfigUpdate) (*models.JobConfigVersion, error) {
+	return pushJobConfig(ctx, p.NodeID, p.ViewID, p.JobName, p.Config, models.ConfigSourcePush, p.Remark)
+}
+
+// RollbackJobConfig 将历史版本重新推送到 Jenkins
+func RollbackJobConfig(ctx context.Context, p *models.ParamJobConfigRollback) (*models.JobConfigVersion, error) {
+	old, err := getJobVersion(p.VersionID, p.NodeID, p.ViewID, p.JobName)
+	if err != nil {
+		return nil, err
+	}
+	remark := p.Remark
+	if remark == "" {
+		remark = fmt.Sprintf("回滚到版本 #%d", old.ID)
+	}
+	return pushJobConfig(ctx, old.NodeID, old.ViewID, old.JobName, old.Config, models.ConfigSourceRollback, remark)
+}
+
+// GetJobConfigVersions 获取 Job 的版本列表
+func GetJobConfigVersions(p *models.ParamJobConfig) ([]models.JobConfigVersion, error) {
+	versions, err := mysql.GetJobConfigVersions(p.NodeID, p.ViewID, p.JobName)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return []models.JobConfigVersion{}, nil
+	}
+	return versions, nil
+}
+
+// GetJobConfigVersion 获取单个版本 (含 config 内容)
+func GetJobConfigVersion(id int64) (*models.JobConfigVersion, error) {
+	v, err := mysql.GetJobConfigVersionByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrorConfigVersionNotExist
+	}
+	return v, err
+}
+
+// getJobVersion 获取属于指定 Job 的版本, 不属于该 Job 时与不存在同样处理
+func getJobVersion(id int64, nodeID int, viewID, jobName string) (*models.JobConfigVersion, error) {
+	v, err := GetJobConfigVersion(id)
+	if err != nil {
+		return nil, err
+	}
+	if v.NodeID != nodeID || v.ViewID != viewID || v.JobName != jobName {
+		return nil, ErrorConfigVersionNotExist
+	}
+	return v, nil
+}
+
+// DiffJobConfig 生成同一 Job 两个版本间的 unified diff
+func DiffJobConfig(p *models.ParamJobConfigDiff) (string, error) {
+	from, err := getJobVersion(p.FromID, p.NodeID, p.ViewID, p.JobName)
+	if err != nil {
+		return "", err
+	}
+	to, err := getJobVersion(p.ToID, p.NodeID, p.ViewID, p.JobName)
+	if err != nil {
+		return "", err
+	}
+	fromName := fmt.Sprintf("config.xml#%d (%s)", from.ID, from.CreateTime)
+	toName := fmt.Sprintf("config.xml#%d (%s)", to.ID, to.CreateTime)
+	return diff.Unified(from.Config, to.Config, fromName, toName, diffContextLines), nil
+}
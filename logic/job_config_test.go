@@ -0,0 +1,58 @@
+package logic
+
+import (
+	"bluebell/models"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiffJobConfigOwnership(t *testing.T) {
+	view := uniqueName("view")
+	v1, err := saveConfigVersion(1, view, "web", "<project>\n<a/>\n</project>", models.ConfigSourcePull, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := saveConfigVersion(1, view, "web", "<project>\n<b/>\n</project>", models.ConfigSourcePush, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := saveConfigVersion(2, view, "web", "<project/>", models.ConfigSourcePull, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := DiffJobConfig(&models.ParamJobConfigDiff{NodeID: 1, ViewID: view, JobName: "web", FromID: v1.ID, ToID: v2.ID})
+	if err != nil {
+		t.Fatalf("DiffJobConfig: %v", err)
+	}
+	if !strings.Contains(d, "-<a/>\n+<b/>\n") {
+		t.Errorf("diff = %s", d)
+	}
+
+	tests := []struct {
+		name string
+		p    models.ParamJobConfigDiff
+	}{
+		{"missing version", models.ParamJobConfigDiff{NodeID: 1, ViewID: view, JobName: "web", FromID: v1.ID, ToID: -1}},
+		{"version of another node", models.ParamJobConfigDiff{NodeID: 1, ViewID: view, JobName: "web", FromID: v1.ID, ToID: other.ID}},
+		{"versions of another job", models.ParamJobConfigDiff{NodeID: 1, ViewID: view, JobName: "api", FromID: v1.ID, ToID: v2.ID}},
+		{"versions of the folder", models.ParamJobConfigDiff{NodeID: 1, ViewID: view, FromID: v1.ID, ToID: v2.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DiffJobConfig(&tt.p); err != ErrorConfigVersionNotExist {
+				t.Errorf("err = %v, want ErrorConfigVersionNotExist", err)
+			}
+		})
+	}
+
+	// 版本不属于请求的 Job 时, 不会连接 Jenkins 推送配置
+	p := &models.ParamJobConfigRollback{NodeID: 2, ViewID: view, JobName: "web", VersionID: v1.ID}
+	if _, err := RollbackJobConfig(context.Background(), p); err != ErrorConfigVersionNotExist {
+		t.Errorf("rollback: err = %v, want ErrorConfigVersionNotExist", err)
+	}
+	if _, err := GetJobConfigVersion(-1); err != ErrorConfigVersionNotExist {
+		t.Errorf("GetJobConfigVersion: err = %v, want ErrorConfigVersionNotExist", err)
+	}
+}
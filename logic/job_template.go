@@ -0,0 +1,210 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// 模板应用到 Job 时的动作
+const (
+	TemplateActionCreate = "create"
+	TemplateActionUpdate = "update"
+)
+
+// xmlText 已转义的变量值, 渲染时原样输出
+type xmlText string
+
+func escapeXML(s string) (xmlText, error) {
+	var buf bytes.Buffer
+	if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+		return "", err
+	}
+	return xmlText(buf.String()), nil
+}
+
+// templateFuncs 模板中可用的函数
+// 变量值默认已转义, xml 对已转义的值不再重复转义, 兼容显式调用 xml 的模板
+var templateFuncs = template.FuncMap{
+	"xml": func(v interface{}) (xmlText, error) {
+		if t, ok := v.(xmlText); ok {
+			return t, nil
+		}
+		return escapeXML(fmt.Sprint(v))
+	},
+}
+
+func parseTemplate(t *models.JobTemplate) (*template.Template, error) {
+	return template.New(t.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(t.Content)
+}
+
+// AddJobTemplate 新增模板, 保存前校验模板语法
+func AddJobTemplate(t *models.JobTemplate) error {
+	if _, err := parseTemplate(t); err != nil {
+		return fmt.Errorf("模板解析失败: %v", err)
+	}
+	return mysql.AddJobTemplate(t)
+}
+
+// GetJobTemplates 获取模板列表
+func GetJobTemplates(name string) ([]models.JobTemplate, error) {
+	templates, err := mysql.GetJobTemplates(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return []models.JobTemplate{}, nil
+	}
+	return templates, nil
+}
+
+// GetJobTemplate 获取单个模板
+func GetJobTemplate(id int64) (*models.JobTemplate, error) {
+	return mysql.GetJobTemplateByID(id)
+}
+
+// UpdateJobTemplate 更新模板
+func UpdateJobTemplate(id int64, t *models.JobTemplate) error {
+	if _, err := parseTemplate(t); err != nil {
+		return fmt.Errorf("模板解析失败: %v", err)
+	}
+	return mysql.UpdateJobTemplate(id, t)
+}
+
+// DeleteJobTemplate 删除模板
+func DeleteJobTemplate(id int64) error {
+	return mysql.DeleteJobTemplate(id)
+}
+
+// renderTemplate 使用变量值渲染模板, 未传入的变量取默认值, 必填变量缺失时报错
+// 变量值按 XML 文本转义后再填入, 只有声明为 raw 的变量原样输出
+func renderTemplate(t *models.JobTemplate, values map[string]string) (string, error) {
+	data := make(map[string]interface{}, len(t.Variables))
+	for _, v := range t.Variables {
+		val, ok := values[v.Name]
+		if !ok || val == "" {
+			if v.Required && v.Default == "" {
+				return "", fmt.Errorf("缺少必填变量 [%s]", v.Name)
+			}
+			val = v.Default
+		}
+		if v.Raw {
+			data[v.Name] = val
+			continue
+		}
+		escaped, err := escapeXML(val)
+		if err != nil {
+			return "", fmt.Errorf("变量 [%s] 无效: %v", v.Name, err)
+		}
+		data[v.Name] = escaped
+	}
+	// 允许传入未声明的变量, 始终转义
+	for k, val := range values {
+		if _, ok := data[k]; ok {
+			continue
+		}
+		escaped, err := escapeXML(val)
+		if err != nil {
+			return "", fmt.Errorf("变量 [%s] 无效: %v", k, err)
+		}
+		data[k] = escaped
+	}
+
+	tmpl, err := parseTemplate(t)
+	if err != nil {
+		return "", fmt.Errorf("模板解析失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderJobTemplate 渲染模板, 用于预览
+func RenderJobTemplate(p *models.ParamTemplateRender) (string, error) {
+	t, err := mysql.GetJobTemplateByID(p.TemplateID)
+	if err != nil {
+		return "", err
+	}
+	return renderTemplate(t, p.Values)
+}
+
+// ApplyJobTemplate 渲染模板并在各目标节点上创建或更新 Job, 返回每个目标的结果
+func ApplyJobTemplate(ctx context.Context, p *models.ParamTemplateApply) ([]models.TemplateApplyResult, error) {
+	t, err := mysql.GetJobTemplateByID(p.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	config, err := renderTemplate(t, p.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	remark := fmt.Sprintf("模板 [%s]", t.Name)
+	results := make([]models.TemplateApplyResult, len(p.Targets))
+	var wg sync.WaitGroup
+	for i, target := range p.Targets {
+		wg.Add(1)
+		go func(i int, target models.TemplateTarget) {
+			defer wg.Done()
+			results[i] = applyTemplateToTarget(ctx, target, config, remark)
+		}(i, target)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func applyTemplateToTarget(ctx context.Context, target models.TemplateTarget, config, remark string) models.TemplateApplyResult {
+	res := models.TemplateApplyResult{
+		NodeID:  target.NodeID,
+		ViewID:  target.ViewID,
+		JobName: target.JobName,
+	}
+
+	jenkins, err := newJenkinsByNodeID(ctx, target.NodeID)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	// 与其他接口保持一致: viewID 为顶层 Job 或目录, jobName 为目录下的 Job
+	viewID, jobName := target.JobName, ""
+	var parents []string
+	if target.ViewID != "" {
+		viewID, jobName = target.ViewID, target.JobName
+		parents = strings.Split(target.ViewID, "/") // 多级目录
+	}
+
+	_, err = getJob(ctx, jenkins, viewID, jobName)
+	switch {
+	case err == nil:
+		res.Action = TemplateActionUpdate
+		if _, err := pushJobConfig(ctx, target.NodeID, viewID, jobName, config, models.ConfigSourcePush, remark); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	case errors.Is(err, ErrorJobNotFound):
+		res.Action = TemplateActionCreate
+		if _, err := jenkins.CreateJobInFolder(ctx, config, target.JobName, parents...); err != nil {
+			res.Error = fmt.Sprintf("创建 Job [%s] 失败: %v", target.JobName, err)
+			return res
+		}
+		if _, err := saveConfigVersion(target.NodeID, viewID, jobName, config, models.ConfigSourcePush, remark); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+	default:
+		res.Error = fmt.Sprintf("获取 Job [%s] 失败: %v", target.JobName, err)
+		return res
+	}
+	res.Success = true
+	return res
+}
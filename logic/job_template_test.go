@@ -0,0 +1,128 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRenderTemplateEscaping(t *testing.T) {
+	tmpl := &models.JobTemplate{
+		Name: "escape",
+		Content: `<project><description>{{.desc}}</description><url>{{xml .url}}</url>` +
+			`<builders>{{.builders}}</builders><extra>{{.extra}}</extra></project>`,
+		Variables: models.TemplateVariables{
+			{Name: "desc"},
+			{Name: "url"},
+			{Name: "builders", Raw: true, Default: "<hudson.tasks.Shell/>"},
+		},
+	}
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   string
+	}{
+		{
+			"escaped by default",
+			map[string]string{
+				"desc":  `</description><builders><hudson.tasks.Shell><command>rm -rf /</command></hudson.tasks.Shell></builders><description>`,
+				"url":   "http://git/a?b=1&c=2",
+				"extra": `"x" & <y>`,
+			},
+			`<project><description>&lt;/description&gt;&lt;builders&gt;&lt;hudson.tasks.Shell&gt;&lt;command&gt;rm -rf /&lt;/command&gt;` +
+				`&lt;/hudson.tasks.Shell&gt;&lt;/builders&gt;&lt;description&gt;</description>` +
+				`<url>http://git/a?b=1&amp;c=2</url><builders><hudson.tasks.Shell/></builders>` +
+				`<extra>&#34;x&#34; &amp; &lt;y&gt;</extra></project>`,
+		},
+		{
+			"raw variable",
+			map[string]string{"builders": "<hudson.tasks.Maven/>", "extra": ""},
+			`<project><description></description><url></url><builders><hudson.tasks.Maven/></builders><extra></extra></project>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate(tmpl, tt.values)
+			if err != nil {
+				t.Fatalf("renderTemplate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderTemplate =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeTemplateJenkins 只实现 Job 查询和创建, jobs 中的 Job 存在, 其余返回 404, broken 中的返回 500
+type fakeTemplateJenkins struct {
+	*httptest.Server
+	mu      sync.Mutex
+	jobs    map[string]bool
+	broken  map[string]bool
+	created map[string]string
+}
+
+func (f *fakeTemplateJenkins) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var name string
+	switch {
+	case r.URL.Path == "/api/json":
+		fmt.Fprint(w, `{}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/createItem":
+		name = r.URL.Query().Get("name")
+		body, _ := ioutil.ReadAll(r.Body)
+		f.created[name] = string(body)
+		f.jobs[name] = true
+	case r.Method == http.MethodGet:
+		if _, err := fmt.Sscanf(r.URL.Path, "/job/%s", &name); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		name = name[:len(name)-len("/api/json")]
+		switch {
+		case f.broken[name]:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case f.jobs[name]:
+			fmt.Fprintf(w, `{"name":%q}`, name)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestApplyTemplateToTargetCreate(t *testing.T) {
+	f := &fakeTemplateJenkins{jobs: map[string]bool{}, broken: map[string]bool{"broken": true}, created: map[string]string{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	defer f.Close()
+	nodeID := addTestNode(t, f.URL)
+	config := "<project/>"
+
+	res := applyTemplateToTarget(context.Background(), models.TemplateTarget{NodeID: nodeID, JobName: uniqueName("web")}, config, "模板 [t]")
+	if !res.Success || res.Action != TemplateActionCreate {
+		t.Fatalf("result = %+v, want created", res)
+	}
+	if f.created[res.JobName] != config {
+		t.Errorf("created = %v", f.created)
+	}
+	if v, err := mysql.GetLatestJobConfigVersion(nodeID, res.JobName, ""); err != nil || v == nil || v.Config != config {
+		t.Errorf("version = %+v, %v", v, err)
+	}
+
+	// 查询 Job 出错 (非 404) 时不能当作不存在去创建
+	res = applyTemplateToTarget(context.Background(), models.TemplateTarget{NodeID: nodeID, JobName: "broken"}, config, "")
+	if res.Success || res.Action != "" || res.Error == "" {
+		t.Errorf("result = %+v, want error without action", res)
+	}
+	if _, ok := f.created["broken"]; ok {
+		t.Error("created job after lookup error")
+	}
+}
@@ -0,0 +1,244 @@
+package logic
+
+import (
+	"bluebell/dao/redis"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 登录防暴力破解: 按用户名和 IP 分别统计失败次数, 超过阈值后锁定, 锁定时间随失败次数指数增长
+// 配置了 Redis 时计数保存在 Redis 中, 多实例共享; 否则保存在内存中
+const (
+	loginUserThreshold = 5                // 同一用户名允许连续失败的次数
+	loginIPThreshold   = 20               // 同一 IP 允许连续失败的次数
+	loginLockBase      = 30 * time.Second // 超过阈值后首次锁定的时间, 之后每次失败翻倍
+	loginLockMax       = 15 * time.Minute // 最长锁定时间
+	loginFailWindow    = 30 * time.Minute // 失败次数在最后一次失败后保留的时间
+)
+
+// LoginLockedError 登录失败次数过多被暂时锁定
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("登录失败次数过多, 请在 %d 秒后重试", int(e.RetryAfter.Seconds()+0.5))
+}
+
+// attemptStore 登录失败次数的存储
+type attemptStore interface {
+	lockTTL(key string) (time.Duration, error)
+	incrFailures(key string) (int64, error)
+	lock(key string, d time.Duration) error
+	reset(key string) error
+}
+
+type redisAttemptStore struct{}
+
+func (redisAttemptStore) lockTTL(key string) (time.Duration, error) { return redis.LoginLockTTL(key) }
+func (redisAttemptStore) incrFailures(key string) (int64, error) {
+	return redis.IncrLoginFailures(key, loginFailWindow)
+}
+func (redisAttemptStore) lock(key string, d time.Duration) error { return redis.LockLogin(key, d) }
+func (redisAttemptStore) reset(key string) error                 { return redis.ResetLoginFailures(key) }
+
+// memoryAttemptStore 单实例部署时使用的内存存储
+type memoryAttemptStore struct {
+	mu        sync.Mutex
+	entries   map[string]*attemptEntry
+	lastSweep time.Time
+}
+
+type attemptEntry struct {
+	failures    int64
+	expires     time.Time
+	lockedUntil time.Time
+}
+
+var memoryAttempts = &memoryAttemptStore{entries: make(map[string]*attemptEntry)}
+
+func (s *memoryAttemptStore) lockTTL(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		if d := time.Until(e.lockedUntil); d > 0 {
+			return d, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memoryAttemptStore) incrFailures(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	e, ok := s.entries[key]
+	if !ok || now.After(e.expires) {
+		e = &attemptEntry{}
+		s.entries[key] = e
+	}
+	e.failures++
+	e.expires = now.Add(loginFailWindow)
+	return e.failures, nil
+}
+
+func (s *memoryAttemptStore) lock(key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		e.lockedUntil = time.Now().Add(d)
+	}
+	return nil
+}
+
+func (s *memoryAttemptStore) reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep 每分钟清理一次过期的记录, 调用方需持有锁
+func (s *memoryAttemptStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if now.After(e.expires) && now.After(e.lockedUntil) {
+			delete(s.entries, k)
+		}
+	}
+}
+
+func attempts() attemptStore {
+	if redis.Enabled() {
+		return redisAttemptStore{}
+	}
+	return memoryAttempts
+}
+
+// failureCounter 一个失败次数计数键及其锁定阈值
+type failureCounter struct {
+	key       string
+	threshold int64
+}
+
+// loginKeys 登录限制的计数键, 用户名不区分大小写
+func loginKeys(username, ip string) (userKey, ipKey string) {
+	return "user:" + strings.ToLower(username), "ip:" + ip
+}
+
+// twoFactorCounters 两步验证的计数键: 按用户 ID 跨登录挑战累计, 重新输入密码不会清除; ip 为空时不按 IP 统计
+func twoFactorCounters(userID int64, ip string) []failureCounter {
+	counters := []failureCounter{{"2fa:" + strconv.FormatInt(userID, 10), loginUserThreshold}}
+	if ip != "" {
+		counters = append(counters, failureCounter{"ip:" + ip, loginIPThreshold})
+	}
+	return counters
+}
+
+// checkLocked 任一计数键处于锁定中时返回 LoginLockedError
+func checkLocked(counters []failureCounter) error {
+	store := attempts()
+	var wait time.Duration
+	for _, k := range counters {
+		d, err := store.lockTTL(k.key)
+		if err != nil {
+			// 存储不可用时不阻止登录
+			zap.L().Error("get login lock failed", zap.String("key", k.key), zap.Error(err))
+			continue
+		}
+		if d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return &LoginLockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// recordFailures 各计数键的失败次数加一, 超过阈值时按失败次数锁定
+func recordFailures(counters []failureCounter) {
+	store := attempts()
+	for _, k := range counters {
+		failures, err := store.incrFailures(k.key)
+		if err != nil {
+			zap.L().Error("record login failure failed", zap.String("key", k.key), zap.Error(err))
+			continue
+		}
+		if d := loginLockDuration(failures, k.threshold); d > 0 {
+			if err := store.lock(k.key, d); err != nil {
+				zap.L().Error("lock login failed", zap.String("key", k.key), zap.Error(err))
+			}
+			zap.L().Warn("login locked", zap.String("key", k.key), zap.Int64("failures", failures), zap.Duration("duration", d))
+		}
+	}
+}
+
+func resetFailures(key string) {
+	if err := attempts().reset(key); err != nil {
+		zap.L().Error("reset login failures failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func loginCounters(username, ip string) []failureCounter {
+	userKey, ipKey := loginKeys(username, ip)
+	return []failureCounter{{userKey, loginUserThreshold}, {ipKey, loginIPThreshold}}
+}
+
+// checkLoginAllowed 用户名或 IP 处于锁定中时返回 LoginLockedError
+func checkLoginAllowed(username, ip string) error {
+	return checkLocked(loginCounters(username, ip))
+}
+
+// recordLoginFailure 记录一次登录失败, 超过阈值时按失败次数锁定
+func recordLoginFailure(username, ip string) {
+	recordFailures(loginCounters(username, ip))
+	zap.L().Warn("login attempt failed", zap.String("username", username), zap.String("ip", ip))
+}
+
+// resetLoginFailures 登录成功后清除该用户名的失败次数, IP 的计数保留, 避免用自己的账号重置
+func resetLoginFailures(username, ip string) {
+	userKey, _ := loginKeys(username, ip)
+	resetFailures(userKey)
+}
+
+// checkTwoFactorAllowed 用户的两步验证或 IP 处于锁定中时返回 LoginLockedError
+func checkTwoFactorAllowed(userID int64, ip string) error {
+	return checkLocked(twoFactorCounters(userID, ip))
+}
+
+// recordTwoFactorFailure 记录一次验证码或恢复码错误, 与密码错误使用相同的锁定策略
+func recordTwoFactorFailure(userID int64, ip string) {
+	recordFailures(twoFactorCounters(userID, ip))
+	zap.L().Warn("second factor failed", zap.Int64("userId", userID), zap.String("ip", ip))
+}
+
+// resetTwoFactorFailures 验证码校验通过后清除用户的两步验证失败次数
+func resetTwoFactorFailures(userID int64) {
+	resetFailures(twoFactorCounters(userID, "")[0].key)
+}
+
+// loginLockDuration 失败次数达到阈值后的锁定时间: base, 2*base, 4*base ... 最长 loginLockMax
+func loginLockDuration(failures, threshold int64) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+	n := failures - threshold
+	if n > 10 {
+		return loginLockMax
+	}
+	d := loginLockBase << uint(n)
+	if d > loginLockMax {
+		d = loginLockMax
+	}
+	return d
+}
@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginLockDuration(t *testing.T) {
+	tests := []struct {
+		failures, threshold int64
+		want                time.Duration
+	}{
+		{4, 5, 0},
+		{5, 5, loginLockBase},
+		{6, 5, 2 * loginLockBase},
+		{7, 5, 4 * loginLockBase},
+		{9, 5, 16 * loginLockBase},
+		{10, 5, loginLockMax}, // 32 * 30s 超过上限
+		{100, 5, loginLockMax},
+		{20, 20, loginLockBase},
+	}
+	for _, tt := range tests {
+		if got := loginLockDuration(tt.failures, tt.threshold); got != tt.want {
+			t.Errorf("loginLockDuration(%d, %d) = %v, want %v", tt.failures, tt.threshold, got, tt.want)
+		}
+	}
+}
+
+// lockedFor 返回锁定的剩余时间, 未锁定时为 0
+func lockedFor(t *testing.T, username, ip string) time.Duration {
+	t.Helper()
+	err := checkLoginAllowed(username, ip)
+	if err == nil {
+		return 0
+	}
+	locked, ok := err.(*LoginLockedError)
+	if !ok {
+		t.Fatalf("checkLoginAllowed: %v", err)
+	}
+	return locked.RetryAfter
+}
+
+func TestLoginLockout(t *testing.T) {
+	t.Run("user", func(t *testing.T) {
+		name, ip := uniqueName("guard"), uniqueName("ip")
+		for i := 1; i < loginUserThreshold; i++ {
+			recordLoginFailure(name, ip)
+		}
+		if d := lockedFor(t, name, ip); d != 0 {
+			t.Fatalf("locked after %d failures", loginUserThreshold-1)
+		}
+		recordLoginFailure(name, ip)
+		if d := lockedFor(t, name, ip); d <= loginLockBase-time.Second || d > loginLockBase {
+			t.Errorf("retry after %v, want about %v", d, loginLockBase)
+		}
+		// 用户名不区分大小写, 换 IP 也不能绕过
+		if d := lockedFor(t, strings.ToUpper(name), uniqueName("ip")); d == 0 {
+			t.Error("username lock bypassed by case or IP")
+		}
+		// 锁定后继续失败, 锁定时间翻倍
+		recordLoginFailure(name, ip)
+		if d := lockedFor(t, name, ip); d <= 2*loginLockBase-time.Second {
+			t.Errorf("retry after %v, want about %v", d, 2*loginLockBase)
+		}
+	})
+
+	t.Run("ip", func(t *testing.T) {
+		ip := uniqueName("ip")
+		for i := 0; i < loginIPThreshold; i++ {
+			recordLoginFailure(uniqueName("guard"), ip)
+		}
+		if d := lockedFor(t, uniqueName("guard"), ip); d == 0 {
+			t.Error("ip not locked after spraying usernames")
+		}
+		if d := lockedFor(t, uniqueName("guard"), uniqueName("ip")); d != 0 {
+			t.Errorf("other ip locked: %v", d)
+		}
+	})
+}
+
+// 登录成功后清除用户名的失败次数, IP 的计数保留
+func TestLoginResetOnSuccess(t *testing.T) {
+	name, ip := uniqueName("guard"), uniqueName("ip")
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: "right-pw", RePassword: "right-pw"}); err != nil {
+		t.Fatal(err)
+	}
+	login := func(pw string) error {
+		_, _, err := Login(&models.ParamLogin{Username: name, Password: pw}, ip)
+		return err
+	}
+
+	for round := 0; round < 2; round++ {
+		for i := 1; i < loginUserThreshold; i++ {
+			if err := login("wrong"); err != mysql.ErrorInvalidPassword {
+				t.Fatalf("round %d: err = %v, want ErrorInvalidPassword", round, err)
+			}
+		}
+		if err := login("right-pw"); err != nil {
+			t.Fatalf("round %d: login: %v", round, err)
+		}
+	}
+	memoryAttempts.mu.Lock()
+	_, userKept := memoryAttempts.entries["user:"+name]
+	ipEntry := memoryAttempts.entries["ip:"+ip]
+	memoryAttempts.mu.Unlock()
+	if userKept {
+		t.Error("user failures not reset")
+	}
+	if ipEntry == nil || ipEntry.failures != 2*(loginUserThreshold-1) {
+		t.Errorf("ip entry = %+v, want %d failures kept", ipEntry, 2*(loginUserThreshold-1))
+	}
+
+	// 锁定期间即使密码正确也不能登录
+	for i := 0; i < loginUserThreshold; i++ {
+		login("wrong")
+	}
+	if _, ok := login("right-pw").(*LoginLockedError); !ok {
+		t.Error("locked user logged in with the right password")
+	}
+}
+
+// 最后一次失败超过 loginFailWindow 后重新计数
+func TestLoginFailuresExpire(t *testing.T) {
+	key := "user:" + uniqueName("guard")
+	if n, _ := memoryAttempts.incrFailures(key); n != 1 {
+		t.Fatalf("failures = %d, want 1", n)
+	}
+	if n, _ := memoryAttempts.incrFailures(key); n != 2 {
+		t.Fatalf("failures = %d, want 2", n)
+	}
+	memoryAttempts.mu.Lock()
+	memoryAttempts.entries[key].expires = time.Now().Add(-time.Second)
+	memoryAttempts.mu.Unlock()
+	if n, _ := memoryAttempts.incrFailures(key); n != 1 {
+		t.Errorf("failures after window = %d, want 1", n)
+	}
+
+	memoryAttempts.lock(key, -time.Second)
+	if d, _ := memoryAttempts.lockTTL(key); d != 0 {
+		t.Errorf("expired lock ttl = %v", d)
+	}
+}
+
+func TestLoginSweep(t *testing.T) {
+	s := &memoryAttemptStore{entries: make(map[string]*attemptEntry)}
+	now := time.Now()
+	s.entries["expired"] = &attemptEntry{failures: 3, expires: now.Add(-time.Second)}
+	s.entries["locked"] = &attemptEntry{failures: 9, expires: now.Add(-time.Second), lockedUntil: now.Add(time.Minute)}
+	s.entries["active"] = &attemptEntry{failures: 1, expires: now.Add(time.Minute)}
+
+	s.lastSweep = now.Add(-30 * time.Second)
+	s.sweep(now)
+	if len(s.entries) != 3 {
+		t.Errorf("swept within a minute of the last sweep: %d entries", len(s.entries))
+	}
+
+	s.lastSweep = now.Add(-2 * time.Minute)
+	s.sweep(now)
+	if _, ok := s.entries["expired"]; ok {
+		t.Error("expired entry not swept")
+	}
+	if len(s.entries) != 2 {
+		t.Errorf("entries = %d, want locked and active kept", len(s.entries))
+	}
+	if !s.lastSweep.Equal(now) {
+		t.Error("lastSweep not updated")
+	}
+}
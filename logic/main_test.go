@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/jwt"
+	"bluebell/pkg/snowflake"
+	"bluebell/setting"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestMain 在临时目录中创建 SQLite 数据库, 表结构由 mysql.Init 自动创建
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "bluebell-logic")
+	if err != nil {
+		panic(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	if err := mysql.Init(&setting.MySQLConfig{}); err != nil {
+		panic(err)
+	}
+	if err := snowflake.Init("2020-07-01", 1); err != nil {
+		panic(err)
+	}
+	setting.Conf = &setting.AppConfig{}
+	if err := jwt.Init(&setting.JWTConfig{
+		SigningKey: "test",
+		Keys:       []setting.JWTKey{{ID: "test", Algorithm: "HS256", Secret: "0123456789abcdef0123456789abcdef"}},
+	}, "test"); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	mysql.Close()
+	os.Chdir(wd)
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setAuthConfig 在测试期间替换认证配置
+func setAuthConfig(t *testing.T, conf *setting.AuthConfig) {
+	t.Helper()
+	old := setting.Conf.AuthConfig
+	setting.Conf.AuthConfig = conf
+	t.Cleanup(func() { setting.Conf.AuthConfig = old })
+}
+
+// addTestRole 新增一个拥有 perm 权限的角色
+func addTestRole(t *testing.T, name, perm string) *models.Role {
+	t.Helper()
+	r := &models.Role{Name: name, Permissions: []models.RolePermission{{Permission: perm}}}
+	if err := mysql.AddRole(r); err != nil {
+		t.Fatal(err)
+	}
+	r, err := mysql.GetRoleByName(name)
+	if err != nil || r == nil {
+		t.Fatalf("get role %s: %v", name, err)
+	}
+	return r
+}
+
+// userRoleNames 获取用户的角色名
+func userRoleNames(t *testing.T, userID int64) []string {
+	t.Helper()
+	roles, err := mysql.GetUserRoles(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// uniqueName 生成测试中唯一的名称, 避免不同测试之间的数据冲突
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, snowflake.GenID())
+}
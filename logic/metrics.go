@@ -0,0 +1,104 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/metrics"
+	"bluebell/setting"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bndr/gojenkins"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultJenkinsMetricsInterval = 30 * time.Second
+	jenkinsMetricsTimeout         = 10 * time.Second // 采集单个节点的超时时间
+)
+
+// StartJenkinsMetrics 定时采集各节点 Jenkins 的队列长度和执行器使用情况, 通过 /metrics 输出
+func StartJenkinsMetrics() {
+	interval := defaultJenkinsMetricsInterval
+	if cfg := setting.Conf.MetricsConfig; cfg != nil && cfg.JenkinsInterval != 0 {
+		if cfg.JenkinsInterval < 0 {
+			return
+		}
+		interval = time.Duration(cfg.JenkinsInterval) * time.Second
+	}
+	go func() {
+		collectJenkinsMetrics()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			collectJenkinsMetrics()
+		}
+	}()
+}
+
+// collectJenkinsMetrics 并发采集所有节点的状态
+func collectJenkinsMetrics() {
+	nodes, err := mysql.GetAllNodes()
+	if err != nil {
+		zap.L().Error("mysql.GetAllNodes failed", zap.Error(err))
+		return
+	}
+	stats := make([]metrics.JenkinsNodeStats, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stats[i] = jenkinsNodeStats(&nodes[i])
+		}(i)
+	}
+	wg.Wait()
+	metrics.SetJenkinsNodes(stats)
+}
+
+// jenkinsNodeStats 从 /queue 和 /computer 接口获取节点的队列长度和执行器数, 失败时 Up 为 false
+func jenkinsNodeStats(node *models.ServerNode) metrics.JenkinsNodeStats {
+	s := metrics.JenkinsNodeStats{NodeID: node.ID, Name: node.Name, Node: fmt.Sprintf("%s:%s", node.Host, node.Port)}
+	ctx, cancel := context.WithTimeout(context.Background(), jenkinsMetricsTimeout)
+	defer cancel()
+	client := JenkinsClient(WithJenkinsNode(ctx, node.ID), &http.Client{Timeout: jenkinsMetricsTimeout})
+	jenkins := gojenkins.CreateJenkins(client, "http://"+s.Node, node.Account, node.Password)
+
+	var queue struct {
+		Items []struct {
+			ID int64 `json:"id"`
+		} `json:"items"`
+	}
+	if err := getJenkinsJSON(ctx, jenkins, "/queue", "items[id]", &queue); err != nil {
+		zap.L().Debug("collect jenkins queue failed", zap.Int("nodeId", node.ID), zap.Error(err))
+		return s
+	}
+	var computer struct {
+		BusyExecutors  int `json:"busyExecutors"`
+		TotalExecutors int `json:"totalExecutors"`
+	}
+	if err := getJenkinsJSON(ctx, jenkins, "/computer", "busyExecutors,totalExecutors", &computer); err != nil {
+		zap.L().Debug("collect jenkins executors failed", zap.Int("nodeId", node.ID), zap.Error(err))
+		return s
+	}
+	s.Up = true
+	s.QueueLength = len(queue.Items)
+	s.BusyExecutors = computer.BusyExecutors
+	s.TotalExecutors = computer.TotalExecutors
+	return s
+}
+
+// getJenkinsJSON 请求 Jenkins 的 api/json 接口, tree 用于只返回需要的字段
+func getJenkinsJSON(ctx context.Context, jenkins *gojenkins.Jenkins, endpoint, tree string, v interface{}) error {
+	resp, err := jenkins.Requester.GetJSON(ctx, endpoint, v, map[string]string{"tree": tree})
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求失败，状态码：%d", resp.StatusCode)
+	}
+	return nil
+}
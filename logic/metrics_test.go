@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/pkg/metrics"
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricSamples 返回默认 Registry 中名为 name 且标签包含 labels 的样本
+func metricSamples(t *testing.T, name string, labels map[string]string) []*dto.Metric {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []*dto.Metric
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	next:
+		for _, m := range f.GetMetric() {
+			got := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+			for k, v := range labels {
+				if got[k] != v {
+					continue next
+				}
+			}
+			samples = append(samples, m)
+		}
+	}
+	return samples
+}
+
+// 构建触发和 Jenkins 请求的指标以数据库中的节点 ID 为标签, 失败的触发记为 failure
+func TestJenkinsMetricsNodeLabel(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	nodeID := addTestNode(t, f.URL)
+	node, err := mysql.GetNodeByID(nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	jenkins, err := newJenkins(ctx, node, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := triggerBuild(ctx, jenkins, "app", "", nil, metrics.SourceManual); err != nil {
+		t.Fatalf("triggerBuild: %v", err)
+	}
+	if _, err := triggerBuild(ctx, jenkins, "missing", "", nil, metrics.SourceManual); err == nil {
+		t.Fatal("triggerBuild missing job succeeded")
+	}
+
+	label := strconv.Itoa(nodeID)
+	for _, result := range []string{"success", "failure"} {
+		samples := metricSamples(t, "bluebell_build_triggers_total", map[string]string{"node_id": label, "source": metrics.SourceManual, "result": result})
+		if len(samples) != 1 || samples[0].GetCounter().GetValue() != 1 {
+			t.Errorf("build_triggers_total{result=%q} = %v, want 1", result, samples)
+		}
+	}
+	if samples := metricSamples(t, "bluebell_jenkins_request_duration_seconds", map[string]string{"node_id": label, "endpoint": "/job/:name/build"}); len(samples) != 1 {
+		t.Errorf("jenkins_request_duration_seconds samples = %v", samples)
+	}
+	if samples := metricSamples(t, "bluebell_jenkins_request_errors_total", map[string]string{"node_id": label, "code": "404"}); len(samples) == 0 {
+		t.Error("missing jenkins_request_errors_total for 404")
+	}
+}
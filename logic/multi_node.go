@@ -0,0 +1,202 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/metrics"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bndr/gojenkins"
+)
+
+// defaultNodeTimeout 单个节点默认超时时间
+const defaultNodeTimeout = 10 * time.Second
+
+// nodeFunc 在单个节点上执行的操作
+type nodeFunc func(ctx context.Context, node *models.ServerNode, jenkins *gojenkins.Jenkins) (interface{}, error)
+
+// selectNodes 根据 ID 选择节点, ids 为空时返回所有节点
+func selectNodes(ids []int) ([]models.ServerNode, error) {
+	if len(ids) == 0 {
+		return mysql.GetAllNodes()
+	}
+	nodes := make([]models.ServerNode, 0, len(ids))
+	for _, id := range ids {
+		node, err := mysql.GetNodeByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("获取节点 [%d] 失败: %v", id, err)
+		}
+		nodes = append(nodes, *node)
+	}
+	return nodes, nil
+}
+
+// fanOut 在多个节点上并行执行 fn, 每个节点单独超时, 单个节点失败不影响其他节点
+func fanOut(ctx context.Context, p models.ParamMultiNode, fn nodeFunc) ([]models.NodeResult, error) {
+	nodes, err := selectNodes(p.NodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	timeout := defaultNodeTimeout
+	if p.Timeout > 0 {
+		timeout = time.Duration(p.Timeout) * time.Second
+	}
+
+	results := make([]models.NodeResult, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int, node *models.ServerNode) {
+			defer wg.Done()
+			results[i] = runOnNode(ctx, node, timeout, fn)
+		}(i, &nodes[i])
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func runOnNode(ctx context.Context, node *models.ServerNode, timeout time.Duration, fn nodeFunc) models.NodeResult {
+	res := models.NodeResult{NodeID: node.ID, NodeName: node.Name}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		data interface{}
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// gojenkins 的请求不感知 ctx, 这里同时给 http.Client 设置超时
+		jenkins, err := newJenkins(ctx, node, &http.Client{Timeout: timeout})
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		data, err := fn(ctx, node, jenkins)
+		done <- result{data: data, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			res.Error = r.err.Error()
+			return res
+		}
+		res.Success = true
+		res.Data = r.data
+	case <-ctx.Done():
+		res.Error = fmt.Sprintf("节点 [%s] 超时", node.Name)
+	}
+	return res
+}
+
+// jobTree Jenkins 递归 Job 列表
+type jobTree struct {
+	Jobs []struct {
+		Class string `json:"_class"`
+		Name  string `json:"name"`
+		URL   string `json:"url"`
+		Color string `json:"color"`
+		jobTree
+	} `json:"jobs"`
+}
+
+// jobTreeQuery 一次请求获取最多 4 层目录的 Job
+const jobTreeQuery = "jobs[_class,name,url,color,jobs[_class,name,url,color,jobs[_class,name,url,color,jobs[_class,name,url,color]]]]"
+
+func collectJobs(node *models.ServerNode, tree *jobTree, prefix string, match func(string) bool, out *[]models.JobSearchResult) {
+	for _, job := range tree.Jobs {
+		path := job.Name
+		if prefix != "" {
+			path = prefix + "/" + job.Name
+		}
+		if strings.Contains(job.Class, "Folder") {
+			collectJobs(node, &job.jobTree, path, match, out)
+			continue
+		}
+		if match(job.Name) {
+			*out = append(*out, models.JobSearchResult{
+				NodeID:   node.ID,
+				NodeName: node.Name,
+				Path:     path,
+				Name:     job.Name,
+				URL:      job.URL,
+				Color:    job.Color,
+			})
+		}
+	}
+}
+
+// MultiSearchJobs 在多个节点上按名称或正则搜索 Job 并合并结果
+func MultiSearchJobs(ctx context.Context, p *models.ParamMultiSearch) (*models.MultiSearchResult, error) {
+	var match func(string) bool
+	if p.Regex {
+		re, err := regexp.Compile(p.Keyword)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %v", err)
+		}
+		match = re.MatchString
+	} else {
+		keyword := strings.ToLower(p.Keyword)
+		match = func(name string) bool {
+			return strings.Contains(strings.ToLower(name), keyword)
+		}
+	}
+
+	nodes, err := fanOut(ctx, p.ParamMultiNode, func(ctx context.Context, node *models.ServerNode, jenkins *gojenkins.Jenkins) (interface{}, error) {
+		var tree jobTree
+		if _, err := jenkins.Requester.GetJSON(ctx, "/", &tree, map[string]string{"tree": jobTreeQuery}); err != nil {
+			return nil, err
+		}
+		jobs := make([]models.JobSearchResult, 0)
+		collectJobs(node, &tree, "", match, &jobs)
+		return jobs, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &models.MultiSearchResult{Jobs: []models.JobSearchResult{}, Nodes: nodes}
+	for i := range nodes {
+		if jobs, ok := nodes[i].Data.([]models.JobSearchResult); ok {
+			res.Jobs = append(res.Jobs, jobs...)
+		}
+		nodes[i].Data = nil
+	}
+	sort.SliceStable(res.Jobs, func(i, j int) bool {
+		if res.Jobs[i].Path != res.Jobs[j].Path {
+			return res.Jobs[i].Path < res.Jobs[j].Path
+		}
+		return res.Jobs[i].NodeID < res.Jobs[j].NodeID
+	})
+	return res, nil
+}
+
+// MultiBuildJobs 在多个节点上触发同名 Job 的构建
+func MultiBuildJobs(ctx context.Context, p *models.ParamMultiBuild) ([]models.NodeResult, error) {
+	return fanOut(ctx, p.ParamMultiNode, func(ctx context.Context, node *models.ServerNode, jenkins *gojenkins.Jenkins) (interface{}, error) {
+		queueID, err := triggerBuild(ctx, jenkins, p.ViewID, p.JobName, p.Params, metrics.SourceMultiNode)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int64{"queue_id": queueID}, nil
+	})
+}
+
+// MultiStopJobs 在多个节点上停止同名 Job 的最新构建
+func MultiStopJobs(ctx context.Context, p *models.ParamMultiStop) ([]models.NodeResult, error) {
+	return fanOut(ctx, p.ParamMultiNode, func(ctx context.Context, node *models.ServerNode, jenkins *gojenkins.Jenkins) (interface{}, error) {
+		number, err := stopLatestBuild(ctx, jenkins, p.ViewID, p.JobName)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int64{"build_number": number}, nil
+	})
+}
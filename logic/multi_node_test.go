@@ -0,0 +1,175 @@
+package logic
+
+import (
+	"bluebell/models"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSearchJenkins 返回指定 Job 树的 fake Jenkins, tree 为 /api/json?tree=... 的响应
+func newSearchJenkins(t *testing.T, tree string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/json" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("tree") != "" {
+			fmt.Fprint(w, tree)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newSlowJenkins 返回在请求被取消前不响应的 fake Jenkins
+func newSlowJenkins(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// nodeResult 按节点 ID 查找结果
+func nodeResult(t *testing.T, results []models.NodeResult, nodeID int) models.NodeResult {
+	t.Helper()
+	for _, r := range results {
+		if r.NodeID == nodeID {
+			return r
+		}
+	}
+	t.Fatalf("no result for node %d in %+v", nodeID, results)
+	return models.NodeResult{}
+}
+
+func TestMultiSearchJobs(t *testing.T) {
+	a := addTestNode(t, newSearchJenkins(t, `{"jobs":[
+		{"_class":"hudson.model.FreeStyleProject","name":"api-server","url":"http://a/job/api-server/","color":"blue"},
+		{"_class":"com.cloudbees.hudson.plugins.folder.Folder","name":"team","jobs":[
+			{"_class":"org.jenkinsci.plugins.workflow.job.WorkflowJob","name":"API-gateway","url":"http://a/job/team/job/API-gateway/","color":"red"},
+			{"_class":"hudson.model.FreeStyleProject","name":"web","url":"http://a/job/team/job/web/","color":"blue"}
+		]}
+	]}`).URL)
+	b := addTestNode(t, newSearchJenkins(t, `{"jobs":[
+		{"_class":"hudson.model.FreeStyleProject","name":"api-server","url":"http://b/job/api-server/","color":"blue"}
+	]}`).URL)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	c := addTestNode(t, down.URL)
+
+	res, err := MultiSearchJobs(context.Background(), &models.ParamMultiSearch{
+		ParamMultiNode: models.ParamMultiNode{NodeIDs: []int{a, b, c}},
+		Keyword:        "api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按路径和节点排序, 目录中的 Job 带上目录前缀
+	want := []string{
+		fmt.Sprintf("%d:api-server", a),
+		fmt.Sprintf("%d:api-server", b),
+		fmt.Sprintf("%d:team/API-gateway", a),
+	}
+	var got []string
+	for _, j := range res.Jobs {
+		got = append(got, fmt.Sprintf("%d:%s", j.NodeID, j.Path))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("jobs = %v, want %v", got, want)
+	}
+	// 单个节点失败不影响其他节点, 结果中不再携带各节点的数据
+	for _, id := range []int{a, b} {
+		if r := nodeResult(t, res.Nodes, id); !r.Success || r.Data != nil {
+			t.Errorf("node %d result = %+v, want success without data", id, r)
+		}
+	}
+	if r := nodeResult(t, res.Nodes, c); r.Success || r.Error == "" {
+		t.Errorf("unreachable node result = %+v, want error", r)
+	}
+
+	res, err = MultiSearchJobs(context.Background(), &models.ParamMultiSearch{
+		ParamMultiNode: models.ParamMultiNode{NodeIDs: []int{a}},
+		Keyword:        "^(web|api-server)$",
+		Regex:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Jobs) != 2 || res.Jobs[0].Path != "api-server" || res.Jobs[1].Path != "team/web" {
+		t.Errorf("regex search = %+v", res.Jobs)
+	}
+
+	if _, err := MultiSearchJobs(context.Background(), &models.ParamMultiSearch{Keyword: "(", Regex: true}); err == nil {
+		t.Error("invalid regex accepted")
+	}
+}
+
+func TestMultiBuildJobs(t *testing.T) {
+	f1, f2 := newFakeWorkflowJenkins(t), newFakeWorkflowJenkins(t)
+	a, b := addTestNode(t, f1.URL), addTestNode(t, f2.URL)
+	slow := addTestNode(t, newSlowJenkins(t).URL)
+
+	start := time.Now()
+	results, err := MultiBuildJobs(context.Background(), &models.ParamMultiBuild{
+		ParamMultiNode: models.ParamMultiNode{NodeIDs: []int{a, slow, b}, Timeout: 1},
+		ViewID:         "app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 节点并行执行, 慢节点按单个节点的超时时间返回
+	if d := time.Since(start); d > 3*time.Second {
+		t.Errorf("fan-out took %v, want about the per-node timeout", d)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v, want 3", results)
+	}
+	for i, id := range []int{a, slow, b} {
+		if results[i].NodeID != id {
+			t.Errorf("results[%d].NodeID = %d, want %d (request order)", i, results[i].NodeID, id)
+		}
+	}
+	for _, id := range []int{a, b} {
+		r := nodeResult(t, results, id)
+		if data, ok := r.Data.(map[string]int64); !r.Success || !ok || data["queue_id"] != 101 {
+			t.Errorf("node %d result = %+v, want queue_id 101", id, r)
+		}
+	}
+	if r := nodeResult(t, results, slow); r.Success || r.Error == "" {
+		t.Errorf("slow node result = %+v, want timeout error", r)
+	}
+	if f1.triggered != 1 || f2.triggered != 1 {
+		t.Errorf("triggered = %d, %d, want 1 on each node", f1.triggered, f2.triggered)
+	}
+
+	// Job 在节点上不存在时返回该节点的错误
+	results, err = MultiBuildJobs(context.Background(), &models.ParamMultiBuild{
+		ParamMultiNode: models.ParamMultiNode{NodeIDs: []int{a}},
+		ViewID:         "missing",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Success {
+		t.Errorf("build of missing job = %+v, want error", results[0])
+	}
+}
+
+func TestMultiNodeUnknownNode(t *testing.T) {
+	if _, err := MultiStopJobs(context.Background(), &models.ParamMultiStop{
+		ParamMultiNode: models.ParamMultiNode{NodeIDs: []int{-1}},
+		ViewID:         "app",
+	}); err == nil {
+		t.Error("unknown node accepted")
+	}
+}
@@ -3,10 +3,29 @@ package logic
 import (
 	"bluebell/dao/mysql"
 	"bluebell/models"
-	"fmt"
+	"errors"
+	"strconv"
 )
 
+var (
+	ErrorNodePasswordRequired = errors.New("节点密码不能为空")
+	ErrorNodePasswordReenter  = errors.New("修改节点地址或账号时需要重新填写密码")
+)
+
+// GetNode 根据请求中的节点 ID 获取节点
+// 请求 Jenkins 时只使用节点中保存的地址和账号, 不接受客户端传入的连接信息, 保证权限校验的节点就是实际访问的节点
+func GetNode(id string) (*models.ServerNode, error) {
+	nodeID, err := strconv.Atoi(id)
+	if err != nil || nodeID <= 0 {
+		return nil, mysql.ErrorNodeNotExist
+	}
+	return mysql.GetNodeByID(nodeID)
+}
+
 func AddNode(n *models.ServerNode) (err error) {
+	if n.Password == "" {
+		return ErrorNodePasswordRequired
+	}
 	if err := mysql.AddNode(n); err != nil {
 		return err
 	}
@@ -22,23 +41,57 @@ func GetServerNodes(name string) (nodes []models.ServerNode, err error) {
 	return nodes, nil
 }
 
+// FilterVisibleNodes 只保留用户拥有查看权限的节点, 不限节点的授权可以查看所有节点
+func FilterVisibleNodes(userID int64, nodes []models.ServerNode) ([]models.ServerNode, error) {
+	grants, err := mysql.GetUserGrants(userID)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[int]bool)
+	for _, g := range grants {
+		if g.Permission != models.PermView && g.Permission != models.PermAdmin {
+			continue
+		}
+		if g.NodeID == 0 {
+			return nodes, nil
+		}
+		visible[g.NodeID] = true
+	}
+	filtered := make([]models.ServerNode, 0, len(nodes))
+	for _, n := range nodes {
+		if visible[n.ID] {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered, nil
+}
+
 func GetAllNodes() ([]models.ServerNode, error) {
 	nodes, err := mysql.GetAllNodes()
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 
 	if len(nodes) == 0 {
-		fmt.Println("len(nodes) == 0")
 		return []models.ServerNode{}, nil
 	}
 
 	return nodes, nil
 }
 
+// UpdateNode 更新节点, 密码为空时保留原密码 (节点列表不返回密码)
+// 地址或账号改变时必须重新填写密码, 否则保存的密码会被发送到新的地址
 func UpdateNode(id int, updatedNode models.ServerNode) error {
-
+	if updatedNode.Password == "" {
+		old, err := mysql.GetNodeByID(id)
+		if err != nil {
+			return err
+		}
+		if updatedNode.Host != old.Host || updatedNode.Port != old.Port || updatedNode.Account != old.Account {
+			return ErrorNodePasswordReenter
+		}
+		updatedNode.Password = old.Password
+	}
 	err := mysql.UpdateNode(id, &updatedNode)
 	if err != nil {
 		return err
@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"testing"
+)
+
+// 只修改名称等信息时保留原密码, 修改地址或账号时必须重新填写密码
+func TestUpdateNodePassword(t *testing.T) {
+	id := addTestNode(t, "http://10.0.0.1:8080")
+	node, err := mysql.GetNodeByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node.Password = "admin-pw"
+	if err := UpdateNode(id, *node); err != nil {
+		t.Fatalf("UpdateNode: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(n *models.ServerNode)
+		wantErr error
+	}{
+		{"rename", func(n *models.ServerNode) { n.Name += "-renamed"; n.Remark = "r" }, nil},
+		{"host", func(n *models.ServerNode) { n.Host = "attacker.example.com" }, ErrorNodePasswordReenter},
+		{"port", func(n *models.ServerNode) { n.Port = "9090" }, ErrorNodePasswordReenter},
+		{"account", func(n *models.ServerNode) { n.Account = "other" }, ErrorNodePasswordReenter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := mysql.GetNodeByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			updated := *current
+			updated.Password = ""
+			tt.change(&updated)
+			if err := UpdateNode(id, updated); err != tt.wantErr {
+				t.Fatalf("UpdateNode: err = %v, want %v", err, tt.wantErr)
+			}
+			got, err := mysql.GetNodeByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Password != "admin-pw" {
+				t.Errorf("password = %q, want kept", got.Password)
+			}
+			if tt.wantErr != nil && (got.Host != current.Host || got.Port != current.Port || got.Account != current.Account) {
+				t.Errorf("node changed without password: %+v", got)
+			}
+		})
+	}
+
+	// 重新填写密码后可以修改地址
+	current, _ := mysql.GetNodeByID(id)
+	current.Host, current.Password = "10.0.0.2", "new-pw"
+	if err := UpdateNode(id, *current); err != nil {
+		t.Fatalf("UpdateNode with password: %v", err)
+	}
+	if got, _ := mysql.GetNodeByID(id); got.Host != "10.0.0.2" || got.Password != "new-pw" {
+		t.Errorf("node = %+v", got)
+	}
+}
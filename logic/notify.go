@@ -0,0 +1,340 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/notify"
+	"bluebell/setting"
+	"bytes"
+	"fmt"
+	"path"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bndr/gojenkins"
+	"go.uber.org/zap"
+)
+
+const (
+	notifyMaxAttempts = 3           // 每条通知最多发送次数
+	notifyRetryDelay  = time.Second // 首次重试间隔, 之后按指数递增
+	notifyLogLimit    = 200         // 查询发送记录的最大条数
+)
+
+const defaultNotifyTemplate = `【Jenkins 构建通知】{{.EventName}}
+节点: {{.NodeName}}
+Job: {{.JobPath}} #{{.BuildNumber}}
+结果: {{.Result}}{{if .PreviousResult}} (上次: {{.PreviousResult}}){{end}}
+耗时: {{.DurationText}}
+时间: {{.Time}}{{if .URL}}
+链接: {{.URL}}{{end}}`
+
+var notifyEventNames = map[string]string{
+	models.NotifyEventFailure:      "构建失败",
+	models.NotifyEventRecovery:     "构建恢复",
+	models.NotifyEventLongDuration: "构建耗时过长",
+}
+
+// notifyTemplateData 渲染消息模板时使用的数据
+type notifyTemplateData struct {
+	*models.BuildEvent
+	EventName    string
+	DurationText string
+}
+
+// observeMu 保证同一时间只处理一个构建事件, 避免多处同时观察到同一构建时重复通知
+var observeMu sync.Mutex
+
+func validateNotifyRule(r *models.NotifyRule) error {
+	switch r.Channel {
+	case notify.ChannelWebhook, notify.ChannelEmail, notify.ChannelDingTalk, notify.ChannelWeCom, notify.ChannelFeishu:
+	default:
+		return fmt.Errorf("不支持的通知渠道 [%s]", r.Channel)
+	}
+	for _, e := range r.Events {
+		if _, ok := notifyEventNames[e]; !ok {
+			return fmt.Errorf("不支持的事件类型 [%s]", e)
+		}
+		if e == models.NotifyEventLongDuration && r.DurationThreshold <= 0 {
+			return fmt.Errorf("long_duration 事件需要设置 duration_threshold")
+		}
+	}
+	if r.Channel != notify.ChannelEmail {
+		if err := notify.ValidateTarget(r.Target); err != nil {
+			return err
+		}
+	}
+	if _, err := path.Match(r.JobPattern, ""); err != nil {
+		return fmt.Errorf("job_pattern 无效: %v", err)
+	}
+	if r.Template != "" {
+		if _, err := template.New("notify").Parse(r.Template); err != nil {
+			return fmt.Errorf("消息模板无效: %v", err)
+		}
+	}
+	return nil
+}
+
+// AddNotifyRule 新增通知规则
+func AddNotifyRule(r *models.NotifyRule) error {
+	if err := validateNotifyRule(r); err != nil {
+		return err
+	}
+	return mysql.AddNotifyRule(r)
+}
+
+// GetNotifyRules 获取通知规则列表
+func GetNotifyRules() ([]models.NotifyRule, error) {
+	rules, err := mysql.GetNotifyRules()
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return []models.NotifyRule{}, nil
+	}
+	return rules, nil
+}
+
+// GetNotifyRule 获取单个通知规则
+func GetNotifyRule(id int64) (*models.NotifyRule, error) {
+	return mysql.GetNotifyRuleByID(id)
+}
+
+// UpdateNotifyRule 更新通知规则, 未填写加签密钥时保留原密钥
+func UpdateNotifyRule(id int64, r *models.NotifyRule) error {
+	if err := validateNotifyRule(r); err != nil {
+		return err
+	}
+	if r.Secret == "" {
+		old, err := mysql.GetNotifyRuleByID(id)
+		if err != nil {
+			return err
+		}
+		r.Secret = old.Secret
+	}
+	return mysql.UpdateNotifyRule(id, r)
+}
+
+// DeleteNotifyRule 删除通知规则
+func DeleteNotifyRule(id int64) error {
+	return mysql.DeleteNotifyRule(id)
+}
+
+// GetNotifyLogs 获取通知发送记录
+func GetNotifyLogs(ruleID int64) ([]models.NotifyLog, error) {
+	logs, err := mysql.GetNotifyLogs(ruleID, notifyLogLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return []models.NotifyLog{}, nil
+	}
+	return logs, nil
+}
+
+// TestNotifyRule 使用示例事件同步发送一次通知, 用于验证渠道配置
+func TestNotifyRule(id int64) error {
+	r, err := mysql.GetNotifyRuleByID(id)
+	if err != nil {
+		return err
+	}
+	e := &models.BuildEvent{
+		NodeName:    "test",
+		JobPath:     "test/job",
+		BuildNumber: 1,
+		Result:      "FAILURE",
+		Event:       models.NotifyEventFailure,
+		Time:        time.Now().Format(timeLayout),
+	}
+	msg, err := renderNotifyMessage(r, e)
+	if err != nil {
+		return err
+	}
+	return notify.Send(r.Channel, r.Target, r.Secret, smtpConfig(), msg)
+}
+
+func jobPath(viewID, jobName string) string {
+	if jobName == "" {
+		return viewID
+	}
+	return viewID + "/" + jobName
+}
+
+func isFailedResult(result string) bool {
+	return result == "FAILURE" || result == "UNSTABLE"
+}
+
+// ObserveBuild 记录后端观察到的已结束构建, 与上次记录的结果比较后触发通知
+// 同一构建只处理一次, 新观察到的构建会推送给订阅者, 返回该构建是否为新观察到的
+func ObserveBuild(e *models.BuildEvent) bool {
+	if e.Result == "" || e.BuildNumber == 0 {
+		return false // 构建尚未结束
+	}
+	observeMu.Lock()
+	prev, err := mysql.GetJobBuildStatus(e.NodeID, e.ViewID, e.JobName)
+	if err != nil || (prev != nil && prev.BuildNumber >= e.BuildNumber) {
+		observeMu.Unlock()
+		return false
+	}
+	err = mysql.SaveJobBuildStatus(&models.JobBuildStatus{
+		NodeID:      e.NodeID,
+		ViewID:      e.ViewID,
+		JobName:     e.JobName,
+		BuildNumber: e.BuildNumber,
+		Result:      e.Result,
+	}, prev != nil)
+	observeMu.Unlock()
+	if err != nil {
+		return false
+	}
+
+	if prev != nil {
+		e.PreviousResult = prev.Result
+	}
+	e.JobPath = jobPath(e.ViewID, e.JobName)
+	if e.Time == "" {
+		e.Time = time.Now().Format(timeLayout)
+	}
+	if e.NodeName == "" {
+		if node, err := mysql.GetNodeByID(e.NodeID); err == nil && node != nil {
+			e.NodeName = node.Name
+		}
+	}
+	publishBuildEvent(*e)
+	go dispatchBuildEvent(*e)
+	if archiveStorage != nil {
+		go archiveObservedBuild(*e)
+	}
+	return true
+}
+
+// ObserveJenkinsBuild 根据 gojenkins 构建对象生成事件并交给 ObserveBuild, 构建仍在进行时忽略
+func ObserveJenkinsBuild(nodeID int, viewID, jobName string, build *gojenkins.Build) bool {
+	if build == nil || build.Raw.Building {
+		return false
+	}
+	return ObserveBuild(&models.BuildEvent{
+		NodeID:      nodeID,
+		ViewID:      viewID,
+		JobName:     jobName,
+		BuildNumber: build.GetBuildNumber(),
+		Result:      build.GetResult(),
+		Duration:    int64(build.GetDuration()),
+		URL:         build.GetUrl(),
+	})
+}
+
+// dispatchBuildEvent 按规则匹配事件并发送通知
+func dispatchBuildEvent(e models.BuildEvent) {
+	rules, err := mysql.GetEnabledNotifyRules(e.NodeID)
+	if err != nil {
+		zap.L().Error("mysql.GetEnabledNotifyRules failed", zap.Error(err))
+		return
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.JobPattern != "" {
+			if ok, _ := path.Match(r.JobPattern, e.JobPath); !ok {
+				continue
+			}
+		}
+		for _, event := range r.Events {
+			if !matchNotifyEvent(r, event, &e) {
+				continue
+			}
+			ev := e
+			ev.Event = event
+			deliverNotify(r, &ev)
+		}
+	}
+}
+
+func matchNotifyEvent(r *models.NotifyRule, event string, e *models.BuildEvent) bool {
+	switch event {
+	case models.NotifyEventFailure:
+		return isFailedResult(e.Result)
+	case models.NotifyEventRecovery:
+		return e.Result == "SUCCESS" && isFailedResult(e.PreviousResult)
+	case models.NotifyEventLongDuration:
+		return r.DurationThreshold > 0 && e.Duration > r.DurationThreshold*1000
+	}
+	return false
+}
+
+func renderNotifyMessage(r *models.NotifyRule, e *models.BuildEvent) (*notify.Message, error) {
+	text := r.Template
+	if text == "" {
+		text = defaultNotifyTemplate
+	}
+	tmpl, err := template.New("notify").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	data := notifyTemplateData{
+		BuildEvent:   e,
+		EventName:    notifyEventNames[e.Event],
+		DurationText: formatDurationMs(e.Duration),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return &notify.Message{
+		Title:   fmt.Sprintf("[%s] %s #%d", data.EventName, e.JobPath, e.BuildNumber),
+		Content: buf.String(),
+		Data:    e,
+	}, nil
+}
+
+// deliverNotify 发送通知, 失败时按指数退避重试, 并记录发送日志
+func deliverNotify(r *models.NotifyRule, e *models.BuildEvent) {
+	l := &models.NotifyLog{
+		RuleID:      r.ID,
+		Event:       e.Event,
+		NodeID:      e.NodeID,
+		JobPath:     e.JobPath,
+		BuildNumber: e.BuildNumber,
+		Channel:     r.Channel,
+		Status:      "failed",
+	}
+	msg, err := renderNotifyMessage(r, e)
+	if err == nil {
+		l.Content = msg.Content
+		delay := notifyRetryDelay
+		for l.Attempts = 1; ; l.Attempts++ {
+			err = notify.Send(r.Channel, r.Target, r.Secret, smtpConfig(), msg)
+			if err == nil || l.Attempts >= notifyMaxAttempts {
+				break
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	if err != nil {
+		l.Error = err.Error()
+		zap.L().Error("deliver notify failed", zap.Int64("ruleId", r.ID), zap.String("event", e.Event), zap.Error(err))
+	} else {
+		l.Status = "success"
+	}
+	_ = mysql.AddNotifyLog(l)
+}
+
+func smtpConfig() *notify.SMTPConfig {
+	cfg := setting.Conf.SMTPConfig
+	if cfg == nil {
+		return nil
+	}
+	return &notify.SMTPConfig{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
+
+// formatDurationMs 将毫秒转换为可读的时长
+func formatDurationMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/notify"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 加签密钥只写: 不出现在响应中, 更新时留空保留原密钥
+func TestNotifyRuleSecret(t *testing.T) {
+	r := &models.NotifyRule{
+		Name:    uniqueName("rule"),
+		Channel: notify.ChannelWebhook,
+		Target:  "http://example.com/hook",
+		Events:  models.StringList{models.NotifyEventFailure},
+		Secret:  "s3cret",
+	}
+	if err := AddNotifyRule(r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mysql.GetNotifyRuleByID(r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), `"secret"`) {
+		t.Errorf("secret in response: %s", data)
+	}
+	if !strings.Contains(string(data), `"target":"http://example.com/hook"`) {
+		t.Errorf("other fields missing: %s", data)
+	}
+
+	// 编辑页提交的规则不含密钥
+	var update models.NotifyRule
+	if err := json.Unmarshal(data, &update); err != nil {
+		t.Fatal(err)
+	}
+	update.Target = "http://example.com/hook2"
+	if err := UpdateNotifyRule(r.ID, &update); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = mysql.GetNotifyRuleByID(r.ID); got.Secret != "s3cret" || got.Target != "http://example.com/hook2" {
+		t.Errorf("after update without secret: secret = %q, target = %q", got.Secret, got.Target)
+	}
+
+	update.Secret = "rotated"
+	if err := UpdateNotifyRule(r.ID, &update); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = mysql.GetNotifyRuleByID(r.ID); got.Secret != "rotated" {
+		t.Errorf("after rotation: secret = %q", got.Secret)
+	}
+}
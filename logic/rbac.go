@@ -0,0 +1,388 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/password"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorRoleNotExist = errors.New("角色不存在")
+	ErrorRoleExist    = errors.New("角色名已存在")
+	ErrorBuiltinRole  = errors.New("内置角色不能修改或删除")
+	ErrorLastAdmin    = errors.New("至少需要保留一个管理员")
+)
+
+var validPermissions = map[string]bool{
+	models.PermView:        true,
+	models.PermBuild:       true,
+	models.PermStop:        true,
+	models.PermDeleteBuild: true,
+	models.PermManageNode:  true,
+	models.PermAdmin:       true,
+}
+
+func validateRole(r *models.Role) error {
+	for _, p := range r.Permissions {
+		if !validPermissions[p.Permission] {
+			return fmt.Errorf("不支持的权限 [%s]", p.Permission)
+		}
+		if p.NodeID < 0 {
+			return fmt.Errorf("node_id 无效: %d", p.NodeID)
+		}
+		if _, err := path.Match(strings.TrimSuffix(p.JobPattern, "/**"), ""); err != nil {
+			return fmt.Errorf("job_pattern 无效: %v", err)
+		}
+	}
+	return nil
+}
+
+// AddRole 新增角色
+func AddRole(r *models.Role) error {
+	if err := validateRole(r); err != nil {
+		return err
+	}
+	exist, err := mysql.GetRoleByName(r.Name)
+	if err != nil {
+		return err
+	}
+	if exist != nil {
+		return ErrorRoleExist
+	}
+	return mysql.AddRole(r)
+}
+
+// GetRoles 获取角色列表
+func GetRoles() ([]models.Role, error) {
+	roles, err := mysql.GetRoles()
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return []models.Role{}, nil
+	}
+	return roles, nil
+}
+
+// GetRole 获取单个角色
+func GetRole(id int64) (*models.Role, error) {
+	r, err := mysql.GetRoleByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, ErrorRoleNotExist
+	}
+	return r, nil
+}
+
+// UpdateRole 更新角色, 内置的管理员角色不能修改
+func UpdateRole(id int64, r *models.Role) error {
+	if err := validateRole(r); err != nil {
+		return err
+	}
+	old, err := GetRole(id)
+	if err != nil {
+		return err
+	}
+	if old.Name == models.AdminRoleName {
+		return ErrorBuiltinRole
+	}
+	exist, err := mysql.GetRoleByName(r.Name)
+	if err != nil {
+		return err
+	}
+	if exist != nil && exist.ID != id {
+		return ErrorRoleExist
+	}
+	return mysql.UpdateRole(id, r)
+}
+
+// DeleteRole 删除角色, 内置的管理员角色不能删除
+func DeleteRole(id int64) error {
+	old, err := GetRole(id)
+	if err != nil {
+		return err
+	}
+	if old.Name == models.AdminRoleName {
+		return ErrorBuiltinRole
+	}
+	return mysql.DeleteRole(id)
+}
+
+// SetUserRoles 设置用户的角色, 不允许移除最后一个管理员
+func SetUserRoles(p *models.ParamUserRoles) error {
+	exist, err := mysql.CheckUserIDExist(p.UserID)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return mysql.ErrorUserNotExist
+	}
+	grantsAdmin := false
+	for _, id := range p.RoleIDs {
+		r, err := mysql.GetRoleByID(id)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return fmt.Errorf("角色 [%d] 不存在", id)
+		}
+		for _, rp := range r.Permissions {
+			if grantAllows(models.Grant{Permission: rp.Permission, NodeID: rp.NodeID, JobPattern: rp.JobPattern}, models.PermAdmin, models.Resource{}) {
+				grantsAdmin = true
+			}
+		}
+	}
+
+	if !grantsAdmin {
+		isAdmin, err := IsAdmin(p.UserID)
+		if err != nil {
+			return err
+		}
+		count, err := mysql.CountUsersWithPermission(models.PermAdmin)
+		if err != nil {
+			return err
+		}
+		if isAdmin && count <= 1 {
+			return ErrorLastAdmin
+		}
+	}
+	return mysql.SetUserRoles(p.UserID, p.RoleIDs)
+}
+
+// GetUserRoles 获取用户的角色
+func GetUserRoles(userID int64) ([]models.Role, error) {
+	return mysql.GetUserRoles(userID)
+}
+
+// IsAdmin 判断用户是否拥有全局的 admin 权限
+func IsAdmin(userID int64) (bool, error) {
+	return CheckPermission(userID, models.PermAdmin, nil)
+}
+
+// InitAdmins 启动时为配置的 admin_users 授予内置的管理员角色, 保留用户已有的角色
+// 配置的用户不存在时跳过; 系统中没有可用的管理员时输出警告
+func InitAdmins(usernames []string) error {
+	role, err := mysql.GetRoleByName(models.AdminRoleName)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return fmt.Errorf("内置角色 [%s] 不存在", models.AdminRoleName)
+	}
+	for _, name := range usernames {
+		user, err := mysql.GetUserByUsername(name)
+		if err == mysql.ErrorUserNotExist {
+			zap.L().Warn("admin user not exist", zap.String("username", name))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := mysql.AddUserRole(user.UserID, role.ID); err != nil {
+			return err
+		}
+		if password.IsLegacy(user.Password) {
+			zap.L().Warn("admin user still uses a legacy password, change it after login", zap.String("username", name))
+		}
+	}
+	count, err := mysql.CountUsersWithPermission(models.PermAdmin)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		zap.L().Warn("no admin user, configure admin_users in the config file")
+	}
+	return nil
+}
+
+// CheckPermission 判断用户对所有资源是否都拥有 perm 权限
+// resources 为空时表示全局操作, 需要不限节点和 Job 的授权
+func CheckPermission(userID int64, perm string, resources []models.Resource) (bool, error) {
+	grants, err := mysql.GetUserGrants(userID)
+	if err != nil {
+		return false, err
+	}
+	if len(resources) == 0 {
+		resources = []models.Resource{{}}
+	}
+	for _, res := range resources {
+		allowed := false
+		for _, g := range grants {
+			if grantAllows(g, perm, res) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// grantAllows 判断一条授权是否允许对资源执行 perm 操作
+func grantAllows(g models.Grant, perm string, res models.Resource) bool {
+	if g.Permission != perm && g.Permission != models.PermAdmin {
+		return false
+	}
+	if g.NodeID != 0 && g.NodeID != res.NodeID {
+		return false
+	}
+	if g.JobPattern == "" || g.JobPattern == "*" {
+		return true
+	}
+	if res.ViewID == "" {
+		// 不针对具体 Job 的请求 (如查看节点的 Job 列表), 只要求拥有节点上的查看权限
+		return perm == models.PermView && res.NodeID != 0
+	}
+	return matchJobPattern(g.JobPattern, jobPath(res.ViewID, res.JobName), perm == models.PermView)
+}
+
+// matchJobPattern 判断 Job 路径是否匹配授权的通配符
+// 以 /** 结尾时匹配目录下的所有 Job; browse 为 true 时, 授权范围内 Job 的上级目录也视为匹配, 便于逐级浏览
+func matchJobPattern(pattern, jobPath string, browse bool) bool {
+	if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern {
+		if ok, _ := path.Match(prefix, jobPath); ok {
+			return true
+		}
+		parts := strings.Split(jobPath, "/")
+		for i := 1; i < len(parts); i++ {
+			if ok, _ := path.Match(prefix, strings.Join(parts[:i], "/")); ok {
+				return true
+			}
+		}
+		pattern = prefix
+	} else if ok, _ := path.Match(pattern, jobPath); ok {
+		return true
+	}
+	return browse && strings.HasPrefix(pattern, jobPath+"/")
+}
+
+// GetUserAuthorities 获取用户的角色名和权限标识
+// 权限标识格式为 权限:节点:Job 通配符, 不限范围的部分为 *, 管理员为 *:*:*
+func GetUserAuthorities(userID int64) ([]string, []string, error) {
+	roles, err := mysql.GetUserRoles(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	grants, err := mysql.GetUserGrants(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	roleNames := make([]string, 0, len(roles))
+	for _, r := range roles {
+		roleNames = append(roleNames, r.Name)
+	}
+	perms := make([]string, 0, len(grants))
+	seen := make(map[string]bool)
+	for _, g := range grants {
+		perm, node, pattern := g.Permission, "*", g.JobPattern
+		if perm == models.PermAdmin {
+			perm = "*"
+		}
+		if g.NodeID != 0 {
+			node = strconv.Itoa(g.NodeID)
+		}
+		if pattern == "" {
+			pattern = "*"
+		}
+		s := perm + ":" + node + ":" + pattern
+		if !seen[s] {
+			seen[s] = true
+			perms = append(perms, s)
+		}
+	}
+	return roleNames, perms, nil
+}
+
+// HasPermission 判断用户是否在任意范围内拥有 perm 权限
+func HasPermission(userID int64, perm string) (bool, error) {
+	grants, err := mysql.GetUserGrants(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, g := range grants {
+		if g.Permission == perm || g.Permission == models.PermAdmin {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 按 ID 访问的记录类型, 权限按记录自身的节点和 Job 校验
+const (
+	RecordJobConfigVersion = "job_config_version"
+	RecordConsoleArchive   = "console_archive"
+	RecordSchedule         = "schedule"
+	RecordWorkflow         = "workflow"
+	RecordWorkflowRun      = "workflow_run"
+	RecordNotifyRule       = "notify_rule"
+)
+
+// RecordResources 获取记录涉及的资源, 记录不存在时返回 found 为 false
+// 不限节点的通知规则返回空资源, 需要不限节点的授权
+func RecordResources(kind string, id int64) (resources []models.Resource, found bool, err error) {
+	switch kind {
+	case RecordJobConfigVersion:
+		var v *models.JobConfigVersion
+		if v, err = mysql.GetJobConfigVersionByID(id); err == nil {
+			resources = []models.Resource{{NodeID: v.NodeID, ViewID: v.ViewID, JobName: v.JobName}}
+		}
+	case RecordConsoleArchive:
+		var a *models.ConsoleArchive
+		if a, err = mysql.GetConsoleArchiveByID(id); err == nil {
+			resources = []models.Resource{{NodeID: a.NodeID, ViewID: a.ViewID, JobName: a.JobName}}
+		}
+	case RecordSchedule:
+		var s *models.BuildSchedule
+		if s, err = mysql.GetScheduleByID(id); err == nil {
+			resources = []models.Resource{{NodeID: s.NodeID, ViewID: s.ViewID, JobName: s.JobName}}
+		}
+	case RecordWorkflow:
+		var w *models.Workflow
+		if w, err = mysql.GetWorkflowByID(id); err == nil {
+			resources = stepResources(w.Steps)
+		}
+	case RecordWorkflowRun:
+		// 运行记录保存了启动时的步骤快照, 按快照校验
+		var r *models.WorkflowRun
+		if r, err = mysql.GetWorkflowRunByID(id); err == nil {
+			resources = stepResources(r.Steps)
+		}
+	case RecordNotifyRule:
+		var r *models.NotifyRule
+		if r, err = mysql.GetNotifyRuleByID(id); err == nil && r.NodeID != 0 {
+			resources = []models.Resource{{NodeID: r.NodeID}}
+		}
+	default:
+		return nil, false, fmt.Errorf("未知的记录类型 [%s]", kind)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return resources, true, nil
+}
+
+// stepResources 工作流中 Job 步骤访问的资源, 审批步骤不涉及节点
+func stepResources(steps []models.WorkflowStep) []models.Resource {
+	resources := make([]models.Resource, 0, len(steps))
+	for _, st := range steps {
+		if st.NodeID > 0 {
+			resources = append(resources, models.Resource{NodeID: st.NodeID, ViewID: st.ViewID, JobName: st.JobName})
+		}
+	}
+	return resources
+}
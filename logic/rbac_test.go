@@ -0,0 +1,62 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/snowflake"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 注册的用户不会成为管理员, 管理员只能通过 admin_users 配置
+func TestSignUpNotAdmin(t *testing.T) {
+	var wg sync.WaitGroup
+	names := make([]string, 5)
+	for i := range names {
+		names[i] = uniqueName("signup")
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := SignUp(&models.ParamSignUp{Username: name, Password: "pw", RePassword: "pw"}); err != nil {
+				t.Errorf("SignUp %s: %v", name, err)
+			}
+		}(names[i])
+	}
+	wg.Wait()
+	for _, name := range names {
+		user, err := mysql.GetUserByUsername(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if roles := userRoleNames(t, user.UserID); len(roles) != 0 {
+			t.Errorf("%s roles = %v, want none", name, roles)
+		}
+	}
+}
+
+func TestInitAdmins(t *testing.T) {
+	dev := addTestRole(t, uniqueName("dev"), models.PermBuild)
+	name := uniqueName("boss")
+	user := &models.User{UserID: snowflake.GenID(), Username: name, Password: "admin123"}
+	if err := mysql.InsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := mysql.SetUserRoles(user.UserID, []int64{dev.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不存在的用户跳过, 重复执行不报错
+	for i := 0; i < 2; i++ {
+		if err := InitAdmins([]string{uniqueName("missing"), name}); err != nil {
+			t.Fatalf("InitAdmins: %v", err)
+		}
+	}
+	want := []string{models.AdminRoleName, dev.Name}
+	if got := userRoleNames(t, user.UserID); !reflect.DeepEqual(got, want) {
+		t.Errorf("roles = %v, want %v", got, want)
+	}
+	if ok, err := IsAdmin(user.UserID); !ok || err != nil {
+		t.Errorf("IsAdmin = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,274 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/dao/redis"
+	"bluebell/models"
+	"bluebell/pkg/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+const (
+	timeLayout = "2006-01-02 15:04:05"
+
+	scheduleTickInterval = 15 * time.Second // 调度器轮询间隔
+	scheduleMissGrace    = time.Minute      // 错过触发时间超过该值 (如服务停机期间) 时跳过本次
+	scheduleLockTTL      = 10 * time.Minute // Redis 触发锁过期时间
+)
+
+var (
+	ErrorScheduleTime  = errors.New("cron_expr 和 run_at 至少填写一个")
+	ErrorScheduleRunAt = errors.New("run_at 不能早于当前时间")
+)
+
+// nextRunTime 计算 after 之后的下一次触发时间, 没有下一次时返回空字符串
+func nextRunTime(s *models.BuildSchedule, after time.Time) (string, error) {
+	if s.CronExpr != "" {
+		sched, err := cron.ParseStandard(s.CronExpr)
+		if err != nil {
+			return "", fmt.Errorf("cron 表达式无效: %v", err)
+		}
+		return sched.Next(after).Format(timeLayout), nil
+	}
+	if s.RunAt != "" {
+		t, err := time.ParseInLocation(timeLayout, s.RunAt, time.Local)
+		if err != nil {
+			return "", fmt.Errorf("run_at 格式无效: %v", err)
+		}
+		if t.After(after) {
+			return t.Format(timeLayout), nil
+		}
+		return "", nil
+	}
+	return "", ErrorScheduleTime
+}
+
+// validateSchedule 校验计划, 并将每天的禁止窗口时间规范化为 HH:MM
+// 启用的一次性计划的 run_at 必须晚于当前时间, 否则永远不会触发
+func validateSchedule(s *models.BuildSchedule) error {
+	next, err := nextRunTime(s, time.Now())
+	if err != nil {
+		return err
+	}
+	if s.Enabled && next == "" {
+		return ErrorScheduleRunAt
+	}
+	for i := range s.Blackouts {
+		w := &s.Blackouts[i]
+		if w.From != "" || w.To != "" {
+			if _, err := time.ParseInLocation(timeLayout, w.From, time.Local); err != nil {
+				return fmt.Errorf("禁止窗口 from 格式无效: %v", err)
+			}
+			if _, err := time.ParseInLocation(timeLayout, w.To, time.Local); err != nil {
+				return fmt.Errorf("禁止窗口 to 格式无效: %v", err)
+			}
+			continue
+		}
+		start, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return fmt.Errorf("禁止窗口 start 格式无效: %v", err)
+		}
+		end, err := time.Parse("15:04", w.End)
+		if err != nil {
+			return fmt.Errorf("禁止窗口 end 格式无效: %v", err)
+		}
+		w.Start, w.End = start.Format("15:04"), end.Format("15:04")
+	}
+	return nil
+}
+
+// clockMinutes 将 15:04 格式的时间转换为当天零点起的分钟数, 兼容未补零的 9:00
+func clockMinutes(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// inBlackout 判断 t 是否处于任一禁止窗口内
+// 每天的窗口按零点起的分钟数比较, 不依赖字符串格式
+func inBlackout(windows models.BlackoutWindows, t time.Time) bool {
+	for _, w := range windows {
+		if w.From != "" {
+			from, err1 := time.ParseInLocation(timeLayout, w.From, time.Local)
+			to, err2 := time.ParseInLocation(timeLayout, w.To, time.Local)
+			if err1 == nil && err2 == nil && !t.Before(from) && t.Before(to) {
+				return true
+			}
+			continue
+		}
+		start, ok1 := clockMinutes(w.Start)
+		end, ok2 := clockMinutes(w.End)
+		if !ok1 || !ok2 {
+			continue
+		}
+		clock := t.Hour()*60 + t.Minute()
+		day := t
+		if start > end && clock < end {
+			// 跨零点窗口的后半段, 按窗口开始那天判断星期
+			day = t.AddDate(0, 0, -1)
+		}
+		if !matchWeekday(w.Weekdays, day.Weekday()) {
+			continue
+		}
+		if start <= end {
+			if clock >= start && clock < end {
+				return true
+			}
+		} else if clock >= start || clock < end {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWeekday(weekdays []int, d time.Weekday) bool {
+	if len(weekdays) == 0 {
+		return true
+	}
+	for _, wd := range weekdays {
+		if time.Weekday(wd) == d {
+			return true
+		}
+	}
+	return false
+}
+
+// AddSchedule 新增定时构建计划
+func AddSchedule(s *models.BuildSchedule) (err error) {
+	if err = validateSchedule(s); err != nil {
+		return err
+	}
+	if s.Enabled {
+		if s.NextRunTime, err = nextRunTime(s, time.Now()); err != nil {
+			return err
+		}
+	}
+	return mysql.AddSchedule(s)
+}
+
+// GetSchedules 获取计划列表
+func GetSchedules(nodeID int) ([]models.BuildSchedule, error) {
+	schedules, err := mysql.GetSchedules(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(schedules) == 0 {
+		return []models.BuildSchedule{}, nil
+	}
+	return schedules, nil
+}
+
+// GetSchedule 获取单个计划
+func GetSchedule(id int64) (*models.BuildSchedule, error) {
+	return mysql.GetScheduleByID(id)
+}
+
+// UpdateSchedule 更新计划, 并重新计算下次触发时间
+func UpdateSchedule(id int64, s *models.BuildSchedule) (err error) {
+	if err = validateSchedule(s); err != nil {
+		return err
+	}
+	s.NextRunTime = ""
+	if s.Enabled {
+		if s.NextRunTime, err = nextRunTime(s, time.Now()); err != nil {
+			return err
+		}
+	}
+	return mysql.UpdateSchedule(id, s)
+}
+
+// DeleteSchedule 删除计划
+func DeleteSchedule(id int64) error {
+	return mysql.DeleteSchedule(id)
+}
+
+// StartScheduler 启动定时构建调度器
+// 计划及下次触发时间保存在数据库中, 服务重启后继续生效
+func StartScheduler() {
+	go func() {
+		ticker := time.NewTicker(scheduleTickInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			runDueSchedules(now)
+		}
+	}()
+}
+
+func runDueSchedules(now time.Time) {
+	schedules, err := mysql.GetDueSchedules(now.Format(timeLayout))
+	if err != nil {
+		zap.L().Error("mysql.GetDueSchedules failed", zap.Error(err))
+		return
+	}
+	for i := range schedules {
+		runSchedule(&schedules[i], now)
+	}
+}
+
+// runSchedule 执行一次到期的计划
+// 先通过 Redis 锁 (已配置时) 和数据库条件更新抢占本次触发, 保证多实例部署时不会重复触发
+func runSchedule(s *models.BuildSchedule, now time.Time) {
+	prev := s.NextRunTime
+	next, err := nextRunTime(s, now)
+	if err != nil {
+		zap.L().Error("nextRunTime failed", zap.Int64("scheduleId", s.ID), zap.Error(err))
+		return
+	}
+
+	if redis.Enabled() {
+		ok, err := redis.TryLockSchedule(s.ID, prev, scheduleLockTTL)
+		if err != nil {
+			zap.L().Error("redis.TryLockSchedule failed", zap.Int64("scheduleId", s.ID), zap.Error(err))
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+
+	due, _ := time.ParseInLocation(timeLayout, prev, time.Local)
+	fire := false
+	var result string
+	switch {
+	case now.Sub(due) > scheduleMissGrace:
+		result = fmt.Sprintf("跳过: 错过触发时间 %s", prev)
+	case inBlackout(s.Blackouts, now):
+		result = "跳过: 处于禁止窗口"
+	default:
+		fire = true
+		result = "触发中"
+	}
+
+	claimed, err := mysql.ClaimScheduleRun(s.ID, prev, next, now.Format(timeLayout), result, next != "")
+	if err != nil || !claimed {
+		return
+	}
+	zap.L().Info("schedule due", zap.Int64("scheduleId", s.ID), zap.String("name", s.Name), zap.String("result", result))
+	if !fire {
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		result := ""
+		jenkins, err := newJenkinsByNodeID(ctx, s.NodeID)
+		if err == nil {
+			var queueID int64
+			queueID, err = triggerBuild(ctx, jenkins, s.ViewID, s.JobName, s.Params, metrics.SourceSchedule)
+			result = fmt.Sprintf("已触发, 队列ID: %d", queueID)
+		}
+		if err != nil {
+			zap.L().Error("schedule trigger failed", zap.Int64("scheduleId", s.ID), zap.Error(err))
+			result = fmt.Sprintf("触发失败: %v", err)
+		}
+		_ = mysql.UpdateScheduleResult(s.ID, result)
+	}()
+}
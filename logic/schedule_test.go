@@ -0,0 +1,201 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 2026-10-19 为周一
+func testClock(day, hour, min int) time.Time {
+	return time.Date(2026, 10, day, hour, min, 0, 0, time.Local)
+}
+
+func TestInBlackout(t *testing.T) {
+	daily := models.BlackoutWindow{Start: "9:00", End: "18:00"} // 未补零的时间按分钟数比较
+	overnight := models.BlackoutWindow{Start: "22:00", End: "06:00"}
+	weekend := models.BlackoutWindow{Start: "09:00", End: "18:00", Weekdays: []int{6, 0}}
+	mondayNight := models.BlackoutWindow{Start: "22:00", End: "06:00", Weekdays: []int{1}}
+	absolute := models.BlackoutWindow{From: "2026-10-19 09:00:00", To: "2026-10-19 12:00:00"}
+	invalid := models.BlackoutWindow{Start: "x", End: "y"}
+
+	tests := []struct {
+		name   string
+		window models.BlackoutWindow
+		at     time.Time
+		want   bool
+	}{
+		{"daily inside", daily, testClock(19, 10, 0), true},
+		{"daily start", daily, testClock(19, 9, 0), true},
+		{"daily before", daily, testClock(19, 8, 59), false},
+		{"daily end exclusive", daily, testClock(19, 18, 0), false},
+		{"overnight evening", overnight, testClock(19, 23, 0), true},
+		{"overnight morning", overnight, testClock(20, 5, 59), true},
+		{"overnight end exclusive", overnight, testClock(20, 6, 0), false},
+		{"overnight noon", overnight, testClock(19, 12, 0), false},
+		{"weekend on monday", weekend, testClock(19, 10, 0), false},
+		{"weekend on saturday", weekend, testClock(24, 10, 0), true},
+		{"weekend on sunday", weekend, testClock(25, 17, 59), true},
+		// 跨零点窗口的后半段按开始那天的星期判断
+		{"monday night on tuesday morning", mondayNight, testClock(20, 2, 0), true},
+		{"monday night on monday morning", mondayNight, testClock(19, 2, 0), false},
+		{"absolute inside", absolute, testClock(19, 10, 0), true},
+		{"absolute end exclusive", absolute, testClock(19, 12, 0), false},
+		{"absolute other day", absolute, testClock(20, 10, 0), false},
+		{"invalid ignored", invalid, testClock(19, 10, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inBlackout(models.BlackoutWindows{tt.window}, tt.at); got != tt.want {
+				t.Errorf("inBlackout(%+v, %s) = %v, want %v", tt.window, tt.at.Format(timeLayout), got, tt.want)
+			}
+		})
+	}
+	if !inBlackout(models.BlackoutWindows{weekend, daily}, testClock(19, 10, 0)) {
+		t.Error("any matching window should block")
+	}
+}
+
+func TestNextRunTime(t *testing.T) {
+	after := testClock(19, 10, 7)
+	tests := []struct {
+		name    string
+		s       models.BuildSchedule
+		want    string
+		wantErr bool
+	}{
+		{"cron daily", models.BuildSchedule{CronExpr: "0 2 * * *"}, "2026-10-20 02:00:00", false},
+		{"cron every 15 minutes", models.BuildSchedule{CronExpr: "*/15 * * * *"}, "2026-10-19 10:15:00", false},
+		{"cron weekdays", models.BuildSchedule{CronExpr: "30 9 * * 1-5"}, "2026-10-20 09:30:00", false},
+		{"cron wins over run_at", models.BuildSchedule{CronExpr: "0 12 * * *", RunAt: "2026-10-19 11:00:00"}, "2026-10-19 12:00:00", false},
+		{"cron invalid", models.BuildSchedule{CronExpr: "0 25 * * *"}, "", true},
+		{"run_at future", models.BuildSchedule{RunAt: "2026-10-19 11:00:00"}, "2026-10-19 11:00:00", false},
+		{"run_at past", models.BuildSchedule{RunAt: "2026-10-19 10:00:00"}, "", false},
+		{"run_at now", models.BuildSchedule{RunAt: "2026-10-19 10:07:00"}, "", false},
+		{"run_at invalid", models.BuildSchedule{RunAt: "2026/10/19 11:00"}, "", true},
+		{"none", models.BuildSchedule{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextRunTime(&tt.s, after)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("nextRunTime = %q, %v, want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(timeLayout)
+	past := time.Now().Add(-time.Hour).Format(timeLayout)
+	tests := []struct {
+		name    string
+		s       models.BuildSchedule
+		wantErr error
+		errText string
+	}{
+		{"cron", models.BuildSchedule{CronExpr: "0 2 * * *", Enabled: true}, nil, ""},
+		{"run_at future", models.BuildSchedule{RunAt: future, Enabled: true}, nil, ""},
+		{"run_at past", models.BuildSchedule{RunAt: past, Enabled: true}, ErrorScheduleRunAt, ""},
+		{"run_at past disabled", models.BuildSchedule{RunAt: past}, nil, ""},
+		{"no time", models.BuildSchedule{Enabled: true}, ErrorScheduleTime, ""},
+		{"bad start", models.BuildSchedule{CronExpr: "0 2 * * *", Blackouts: models.BlackoutWindows{{Start: "25:00", End: "06:00"}}}, nil, "start"},
+		{"bad end", models.BuildSchedule{CronExpr: "0 2 * * *", Blackouts: models.BlackoutWindows{{Start: "22:00"}}}, nil, "end"},
+		{"bad from", models.BuildSchedule{CronExpr: "0 2 * * *", Blackouts: models.BlackoutWindows{{From: "x", To: future}}}, nil, "from"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchedule(&tt.s)
+			switch {
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("err = %v, want error about %s", err, tt.errText)
+				}
+			case err != tt.wantErr:
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 每天的窗口保存为规范化的 HH:MM
+	s := models.BuildSchedule{CronExpr: "0 2 * * *", Blackouts: models.BlackoutWindows{{Start: "9:00", End: "18:00"}}}
+	if err := validateSchedule(&s); err != nil {
+		t.Fatal(err)
+	}
+	if w := s.Blackouts[0]; w.Start != "09:00" || w.End != "18:00" {
+		t.Errorf("window = %+v, want 09:00-18:00", w)
+	}
+}
+
+// waitScheduleResult 等待计划的执行结果包含 want
+func waitScheduleResult(t *testing.T, id int64, want string) *models.BuildSchedule {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s, err := mysql.GetScheduleByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(s.LastResult, want) {
+			return s
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("last_result = %q, want %q", s.LastResult, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunSchedule(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	nodeID := addTestNode(t, f.URL)
+	runDue := func(t *testing.T, s *models.BuildSchedule) time.Time {
+		t.Helper()
+		got, err := mysql.GetScheduleByID(s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		due, err := time.ParseInLocation(timeLayout, got.NextRunTime, time.Local)
+		if err != nil {
+			t.Fatalf("next_run_time = %q: %v", got.NextRunTime, err)
+		}
+		runSchedule(got, due)
+		return due
+	}
+
+	t.Run("blackout skips cron", func(t *testing.T) {
+		s := &models.BuildSchedule{Name: uniqueName("cron"), NodeID: nodeID, ViewID: "app", CronExpr: "* * * * *", Enabled: true,
+			Blackouts: models.BlackoutWindows{{Start: "0:00", End: "23:59"}, {Start: "23:59", End: "0:00"}}}
+		if err := AddSchedule(s); err != nil {
+			t.Fatal(err)
+		}
+		due := runDue(t, s)
+		got := waitScheduleResult(t, s.ID, "禁止窗口")
+		if !got.Enabled || got.NextRunTime != due.Add(time.Minute).Format(timeLayout) {
+			t.Errorf("schedule = %+v, want enabled with next run a minute later", got)
+		}
+		if f.triggered != 0 {
+			t.Errorf("triggered %d builds inside blackout", f.triggered)
+		}
+	})
+
+	t.Run("one-shot fires once", func(t *testing.T) {
+		s := &models.BuildSchedule{Name: uniqueName("once"), NodeID: nodeID, ViewID: "app", Enabled: true,
+			RunAt: time.Now().Add(time.Hour).Format(timeLayout)}
+		if err := AddSchedule(s); err != nil {
+			t.Fatal(err)
+		}
+		runDue(t, s)
+		got := waitScheduleResult(t, s.ID, "已触发")
+		if got.Enabled || got.NextRunTime != "" {
+			t.Errorf("schedule = %+v, want disabled without next run", got)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.triggered != 1 {
+			t.Errorf("triggered %d builds, want 1", f.triggered)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/jwt"
+	"bluebell/setting"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	refreshTokenExpireDuration = 7 * 24 * time.Hour
+	tokenExpiresLayout         = "2006/01/02 15:04:05" // 返回给前端的过期时间格式
+)
+
+// refreshTokenExpire refresh token 的有效期, 可在 jwt.refresh_expire 中配置
+func refreshTokenExpire() time.Duration {
+	if cfg := setting.Conf.JWTConfig; cfg != nil && cfg.RefreshExpire > 0 {
+		return time.Duration(cfg.RefreshExpire) * time.Hour
+	}
+	return refreshTokenExpireDuration
+}
+
+var ErrorInvalidRefreshToken = errors.New("refresh token 无效或已过期")
+
+// issueTokens 签发 access token 和 refresh token, familyID 为空时开始新的轮换链
+func issueTokens(user *models.User, familyID string) (*models.TokenPair, error) {
+	roles, _, err := GetUserAuthorities(user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	access, claims, err := jwt.GenToken(user.UserID, user.Username, roles)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(); err != nil {
+			return nil, err
+		}
+	}
+	t := &models.RefreshToken{
+		UserID:    user.UserID,
+		TokenHash: hashToken(refresh),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(refreshTokenExpire()).Format(timeLayout),
+	}
+	if err := mysql.AddRefreshToken(t); err != nil {
+		return nil, err
+	}
+	return &models.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		Expires:      time.Unix(claims.ExpiresAt, 0).Format(tokenExpiresLayout),
+	}, nil
+}
+
+// RefreshToken 使用 refresh token 换取新的 token, 旧的 refresh token 随即失效
+// 已失效的 refresh token 被再次使用说明可能已泄露, 吊销同一轮换链上的所有 token
+func RefreshToken(p *models.ParamRefreshToken) (*models.TokenPair, error) {
+	t, err := mysql.GetRefreshTokenByHash(hashToken(p.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.ExpiresAt < time.Now().Format(timeLayout) {
+		return nil, ErrorInvalidRefreshToken
+	}
+	rotated := false
+	if !t.Revoked {
+		if rotated, err = mysql.RevokeRefreshToken(t.ID); err != nil {
+			return nil, err
+		}
+	}
+	if !rotated {
+		zap.L().Warn("revoked refresh token reused, revoking family", zap.Int64("userId", t.UserID))
+		if err := mysql.RevokeRefreshTokenFamily(t.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrorInvalidRefreshToken
+	}
+
+	user, err := mysql.GetUserByID(t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrorInvalidRefreshToken
+	}
+	return issueTokens(user, t.FamilyID)
+}
+
+// Logout 注销: 吊销当前的 access token, 以及请求中 refresh token 所在的轮换链, all 为 true 时吊销用户所有的 refresh token
+func Logout(userID int64, claims *jwt.MyClaims, p *models.ParamLogout) error {
+	if claims != nil && claims.Id != "" {
+		expiresAt := time.Unix(claims.ExpiresAt, 0).Format(timeLayout)
+		if err := mysql.AddRevokedToken(claims.Id, expiresAt); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.All:
+		if err := mysql.RevokeUserRefreshTokens(userID); err != nil {
+			return err
+		}
+	case p.RefreshToken != "":
+		t, err := mysql.GetRefreshTokenByHash(hashToken(p.RefreshToken))
+		if err != nil {
+			return err
+		}
+		if t != nil && t.UserID == userID {
+			if err := mysql.RevokeRefreshTokenFamily(t.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+	// 顺带清理过期的记录
+	if err := mysql.DeleteExpiredTokens(time.Now().Format(timeLayout)); err != nil {
+		zap.L().Warn("delete expired tokens failed", zap.Error(err))
+	}
+	return nil
+}
+
+// IsTokenRevoked 判断 access token 是否已注销
+func IsTokenRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	return mysql.IsTokenRevoked(jti)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
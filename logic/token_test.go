@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"testing"
+)
+
+// addTokenUser 注册用户并签发一组 token
+func addTokenUser(t *testing.T) (*models.User, *models.TokenPair) {
+	t.Helper()
+	name := uniqueName("kate")
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: "p@ssw0rd", RePassword: "p@ssw0rd"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := mysql.GetUserByUsername(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, tokens
+}
+
+func refresh(token string) (*models.TokenPair, error) {
+	return RefreshToken(&models.ParamRefreshToken{RefreshToken: token})
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	_, first := addTokenUser(t)
+	second, err := refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatalf("refresh returned %+v, want a new token pair", second)
+	}
+	third, err := refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh rotated token: %v", err)
+	}
+	if _, err := refresh(third.RefreshToken); err != nil {
+		t.Fatalf("refresh latest token: %v", err)
+	}
+	if _, err := refresh("unknown"); err != ErrorInvalidRefreshToken {
+		t.Errorf("unknown token: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	user, first := addTokenUser(t)
+	second, err := refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一用户另一次登录的 token 属于另一条轮换链
+	other, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已轮换的 token 被再次使用 (如被窃取后重放), 整条轮换链被吊销
+	if _, err := refresh(first.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Fatalf("reused token: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+	for name, token := range map[string]string{"second": second.RefreshToken, "latest": third.RefreshToken} {
+		if _, err := refresh(token); err != ErrorInvalidRefreshToken {
+			t.Errorf("%s token after reuse: err = %v, want ErrorInvalidRefreshToken", name, err)
+		}
+	}
+	latest, err := mysql.GetRefreshTokenByHash(hashToken(third.RefreshToken))
+	if err != nil || latest == nil || !latest.Revoked {
+		t.Errorf("latest token record = %+v, %v, want revoked", latest, err)
+	}
+
+	// 其他轮换链不受影响
+	if _, err := refresh(other.RefreshToken); err != nil {
+		t.Errorf("token of another family: %v", err)
+	}
+}
+
+func TestLogoutRevokesFamily(t *testing.T) {
+	user, first := addTokenUser(t)
+	second, err := refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Logout(user.UserID, nil, &models.ParamLogout{RefreshToken: second.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refresh(second.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("after logout: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+	if _, err := refresh(other.RefreshToken); err != nil {
+		t.Errorf("other login after logout: %v", err)
+	}
+
+	again, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Logout(user.UserID, nil, &models.ParamLogout{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refresh(again.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("after logout all: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+}
@@ -0,0 +1,365 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/totp"
+	"bluebell/setting"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	totpSkew                  = 1 // 允许前后一个时间步的时钟误差
+	loginChallengeExpire      = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+	recoveryCodeCount         = 10
+)
+
+var (
+	ErrorTOTPAlreadyEnabled    = errors.New("已开启两步验证")
+	ErrorTOTPNotEnrolled       = errors.New("未绑定两步验证")
+	ErrorInvalidTOTPCode       = errors.New("验证码错误")
+	ErrorInvalidLoginChallenge = errors.New("登录已过期, 请重新登录")
+	ErrorTOTPRequired          = errors.New("所属角色要求开启两步验证, 不能关闭")
+)
+
+// TwoFactorRequiredError 密码校验通过, 需要完成两步验证后才签发 token
+// Enroll 为 true 时用户需要先绑定两步验证
+type TwoFactorRequiredError struct {
+	Challenge string
+	Enroll    bool
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	if e.Enroll {
+		return "所属角色要求开启两步验证, 请先绑定"
+	}
+	return "需要两步验证"
+}
+
+func totpIssuer() string {
+	if setting.Conf != nil && setting.Conf.Name != "" {
+		return setting.Conf.Name
+	}
+	return "bluebell"
+}
+
+// requireSecondFactor 用户开启了两步验证, 或所属角色要求两步验证时, 返回 TwoFactorRequiredError
+func requireSecondFactor(user *models.User) error {
+	t, err := mysql.GetUserTOTP(user.UserID)
+	if err != nil {
+		return err
+	}
+	enroll := false
+	if t == nil || !t.Enabled {
+		required, err := mysql.UserRequires2FA(user.UserID)
+		if err != nil || !required {
+			return err
+		}
+		enroll = true
+	}
+	raw, err := randomToken()
+	if err != nil {
+		return err
+	}
+	c := &models.LoginChallenge{
+		TokenHash: hashToken(raw),
+		UserID:    user.UserID,
+		Enroll:    enroll,
+		ExpiresAt: time.Now().Add(loginChallengeExpire).Format(timeLayout),
+	}
+	if err := mysql.AddLoginChallenge(c); err != nil {
+		return err
+	}
+	return &TwoFactorRequiredError{Challenge: raw, Enroll: enroll}
+}
+
+// getLoginChallenge 获取有效的登录挑战, 失败次数过多时作废
+func getLoginChallenge(raw string) (*models.LoginChallenge, error) {
+	c, err := mysql.GetLoginChallenge(hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil || c.ExpiresAt < time.Now().Format(timeLayout) {
+		return nil, ErrorInvalidLoginChallenge
+	}
+	if c.Attempts >= loginChallengeMaxAttempts {
+		if _, err := mysql.DeleteLoginChallenge(c.TokenHash); err != nil {
+			return nil, err
+		}
+		return nil, ErrorInvalidLoginChallenge
+	}
+	return c, nil
+}
+
+// LoginEnrollTOTP 登录时绑定两步验证 (所属角色要求两步验证但尚未绑定)
+func LoginEnrollTOTP(challenge string) (*models.TOTPEnrollment, error) {
+	c, err := getLoginChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Enroll {
+		return nil, ErrorTOTPAlreadyEnabled
+	}
+	return EnrollTOTP(c.UserID)
+}
+
+// Login2FA 登录第二步: 校验验证码或恢复码后签发 token
+// 登录时绑定两步验证的, 同时返回新生成的恢复码
+// 每个挑战最多尝试 loginChallengeMaxAttempts 次; 失败次数另按用户跨挑战累计, 与密码错误使用相同的锁定策略
+func Login2FA(p *models.ParamLogin2FA, ip string) (*models.User, *models.TokenPair, []string, error) {
+	c, err := getLoginChallenge(p.Challenge)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkTwoFactorAllowed(c.UserID, ip); err != nil {
+		return nil, nil, nil, err
+	}
+	// 先占用一次尝试机会再校验
+	claimed, err := mysql.ClaimLoginChallengeAttempt(c.TokenHash, loginChallengeMaxAttempts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !claimed {
+		if _, err := mysql.DeleteLoginChallenge(c.TokenHash); err != nil {
+			return nil, nil, nil, err
+		}
+		return nil, nil, nil, ErrorInvalidLoginChallenge
+	}
+	user, err := mysql.GetUserByID(c.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, nil, ErrorUserDisabled
+	}
+	t, err := mysql.GetUserTOTP(c.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if t == nil {
+		return nil, nil, nil, ErrorTOTPNotEnrolled
+	}
+
+	var ok bool
+	if c.Enroll {
+		// 绑定时只接受验证码
+		if !t.Enabled {
+			ok, err = verifyTOTP(t, p.Code)
+		}
+	} else if t.Enabled {
+		ok, err = verifySecondFactor(t, p.Code)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !ok {
+		recordTwoFactorFailure(c.UserID, ip)
+		return nil, nil, nil, ErrorInvalidTOTPCode
+	}
+	resetTwoFactorFailures(c.UserID)
+	// 挑战只能使用一次
+	if deleted, err := mysql.DeleteLoginChallenge(c.TokenHash); err != nil || !deleted {
+		if err == nil {
+			err = ErrorInvalidLoginChallenge
+		}
+		return nil, nil, nil, err
+	}
+
+	var codes []string
+	if c.Enroll {
+		if codes, err = newRecoveryCodes(c.UserID); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return user, tokens, codes, nil
+}
+
+// GetTOTPStatus 获取用户的两步验证状态
+func GetTOTPStatus(userID int64) (*models.TOTPStatus, error) {
+	t, err := mysql.GetUserTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := mysql.UserRequires2FA(userID)
+	if err != nil {
+		return nil, err
+	}
+	count, err := mysql.CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TOTPStatus{
+		Enabled:            t != nil && t.Enabled,
+		Required:           required,
+		RecoveryCodesCount: count,
+	}, nil
+}
+
+// EnrollTOTP 生成新的两步验证密钥, 使用验证码激活后生效
+func EnrollTOTP(userID int64) (*models.TOTPEnrollment, error) {
+	t, err := mysql.GetUserTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil && t.Enabled {
+		return nil, ErrorTOTPAlreadyEnabled
+	}
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := mysql.SaveUserTOTP(userID, secret); err != nil {
+		return nil, err
+	}
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer(), user.Username, secret),
+	}, nil
+}
+
+// ActivateTOTP 使用验证码激活两步验证, 返回恢复码 (只显示一次)
+func ActivateTOTP(userID int64, code string) ([]string, error) {
+	t, err := mysql.GetUserTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrorTOTPNotEnrolled
+	}
+	if t.Enabled {
+		return nil, ErrorTOTPAlreadyEnabled
+	}
+	if err := checkCode(userID, func() (bool, error) { return verifyTOTP(t, code) }); err != nil {
+		return nil, err
+	}
+	return newRecoveryCodes(userID)
+}
+
+// DisableTOTP 使用验证码或恢复码关闭两步验证, 所属角色要求两步验证时不能关闭
+func DisableTOTP(userID int64, code string) error {
+	required, err := mysql.UserRequires2FA(userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrorTOTPRequired
+	}
+	t, err := mysql.GetUserTOTP(userID)
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Enabled {
+		return ErrorTOTPNotEnrolled
+	}
+	if err := checkCode(userID, func() (bool, error) { return verifySecondFactor(t, code) }); err != nil {
+		return err
+	}
+	return mysql.DeleteUserTOTP(userID)
+}
+
+// RegenerateRecoveryCodes 使用验证码重新生成恢复码, 旧的恢复码失效
+func RegenerateRecoveryCodes(userID int64, code string) ([]string, error) {
+	t, err := mysql.GetUserTOTP(userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || !t.Enabled {
+		return nil, ErrorTOTPNotEnrolled
+	}
+	if err := checkCode(userID, func() (bool, error) { return verifyTOTP(t, code) }); err != nil {
+		return nil, err
+	}
+	return newRecoveryCodes(userID)
+}
+
+// ResetUserTOTP 管理员重置用户的两步验证 (用户丢失了身份验证器和恢复码)
+func ResetUserTOTP(userID int64) error {
+	if _, err := mysql.GetUserByID(userID); err != nil {
+		return err
+	}
+	return mysql.DeleteUserTOTP(userID)
+}
+
+// SetRoleRequire2FA 设置角色是否要求两步验证, 内置的管理员角色也可以设置
+func SetRoleRequire2FA(p *models.ParamRoleRequire2FA) error {
+	if _, err := GetRole(p.ID); err != nil {
+		return err
+	}
+	return mysql.SetRoleRequire2FA(p.ID, p.Require2FA)
+}
+
+// checkCode 已登录用户提交验证码或恢复码时的校验, 与登录的两步验证共用失败次数和锁定
+func checkCode(userID int64, verify func() (bool, error)) error {
+	if err := checkTwoFactorAllowed(userID, ""); err != nil {
+		return err
+	}
+	ok, err := verify()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		recordTwoFactorFailure(userID, "")
+		return ErrorInvalidTOTPCode
+	}
+	resetTwoFactorFailures(userID)
+	return nil
+}
+
+// verifyTOTP 校验验证码, 同一时间步的验证码只能使用一次; 首次校验通过时启用两步验证
+func verifyTOTP(t *models.UserTOTP, code string) (bool, error) {
+	counter, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return false, nil
+	}
+	return mysql.UseTOTPCounter(t.UserID, counter)
+}
+
+// verifySecondFactor 校验验证码, 不是验证码时按恢复码校验
+func verifySecondFactor(t *models.UserTOTP, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return verifyTOTP(t, code)
+	}
+	if code == "" {
+		return false, nil
+	}
+	return mysql.UseRecoveryCode(t.UserID, hashToken(normalizeRecoveryCode(code)))
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes 生成新的恢复码, 只保存哈希, 格式为 xxxx-xxxx
+func newRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		codes = append(codes, s[:4]+"-"+s[4:])
+		hashes = append(hashes, hashToken(s))
+	}
+	if err := mysql.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,203 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/totp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// addTOTPUser 新增开启了两步验证的本地用户, 返回用户 ID 和密钥
+// 激活时使用了当前时间步的验证码, 之后只能使用更大的时间步
+func addTOTPUser(t *testing.T, name, password string) (int64, string) {
+	t.Helper()
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: password, RePassword: password}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := mysql.GetUserByUsername(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := EnrollTOTP(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ActivateTOTP(user.UserID, totpCode(t, e.Secret, 0)); err != nil {
+		t.Fatalf("ActivateTOTP: %v", err)
+	}
+	return user.UserID, e.Secret
+}
+
+// totpCode 当前时间步之后第 offset 个时间步的验证码
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := totp.Code(secret, totp.Counter(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// wrongCode 与当前前后时间步的验证码都不同的错误验证码
+func wrongCode(t *testing.T, secret string) string {
+	t.Helper()
+	for _, c := range []string{"000000", "111111", "222222", "333333"} {
+		if _, ok := totp.Validate(secret, c, time.Now(), totpSkew+1); !ok {
+			return c
+		}
+	}
+	t.Fatal("no wrong code")
+	return ""
+}
+
+// loginChallenge 密码登录并返回两步验证的挑战
+func loginChallenge(t *testing.T, name, password, ip string) string {
+	t.Helper()
+	_, _, err := Login(&models.ParamLogin{Username: name, Password: password}, ip)
+	twoFactor, ok := err.(*TwoFactorRequiredError)
+	if !ok {
+		t.Fatalf("Login: err = %v, want TwoFactorRequiredError", err)
+	}
+	return twoFactor.Challenge
+}
+
+func TestTOTPReplay(t *testing.T) {
+	name := uniqueName("totp")
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: "pw", RePassword: "pw"}); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := mysql.GetUserByUsername(name)
+	e, err := EnrollTOTP(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := totpCode(t, e.Secret, 0)
+	if _, err := ActivateTOTP(user.UserID, code); err != nil {
+		t.Fatalf("ActivateTOTP: %v", err)
+	}
+	tp, err := mysql.GetUserTOTP(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 激活时使用过的验证码和更早的时间步不能再次使用
+	if ok, err := verifyTOTP(tp, code); ok || err != nil {
+		t.Errorf("replayed activation code: %v, %v", ok, err)
+	}
+	if ok, _ := verifyTOTP(tp, totpCode(t, e.Secret, -1)); ok {
+		t.Error("older step accepted")
+	}
+	next := totpCode(t, e.Secret, 1)
+	if ok, err := verifyTOTP(tp, next); !ok || err != nil {
+		t.Fatalf("next step: %v, %v", ok, err)
+	}
+	if ok, _ := verifyTOTP(tp, next); ok {
+		t.Error("next step replayed")
+	}
+}
+
+// 重新输入密码得到新的挑战, 两步验证的失败次数仍然累计并锁定
+func TestLogin2FALockoutAcrossChallenges(t *testing.T) {
+	name := uniqueName("totp")
+	userID, secret := addTOTPUser(t, name, "pw")
+	wrong := wrongCode(t, secret)
+
+	for i := 0; i < loginUserThreshold; i++ {
+		ch := loginChallenge(t, name, "pw", uniqueName("ip"))
+		if _, _, _, err := Login2FA(&models.ParamLogin2FA{Challenge: ch, Code: wrong}, uniqueName("ip")); err != ErrorInvalidTOTPCode {
+			t.Fatalf("attempt %d: err = %v, want ErrorInvalidTOTPCode", i, err)
+		}
+	}
+	ch := loginChallenge(t, name, "pw", uniqueName("ip"))
+	_, _, _, err := Login2FA(&models.ParamLogin2FA{Challenge: ch, Code: totpCode(t, secret, 1)}, uniqueName("ip"))
+	if _, ok := err.(*LoginLockedError); !ok {
+		t.Fatalf("err = %v, want LoginLockedError", err)
+	}
+
+	// 锁定过期后可以登录, 成功后清除失败次数
+	resetFailures(twoFactorCounters(userID, "")[0].key)
+	if _, tokens, _, err := Login2FA(&models.ParamLogin2FA{Challenge: ch, Code: totpCode(t, secret, 1)}, uniqueName("ip")); err != nil || tokens == nil {
+		t.Fatalf("Login2FA: %v", err)
+	}
+}
+
+// 同一挑战并发提交时, 校验次数不超过 loginChallengeMaxAttempts
+func TestLogin2FAChallengeAttempts(t *testing.T) {
+	name := uniqueName("totp")
+	_, secret := addTOTPUser(t, name, "pw")
+	ch := loginChallenge(t, name, "pw", uniqueName("ip"))
+	wrong := wrongCode(t, secret)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		verified int
+	)
+	for i := 0; i < 3*loginChallengeMaxAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 每次使用不同的 IP, 只受挑战和用户的次数限制
+			_, _, _, err := Login2FA(&models.ParamLogin2FA{Challenge: ch, Code: wrong}, uniqueName("ip"))
+			if err == ErrorInvalidTOTPCode {
+				mu.Lock()
+				verified++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if verified == 0 || verified > loginChallengeMaxAttempts {
+		t.Errorf("verified %d codes, want 1..%d", verified, loginChallengeMaxAttempts)
+	}
+	// 用完次数的挑战被作废
+	if _, _, _, err := Login2FA(&models.ParamLogin2FA{Challenge: ch, Code: totpCode(t, secret, 1)}, uniqueName("ip")); err == nil {
+		t.Error("exhausted challenge accepted")
+	}
+}
+
+// 关闭两步验证、重新生成恢复码也受失败次数限制
+func TestTOTPManagementThrottled(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(userID int64, code string) error
+	}{
+		{"disable", DisableTOTP},
+		{"regenerate", func(userID int64, code string) error {
+			_, err := RegenerateRecoveryCodes(userID, code)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, secret := addTOTPUser(t, uniqueName("totp"), "pw")
+			wrong := wrongCode(t, secret)
+			for i := 0; i < loginUserThreshold; i++ {
+				if err := tt.call(userID, wrong); err != ErrorInvalidTOTPCode {
+					t.Fatalf("attempt %d: err = %v", i, err)
+				}
+			}
+			if _, ok := tt.call(userID, totpCode(t, secret, 1)).(*LoginLockedError); !ok {
+				t.Error("correct code accepted while locked")
+			}
+		})
+	}
+
+	// 激活时的验证码错误同样计数
+	name := uniqueName("totp")
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: "pw", RePassword: "pw"}); err != nil {
+		t.Fatal(err)
+	}
+	user, _ := mysql.GetUserByUsername(name)
+	e, err := EnrollTOTP(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < loginUserThreshold; i++ {
+		ActivateTOTP(user.UserID, wrongCode(t, e.Secret))
+	}
+	if _, err := ActivateTOTP(user.UserID, totpCode(t, e.Secret, 0)); err == nil {
+		t.Error("activation accepted while locked")
+	}
+}
@@ -3,12 +3,20 @@ package logic
 import (
 	"bluebell/dao/mysql"
 	"bluebell/models"
-	"bluebell/pkg/jwt"
+	"bluebell/pkg/password"
 	"bluebell/pkg/snowflake"
+	"errors"
 )
 
 // 存放业务逻辑的代码
 
+var (
+	ErrorUserDisabled     = errors.New("用户已被禁用")
+	ErrorNotLocalUser     = errors.New("外部认证的用户不能在本系统修改密码")
+	ErrorOperateSelf      = errors.New("不能禁用或删除自己")
+	ErrorWrongOldPassword = errors.New("原密码错误")
+)
+
 func SignUp(p *models.ParamSignUp) (err error) {
 	// 1.判断用户存不存在
 	if err := mysql.CheckUserExist(p.Username); err != nil {
@@ -16,25 +24,210 @@ func SignUp(p *models.ParamSignUp) (err error) {
 	}
 	// 2.生成UID
 	userID := snowflake.GenID()
+	// 对密码进行哈希
+	hash, err := password.Hash(p.Password)
+	if err != nil {
+		return err
+	}
 	// 构造一个User实例
 	user := &models.User{
 		UserID:   userID,
 		Username: p.Username,
-		Password: p.Password,
+		Password: hash,
 	}
 	// 3.保存进数据库
 	return mysql.InsertUser(user)
 }
 
-func Login(p *models.ParamLogin) (token string, err error) {
+// Login 按配置的认证方式校验用户名和密码, 成功后签发 token
+// ip 为客户端地址, 用户名或 IP 连续登录失败过多时暂时锁定
+func Login(p *models.ParamLogin, ip string) (user *models.User, tokens *models.TokenPair, err error) {
+	if err := checkLoginAllowed(p.Username, ip); err != nil {
+		return nil, nil, err
+	}
+	user, err = authenticate(p.Username, p.Password)
+	if err == mysql.ErrorUserNotExist || err == mysql.ErrorInvalidPassword {
+		recordLoginFailure(p.Username, ip)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	resetLoginFailures(p.Username, ip)
+	if user.Disabled {
+		return nil, nil, ErrorUserDisabled
+	}
+	// 开启了两步验证的用户, 完成验证后才签发 token
+	if err := requireSecondFactor(user); err != nil {
+		return nil, nil, err
+	}
+	// 签发 access token 和 refresh token
+	tokens, err = issueTokens(user, "")
+	return user, tokens, err
+}
+
+func upgradePasswordHash(userID int64, plain string) error {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	return mysql.UpdateUserPassword(userID, hash)
+}
+
+// IsUserActive 判断用户是否存在且未被禁用
+func IsUserActive(userID int64) (bool, error) {
+	user, err := mysql.GetUserByID(userID)
+	if err == mysql.ErrorUserNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !user.Disabled, nil
+}
+
+// GetUser 获取用户信息
+func GetUser(userID int64) (*models.User, error) {
+	return mysql.GetUserByID(userID)
+}
+
+// UpdateProfile 修改个人资料
+func UpdateProfile(userID int64, p *models.ParamUpdateProfile) error {
+	return mysql.UpdateUserProfile(userID, p)
+}
+
+// ChangePassword 使用原密码修改密码, 成功后其他已登录的会话需要重新登录
+func ChangePassword(userID int64, p *models.ParamChangePassword) error {
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Source != models.UserSourceLocal {
+		return ErrorNotLocalUser
+	}
+	ok, _, err := password.Verify(p.OldPassword, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorWrongOldPassword
+	}
+	return setPassword(userID, p.NewPassword)
+}
+
+// setPassword 设置新密码并吊销用户所有的 refresh token
+func setPassword(userID int64, plain string) error {
+	hash, err := password.Hash(plain)
+	if err != nil {
+		return err
+	}
+	if err := mysql.UpdateUserPassword(userID, hash); err != nil {
+		return err
+	}
+	return mysql.RevokeUserRefreshTokens(userID)
+}
+
+// GetUsers 获取所有用户
+func GetUsers() ([]models.User, error) {
+	return mysql.GetUsers()
+}
+
+// AddUser 管理员新增本地用户
+func AddUser(p *models.ParamAddUser) (*models.User, error) {
+	if err := mysql.CheckUserExist(p.Username); err != nil {
+		return nil, err
+	}
+	for _, id := range p.RoleIDs {
+		r, err := mysql.GetRoleByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			return nil, ErrorRoleNotExist
+		}
+	}
+	hash, err := password.Hash(p.Password)
+	if err != nil {
+		return nil, err
+	}
 	user := &models.User{
+		UserID:   snowflake.GenID(),
 		Username: p.Username,
-		Password: p.Password,
+		Password: hash,
+		Source:   models.UserSourceLocal,
+		Nickname: p.Nickname,
+		Email:    p.Email,
+		Gender:   p.Gender,
+	}
+	if err := mysql.InsertUser(user); err != nil {
+		return nil, err
+	}
+	if len(p.RoleIDs) > 0 {
+		if err := mysql.SetUserRoles(user.UserID, p.RoleIDs); err != nil {
+			return nil, err
+		}
+	}
+	return mysql.GetUserByID(user.UserID)
+}
+
+// SetUserDisabled 启用或禁用用户, 禁用后吊销其所有的 refresh token
+// 不能禁用自己, 也不能禁用最后一个管理员
+func SetUserDisabled(operatorID int64, p *models.ParamUserStatus) error {
+	if _, err := mysql.GetUserByID(p.UserID); err != nil {
+		return err
+	}
+	if !p.Disabled {
+		return mysql.SetUserDisabled(p.UserID, false)
+	}
+	if p.UserID == operatorID {
+		return ErrorOperateSelf
+	}
+	if err := checkNotLastAdmin(p.UserID); err != nil {
+		return err
+	}
+	if err := mysql.SetUserDisabled(p.UserID, true); err != nil {
+		return err
+	}
+	return mysql.RevokeUserRefreshTokens(p.UserID)
+}
+
+// ResetPassword 管理员重置本地用户的密码
+func ResetPassword(p *models.ParamResetPassword) error {
+	user, err := mysql.GetUserByID(p.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Source != models.UserSourceLocal {
+		return ErrorNotLocalUser
+	}
+	return setPassword(p.UserID, p.Password)
+}
+
+// DeleteUser 删除用户, 不能删除自己, 也不能删除最后一个管理员
+func DeleteUser(operatorID, userID int64) error {
+	if _, err := mysql.GetUserByID(userID); err != nil {
+		return err
+	}
+	if userID == operatorID {
+		return ErrorOperateSelf
+	}
+	if err := checkNotLastAdmin(userID); err != nil {
+		return err
+	}
+	return mysql.DeleteUser(userID)
+}
+
+// checkNotLastAdmin 用户是最后一个可用的管理员时返回 ErrorLastAdmin
+func checkNotLastAdmin(userID int64) error {
+	isAdmin, err := IsAdmin(userID)
+	if err != nil || !isAdmin {
+		return err
+	}
+	count, err := mysql.CountUsersWithPermission(models.PermAdmin)
+	if err != nil {
+		return err
 	}
-	// 传递的是指针，就能拿到user.UserID
-	if err := mysql.Login(user); err != nil {
-		return "", err
+	if count <= 1 {
+		return ErrorLastAdmin
 	}
-	// 生成JWT
-	return jwt.GenToken(user.UserID, user.Username)
+	return nil
 }
@@ -0,0 +1,233 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/snowflake"
+	"testing"
+)
+
+// addLocalUser 注册本地用户并返回用户
+func addLocalUser(t *testing.T, password string) *models.User {
+	t.Helper()
+	name := uniqueName("leo")
+	if err := SignUp(&models.ParamSignUp{Username: name, Password: password, RePassword: password}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := mysql.GetUserByUsername(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func login(username, password string) (*models.TokenPair, error) {
+	_, tokens, err := Login(&models.ParamLogin{Username: username, Password: password}, "")
+	return tokens, err
+}
+
+func TestUpdateProfile(t *testing.T) {
+	user := addLocalUser(t, "p@ssw0rd")
+	p := &models.ParamUpdateProfile{Nickname: "Leo", Avatar: "https://example.com/a.png", Email: "leo@example.com", Gender: 1}
+	if err := UpdateProfile(user.UserID, p); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetUser(user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Nickname != p.Nickname || got.Avatar != p.Avatar || got.Email != p.Email || got.Gender != p.Gender {
+		t.Errorf("profile = %+v, want %+v", got, p)
+	}
+	if got.Username != user.Username || got.Password != user.Password {
+		t.Error("UpdateProfile changed username or password")
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user := addLocalUser(t, "old-pass")
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ChangePassword(user.UserID, &models.ParamChangePassword{OldPassword: "wrong", NewPassword: "new-pass", RePassword: "new-pass"})
+	if err != ErrorWrongOldPassword {
+		t.Fatalf("wrong old password: err = %v, want ErrorWrongOldPassword", err)
+	}
+	if err := ChangePassword(user.UserID, &models.ParamChangePassword{OldPassword: "old-pass", NewPassword: "new-pass", RePassword: "new-pass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(user.Username, "old-pass"); err != mysql.ErrorInvalidPassword {
+		t.Errorf("login with old password: err = %v, want ErrorInvalidPassword", err)
+	}
+	if _, err := login(user.Username, "new-pass"); err != nil {
+		t.Errorf("login with new password: %v", err)
+	}
+	// 修改密码后其他会话需要重新登录
+	if _, err := refresh(tokens.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("refresh after password change: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+
+	external := &models.User{UserID: snowflake.GenID(), Username: uniqueName("ldap"), Password: "x", Source: models.UserSourceLDAP}
+	if err := mysql.InsertUser(external); err != nil {
+		t.Fatal(err)
+	}
+	err = ChangePassword(external.UserID, &models.ParamChangePassword{OldPassword: "x", NewPassword: "y", RePassword: "y"})
+	if err != ErrorNotLocalUser {
+		t.Errorf("external user: err = %v, want ErrorNotLocalUser", err)
+	}
+	if err := ResetPassword(&models.ParamResetPassword{UserID: external.UserID, Password: "y"}); err != ErrorNotLocalUser {
+		t.Errorf("reset external user: err = %v, want ErrorNotLocalUser", err)
+	}
+}
+
+func TestAddUser(t *testing.T) {
+	role := addTestRole(t, uniqueName("viewer"), models.PermView)
+	p := &models.ParamAddUser{Username: uniqueName("mia"), Password: "p@ssw0rd", Nickname: "Mia", RoleIDs: []int64{role.ID}}
+	user, err := AddUser(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != p.Username || user.Nickname != "Mia" || user.Source != models.UserSourceLocal {
+		t.Errorf("user = %+v", user)
+	}
+	if names := userRoleNames(t, user.UserID); len(names) != 1 || names[0] != role.Name {
+		t.Errorf("roles = %v, want [%s]", names, role.Name)
+	}
+	if _, err := login(p.Username, p.Password); err != nil {
+		t.Errorf("login as added user: %v", err)
+	}
+
+	if _, err := AddUser(p); err != mysql.ErrorUserExist {
+		t.Errorf("duplicate username: err = %v, want ErrorUserExist", err)
+	}
+	p2 := &models.ParamAddUser{Username: uniqueName("mia"), Password: "p@ssw0rd", RoleIDs: []int64{-1}}
+	if _, err := AddUser(p2); err != ErrorRoleNotExist {
+		t.Errorf("unknown role: err = %v, want ErrorRoleNotExist", err)
+	}
+	if _, err := mysql.GetUserByUsername(p2.Username); err != mysql.ErrorUserNotExist {
+		t.Errorf("user created with unknown role: err = %v", err)
+	}
+}
+
+func TestSetUserDisabled(t *testing.T) {
+	operator := addLocalUser(t, "p@ssw0rd")
+	user := addLocalUser(t, "p@ssw0rd")
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetUserDisabled(operator.UserID, &models.ParamUserStatus{UserID: operator.UserID, Disabled: true}); err != ErrorOperateSelf {
+		t.Errorf("disable self: err = %v, want ErrorOperateSelf", err)
+	}
+	if err := SetUserDisabled(operator.UserID, &models.ParamUserStatus{UserID: user.UserID, Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(user.Username, "p@ssw0rd"); err != ErrorUserDisabled {
+		t.Errorf("login when disabled: err = %v, want ErrorUserDisabled", err)
+	}
+	if active, err := IsUserActive(user.UserID); err != nil || active {
+		t.Errorf("IsUserActive = %v, %v, want false", active, err)
+	}
+	if _, err := refresh(tokens.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("refresh when disabled: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+
+	if err := SetUserDisabled(operator.UserID, &models.ParamUserStatus{UserID: user.UserID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(user.Username, "p@ssw0rd"); err != nil {
+		t.Errorf("login after enabling: %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	user := addLocalUser(t, "p@ssw0rd")
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ResetPassword(&models.ParamResetPassword{UserID: user.UserID, Password: "reset-pass"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(user.Username, "reset-pass"); err != nil {
+		t.Errorf("login with reset password: %v", err)
+	}
+	if _, err := refresh(tokens.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("refresh after reset: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	operator := addLocalUser(t, "p@ssw0rd")
+	user := addLocalUser(t, "p@ssw0rd")
+	role := addTestRole(t, uniqueName("viewer"), models.PermView)
+	if err := mysql.SetUserRoles(user.UserID, []int64{role.ID}); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := issueTokens(user, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteUser(operator.UserID, operator.UserID); err != ErrorOperateSelf {
+		t.Errorf("delete self: err = %v, want ErrorOperateSelf", err)
+	}
+	if err := DeleteUser(operator.UserID, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetUser(user.UserID); err != mysql.ErrorUserNotExist {
+		t.Errorf("GetUser after delete: err = %v, want ErrorUserNotExist", err)
+	}
+	if names := userRoleNames(t, user.UserID); len(names) != 0 {
+		t.Errorf("roles after delete = %v", names)
+	}
+	if _, err := refresh(tokens.RefreshToken); err != ErrorInvalidRefreshToken {
+		t.Errorf("refresh after delete: err = %v, want ErrorInvalidRefreshToken", err)
+	}
+	if err := DeleteUser(operator.UserID, user.UserID); err != mysql.ErrorUserNotExist {
+		t.Errorf("delete twice: err = %v, want ErrorUserNotExist", err)
+	}
+}
+
+func TestLastAdmin(t *testing.T) {
+	// 暂时禁用其他测试留下的管理员, 使新用户成为唯一可用的管理员
+	users, err := mysql.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range users {
+		if isAdmin, err := IsAdmin(u.UserID); err != nil || !isAdmin || u.Disabled {
+			continue
+		}
+		if err := mysql.SetUserDisabled(u.UserID, true); err != nil {
+			t.Fatal(err)
+		}
+		id := u.UserID
+		t.Cleanup(func() { mysql.SetUserDisabled(id, false) })
+	}
+
+	adminRole := addTestRole(t, uniqueName("admin"), models.PermAdmin)
+	admin := addLocalUser(t, "p@ssw0rd")
+	operator := addLocalUser(t, "p@ssw0rd")
+	if err := mysql.SetUserRoles(admin.UserID, []int64{adminRole.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetUserDisabled(operator.UserID, &models.ParamUserStatus{UserID: admin.UserID, Disabled: true}); err != ErrorLastAdmin {
+		t.Errorf("disable last admin: err = %v, want ErrorLastAdmin", err)
+	}
+	if err := DeleteUser(operator.UserID, admin.UserID); err != ErrorLastAdmin {
+		t.Errorf("delete last admin: err = %v, want ErrorLastAdmin", err)
+	}
+
+	// 有第二个管理员后可以禁用
+	if err := mysql.SetUserRoles(operator.UserID, []int64{adminRole.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetUserDisabled(operator.UserID, &models.ParamUserStatus{UserID: admin.UserID, Disabled: true}); err != nil {
+		t.Errorf("disable admin with another admin: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/dao/redis"
+	"bluebell/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrorWebhookSecretNotSet = errors.New("节点未配置 Webhook 密钥")
+	ErrorWebhookSignature    = errors.New("Webhook 签名校验失败")
+	ErrorWebhookPayload      = errors.New("无法识别的 Webhook 负载")
+	ErrorWebhookTimestamp    = errors.New("Webhook 时间戳缺失或超出允许范围")
+	ErrorWebhookReplay       = errors.New("重复的 Webhook 投递")
+)
+
+// webhookTolerance 签名时间戳与服务器时间允许的偏差, 超出时拒绝, 范围内的重复投递由 claimWebhookDelivery 拒绝
+const webhookTolerance = 5 * time.Minute
+
+// subscriberBuffer 每个订阅者的事件缓冲, 消费过慢时丢弃新事件而不阻塞发布方
+const subscriberBuffer = 64
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   = make(map[chan models.BuildEvent]struct{})
+)
+
+// SubscribeBuildEvents 订阅构建事件, 返回的函数用于取消订阅
+func SubscribeBuildEvents() (<-chan models.BuildEvent, func()) {
+	ch := make(chan models.BuildEvent, subscriberBuffer)
+	subscribersMu.Lock()
+	subscribers[ch] = struct{}{}
+	subscribersMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			subscribersMu.Lock()
+			delete(subscribers, ch)
+			subscribersMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func publishBuildEvent(e models.BuildEvent) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// GenerateWebhookSecret 为节点生成新的 Webhook 密钥, 旧密钥立即失效
+func GenerateWebhookSecret(nodeID int) (string, error) {
+	if _, err := mysql.GetNodeByID(nodeID); err != nil {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(b)
+	if err := mysql.UpdateNodeWebhookSecret(nodeID, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// signWebhook 计算签名: HMAC-SHA256(secret, "时间戳.请求体"), 十六进制
+func signWebhook(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// verifyWebhook 校验签名和时间戳, 返回用于去重的投递标识
+// 签名为 HMAC-SHA256(secret, "时间戳.请求体") 的十六进制 (可带 sha256= 前缀), 时间戳为 Unix 秒, 与 now 的偏差不能超过 webhookTolerance
+// Notification 插件无法签名, 此时允许通过 token 直接携带共享密钥, 以请求体的哈希去重
+func verifyWebhook(secret string, body []byte, signature, timestamp, token string, now time.Time) (string, error) {
+	if secret == "" {
+		return "", ErrorWebhookSecretNotSet
+	}
+	if signature != "" {
+		sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return "", ErrorWebhookSignature
+		}
+		if !hmac.Equal(sig, signWebhook(secret, timestamp, body)) {
+			return "", ErrorWebhookSignature
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return "", ErrorWebhookTimestamp
+		}
+		if d := now.Sub(time.Unix(ts, 0)); d > webhookTolerance || d < -webhookTolerance {
+			return "", ErrorWebhookTimestamp
+		}
+		return hex.EncodeToString(sig), nil
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+		return hashToken(string(body)), nil
+	}
+	return "", ErrorWebhookSignature
+}
+
+// webhookDeliveries 未启用 Redis 时记录已处理的投递及其过期时间
+var webhookDeliveries = struct {
+	sync.Mutex
+	seen map[string]time.Time
+}{seen: make(map[string]time.Time)}
+
+// claimWebhookDelivery 记录一次投递, 时间戳允许范围内重复的投递返回 ErrorWebhookReplay
+// 启用 Redis 时多个实例共享记录
+func claimWebhookDelivery(nodeID int, id string, now time.Time) error {
+	key := fmt.Sprintf("%d:%s", nodeID, id)
+	ttl := 2 * webhookTolerance
+	if redis.Enabled() {
+		ok, err := redis.ClaimWebhookDelivery(key, ttl)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrorWebhookReplay
+		}
+		return nil
+	}
+
+	webhookDeliveries.Lock()
+	defer webhookDeliveries.Unlock()
+	for k, expire := range webhookDeliveries.seen {
+		if now.After(expire) {
+			delete(webhookDeliveries.seen, k)
+		}
+	}
+	if _, ok := webhookDeliveries.seen[key]; ok {
+		return ErrorWebhookReplay
+	}
+	webhookDeliveries.seen[key] = now.Add(ttl)
+	return nil
+}
+
+// HandleJenkinsWebhook 校验并处理 Jenkins 推送的构建事件
+// 构建结束时更新本地构建状态并触发通知, 其他阶段只推送给订阅者
+func HandleJenkinsWebhook(nodeID int, body []byte, signature, timestamp, token string) (*models.BuildEvent, error) {
+	node, err := mysql.GetNodeByID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	delivery, err := verifyWebhook(node.WebhookSecret, body, signature, timestamp, token, now)
+	if err != nil {
+		return nil, err
+	}
+	var p models.WebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, ErrorWebhookPayload
+	}
+	e, err := webhookEvent(&p)
+	if err != nil {
+		return nil, err
+	}
+	if err := claimWebhookDelivery(node.ID, delivery, now); err != nil {
+		return nil, err
+	}
+	e.NodeID = node.ID
+	e.NodeName = node.Name
+	e.JobPath = jobPath(e.ViewID, e.JobName)
+	e.Time = time.Now().Format(timeLayout)
+
+	if e.Phase == models.BuildPhaseCompleted || e.Phase == models.BuildPhaseFinalized {
+		// COMPLETED 和 FINALIZED 会先后推送, 由 ObserveBuild 去重
+		ObserveBuild(e)
+		return e, nil
+	}
+	publishBuildEvent(*e)
+	return e, nil
+}
+
+// webhookEvent 将负载转换为构建事件
+func webhookEvent(p *models.WebhookPayload) (*models.BuildEvent, error) {
+	if p.Build != nil {
+		viewID, jobName := splitJobURL(p.URL)
+		if viewID == "" {
+			viewID = p.Name
+		}
+		if viewID == "" || p.Build.Number == 0 {
+			return nil, ErrorWebhookPayload
+		}
+		return &models.BuildEvent{
+			ViewID:      viewID,
+			JobName:     jobName,
+			BuildNumber: p.Build.Number,
+			Phase:       strings.ToUpper(p.Build.Phase),
+			Result:      strings.ToUpper(p.Build.Status),
+			Duration:    p.Build.Duration,
+			URL:         p.Build.FullURL,
+		}, nil
+	}
+
+	if p.ViewID == "" || p.BuildNumber == 0 {
+		return nil, ErrorWebhookPayload
+	}
+	phase := strings.ToUpper(p.Phase)
+	if phase == "" && p.Result != "" {
+		phase = models.BuildPhaseCompleted
+	}
+	return &models.BuildEvent{
+		ViewID:      p.ViewID,
+		JobName:     p.JobName,
+		BuildNumber: p.BuildNumber,
+		Phase:       phase,
+		Result:      strings.ToUpper(p.Result),
+		Duration:    p.Duration,
+		URL:         p.BuildURL,
+	}, nil
+}
+
+// splitJobURL 将 job/a/job/b/ 形式的路径拆分为目录和 Job 名
+// 只有一级时该名称作为 viewID (顶层 Job), 多级目录以 / 连接
+func splitJobURL(u string) (viewID, jobName string) {
+	var names []string
+	parts := strings.Split(strings.Trim(u, "/"), "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		if parts[i] != "job" {
+			break
+		}
+		name, err := url.PathUnescape(parts[i+1])
+		if err != nil {
+			name = parts[i+1]
+		}
+		names = append(names, name)
+	}
+	switch len(names) {
+	case 0:
+		return "", ""
+	case 1:
+		return names[0], ""
+	}
+	return strings.Join(names[:len(names)-1], "/"), names[len(names)-1]
+}
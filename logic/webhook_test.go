@@ -0,0 +1,180 @@
+package logic
+
+import (
+	"bluebell/models"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	const secret = "s3cret"
+	now := time.Now()
+	body := []byte(`{"view_id":"app","build_number":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := hex.EncodeToString(signWebhook(secret, ts, body))
+
+	tests := []struct {
+		name                        string
+		secret                      string
+		body                        string
+		signature, timestamp, token string
+		want                        error
+	}{
+		{"valid", secret, string(body), sig, ts, "", nil},
+		{"sha256 prefix", secret, string(body), "sha256=" + sig, ts, "", nil},
+		{"secret not set", "", string(body), sig, ts, "", ErrorWebhookSecretNotSet},
+		{"wrong secret", "other", string(body), sig, ts, "", ErrorWebhookSignature},
+		{"tampered body", secret, `{"view_id":"other","build_number":1}`, sig, ts, "", ErrorWebhookSignature},
+		{"not hex", secret, string(body), "zz", ts, "", ErrorWebhookSignature},
+		{"signed without timestamp", secret, string(body),
+			hex.EncodeToString(signWebhook(secret, "", body)), "", "", ErrorWebhookTimestamp},
+		{"timestamp changed", secret, string(body), sig, strconv.FormatInt(now.Unix()+1, 10), "", ErrorWebhookSignature},
+		{"stale timestamp", secret, string(body),
+			hex.EncodeToString(signWebhook(secret, strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), body)),
+			strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), "", ErrorWebhookTimestamp},
+		{"future timestamp", secret, string(body),
+			hex.EncodeToString(signWebhook(secret, strconv.FormatInt(now.Add(time.Hour).Unix(), 10), body)),
+			strconv.FormatInt(now.Add(time.Hour).Unix(), 10), "", ErrorWebhookTimestamp},
+		{"token", secret, string(body), "", "", secret, nil},
+		{"wrong token", secret, string(body), "", "", "other", ErrorWebhookSignature},
+		{"nothing", secret, string(body), "", "", "", ErrorWebhookSignature},
+	}
+	for _, tt := range tests {
+		id, err := verifyWebhook(tt.secret, []byte(tt.body), tt.signature, tt.timestamp, tt.token, now)
+		if err != tt.want {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err == nil && id == "" {
+			t.Errorf("%s: empty delivery id", tt.name)
+		}
+	}
+}
+
+func TestHandleJenkinsWebhookReplay(t *testing.T) {
+	nodeID := addTestNode(t, "http://127.0.0.1:1")
+	secret, err := GenerateWebhookSecret(nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, cancel := SubscribeBuildEvents()
+	defer cancel()
+
+	body := []byte(fmt.Sprintf(`{"view_id":"app","job_name":"web","build_number":%d,"phase":"started"}`, time.Now().UnixNano()))
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := hex.EncodeToString(signWebhook(secret, ts, body))
+
+	e, err := HandleJenkinsWebhook(nodeID, body, sig, ts, "")
+	if err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if e.NodeID != nodeID || e.JobPath != "app/web" || e.Phase != models.BuildPhaseStarted {
+		t.Errorf("event = %+v", e)
+	}
+	select {
+	case got := <-events:
+		if got.BuildNumber != e.BuildNumber {
+			t.Errorf("published event = %+v, want build %d", got, e.BuildNumber)
+		}
+	case <-time.After(time.Second):
+		t.Error("event not published")
+	}
+
+	// 原样重放签名的请求
+	if _, err := HandleJenkinsWebhook(nodeID, body, sig, ts, ""); err != ErrorWebhookReplay {
+		t.Errorf("replayed signed delivery: err = %v, want ErrorWebhookReplay", err)
+	}
+	// 使用 token 的投递按请求体去重
+	if _, err := HandleJenkinsWebhook(nodeID, body, "", "", secret); err != nil {
+		t.Errorf("first token delivery: %v", err)
+	}
+	if _, err := HandleJenkinsWebhook(nodeID, body, "", "", secret); err != ErrorWebhookReplay {
+		t.Errorf("replayed token delivery: err = %v, want ErrorWebhookReplay", err)
+	}
+	// 签名有效但负载无法识别时不记录投递
+	bad := []byte(`{"unknown":true}`)
+	badSig := hex.EncodeToString(signWebhook(secret, ts, bad))
+	for i := 0; i < 2; i++ {
+		if _, err := HandleJenkinsWebhook(nodeID, bad, badSig, ts, ""); err != ErrorWebhookPayload {
+			t.Errorf("unknown payload: err = %v, want ErrorWebhookPayload", err)
+		}
+	}
+}
+
+func TestWebhookEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload models.WebhookPayload
+		want    *models.BuildEvent
+	}{
+		{"notification top-level job", models.WebhookPayload{
+			Name: "app", URL: "job/app/",
+			Build: &models.NotificationBuild{Number: 3, Phase: "completed", Status: "success", FullURL: "http://j/job/app/3/"},
+		}, &models.BuildEvent{ViewID: "app", BuildNumber: 3, Phase: "COMPLETED", Result: "SUCCESS", URL: "http://j/job/app/3/"}},
+		{"notification job in folder", models.WebhookPayload{
+			Name: "web", URL: "job/app/job/web/",
+			Build: &models.NotificationBuild{Number: 4, Phase: "STARTED"},
+		}, &models.BuildEvent{ViewID: "app", JobName: "web", BuildNumber: 4, Phase: "STARTED"}},
+		{"notification nested folders", models.WebhookPayload{
+			Name: "my web", URL: "job/team/job/app/job/my%20web/",
+			Build: &models.NotificationBuild{Number: 5, Phase: "FINALIZED", Status: "FAILURE"},
+		}, &models.BuildEvent{ViewID: "team/app", JobName: "my web", BuildNumber: 5, Phase: "FINALIZED", Result: "FAILURE"}},
+		{"notification without url", models.WebhookPayload{
+			Name: "app", Build: &models.NotificationBuild{Number: 6, Phase: "STARTED"},
+		}, &models.BuildEvent{ViewID: "app", BuildNumber: 6, Phase: "STARTED"}},
+		{"notification without number", models.WebhookPayload{
+			Name: "app", URL: "job/app/", Build: &models.NotificationBuild{Phase: "STARTED"},
+		}, nil},
+		{"generic completed", models.WebhookPayload{
+			ViewID: "app", JobName: "web", BuildNumber: 7, Result: "unstable", Duration: 1000,
+		}, &models.BuildEvent{ViewID: "app", JobName: "web", BuildNumber: 7, Phase: "COMPLETED", Result: "UNSTABLE", Duration: 1000}},
+		{"generic without view", models.WebhookPayload{BuildNumber: 8}, nil},
+	}
+	for _, tt := range tests {
+		got, err := webhookEvent(&tt.payload)
+		if tt.want == nil {
+			if err != ErrorWebhookPayload {
+				t.Errorf("%s: err = %v, want ErrorWebhookPayload", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || *got != *tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSplitJobURL(t *testing.T) {
+	tests := []struct {
+		url             string
+		viewID, jobName string
+		path            string
+	}{
+		{"job/app/", "app", "", "job/app/"},
+		{"/job/app/job/web", "app", "web", "job/app/job/web/"},
+		{"job/a/job/b/job/c/", "a/b", "c", "job/a/job/b/job/c/"},
+		{"job/my%20app/job/web/", "my app", "web", "job/my%20app/job/web/"},
+		{"view/all/job/app/", "", "", ""},
+		{"", "", "", ""},
+	}
+	for _, tt := range tests {
+		viewID, jobName := splitJobURL(tt.url)
+		if viewID != tt.viewID || jobName != tt.jobName {
+			t.Errorf("splitJobURL(%q) = %q, %q, want %q, %q", tt.url, viewID, jobName, tt.viewID, tt.jobName)
+			continue
+		}
+		// 拆分结果与 getJob 和归档使用的路径一致
+		if tt.path == "" {
+			continue
+		}
+		if got := JobURLPath(viewID, jobName); got != tt.path {
+			t.Errorf("JobURLPath(%q, %q) = %q, want %q", viewID, jobName, got, tt.path)
+		}
+		if _, err := archiveKey(1, viewID, jobName, 1); err != nil {
+			t.Errorf("archiveKey(%q, %q): %v", viewID, jobName, err)
+		}
+	}
+}
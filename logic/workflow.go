@@ -0,0 +1,638 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"bluebell/pkg/metrics"
+	"bluebell/pkg/snowflake"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bndr/gojenkins"
+	"go.uber.org/zap"
+)
+
+const (
+	// workflowTickInterval 工作流引擎轮询 Jenkins 的间隔
+	workflowTickInterval = 5 * time.Second
+	// workflowLockTTL 运行锁的有效期, 持有者异常退出后锁过期即可被其他实例抢占
+	workflowLockTTL = 2 * time.Minute
+	// workflowJenkinsTimeout 持有运行锁时请求 Jenkins 的总超时时间, 需小于 workflowLockTTL
+	workflowJenkinsTimeout = time.Minute
+	// workflowLockWait 暂停/恢复/取消/审批等待运行锁的最长时间
+	workflowLockWait  = 10 * time.Second
+	workflowLockRetry = 100 * time.Millisecond
+)
+
+var (
+	ErrorWorkflowFinished = errors.New("工作流已结束")
+	ErrorStepNotWaiting   = errors.New("该步骤不在等待审批状态")
+	ErrorWorkflowBusy     = errors.New("工作流正在被其他操作修改, 请稍后重试")
+)
+
+// workflowEngines 本实例正在执行的运行, 避免同一运行在本实例启动多个引擎
+// 多实例部署时各实例的引擎通过运行锁互斥, 每次推进都重新读取状态, 不会重复触发构建
+var workflowEngines sync.Map
+
+// lockWorkflowRun 获取运行锁, 引擎推进与暂停/恢复/取消/审批互斥, 最多等待 wait
+// 锁保存在数据库中, 多实例部署时同一运行同一时间只有一处在修改
+func lockWorkflowRun(id int64, wait time.Duration) (unlock func(), err error) {
+	owner := strconv.FormatInt(snowflake.GenID(), 10)
+	deadline := time.Now().Add(wait)
+	for {
+		now := time.Now()
+		ok, err := mysql.LockWorkflowRun(id, owner, now.Format(timeLayout), now.Add(workflowLockTTL).Format(timeLayout))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() {
+				if err := mysql.UnlockWorkflowRun(id, owner); err != nil {
+					zap.L().Error("mysql.UnlockWorkflowRun failed", zap.Int64("runId", id), zap.Error(err))
+				}
+			}, nil
+		}
+		// 运行不存在时直接返回
+		if _, err := mysql.GetWorkflowRunByID(id); err != nil {
+			return nil, err
+		}
+		if now.After(deadline) {
+			return nil, ErrorWorkflowBusy
+		}
+		time.Sleep(workflowLockRetry)
+	}
+}
+
+// validateWorkflow 校验步骤定义: 名称唯一, 依赖存在且无环
+func validateWorkflow(w *models.Workflow) error {
+	steps := make(map[string]*models.WorkflowStep, len(w.Steps))
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if _, ok := steps[s.Name]; ok {
+			return fmt.Errorf("步骤名称 [%s] 重复", s.Name)
+		}
+		if s.Type == "" {
+			s.Type = models.StepTypeJob
+		}
+		if s.Condition == "" {
+			s.Condition = models.StepConditionSuccess
+		}
+		switch s.Type {
+		case models.StepTypeJob:
+			if s.NodeID == 0 || s.ViewID == "" {
+				return fmt.Errorf("步骤 [%s] 缺少 node_id 或 view_id", s.Name)
+			}
+		case models.StepTypeGate:
+		default:
+			return fmt.Errorf("步骤 [%s] 类型 [%s] 无效", s.Name, s.Type)
+		}
+		switch s.Condition {
+		case models.StepConditionSuccess, models.StepConditionFailure, models.StepConditionAlways:
+		default:
+			return fmt.Errorf("步骤 [%s] 条件 [%s] 无效", s.Name, s.Condition)
+		}
+		for k, v := range s.Params {
+			if _, err := template.New(k).Option("missingkey=zero").Parse(v); err != nil {
+				return fmt.Errorf("步骤 [%s] 参数 [%s] 模板无效: %v", s.Name, k, err)
+			}
+		}
+		steps[s.Name] = s
+	}
+
+	// 拓扑排序检查环
+	inDegree := make(map[string]int, len(steps))
+	for _, s := range w.Steps {
+		for _, dep := range s.DependsOn {
+			if _, ok := steps[dep]; !ok {
+				return fmt.Errorf("步骤 [%s] 依赖的步骤 [%s] 不存在", s.Name, dep)
+			}
+		}
+		inDegree[s.Name] = len(s.DependsOn)
+	}
+	queue := make([]string, 0, len(steps))
+	for name, d := range inDegree {
+		if d == 0 {
+			queue = append(queue, name)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, s := range w.Steps {
+			for _, dep := range s.DependsOn {
+				if dep == name {
+					inDegree[s.Name]--
+					if inDegree[s.Name] == 0 {
+						queue = append(queue, s.Name)
+					}
+				}
+			}
+		}
+	}
+	if visited != len(steps) {
+		return errors.New("步骤依赖存在环")
+	}
+	return nil
+}
+
+// AddWorkflow 新增工作流
+func AddWorkflow(w *models.Workflow) error {
+	if err := validateWorkflow(w); err != nil {
+		return err
+	}
+	return mysql.AddWorkflow(w)
+}
+
+// GetWorkflows 获取工作流列表
+func GetWorkflows(name string) ([]models.Workflow, error) {
+	workflows, err := mysql.GetWorkflows(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(workflows) == 0 {
+		return []models.Workflow{}, nil
+	}
+	return workflows, nil
+}
+
+// GetWorkflow 获取单个工作流
+func GetWorkflow(id int64) (*models.Workflow, error) {
+	return mysql.GetWorkflowByID(id)
+}
+
+// UpdateWorkflow 更新工作流, 不影响已启动的运行
+func UpdateWorkflow(id int64, w *models.Workflow) error {
+	if err := validateWorkflow(w); err != nil {
+		return err
+	}
+	return mysql.UpdateWorkflow(id, w)
+}
+
+// DeleteWorkflow 删除工作流
+func DeleteWorkflow(id int64) error {
+	return mysql.DeleteWorkflow(id)
+}
+
+// StartWorkflow 启动一次运行
+func StartWorkflow(p *models.ParamWorkflowStart) (*models.WorkflowRun, error) {
+	w, err := mysql.GetWorkflowByID(p.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	run := &models.WorkflowRun{
+		WorkflowID: w.ID,
+		Status:     models.WorkflowStatusRunning,
+		Params:     p.Params,
+		Steps:      w.Steps,
+		States:     models.StepStates{},
+	}
+	for _, s := range w.Steps {
+		run.States[s.Name] = &models.StepState{Status: models.StepStatusPending}
+	}
+	if err := mysql.AddWorkflowRun(run); err != nil {
+		return nil, err
+	}
+	startWorkflowEngine(run.ID)
+	return run, nil
+}
+
+// GetWorkflowRuns 获取运行记录
+func GetWorkflowRuns(workflowID int64) ([]models.WorkflowRun, error) {
+	runs, err := mysql.GetWorkflowRuns(workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return []models.WorkflowRun{}, nil
+	}
+	return runs, nil
+}
+
+// GetWorkflowRun 获取单次运行
+func GetWorkflowRun(id int64) (*models.WorkflowRun, error) {
+	return mysql.GetWorkflowRunByID(id)
+}
+
+// updateWorkflowRun 在运行锁内加载、修改并保存运行状态
+func updateWorkflowRun(id int64, fn func(run *models.WorkflowRun) error) (*models.WorkflowRun, error) {
+	unlock, err := lockWorkflowRun(id, workflowLockWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	run, err := mysql.GetWorkflowRunByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(run); err != nil {
+		return nil, err
+	}
+	if err := mysql.UpdateWorkflowRun(run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// PauseWorkflowRun 暂停运行, 已开始的构建继续执行, 不再启动新的步骤
+func PauseWorkflowRun(id int64) (*models.WorkflowRun, error) {
+	return updateWorkflowRun(id, func(run *models.WorkflowRun) error {
+		if run.Status != models.WorkflowStatusRunning {
+			return fmt.Errorf("当前状态 [%s] 不能暂停", run.Status)
+		}
+		run.Status = models.WorkflowStatusPaused
+		return nil
+	})
+}
+
+// ResumeWorkflowRun 恢复已暂停的运行
+func ResumeWorkflowRun(id int64) (*models.WorkflowRun, error) {
+	run, err := updateWorkflowRun(id, func(run *models.WorkflowRun) error {
+		if run.Status != models.WorkflowStatusPaused {
+			return fmt.Errorf("当前状态 [%s] 不能恢复", run.Status)
+		}
+		run.Status = models.WorkflowStatusRunning
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	startWorkflowEngine(id)
+	return run, nil
+}
+
+// CancelWorkflowRun 取消运行, 并停止正在执行的构建
+func CancelWorkflowRun(id int64) (*models.WorkflowRun, error) {
+	return updateWorkflowRun(id, func(run *models.WorkflowRun) error {
+		if isWorkflowFinished(run.Status) {
+			return ErrorWorkflowFinished
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), workflowJenkinsTimeout)
+		defer cancel()
+		clients := map[int]*gojenkins.Jenkins{}
+		for _, s := range run.Steps {
+			state := run.States[s.Name]
+			switch state.Status {
+			case models.StepStatusRunning:
+				if err := cancelStepBuild(ctx, clients, &s, state); err != nil {
+					zap.L().Error("cancelStepBuild failed", zap.Int64("runId", run.ID), zap.String("step", s.Name), zap.Error(err))
+				}
+				finishStep(state, models.StepStatusCancelled)
+			case models.StepStatusPending, models.StepStatusWaiting:
+				finishStep(state, models.StepStatusCancelled)
+			}
+		}
+		run.Status = models.WorkflowStatusCancelled
+		run.FinishTime = time.Now().Format(timeLayout)
+		return nil
+	})
+}
+
+// ApproveWorkflowStep 审批人工步骤
+func ApproveWorkflowStep(p *models.ParamWorkflowApprove, userID int64) (*models.WorkflowRun, error) {
+	return updateWorkflowRun(p.RunID, func(run *models.WorkflowRun) error {
+		if isWorkflowFinished(run.Status) {
+			return ErrorWorkflowFinished
+		}
+		state, ok := run.States[p.Step]
+		if !ok || state.Status != models.StepStatusWaiting {
+			return ErrorStepNotWaiting
+		}
+		state.Operator = userID
+		if p.Approve {
+			finishStep(state, models.StepStatusSuccess)
+		} else {
+			finishStep(state, models.StepStatusFailed)
+			state.Error = "审批未通过"
+		}
+		return nil
+	})
+}
+
+// StartWorkflowEngine 服务启动时恢复未结束的运行
+func StartWorkflowEngine() {
+	runs, err := mysql.GetActiveWorkflowRuns()
+	if err != nil {
+		zap.L().Error("mysql.GetActiveWorkflowRuns failed", zap.Error(err))
+		return
+	}
+	for _, run := range runs {
+		startWorkflowEngine(run.ID)
+	}
+}
+
+func startWorkflowEngine(id int64) {
+	if _, loaded := workflowEngines.LoadOrStore(id, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer workflowEngines.Delete(id)
+		ticker := time.NewTicker(workflowTickInterval)
+		defer ticker.Stop()
+		for {
+			done, err := advanceWorkflowRun(id)
+			if err != nil {
+				zap.L().Error("advanceWorkflowRun failed", zap.Int64("runId", id), zap.Error(err))
+			}
+			if done {
+				return
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func isWorkflowFinished(status string) bool {
+	switch status {
+	case models.WorkflowStatusSuccess, models.WorkflowStatusFailed, models.WorkflowStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func isStepFinished(status string) bool {
+	switch status {
+	case models.StepStatusSuccess, models.StepStatusFailed, models.StepStatusSkipped, models.StepStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func finishStep(state *models.StepState, status string) {
+	state.Status = status
+	state.EndTime = time.Now().Format(timeLayout)
+}
+
+// advanceWorkflowRun 推进一次运行: 更新执行中步骤的状态, 启动满足条件的步骤, 判断是否结束
+// 返回 true 表示引擎可以退出 (运行已结束或已暂停且没有执行中的步骤)
+// 运行锁被其他实例或操作持有时跳过本次推进
+func advanceWorkflowRun(id int64) (done bool, err error) {
+	unlock, err := lockWorkflowRun(id, 0)
+	switch {
+	case err == ErrorWorkflowBusy:
+		return false, nil
+	case err == sql.ErrNoRows:
+		return true, nil // 运行已删除
+	case err != nil:
+		return false, err
+	}
+	defer unlock()
+
+	run, err := mysql.GetWorkflowRunByID(id)
+	if err != nil {
+		return false, err
+	}
+	if isWorkflowFinished(run.Status) {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workflowJenkinsTimeout)
+	defer cancel()
+	clients := map[int]*gojenkins.Jenkins{}
+	running := 0
+	for i := range run.Steps {
+		s := &run.Steps[i]
+		state := run.States[s.Name]
+		if state.Status != models.StepStatusRunning {
+			continue
+		}
+		if err := pollStep(ctx, clients, s, state); err != nil {
+			zap.L().Error("pollStep failed", zap.Int64("runId", id), zap.String("step", s.Name), zap.Error(err))
+		}
+		if state.Status == models.StepStatusRunning {
+			running++
+		}
+	}
+
+	if run.Status == models.WorkflowStatusRunning {
+		for i := range run.Steps {
+			s := &run.Steps[i]
+			state := run.States[s.Name]
+			if state.Status != models.StepStatusPending {
+				continue
+			}
+			ready, ok := checkDependencies(run, s)
+			if !ready {
+				continue
+			}
+			if !ok {
+				finishStep(state, models.StepStatusSkipped)
+				continue
+			}
+			startStep(ctx, clients, run, s, state)
+			if state.Status == models.StepStatusRunning {
+				running++
+			}
+		}
+	}
+
+	// 所有步骤结束后确定运行结果
+	finished, failed := true, false
+	for _, state := range run.States {
+		if !isStepFinished(state.Status) {
+			finished = false
+		}
+		if state.Status == models.StepStatusFailed {
+			failed = true
+		}
+	}
+	if finished {
+		run.Status = models.WorkflowStatusSuccess
+		if failed {
+			run.Status = models.WorkflowStatusFailed
+		}
+		run.FinishTime = time.Now().Format(timeLayout)
+	}
+	if err := mysql.UpdateWorkflowRun(run); err != nil {
+		return false, err
+	}
+	if finished {
+		zap.L().Info("workflow run finished", zap.Int64("runId", id), zap.String("status", run.Status))
+		return true, nil
+	}
+	return run.Status == models.WorkflowStatusPaused && running == 0, nil
+}
+
+// checkDependencies 判断上游是否全部结束 (ready), 以及是否满足执行条件 (ok)
+func checkDependencies(run *models.WorkflowRun, s *models.WorkflowStep) (ready, ok bool) {
+	allSuccess, anyFailed := true, false
+	for _, dep := range s.DependsOn {
+		status := run.States[dep].Status
+		if !isStepFinished(status) {
+			return false, false
+		}
+		if status != models.StepStatusSuccess {
+			allSuccess = false
+		}
+		if status == models.StepStatusFailed {
+			anyFailed = true
+		}
+	}
+	switch s.Condition {
+	case models.StepConditionFailure:
+		return true, anyFailed
+	case models.StepConditionAlways:
+		return true, true
+	default:
+		return true, allSuccess
+	}
+}
+
+func stepJenkins(ctx context.Context, clients map[int]*gojenkins.Jenkins, nodeID int) (*gojenkins.Jenkins, error) {
+	if jenkins, ok := clients[nodeID]; ok {
+		return jenkins, nil
+	}
+	jenkins, err := newJenkinsByNodeID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	clients[nodeID] = jenkins
+	return jenkins, nil
+}
+
+// renderStepParams 渲染步骤参数, 可引用运行参数和上游步骤的输出
+func renderStepParams(run *models.WorkflowRun, s *models.WorkflowStep) (map[string]string, error) {
+	steps := make(map[string]map[string]string, len(run.States))
+	for name, state := range run.States {
+		steps[name] = map[string]string{
+			"status":   state.Status,
+			"result":   state.Result,
+			"number":   strconv.FormatInt(state.BuildNumber, 10),
+			"queue_id": strconv.FormatInt(state.QueueID, 10),
+		}
+	}
+	data := map[string]interface{}{
+		"params": map[string]string(run.Params),
+		"steps":  steps,
+	}
+	params := make(map[string]string, len(s.Params))
+	for k, v := range s.Params {
+		tmpl, err := template.New(k).Option("missingkey=zero").Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("参数 [%s] 渲染失败: %v", k, err)
+		}
+		params[k] = buf.String()
+	}
+	return params, nil
+}
+
+func startStep(ctx context.Context, clients map[int]*gojenkins.Jenkins, run *models.WorkflowRun, s *models.WorkflowStep, state *models.StepState) {
+	state.StartTime = time.Now().Format(timeLayout)
+	if s.Type == models.StepTypeGate {
+		state.Status = models.StepStatusWaiting
+		return
+	}
+
+	params, err := renderStepParams(run, s)
+	if err != nil {
+		state.Error = err.Error()
+		finishStep(state, models.StepStatusFailed)
+		return
+	}
+	state.Params = params
+	jenkins, err := stepJenkins(ctx, clients, s.NodeID)
+	if err != nil {
+		state.Error = err.Error()
+		finishStep(state, models.StepStatusFailed)
+		return
+	}
+	queueID, err := triggerBuild(ctx, jenkins, s.ViewID, s.JobName, params, metrics.SourceWorkflow)
+	if err != nil {
+		state.Error = err.Error()
+		finishStep(state, models.StepStatusFailed)
+		return
+	}
+	state.QueueID = queueID
+	state.Status = models.StepStatusRunning
+}
+
+// pollStep 查询执行中步骤的构建状态
+func pollStep(ctx context.Context, clients map[int]*gojenkins.Jenkins, s *models.WorkflowStep, state *models.StepState) error {
+	jenkins, err := stepJenkins(ctx, clients, s.NodeID)
+	if err != nil {
+		return err
+	}
+	if state.BuildNumber == 0 {
+		item, err := getQueueItem(ctx, jenkins, state.QueueID)
+		switch {
+		case err == ErrorQueueItemNotFound:
+			// 队列项已过期或被删除, 无法再得到构建编号
+			state.Error = fmt.Sprintf("Jenkins 队列项 [%d] 不存在, 无法获取构建", state.QueueID)
+			finishStep(state, models.StepStatusFailed)
+			return nil
+		case err != nil:
+			return err
+		case item.Cancelled:
+			// 与构建被中止 (ABORTED) 相同按失败处理
+			state.Error = "构建在 Jenkins 队列中被取消"
+			finishStep(state, models.StepStatusFailed)
+			return nil
+		case item.Executable.Number == 0:
+			return nil // 仍在排队
+		}
+		state.BuildNumber = item.Executable.Number
+	}
+	job, err := getJob(ctx, jenkins, s.ViewID, s.JobName)
+	if err != nil {
+		return err
+	}
+	build, err := job.GetBuild(ctx, state.BuildNumber)
+	if err != nil {
+		return err
+	}
+	if build.Raw.Building {
+		return nil
+	}
+	state.Result = build.GetResult()
+	ObserveJenkinsBuild(s.NodeID, s.ViewID, s.JobName, build)
+	if state.Result == "SUCCESS" {
+		finishStep(state, models.StepStatusSuccess)
+	} else {
+		finishStep(state, models.StepStatusFailed)
+	}
+	return nil
+}
+
+// cancelStepBuild 停止步骤对应的构建, 尚在排队时取消队列项
+func cancelStepBuild(ctx context.Context, clients map[int]*gojenkins.Jenkins, s *models.WorkflowStep, state *models.StepState) error {
+	jenkins, err := stepJenkins(ctx, clients, s.NodeID)
+	if err != nil {
+		return err
+	}
+	if state.BuildNumber == 0 {
+		item, err := getQueueItem(ctx, jenkins, state.QueueID)
+		switch {
+		case err == ErrorQueueItemNotFound:
+			return nil // 队列项已过期, 无法确定构建
+		case err != nil:
+			return err
+		case item.Cancelled:
+			return nil
+		case item.Executable.Number == 0:
+			return cancelQueueItem(ctx, jenkins, state.QueueID)
+		}
+		state.BuildNumber = item.Executable.Number
+	}
+	job, err := getJob(ctx, jenkins, s.ViewID, s.JobName)
+	if err != nil {
+		return err
+	}
+	build, err := job.GetBuild(ctx, state.BuildNumber)
+	if err != nil {
+		return err
+	}
+	_, err = build.Stop(ctx)
+	return err
+}
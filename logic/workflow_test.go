@@ -0,0 +1,228 @@
+package logic
+
+import (
+	"bluebell/dao/mysql"
+	"bluebell/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bndr/gojenkins"
+)
+
+// fakeWorkflowJenkins 只实现工作流用到的接口: 顶层 Job app 的触发、队列项查询与取消、构建查询
+// queue 中没有的队列项返回 404
+type fakeWorkflowJenkins struct {
+	*httptest.Server
+	mu        sync.Mutex
+	triggered int
+	queue     map[int64]string
+	cancelled []string
+}
+
+func newFakeWorkflowJenkins(t *testing.T) *fakeWorkflowJenkins {
+	f := &fakeWorkflowJenkins{queue: make(map[int64]string)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeWorkflowJenkins) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var id int64
+	switch {
+	case r.URL.Path == "/api/json":
+		fmt.Fprint(w, `{}`)
+	case r.URL.Path == "/job/app/api/json":
+		fmt.Fprintf(w, `{"name":"app","url":"%s/job/app/","inQueue":false}`, f.URL)
+	case r.Method == http.MethodPost && r.URL.Path == "/job/app/build":
+		f.triggered++
+		id = int64(100 + f.triggered)
+		f.queue[id] = `{"id":` + fmt.Sprint(id) + `}`
+		w.Header().Set("Location", fmt.Sprintf("%s/queue/item/%d/", f.URL, id))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && r.URL.Path == "/queue/cancelItem":
+		f.cancelled = append(f.cancelled, r.URL.Query().Get("id"))
+	case strings.HasPrefix(r.URL.Path, "/queue/item/"):
+		fmt.Sscanf(r.URL.Path, "/queue/item/%d/api/json", &id)
+		body, ok := f.queue[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	case r.URL.Path == "/job/app//5/api/json": // gojenkins 以 Job 的 url (带 / 结尾) 拼接构建地址
+		fmt.Fprint(w, `{"number":5,"building":true}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// addTestNode 新增指向 rawURL (fake Jenkins) 的节点
+func addTestNode(t *testing.T, rawURL string) int {
+	t.Helper()
+	u, _ := url.Parse(rawURL)
+	name := uniqueName("node")
+	if err := mysql.AddNode(&models.ServerNode{Name: name, Host: u.Hostname(), Port: u.Port(), Account: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := mysql.GetNodesByName(name)
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("get node %s: %v", name, err)
+	}
+	return nodes[0].ID
+}
+
+// addTestWorkflowRun 新增只有一个 Job 步骤的运行, 不启动引擎
+func addTestWorkflowRun(t *testing.T, nodeID int) *models.WorkflowRun {
+	t.Helper()
+	run := &models.WorkflowRun{
+		Status: models.WorkflowStatusRunning,
+		Steps:  models.WorkflowSteps{{Name: "build", Type: models.StepTypeJob, NodeID: nodeID, ViewID: "app"}},
+		States: models.StepStates{"build": {Status: models.StepStatusPending}},
+	}
+	if err := mysql.AddWorkflowRun(run); err != nil {
+		t.Fatal(err)
+	}
+	return run
+}
+
+func TestLockWorkflowRun(t *testing.T) {
+	run := addTestWorkflowRun(t, 0)
+
+	unlock, err := lockWorkflowRun(run.ID, 0)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	if _, err := lockWorkflowRun(run.ID, 0); err != ErrorWorkflowBusy {
+		t.Errorf("second lock: err = %v, want ErrorWorkflowBusy", err)
+	}
+	unlock()
+
+	unlock, err = lockWorkflowRun(run.ID, 0)
+	if err != nil {
+		t.Fatalf("lock after unlock: %v", err)
+	}
+	unlock()
+
+	// 持有者退出后锁过期, 可被其他实例抢占
+	past := time.Now().Add(-time.Minute).Format(timeLayout)
+	if ok, err := mysql.LockWorkflowRun(run.ID, "crashed-instance", past, past); !ok || err != nil {
+		t.Fatalf("LockWorkflowRun = %v, %v", ok, err)
+	}
+	unlock, err = lockWorkflowRun(run.ID, 0)
+	if err != nil {
+		t.Fatalf("lock expired: %v", err)
+	}
+	unlock()
+
+	if _, err := lockWorkflowRun(-1, 0); err != sql.ErrNoRows {
+		t.Errorf("lock missing run: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+// 锁被其他实例持有时不推进运行, 多个实例同时推进时只触发一次构建
+func TestAdvanceWorkflowRunClaim(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	run := addTestWorkflowRun(t, addTestNode(t, f.URL))
+
+	now := time.Now()
+	if ok, err := mysql.LockWorkflowRun(run.ID, "other-instance", now.Format(timeLayout), now.Add(time.Minute).Format(timeLayout)); !ok || err != nil {
+		t.Fatalf("LockWorkflowRun = %v, %v", ok, err)
+	}
+	if done, err := advanceWorkflowRun(run.ID); done || err != nil {
+		t.Errorf("advance locked run = %v, %v, want false, nil", done, err)
+	}
+	if f.triggered != 0 {
+		t.Fatalf("triggered %d builds while another instance holds the run", f.triggered)
+	}
+	if err := mysql.UnlockWorkflowRun(run.ID, "other-instance"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := advanceWorkflowRun(run.ID); err != nil {
+				t.Errorf("advance: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if f.triggered != 1 {
+		t.Errorf("triggered %d builds, want 1", f.triggered)
+	}
+	got, err := mysql.GetWorkflowRunByID(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := got.States["build"]; s.Status != models.StepStatusRunning || s.QueueID != 101 {
+		t.Errorf("step = %+v, want running with queue 101", s)
+	}
+	if got.LockOwner != "" {
+		t.Errorf("lock not released: %s", got.LockOwner)
+	}
+}
+
+func TestPollStepQueueItem(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	f.queue[1] = `{"id":1,"cancelled":true,"why":null}`
+	f.queue[2] = `{"id":2,"why":"Waiting for next available executor"}`
+	f.queue[3] = `{"id":3,"executable":{"number":5}}`
+	clients := map[int]*gojenkins.Jenkins{1: gojenkins.CreateJenkins(nil, f.URL)}
+	step := &models.WorkflowStep{Name: "build", NodeID: 1, ViewID: "app"}
+
+	tests := []struct {
+		name    string
+		queueID int64
+		status  string
+		number  int64
+	}{
+		{"cancelled in queue", 1, models.StepStatusFailed, 0},
+		{"queue item expired", 404, models.StepStatusFailed, 0},
+		{"still queued", 2, models.StepStatusRunning, 0},
+		{"building", 3, models.StepStatusRunning, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &models.StepState{Status: models.StepStatusRunning, QueueID: tt.queueID}
+			if err := pollStep(context.Background(), clients, step, state); err != nil {
+				t.Fatalf("pollStep: %v", err)
+			}
+			if state.Status != tt.status || state.BuildNumber != tt.number {
+				t.Errorf("state = %+v, want %s with build %d", state, tt.status, tt.number)
+			}
+			if state.Status == models.StepStatusFailed && (state.Error == "" || state.EndTime == "") {
+				t.Errorf("failed step without error or end time: %+v", state)
+			}
+		})
+	}
+}
+
+func TestCancelStepBuildQueued(t *testing.T) {
+	f := newFakeWorkflowJenkins(t)
+	f.queue[2] = `{"id":2}`
+	f.queue[1] = `{"id":1,"cancelled":true}`
+	clients := map[int]*gojenkins.Jenkins{1: gojenkins.CreateJenkins(nil, f.URL)}
+	step := &models.WorkflowStep{Name: "build", NodeID: 1, ViewID: "app"}
+
+	for _, id := range []int64{1, 2, 404} {
+		if err := cancelStepBuild(context.Background(), clients, step, &models.StepState{QueueID: id}); err != nil {
+			t.Errorf("cancel queue item %d: %v", id, err)
+		}
+	}
+	// 只取消仍在排队的队列项
+	if len(f.cancelled) != 1 || f.cancelled[0] != "2" {
+		t.Errorf("cancelled = %v, want [2]", f.cancelled)
+	}
+}
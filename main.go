@@ -3,7 +3,11 @@ package main
 import (
 	"bluebell/controller"
 	"bluebell/dao/mysql"
+	"bluebell/dao/redis"
 	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/pkg/clientip"
+	"bluebell/pkg/jwt"
 	"bluebell/pkg/snowflake"
 	"bluebell/router"
 	"bluebell/setting"
@@ -30,21 +34,50 @@ func main() {
 		return
 	}
 	defer mysql.Close() // 程序退出关闭数据库连接
-	//if err := redis.Init(setting.Conf.RedisConfig); err != nil {
-	//	fmt.Printf("init redis failed, err:%v\n", err)
-	//	return
-	//}
-	//defer redis.Close()
+	// redis 为可选配置, 用于多实例部署时的定时构建触发锁等
+	if setting.Conf.RedisConfig != nil {
+		if err := redis.Init(setting.Conf.RedisConfig); err != nil {
+			fmt.Printf("init redis failed, err:%v\n", err)
+			return
+		}
+		defer redis.Close()
+	}
 
 	if err := snowflake.Init(setting.Conf.StartTime, setting.Conf.MachineID); err != nil {
 		fmt.Printf("init snowflake failed, err:%v\n", err)
 		return
 	}
+	// 为配置的用户授予管理员角色
+	if err := logic.InitAdmins(setting.Conf.AdminUsers); err != nil {
+		fmt.Printf("init admins failed, err:%v\n", err)
+		return
+	}
+	// 设置可信的反向代理
+	if err := clientip.Init(setting.Conf.TrustedProxies); err != nil {
+		fmt.Printf("init trusted proxies failed, err:%v\n", err)
+		return
+	}
+	// 加载JWT签名密钥
+	if err := jwt.Init(setting.Conf.JWTConfig, setting.Conf.Mode); err != nil {
+		fmt.Printf("init jwt failed, err:%v\n", err)
+		return
+	}
 	// 初始化gin框架内置的校验器使用的翻译器
 	if err := controller.InitTrans("zh"); err != nil {
 		fmt.Printf("init validator trans failed, err:%v\n", err)
 		return
 	}
+	// 初始化构建日志归档存储
+	if err := logic.InitArchive(); err != nil {
+		fmt.Printf("init archive failed, err:%v\n", err)
+		return
+	}
+	// 启动定时构建调度器
+	logic.StartScheduler()
+	// 恢复未结束的工作流
+	logic.StartWorkflowEngine()
+	// 定时采集各节点 Jenkins 的状态指标
+	logic.StartJenkinsMetrics()
 	// 注册路由
 	r := router.SetupRouter(setting.Conf.Mode)
 	err := r.Run(fmt.Sprintf(":%d", setting.Conf.Port))
@@ -0,0 +1,281 @@
+package middlewares
+
+import (
+	"bluebell/controller"
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"bluebell/pkg/clientip"
+	"bluebell/pkg/jwt"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditActions 需要审计的接口 (方法 + 路由) 与操作名称
+// 未列出的非 GET 接口同样会记录, 操作名称为方法 + 路由
+var auditActions = map[string]string{
+	"POST /signup":                "user.signup",
+	"POST /login":                 "user.login",
+	"POST /login/2fa":             "user.login_2fa",
+	"POST /login/2fa/enroll":      "user.enroll_2fa",
+	"POST /logout":                "user.logout",
+	"PUT /me":                     "user.update_profile",
+	"PUT /me/password":            "user.change_password",
+	"POST /me/2fa/enroll":         "user.enroll_2fa",
+	"POST /me/2fa/activate":       "user.enable_2fa",
+	"POST /me/2fa/disable":        "user.disable_2fa",
+	"POST /me/2fa/recovery_codes": "user.regenerate_recovery_codes",
+	"POST /api_token":             "api_token.create",
+	"DELETE /api_token/:id":       "api_token.revoke",
+
+	"POST /server/node":       "node.create",
+	"PUT /server/node":        "node.update",
+	"DELETE /server/node/:id": "node.delete",
+
+	"POST /server/view_jobs/start/job":         "build.start",
+	"POST /server/view_jobs/stop/job":          "build.stop",
+	"DELETE /server/view_console/build/delete": "build.delete",
+	"POST /server/multi_node/build":            "build.multi_start",
+	"POST /server/multi_node/stop":             "build.multi_stop",
+	"POST /server/console_archive":             "console_archive.create",
+	"DELETE /server/console_archive/:id":       "console_archive.delete",
+
+	"POST /server/job_config/update":   "job_config.update",
+	"POST /server/job_config/rollback": "job_config.rollback",
+	"POST /server/job_template":        "job_template.create",
+	"PUT /server/job_template":         "job_template.update",
+	"DELETE /server/job_template/:id":  "job_template.delete",
+	"POST /server/job_template/apply":  "job_template.apply",
+
+	"POST /server/schedule":             "schedule.create",
+	"PUT /server/schedule":              "schedule.update",
+	"DELETE /server/schedule/:id":       "schedule.delete",
+	"POST /server/workflow":             "workflow.create",
+	"PUT /server/workflow":              "workflow.update",
+	"DELETE /server/workflow/:id":       "workflow.delete",
+	"POST /server/workflow/start":       "workflow.start",
+	"POST /server/workflow_run/pause":   "workflow.pause",
+	"POST /server/workflow_run/resume":  "workflow.resume",
+	"POST /server/workflow_run/cancel":  "workflow.cancel",
+	"POST /server/workflow_run/approve": "workflow.approve",
+
+	"POST /server/notify_rule":       "notify_rule.create",
+	"PUT /server/notify_rule":        "notify_rule.update",
+	"DELETE /server/notify_rule/:id": "notify_rule.delete",
+	"POST /server/notify_rule/test":  "notify_rule.test",
+	"POST /server/webhook/secret":    "webhook.generate_secret",
+
+	"POST /server/rbac/role":          "role.create",
+	"PUT /server/rbac/role":           "role.update",
+	"DELETE /server/rbac/role/:id":    "role.delete",
+	"PUT /server/rbac/role/2fa":       "role.require_2fa",
+	"PUT /server/rbac/user_role":      "user.set_roles",
+	"POST /server/user":               "user.create",
+	"PUT /server/user/status":         "user.set_status",
+	"PUT /server/user/password":       "user.reset_password",
+	"DELETE /server/user/:userId":     "user.delete",
+	"DELETE /server/user/:userId/2fa": "user.reset_2fa",
+}
+
+// auditSkip 不记录的非 GET 接口: 使用 POST 的只读查询, 以及 Jenkins 推送的 Webhook
+var auditSkip = map[string]bool{
+	"POST /refresh":                               true,
+	"POST /server/node_view/get/view":             true,
+	"POST /server/view/get":                       true,
+	"POST /server/view_jobs/get/job":              true,
+	"POST /server/view_console/get":               true,
+	"POST /server/view_console/pipeline/overview": true,
+	"POST /server/view_console/pipeline/console":  true,
+	"POST /server/view_console/build/previous":    true,
+	"POST /server/view_console/build/next":        true,
+	"POST /server/view_console/search":            true,
+	"POST /server/view_console/problems":          true,
+	"POST /server/job_config/get":                 true,
+	"POST /server/job_config/versions":            true,
+	"POST /server/job_config/diff":                true,
+	"POST /server/job_template/render":            true,
+	"POST /server/multi_node/search":              true,
+	"POST /server/webhook/jenkins/:nodeId":        true,
+}
+
+const (
+	auditBodyLimit  = 4096 // 用于判断结果的响应体最大长度
+	auditValueLimit = 256  // 审计详情中单个参数值的最大长度
+)
+
+// auditWriter 在写出响应的同时保留响应体的开头部分, 用于判断操作结果
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if room := auditBodyLimit - w.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		w.body.Write(b[:room])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// AuditMiddleware 审计中间件, 需要在 AuthMiddleware 之后使用
+// 记录每个变更操作的用户、IP、节点/Job/构建、结果以及 Jenkins 的响应状态码, 被权限校验拒绝的请求也会记录
+func AuditMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		key := routeKey(c)
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if c.FullPath() == "" || auditSkip[key] {
+			c.Next()
+			return
+		}
+		action, ok := auditActions[key]
+		if !ok {
+			action = key
+		}
+
+		fields, _ := requestFields(c) // 参数重复时由 RBACMiddleware 拒绝, 此时不记录参数
+		status := new(logic.JenkinsStatus)
+		c.Set(controller.CtxJenkinsStatusKey, status)
+		w := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		defer func() {
+			// 处理函数 panic 时同样记录, 再交给 GinRecovery 处理
+			if r := recover(); r != nil {
+				l := newAuditLog(c, key, action, fields, status)
+				l.Outcome = models.AuditFailure
+				l.Status = http.StatusInternalServerError
+				l.Message = fmt.Sprint(r)
+				logic.RecordAudit(l)
+				panic(r)
+			}
+		}()
+		c.Next()
+
+		l := newAuditLog(c, key, action, fields, status)
+		l.Status = w.Status()
+		l.Outcome, l.Message = auditOutcome(w.Status(), w.body.Bytes())
+		logic.RecordAudit(l)
+	}
+}
+
+// newAuditLog 根据请求生成审计日志, 结果由调用方填写
+func newAuditLog(c *gin.Context, key, action string, fields map[string]interface{}, status *logic.JenkinsStatus) *models.AuditLog {
+	l := &models.AuditLog{
+		IP:            clientip.FromRequest(c.Request),
+		Method:        c.Request.Method,
+		Path:          c.Request.URL.Path,
+		Action:        action,
+		NodeID:        toInt(fields["nodeid"]),
+		ViewID:        toString(fields["viewid"]),
+		JobName:       toString(fields["jobname"]),
+		BuildNumber:   int64(toInt(fields["buildnumber"])),
+		JenkinsStatus: status.Code(),
+	}
+	if uid, ok := c.Get(controller.CtxUserIDKey); ok {
+		l.UserID, _ = uid.(int64)
+	}
+	if v, ok := c.Get(controller.CtxClaimsKey); ok {
+		if claims, ok := v.(*jwt.MyClaims); ok {
+			l.Username = claims.Username
+		}
+	}
+	if l.UserID == 0 {
+		// 登录等公开接口, 使用请求中的用户名
+		l.Username = toString(fields["username"])
+	}
+
+	// 节点管理接口中 id 即为节点 ID; 批量操作取第一个节点, 完整列表见详情
+	if rp, ok := routePermissions[key]; ok && rp.NodeFromID {
+		l.NodeID = toInt(fields["id"])
+	}
+	if ids, ok := fields["nodeids"].([]interface{}); ok && l.NodeID == 0 && len(ids) > 0 {
+		l.NodeID = toInt(ids[0])
+	}
+	// 启动/停止接口中 viewName 为目录下的 Job
+	if l.JobName == "" {
+		l.JobName = toString(fields["viewname"])
+	}
+	if v, ok := c.Get(controller.CtxBuildNumberKey); ok {
+		if n, _ := v.(int64); n != 0 {
+			l.BuildNumber = n
+		}
+	}
+	if detail, err := json.Marshal(redactAuditValue(fields)); err == nil {
+		l.Detail = string(detail)
+	}
+	return l
+}
+
+// auditOutcome 根据响应判断操作结果
+// 响应体中有 success 字段时以其为准, 有 code 字段时 CodeSuccess 为成功, 否则按 HTTP 状态码判断
+func auditOutcome(status int, body []byte) (outcome, message string) {
+	var resp struct {
+		Success *bool   `json:"success"`
+		Code    *int64  `json:"code"`
+		Error   string  `json:"error"`
+		Msg     *string `json:"msg"`
+	}
+	ok := status < http.StatusBadRequest
+	if json.Unmarshal(body, &resp) == nil {
+		switch {
+		case resp.Success != nil:
+			ok = ok && *resp.Success
+		case resp.Code != nil:
+			ok = ok && controller.ResCode(*resp.Code) == controller.CodeSuccess
+		}
+	}
+	if ok {
+		return models.AuditSuccess, ""
+	}
+	message = resp.Error
+	if message == "" && resp.Msg != nil {
+		message = *resp.Msg
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return models.AuditFailure, message
+}
+
+// redactAuditValue 按日志的脱敏规则脱敏请求参数, 并截断过长的值 (如 Job 配置)
+func redactAuditValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if logger.Sensitive(k) {
+				out[k] = logger.RedactMask
+				continue
+			}
+			out[k] = redactAuditValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = redactAuditValue(val)
+		}
+		return out
+	case string:
+		v = logger.Scrub(v)
+		if len(v) > auditValueLimit {
+			return fmt.Sprintf("%s...(%d bytes)", v[:auditValueLimit], len(v))
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"bluebell/logger"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// 审计详情与日志使用相同的脱敏规则
+func TestRedactAuditValue(t *testing.T) {
+	fields := map[string]interface{}{
+		"username":     "bob",
+		"password":     "p@ss",
+		"re_password":  "p@ss",
+		"code":         "123456",
+		"challenge":    "c",
+		"clientSecret": "p@ss",
+		"nodeId":       float64(1),
+		"host":         "http://admin:p@ss@jenkins:8080",
+		"steps":        []interface{}{map[string]interface{}{"token": "p@ss", "jobName": "web"}},
+		"config":       strings.Repeat("x", auditValueLimit+10),
+	}
+	b, _ := json.Marshal(redactAuditValue(fields))
+	got := string(b)
+	if strings.Contains(got, "p@ss") || strings.Contains(got, "123456") {
+		t.Errorf("secret leaked: %s", got)
+	}
+	for _, want := range []string{
+		`"username":"bob"`,
+		`"password":"` + logger.RedactMask + `"`,
+		`"code":"` + logger.RedactMask + `"`,
+		`"challenge":"` + logger.RedactMask + `"`,
+		`"nodeId":1`,
+		`"host":"http://admin:` + logger.RedactMask + `@jenkins:8080"`,
+		`"steps":[{"jobName":"web","token":"` + logger.RedactMask + `"}]`,
+		`...(266 bytes)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("detail = %s, want %s", got, want)
+		}
+	}
+	// 不修改原始参数
+	if fields["password"] != "p@ss" {
+		t.Error("redactAuditValue modified the request fields")
+	}
+}
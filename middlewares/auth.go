@@ -2,12 +2,37 @@ package middlewares
 
 import (
 	"bluebell/controller"
+	"bluebell/logger"
+	"bluebell/logic"
 	"bluebell/pkg/jwt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
+// ctxPublicKey 标记当前请求为公开接口, 后续的权限校验中间件据此跳过
+const ctxPublicKey = "publicRoute"
+
+// AuthMiddleware 全局认证中间件
+// public 为公开接口白名单 (方法 + 路由, 如 "POST /login"), 其余接口都需要携带有效的 JWT
+// 没有匹配到路由的请求交给 NoRoute 处理, 不要求登录
+func AuthMiddleware(public map[string]bool) func(c *gin.Context) {
+	jwtAuth := JWTAuthMiddleware()
+	return func(c *gin.Context) {
+		if c.FullPath() == "" || public[routeKey(c)] {
+			c.Set(ctxPublicKey, true)
+			c.Next()
+			return
+		}
+		jwtAuth(c)
+	}
+}
+
+func routeKey(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
+
 // JWTAuthMiddleware 基于JWT的认证中间件
 func JWTAuthMiddleware() func(c *gin.Context) {
 	return func(c *gin.Context) {
@@ -16,6 +41,12 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 		// Authorization: Bearer xxxxxxx.xxx.xxx  / X-TOKEN: xxx.xxx.xx
 		// 这里的具体实现方式要依据你的实际业务情况决定
 		authHeader := c.Request.Header.Get("Authorization")
+		if authHeader == "" && c.GetHeader("Accept") == "text/event-stream" {
+			// 浏览器的 EventSource 无法设置请求头, SSE 接口允许通过 URI 的 token 参数携带
+			if token := c.Query("token"); token != "" {
+				authHeader = "Bearer " + token
+			}
+		}
 		if authHeader == "" {
 			controller.ResponseError(c, controller.CodeNeedLogin)
 			c.Abort()
@@ -28,6 +59,11 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			c.Abort()
 			return
 		}
+		// 脚本和 CI 使用个人访问令牌
+		if logic.IsAPIToken(parts[1]) {
+			apiTokenAuth(c, parts[1])
+			return
+		}
 		// parts[1]是获取到的tokenString，我们使用之前定义好的解析JWT的函数来解析它
 		mc, err := jwt.ParseToken(parts[1])
 		if err != nil {
@@ -35,9 +71,54 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			c.Abort()
 			return
 		}
+		// 已注销的token不能再使用
+		revoked, err := logic.IsTokenRevoked(mc.Id)
+		if err != nil {
+			logger.L(c).Error("logic.IsTokenRevoked failed", zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+			c.Abort()
+			return
+		}
+		if revoked {
+			controller.ResponseError(c, controller.CodeInvalidToken)
+			c.Abort()
+			return
+		}
+		// 用户被删除或禁用后, 未过期的token也不能再使用
+		active, err := logic.IsUserActive(mc.UserID)
+		if err != nil {
+			logger.L(c).Error("logic.IsUserActive failed", zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+			c.Abort()
+			return
+		}
+		if !active {
+			controller.ResponseError(c, controller.CodeUserDisabled)
+			c.Abort()
+			return
+		}
 		// 将当前请求的userID信息保存到请求的上下文c上
 		c.Set(controller.CtxUserIDKey, mc.UserID)
+		c.Set(controller.CtxClaimsKey, mc)
 
 		c.Next() // 后续的处理请求的函数中 可以用过c.Get(CtxUserIDKey) 来获取当前请求的用户信息
 	}
 }
+
+// apiTokenAuth 校验个人访问令牌, 令牌的权限范围保存在上下文中, 由 RBACMiddleware 校验
+func apiTokenAuth(c *gin.Context, token string) {
+	t, err := logic.AuthenticateAPIToken(token)
+	if err != nil {
+		if err != logic.ErrorInvalidAPIToken {
+			logger.L(c).Error("logic.AuthenticateAPIToken failed", zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+		} else {
+			controller.ResponseError(c, controller.CodeInvalidToken)
+		}
+		c.Abort()
+		return
+	}
+	c.Set(controller.CtxUserIDKey, t.UserID)
+	c.Set(controller.CtxScopesKey, []string(t.Scopes))
+	c.Next()
+}
@@ -0,0 +1,345 @@
+package middlewares
+
+import (
+	"bluebell/controller"
+	"bluebell/logger"
+	"bluebell/logic"
+	"bluebell/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+)
+
+// routePermission 接口需要的权限
+// NodeFromID 为 true 时, 路径参数或请求体中的 id 即为节点 ID (节点管理接口)
+// AnyScope 为 true 时, 拥有任意范围的该权限即可, 用于节点列表等不针对具体 Job 的接口
+// Records 为按 ID 访问记录的接口中, 参数名 (经过 normalizeKey 处理) 与记录类型的对应关系
+// 需要对记录自身的节点和 Job 拥有权限, 不能只依据客户端传入的 nodeId 校验
+type routePermission struct {
+	Permission string
+	NodeFromID bool
+	AnyScope   bool
+	Records    map[string]string
+}
+
+// byRunID 通过请求体中的 runId 访问工作流运行记录
+var byRunID = map[string]string{"runid": logic.RecordWorkflowRun}
+
+// byID 通过路径参数或请求体中的 id 访问记录
+func byID(kind string) map[string]string {
+	return map[string]string{"id": kind}
+}
+
+// routePermissions 接口 (方法 + 路由) 与权限的对应关系
+// 未列出的接口: GET 需要 view 权限, 其他方法需要 admin 权限
+
+var routePermissions = map[string]routePermission{
+	"POST /server/node":               {Permission: models.PermManageNode},
+	"GET /server/node":                {Permission: models.PermView, AnyScope: true},
+	"PUT /server/node":                {Permission: models.PermManageNode, NodeFromID: true},
+	"DELETE /server/node/:id":         {Permission: models.PermManageNode, NodeFromID: true},
+	"POST /server/node_view/get/view": {Permission: models.PermView},
+	"POST /server/view/get":           {Permission: models.PermView},
+
+	"POST /server/view_jobs/get/job":   {Permission: models.PermView},
+	"POST /server/view_jobs/start/job": {Permission: models.PermBuild},
+	"POST /server/view_jobs/stop/job":  {Permission: models.PermStop},
+
+	"POST /server/view_console/get":               {Permission: models.PermView},
+	"POST /server/view_console/pipeline/overview": {Permission: models.PermView},
+	"POST /server/view_console/pipeline/console":  {Permission: models.PermView},
+	"POST /server/view_console/build/previous":    {Permission: models.PermView},
+	"POST /server/view_console/build/next":        {Permission: models.PermView},
+	"DELETE /server/view_console/build/delete":    {Permission: models.PermDeleteBuild},
+	"POST /server/view_console/search":            {Permission: models.PermView},
+	"POST /server/view_console/problems":          {Permission: models.PermView},
+	"GET /server/view_console/rules":              {Permission: models.PermView, AnyScope: true},
+	"POST /server/console_archive":                {Permission: models.PermView},
+	"GET /server/console_archive":                 {Permission: models.PermView},
+	"GET /server/console_archive/:id":             {Permission: models.PermView, Records: byID(logic.RecordConsoleArchive)},
+	"DELETE /server/console_archive/:id":          {Permission: models.PermDeleteBuild, Records: byID(logic.RecordConsoleArchive)},
+
+	"POST /server/job_config/get":        {Permission: models.PermView},
+	"POST /server/job_config/update":     {Permission: models.PermManageNode},
+	"POST /server/job_config/versions":   {Permission: models.PermView},
+	"GET /server/job_config/version/:id": {Permission: models.PermView, Records: byID(logic.RecordJobConfigVersion)},
+	"POST /server/job_config/diff": {Permission: models.PermView, Records: map[string]string{
+		"fromid": logic.RecordJobConfigVersion,
+		"toid":   logic.RecordJobConfigVersion,
+	}},
+	"POST /server/job_config/rollback": {Permission: models.PermManageNode, Records: map[string]string{"versionid": logic.RecordJobConfigVersion}},
+	"POST /server/job_template":        {Permission: models.PermManageNode},
+	"PUT /server/job_template":         {Permission: models.PermManageNode},
+	"DELETE /server/job_template/:id":  {Permission: models.PermManageNode},
+	"POST /server/job_template/render": {Permission: models.PermView},
+	"POST /server/job_template/apply":  {Permission: models.PermManageNode},
+
+	"POST /server/multi_node/search": {Permission: models.PermView},
+	"POST /server/multi_node/build":  {Permission: models.PermBuild},
+	"POST /server/multi_node/stop":   {Permission: models.PermStop},
+
+	"POST /server/schedule":             {Permission: models.PermBuild},
+	"GET /server/schedule/:id":          {Permission: models.PermView, Records: byID(logic.RecordSchedule)},
+	"PUT /server/schedule":              {Permission: models.PermBuild, Records: byID(logic.RecordSchedule)},
+	"DELETE /server/schedule/:id":       {Permission: models.PermBuild, Records: byID(logic.RecordSchedule)},
+	"POST /server/workflow":             {Permission: models.PermBuild},
+	"GET /server/workflow/:id":          {Permission: models.PermView, Records: byID(logic.RecordWorkflow)},
+	"PUT /server/workflow":              {Permission: models.PermBuild, Records: byID(logic.RecordWorkflow)},
+	"DELETE /server/workflow/:id":       {Permission: models.PermBuild, Records: byID(logic.RecordWorkflow)},
+	"POST /server/workflow/start":       {Permission: models.PermBuild, Records: map[string]string{"workflowid": logic.RecordWorkflow}},
+	"GET /server/workflow_run":          {Permission: models.PermView, Records: map[string]string{"workflowid": logic.RecordWorkflow}},
+	"GET /server/workflow_run/:id":      {Permission: models.PermView, Records: byID(logic.RecordWorkflowRun)},
+	"POST /server/workflow_run/pause":   {Permission: models.PermBuild, Records: byRunID},
+	"POST /server/workflow_run/resume":  {Permission: models.PermBuild, Records: byRunID},
+	"POST /server/workflow_run/cancel":  {Permission: models.PermStop, Records: byRunID},
+	"POST /server/workflow_run/approve": {Permission: models.PermBuild, Records: byRunID},
+
+	"POST /server/notify_rule":       {Permission: models.PermManageNode},
+	"GET /server/notify_rule/:id":    {Permission: models.PermView, Records: byID(logic.RecordNotifyRule)},
+	"PUT /server/notify_rule":        {Permission: models.PermManageNode, Records: byID(logic.RecordNotifyRule)},
+	"DELETE /server/notify_rule/:id": {Permission: models.PermManageNode, Records: byID(logic.RecordNotifyRule)},
+	"POST /server/notify_rule/test":  {Permission: models.PermManageNode, Records: byID(logic.RecordNotifyRule)},
+	"GET /server/notify_log":         {Permission: models.PermView, Records: map[string]string{"ruleid": logic.RecordNotifyRule}},
+	"POST /server/webhook/secret":    {Permission: models.PermManageNode},
+	"GET /server/webhook/events":     {Permission: models.PermView, AnyScope: true}, // 每个事件再按其节点和 Job 校验
+
+	"GET /server/rbac/role":              {Permission: models.PermAdmin},
+	"GET /server/rbac/role/:id":          {Permission: models.PermAdmin},
+	"GET /server/rbac/user_role/:userId": {Permission: models.PermAdmin},
+	"GET /server/user":                   {Permission: models.PermAdmin},
+	"GET /server/audit":                  {Permission: models.PermAdmin},
+	"GET /server/audit/export":           {Permission: models.PermAdmin},
+}
+
+// RBACMiddleware 基于角色的权限校验中间件, 需要在 AuthMiddleware 之后使用, 公开接口不校验
+// 从路径参数、查询参数和 JSON 请求体中提取节点和 Job, 用户需要对其中每一个都拥有接口对应的权限
+func RBACMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if c.GetBool(ctxPublicKey) {
+			c.Next()
+			return
+		}
+		userID, ok := c.Get(controller.CtxUserIDKey)
+		if !ok {
+			controller.ResponseError(c, controller.CodeNeedLogin)
+			c.Abort()
+			return
+		}
+		rp, ok := routePermissions[routeKey(c)]
+		if !ok {
+			rp = routePermission{Permission: models.PermAdmin}
+			if c.Request.Method == http.MethodGet {
+				rp.Permission = models.PermView
+			}
+		}
+
+		// 个人访问令牌只能使用令牌范围内的权限
+		if v, ok := c.Get(controller.CtxScopesKey); ok {
+			if scopes, _ := v.([]string); !logic.ScopeAllows(scopes, rp.Permission) {
+				controller.ResponseError(c, controller.CodeNoPermission)
+				c.Abort()
+				return
+			}
+		}
+
+		fields, err := requestFields(c)
+		if err != nil {
+			controller.ResponseErrorWithMsg(c, controller.CodeInvalidParam, err.Error())
+			c.Abort()
+			return
+		}
+		var allowed bool
+		if rp.AnyScope {
+			allowed, err = logic.HasPermission(userID.(int64), rp.Permission)
+		} else {
+			var resources []models.Resource
+			if resources, err = requestResources(fields, rp); err == nil {
+				// 先拒绝含有 . 或 .. 路径段的目录和 Job 名, 保证校验的路径即为实际访问的路径
+				for _, res := range resources {
+					if err := logic.ValidateJobPath(res.ViewID, res.JobName); err != nil {
+						controller.ResponseErrorWithMsg(c, controller.CodeInvalidParam, err.Error())
+						c.Abort()
+						return
+					}
+				}
+				allowed, err = logic.CheckPermission(userID.(int64), rp.Permission, resources)
+			}
+		}
+		if err != nil {
+			logger.L(c).Error("logic.CheckPermission failed", zap.Error(err))
+			controller.ResponseError(c, controller.CodeServerBusy)
+			c.Abort()
+			return
+		}
+		if !allowed {
+			controller.ResponseError(c, controller.CodeNoPermission)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestResources 提取请求访问的资源
+// 键名不区分大小写和下划线, 支持 nodeId / viewId / jobName, nodeIds 数组, 以及 targets / steps 数组中的对象
+// 按 ID 访问记录的接口还包括记录自身的节点和 Job
+func requestResources(fields map[string]interface{}, rp routePermission) ([]models.Resource, error) {
+	if rp.NodeFromID {
+		if id := toInt(fields["id"]); id > 0 {
+			return []models.Resource{{NodeID: id}}, nil
+		}
+		return nil, nil
+	}
+
+	var resources []models.Resource
+	if res, ok := resourceFromFields(fields); ok {
+		resources = append(resources, res)
+	}
+	if ids, ok := fields["nodeids"].([]interface{}); ok {
+		for _, id := range ids {
+			resources = append(resources, models.Resource{
+				NodeID:  toInt(id),
+				ViewID:  toString(fields["viewid"]),
+				JobName: toString(fields["jobname"]),
+			})
+		}
+	}
+	for _, key := range []string{"targets", "steps"} {
+		items, _ := fields[key].([]interface{})
+		for _, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sub := make(map[string]interface{}, len(obj))
+			for k, v := range obj {
+				if err := setField(sub, k, v); err != nil {
+					return nil, err
+				}
+			}
+			if res, ok := resourceFromFields(sub); ok {
+				resources = append(resources, res)
+			}
+		}
+	}
+
+	for key, kind := range rp.Records {
+		id := toInt(fields[key])
+		if id <= 0 {
+			continue
+		}
+		res, found, err := logic.RecordResources(kind, int64(id))
+		if err != nil {
+			return nil, err
+		}
+		if found && len(res) == 0 {
+			// 不限节点的记录需要不限节点的授权
+			res = []models.Resource{{}}
+		}
+		resources = append(resources, res...)
+	}
+	return resources, nil
+}
+
+// requestFields 合并路径参数、查询参数和请求体 (JSON / 表单) 中的参数, 键名经过 normalizeKey 处理
+// 读取后请求体会被还原, 不影响后续的参数绑定
+// 同一参数以不同的写法 (如 nodeId 和 NodeID) 出现且取值不同时返回错误, 避免校验的值与实际绑定的值不一致
+func requestFields(c *gin.Context) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for k, v := range c.Request.URL.Query() {
+		if len(v) == 0 {
+			continue
+		}
+		if err := setField(fields, k, v[0]); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range c.Params {
+		if err := setField(fields, p.Key, p.Value); err != nil {
+			return nil, err
+		}
+	}
+	switch ct := c.ContentType(); {
+	case c.Request.Body == nil:
+	case strings.Contains(ct, "json"):
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err == nil {
+			c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+			var obj map[string]interface{}
+			if json.Unmarshal(body, &obj) == nil {
+				for k, v := range obj {
+					if err := setField(fields, k, v); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	case ct == binding.MIMEPOSTForm || ct == binding.MIMEMultipartPOSTForm:
+		// 解析结果缓存在 Request.Form 中, 不影响后续的参数绑定
+		if ct == binding.MIMEMultipartPOSTForm {
+			c.Request.ParseMultipartForm(32 << 20)
+		} else {
+			c.Request.ParseForm()
+		}
+		for k, v := range c.Request.PostForm {
+			if len(v) == 0 {
+				continue
+			}
+			if err := setField(fields, k, v[0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fields, nil
+}
+
+// setField 按 normalizeKey 处理后的键名保存参数, 已有取值不同的同名参数时返回错误
+func setField(fields map[string]interface{}, k string, v interface{}) error {
+	key := normalizeKey(k)
+	if old, ok := fields[key]; ok && fmt.Sprint(old) != fmt.Sprint(v) {
+		return fmt.Errorf("参数 %s 重复", k)
+	}
+	fields[key] = v
+	return nil
+}
+
+// resourceFromFields 从参数中取节点和 Job, 没有节点时返回 false
+func resourceFromFields(fields map[string]interface{}) (models.Resource, bool) {
+	nodeID := toInt(fields["nodeid"])
+	if nodeID <= 0 {
+		return models.Resource{}, false
+	}
+	return models.Resource{
+		NodeID:  nodeID,
+		ViewID:  toString(fields["viewid"]),
+		JobName: toString(fields["jobname"]),
+	}, true
+}
+
+func normalizeKey(k string) string {
+	return strings.ToLower(strings.Replace(k, "_", "", -1))
+}
+
+func toInt(v interface{}) int {
+	switch v := v.(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+func toString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"bluebell/controller"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 含有 . 或 .. 路径段的目录和 Job 名在权限校验前被拒绝, 不会越过 job_pattern 的范围
+func TestRBACRejectsDotSegments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(controller.CtxUserIDKey, int64(1)) }, RBACMiddleware())
+	r.POST("/server/view_jobs/start/job", func(c *gin.Context) {
+		t.Error("handler reached")
+	})
+
+	for _, body := range []string{
+		`{"nodeId":1,"viewId":"teamA/../../job/secret"}`,
+		`{"nodeId":1,"viewId":"teamA","jobName":".."}`,
+		`{"nodeId":1,"viewId":"teamA/./x"}`,
+		`{"nodeId":1,"viewId":"teamA//x"}`,
+		`{"nodeId":1,"viewId":"/teamA"}`,
+		`{"nodeIds":[1],"viewId":"teamA/.."}`,
+		`{"targets":[{"nodeId":1,"viewId":"teamA","jobName":"../secret"}]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/server/view_jobs/start/job", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var res controller.ResponseData
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("%s: 响应不是 JSON: %q", body, w.Body.String())
+		}
+		if res.Code != controller.CodeInvalidParam {
+			t.Errorf("%s: code = %d, want %d", body, res.Code, controller.CodeInvalidParam)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+// APIToken 个人访问令牌, 供脚本和 CI 调用接口, 只保存 token 的 SHA-256
+// Scopes 为权限名称, 实际权限为令牌范围与用户角色授权的交集
+type APIToken struct {
+	ID         int64      `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"-"`
+	Name       string     `db:"name" json:"name"`
+	TokenHash  string     `db:"token_hash" json:"-"`
+	Prefix     string     `db:"prefix" json:"prefix"` // token 的前几位, 用于辨认
+	Scopes     StringList `db:"scopes" json:"scopes"`
+	ExpiresAt  string     `db:"expires_at" json:"expires_at"`
+	LastUsedAt string     `db:"last_used_at" json:"last_used_at"`
+	Revoked    bool       `db:"revoked" json:"revoked"`
+	CreateTime string     `db:"create_time" json:"create_time"`
+}
+
+// ParamAPIToken 创建个人访问令牌的请求参数
+type ParamAPIToken struct {
+	Name      string   `json:"name" binding:"required"`
+	Scopes    []string `json:"scopes" binding:"required,min=1"`
+	ExpiresIn int      `json:"expires_in" binding:"required,min=1,max=365"` // 有效天数
+}
@@ -0,0 +1,45 @@
+package models
+
+// 审计结果
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditLog 审计日志, 记录一次变更操作: 谁、何时、从哪个 IP、对哪个节点/Job/构建做了什么以及结果
+type AuditLog struct {
+	ID            int64  `db:"id" json:"id"`
+	UserID        int64  `db:"user_id" json:"user_id,string"`
+	Username      string `db:"username" json:"username"`
+	IP            string `db:"ip" json:"ip"`
+	Method        string `db:"method" json:"method"`
+	Path          string `db:"path" json:"path"`
+	Action        string `db:"action" json:"action"` // 操作, 如 build.start / node.update
+	NodeID        int    `db:"node_id" json:"node_id"`
+	ViewID        string `db:"view_id" json:"view_id"`
+	JobName       string `db:"job_name" json:"job_name"`
+	BuildNumber   int64  `db:"build_number" json:"build_number"`
+	Outcome       string `db:"outcome" json:"outcome"`               // success / failure
+	Status        int    `db:"status" json:"status"`                 // HTTP 响应状态码
+	JenkinsStatus int    `db:"jenkins_status" json:"jenkins_status"` // Jenkins 响应状态码, 未请求 Jenkins 时为 0
+	Message       string `db:"message" json:"message"`               // 失败原因
+	Detail        string `db:"detail" json:"detail"`                 // 请求参数 (已脱敏)
+	CreateTime    string `db:"create_time" json:"create_time"`
+}
+
+// ParamAuditQuery 审计日志查询条件, 时间格式为 2006-01-02 15:04:05
+type ParamAuditQuery struct {
+	UserID   int64  `form:"user_id"`
+	Username string `form:"username"`
+	Action   string `form:"action"` // 以 . 结尾时按前缀匹配, 如 build.
+	NodeID   int    `form:"node_id"`
+	ViewID   string `form:"view_id"`
+	JobName  string `form:"job_name"`
+	Outcome  string `form:"outcome" binding:"omitempty,oneof=success failure"`
+	IP       string `form:"ip"`
+	Start    string `form:"start"`
+	End      string `form:"end"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	Size     int    `form:"size" binding:"omitempty,min=1,max=500"`
+	Format   string `form:"format" binding:"omitempty,oneof=csv json"` // 导出格式
+}
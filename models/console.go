@@ -0,0 +1,36 @@
+package models
+
+import "bluebell/pkg/console"
+
+// ParamConsoleSearch 构建日志搜索参数, BuildNumber 为 0 时使用最新构建
+type ParamConsoleSearch struct {
+	RequestJobData
+	BuildNumber int64  `json:"buildNumber"`
+	Query       string `json:"query" binding:"required"`
+	Regex       bool   `json:"regex"`
+	IgnoreCase  bool   `json:"ignoreCase"`
+	Context     int    `json:"context"`
+	MaxMatches  int    `json:"maxMatches"`
+}
+
+// ParamConsoleProblems 构建日志问题提取参数, Tools 为空时使用全部规则
+type ParamConsoleProblems struct {
+	RequestJobData
+	BuildNumber int64    `json:"buildNumber"`
+	Tools       []string `json:"tools"`
+	Context     int      `json:"context"`
+}
+
+// ConsoleSearchResult 构建日志搜索结果
+type ConsoleSearchResult struct {
+	BuildNumber int64           `json:"buildNumber"`
+	TotalLines  int             `json:"totalLines"`
+	Truncated   bool            `json:"truncated"`
+	Matches     []console.Match `json:"matches"`
+}
+
+// ConsoleProblemsResult 构建日志问题提取结果
+type ConsoleProblemsResult struct {
+	BuildNumber int64 `json:"buildNumber"`
+	*console.Summary
+}
@@ -0,0 +1,22 @@
+package models
+
+// ConsoleArchive 已归档的构建日志索引, 日志内容以 gzip 压缩后保存在归档存储中
+type ConsoleArchive struct {
+	ID           int64  `db:"id" json:"id"`
+	NodeID       int    `db:"node_id" json:"node_id"`
+	ViewID       string `db:"view_id" json:"view_id"`
+	JobName      string `db:"job_name" json:"job_name"`
+	BuildNumber  int64  `db:"build_number" json:"build_number"`
+	Result       string `db:"result" json:"result"`
+	Storage      string `db:"storage" json:"storage"` // local / s3
+	ObjectKey    string `db:"object_key" json:"object_key"`
+	Size         int64  `db:"size" json:"size"`                   // 压缩后大小
+	OriginalSize int64  `db:"original_size" json:"original_size"` // 原始大小
+	CreateTime   string `db:"create_time" json:"create_time"`
+}
+
+// ParamConsoleArchive 手动归档构建日志, BuildNumber 为 0 时归档最新构建
+type ParamConsoleArchive struct {
+	RequestJobData
+	BuildNumber int64 `json:"buildNumber"`
+}
@@ -3,9 +3,13 @@ CREATE TABLE `user`
     `id`          bigint(20)                             NOT NULL AUTO_INCREMENT,
     `user_id`     bigint(20)                             NOT NULL,
     `username`    varchar(64) COLLATE utf8mb4_general_ci NOT NULL,
-    `password`    varchar(64) COLLATE utf8mb4_general_ci NOT NULL,
+    `password`    varchar(255) COLLATE utf8mb4_general_ci NOT NULL,
+    `source`      varchar(16) COLLATE utf8mb4_general_ci  NOT NULL DEFAULT 'local',
+    `nickname`    varchar(64) COLLATE utf8mb4_general_ci  NOT NULL DEFAULT '',
+    `avatar`      varchar(255) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '',
     `email`       varchar(64) COLLATE utf8mb4_general_ci,
     `gender`      tinyint(4)                             NOT NULL DEFAULT '0',
+    `disabled`    tinyint(1)                             NOT NULL DEFAULT '0',
     `create_time` timestamp                              NULL     DEFAULT CURRENT_TIMESTAMP,
     `update_time` timestamp                              NULL     DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     PRIMARY KEY (`id`),
@@ -17,17 +21,351 @@ CREATE TABLE `user`
 
 
 CREATE TABLE server_nodes
+(
+    `id`             bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`           varchar(64)  NOT NULL,
+    `host`           varchar(64)  NOT NULL,
+    `port`           varchar(64)  NOT NULL,
+    `account`        varchar(64)  NOT NULL,
+    `password`       varchar(64)  NOT NULL,
+    `status`         boolean      NOT NULL,
+    `remark`         varchar(64),
+    `webhook_secret` varchar(128) NOT NULL DEFAULT '',
+    `create_time`    timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time`    timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE job_config_versions
+(
+    `id`          bigint(20)   NOT NULL AUTO_INCREMENT,
+    `node_id`     bigint(20)   NOT NULL,
+    `view_id`     varchar(255) NOT NULL,
+    `job_name`    varchar(255) NOT NULL DEFAULT '',
+    `config`      mediumtext   NOT NULL,
+    `hash`        varchar(64)  NOT NULL,
+    `source`      varchar(16)  NOT NULL,
+    `remark`      varchar(255) NOT NULL DEFAULT '',
+    `create_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_job` (`node_id`, `view_id`, `job_name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE job_templates
+(
+    `id`          bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`        varchar(64)  NOT NULL,
+    `description` varchar(255) NOT NULL DEFAULT '',
+    `content`     mediumtext   NOT NULL,
+    `variables`   text         NOT NULL,
+    `create_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_name` (`name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE build_schedules
+(
+    `id`            bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`          varchar(64)  NOT NULL,
+    `node_id`       bigint(20)   NOT NULL,
+    `view_id`       varchar(255) NOT NULL,
+    `job_name`      varchar(255) NOT NULL DEFAULT '',
+    `params`        text         NOT NULL,
+    `cron_expr`     varchar(64)  NOT NULL DEFAULT '',
+    `run_at`        varchar(32)  NOT NULL DEFAULT '',
+    `blackouts`     text         NOT NULL,
+    `enabled`       boolean      NOT NULL DEFAULT TRUE,
+    `next_run_time` varchar(32)  NOT NULL DEFAULT '',
+    `last_run_time` varchar(32)  NOT NULL DEFAULT '',
+    `last_result`   varchar(255) NOT NULL DEFAULT '',
+    `create_time`   timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time`   timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_next_run_time` (`enabled`, `next_run_time`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE workflows
+(
+    `id`          bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`        varchar(64)  NOT NULL,
+    `description` varchar(255) NOT NULL DEFAULT '',
+    `steps`       text         NOT NULL,
+    `create_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_name` (`name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE workflow_runs
 (
     `id`          bigint(20)  NOT NULL AUTO_INCREMENT,
-    `name`        varchar(64) NOT NULL,
-    `host`        varchar(64) NOT NULL,
-    `port`        varchar(64) NOT NULL,
-    `account`     varchar(64) NOT NULL,
-    `password`    varchar(64) NOT NULL,
-    `status`      boolean     NOT NULL,
-    `remark`      varchar(64),
+    `workflow_id` bigint(20)  NOT NULL,
+    `status`      varchar(16) NOT NULL,
+    `params`      text        NOT NULL,
+    `steps`       text        NOT NULL,
+    `states`      mediumtext  NOT NULL,
     `create_time` timestamp   NULL DEFAULT CURRENT_TIMESTAMP,
-    `update_time` timestamp   NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    `update_time` timestamp   NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `finish_time` varchar(32) NOT NULL DEFAULT '',
+    PRIMARY KEY (`id`),
+    KEY `idx_workflow_id` (`workflow_id`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
-  COLLATE = utf8mb4_general_ci;
\ No newline at end of file
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE notify_rules
+(
+    `id`                 bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`               varchar(64)  NOT NULL,
+    `node_id`            int(11)      NOT NULL DEFAULT 0,
+    `job_pattern`        varchar(255) NOT NULL DEFAULT '',
+    `events`             varchar(255) NOT NULL DEFAULT '[]',
+    `duration_threshold` bigint(20)   NOT NULL DEFAULT 0,
+    `channel`            varchar(16)  NOT NULL,
+    `target`             varchar(512) NOT NULL,
+    `secret`             varchar(255) NOT NULL DEFAULT '',
+    `template`           text         NOT NULL,
+    `enabled`            tinyint(1)   NOT NULL DEFAULT 1,
+    `create_time`        timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time`        timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE notify_logs
+(
+    `id`           bigint(20)   NOT NULL AUTO_INCREMENT,
+    `rule_id`      bigint(20)   NOT NULL,
+    `event`        varchar(32)  NOT NULL,
+    `node_id`      int(11)      NOT NULL DEFAULT 0,
+    `job_path`     varchar(255) NOT NULL DEFAULT '',
+    `build_number` bigint(20)   NOT NULL DEFAULT 0,
+    `channel`      varchar(16)  NOT NULL,
+    `status`       varchar(16)  NOT NULL,
+    `attempts`     int(11)      NOT NULL DEFAULT 0,
+    `error`        text         NOT NULL,
+    `content`      text         NOT NULL,
+    `create_time`  timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_rule_id` (`rule_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE job_build_status
+(
+    `node_id`      int(11)      NOT NULL,
+    `view_id`      varchar(255) NOT NULL,
+    `job_name`     varchar(255) NOT NULL DEFAULT '',
+    `build_number` bigint(20)   NOT NULL,
+    `result`       varchar(16)  NOT NULL,
+    `update_time`  timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`node_id`, `view_id`, `job_name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE console_archives
+(
+    `id`            bigint(20)   NOT NULL AUTO_INCREMENT,
+    `node_id`       int(11)      NOT NULL,
+    `view_id`       varchar(255) NOT NULL,
+    `job_name`      varchar(255) NOT NULL DEFAULT '',
+    `build_number`  bigint(20)   NOT NULL,
+    `result`        varchar(16)  NOT NULL DEFAULT '',
+    `storage`       varchar(16)  NOT NULL,
+    `object_key`    varchar(512) NOT NULL,
+    `size`          bigint(20)   NOT NULL DEFAULT 0,
+    `original_size` bigint(20)   NOT NULL DEFAULT 0,
+    `create_time`   timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_build` (`node_id`, `view_id`, `job_name`, `build_number`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE roles
+(
+    `id`          bigint(20)   NOT NULL AUTO_INCREMENT,
+    `name`        varchar(64)  NOT NULL,
+    `description` varchar(255) NOT NULL DEFAULT '',
+    `require_2fa` tinyint(1)   NOT NULL DEFAULT 0,
+    `create_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_name` (`name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE role_permissions
+(
+    `id`          bigint(20)   NOT NULL AUTO_INCREMENT,
+    `role_id`     bigint(20)   NOT NULL,
+    `permission`  varchar(32)  NOT NULL,
+    `node_id`     int(11)      NOT NULL DEFAULT 0,
+    `job_pattern` varchar(255) NOT NULL DEFAULT '',
+    `create_time` timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_role_id` (`role_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE user_roles
+(
+    `user_id` bigint(20) NOT NULL,
+    `role_id` bigint(20) NOT NULL,
+    PRIMARY KEY (`user_id`, `role_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+-- 内置管理员角色, 启动时授予配置文件 admin_users 中的用户
+INSERT INTO roles (id, name, description) VALUES (1, 'admin', '超级管理员');
+INSERT INTO role_permissions (role_id, permission, node_id, job_pattern) VALUES (1, 'admin', 0, '');
+
+
+CREATE TABLE refresh_tokens
+(
+    `id`          bigint(20)  NOT NULL AUTO_INCREMENT,
+    `user_id`     bigint(20)  NOT NULL,
+    `token_hash`  char(64)    NOT NULL,
+    `family_id`   varchar(64) NOT NULL,
+    `expires_at`  varchar(32) NOT NULL,
+    `revoked`     tinyint(1)  NOT NULL DEFAULT 0,
+    `create_time` timestamp   NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_token_hash` (`token_hash`),
+    KEY `idx_family_id` (`family_id`),
+    KEY `idx_user_id` (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE revoked_tokens
+(
+    `jti`        varchar(64) NOT NULL,
+    `expires_at` varchar(32) NOT NULL,
+    PRIMARY KEY (`jti`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE oidc_states
+(
+    `state`         varchar(64)  NOT NULL,
+    `nonce`         varchar(64)  NOT NULL,
+    `code_verifier` varchar(128) NOT NULL,
+    `expires_at`    varchar(32)  NOT NULL,
+    PRIMARY KEY (`state`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE api_tokens
+(
+    `id`           bigint(20)   NOT NULL AUTO_INCREMENT,
+    `user_id`      bigint(20)   NOT NULL,
+    `name`         varchar(64)  NOT NULL,
+    `token_hash`   char(64)     NOT NULL,
+    `prefix`       varchar(16)  NOT NULL,
+    `scopes`       text         NOT NULL,
+    `expires_at`   varchar(32)  NOT NULL,
+    `last_used_at` varchar(32)  NOT NULL DEFAULT '',
+    `revoked`      tinyint(1)   NOT NULL DEFAULT '0',
+    `create_time`  varchar(32)  NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_token_hash` (`token_hash`),
+    KEY `idx_user_id` (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE user_totp
+(
+    `user_id`      bigint(20)  NOT NULL,
+    `secret`       varchar(64) NOT NULL,
+    `enabled`      tinyint(1)  NOT NULL DEFAULT 0,
+    `last_counter` bigint(20)  NOT NULL DEFAULT 0,
+    `create_time`  varchar(32) NOT NULL,
+    PRIMARY KEY (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE user_recovery_codes
+(
+    `id`        bigint(20) NOT NULL AUTO_INCREMENT,
+    `user_id`   bigint(20) NOT NULL,
+    `code_hash` char(64)   NOT NULL,
+    `used`      tinyint(1) NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+CREATE TABLE login_challenges
+(
+    `token_hash` char(64)    NOT NULL,
+    `user_id`    bigint(20)  NOT NULL,
+    `enroll`     tinyint(1)  NOT NULL DEFAULT 0,
+    `attempts`   int(11)     NOT NULL DEFAULT 0,
+    `expires_at` varchar(32) NOT NULL,
+    PRIMARY KEY (`token_hash`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
+
+
+CREATE TABLE audit_logs
+(
+    `id`             bigint(20)   NOT NULL AUTO_INCREMENT,
+    `user_id`        bigint(20)   NOT NULL DEFAULT 0,
+    `username`       varchar(64)  NOT NULL DEFAULT '',
+    `ip`             varchar(64)  NOT NULL DEFAULT '',
+    `method`         varchar(8)   NOT NULL,
+    `path`           varchar(255) NOT NULL,
+    `action`         varchar(64)  NOT NULL,
+    `node_id`        int(11)      NOT NULL DEFAULT 0,
+    `view_id`        varchar(255) NOT NULL DEFAULT '',
+    `job_name`       varchar(255) NOT NULL DEFAULT '',
+    `build_number`   bigint(20)   NOT NULL DEFAULT 0,
+    `outcome`        varchar(16)  NOT NULL,
+    `status`         int(11)      NOT NULL DEFAULT 0,
+    `jenkins_status` int(11)      NOT NULL DEFAULT 0,
+    `message`        varchar(512) NOT NULL DEFAULT '',
+    `detail`         text         NOT NULL,
+    `create_time`    timestamp    NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_create_time` (`create_time`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_node_job` (`node_id`, `view_id`, `job_name`),
+    KEY `idx_action` (`action`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_general_ci;
@@ -0,0 +1,55 @@
+package models
+
+// JobConfigVersion Job config.xml 的历史版本
+type JobConfigVersion struct {
+	ID         int64  `db:"id" json:"id"`
+	NodeID     int    `db:"node_id" json:"node_id"`
+	ViewID     string `db:"view_id" json:"view_id"`
+	JobName    string `db:"job_name" json:"job_name"`
+	Config     string `db:"config" json:"config,omitempty"`
+	Hash       string `db:"hash" json:"hash"`
+	Source     string `db:"source" json:"source"` // pull: 从 Jenkins 拉取, push: 推送到 Jenkins, rollback: 回滚
+	Remark     string `db:"remark" json:"remark"`
+	CreateTime string `db:"create_time" json:"create_time"`
+}
+
+// 版本来源
+const (
+	ConfigSourcePull     = "pull"
+	ConfigSourcePush     = "push"
+	ConfigSourceRollback = "rollback"
+)
+
+// ParamJobConfig 获取 Job 配置请求参数
+type ParamJobConfig struct {
+	NodeID  int    `json:"nodeId" binding:"required"`
+	ViewID  string `json:"viewId" binding:"required"`
+	JobName string `json:"jobName"`
+}
+
+// ParamJobConfigUpdate 更新 Job 配置请求参数
+type ParamJobConfigUpdate struct {
+	NodeID  int    `json:"nodeId" binding:"required"`
+	ViewID  string `json:"viewId" binding:"required"`
+	JobName string `json:"jobName"`
+	Config  string `json:"config" binding:"required"`
+	Remark  string `json:"remark"`
+}
+
+// ParamJobConfigDiff 对比两个版本请求参数, 两个版本都需要属于指定的 Job
+type ParamJobConfigDiff struct {
+	NodeID  int    `json:"nodeId" binding:"required"`
+	ViewID  string `json:"viewId" binding:"required"`
+	JobName string `json:"jobName"`
+	FromID  int64  `json:"fromId" binding:"required"`
+	ToID    int64  `json:"toId" binding:"required"`
+}
+
+// ParamJobConfigRollback 回滚到指定版本请求参数, 版本需要属于指定的 Job
+type ParamJobConfigRollback struct {
+	NodeID    int    `json:"nodeId" binding:"required"`
+	ViewID    string `json:"viewId" binding:"required"`
+	JobName   string `json:"jobName"`
+	VersionID int64  `json:"versionId" binding:"required"`
+	Remark    string `json:"remark"`
+}
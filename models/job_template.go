@@ -0,0 +1,70 @@
+package models
+
+import "database/sql/driver"
+
+// TemplateVariable 模板中声明的变量
+type TemplateVariable struct {
+	Name        string `json:"name" binding:"required"`
+	Default     string `json:"default"`
+	Required    bool   `json:"required"`
+	Raw         bool   `json:"raw"` // 原样输出, 不做 XML 转义, 用于传入 XML 片段
+	Description string `json:"description"`
+}
+
+// TemplateVariables 以 JSON 形式存储在数据库中
+type TemplateVariables []TemplateVariable
+
+// Value 实现 driver.Valuer
+func (v TemplateVariables) Value() (driver.Value, error) {
+	if v == nil {
+		return "[]", nil
+	}
+	return jsonValue([]TemplateVariable(v))
+}
+
+// Scan 实现 sql.Scanner
+func (v *TemplateVariables) Scan(src interface{}) error {
+	*v = TemplateVariables{}
+	return jsonScan(src, (*[]TemplateVariable)(v))
+}
+
+// JobTemplate Job 模板, Content 为带 text/template 占位符的 config.xml
+type JobTemplate struct {
+	ID          int64             `db:"id" json:"id"`
+	Name        string            `db:"name" json:"name" binding:"required"`
+	Description string            `db:"description" json:"description"`
+	Content     string            `db:"content" json:"content" binding:"required"`
+	Variables   TemplateVariables `db:"variables" json:"variables"`
+	CreateTime  string            `db:"create_time" json:"create_time"`
+	UpdateTime  string            `db:"update_time" json:"update_time"`
+}
+
+// ParamTemplateRender 渲染模板请求参数
+type ParamTemplateRender struct {
+	TemplateID int64             `json:"templateId" binding:"required"`
+	Values     map[string]string `json:"values"`
+}
+
+// TemplateTarget 模板应用的目标 Job
+type TemplateTarget struct {
+	NodeID  int    `json:"nodeId" binding:"required"`
+	ViewID  string `json:"viewId"` // 所在目录, 为空表示顶层
+	JobName string `json:"jobName" binding:"required"`
+}
+
+// ParamTemplateApply 将模板批量应用到节点的请求参数
+type ParamTemplateApply struct {
+	TemplateID int64             `json:"templateId" binding:"required"`
+	Values     map[string]string `json:"values"`
+	Targets    []TemplateTarget  `json:"targets" binding:"required,min=1,dive"`
+}
+
+// TemplateApplyResult 单个目标的应用结果
+type TemplateApplyResult struct {
+	NodeID  int    `json:"node_id"`
+	ViewID  string `json:"view_id"`
+	JobName string `json:"job_name"`
+	Action  string `json:"action"` // create / update
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// 以 JSON 文本形式存储在数据库中的字段通过以下两个函数实现 driver.Valuer 和 sql.Scanner
+
+func jsonValue(v interface{}) (driver.Value, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func jsonScan(src interface{}, dst interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		return nil
+	case string:
+		if s == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(s), dst)
+	case []byte:
+		if len(s) == 0 {
+			return nil
+		}
+		return json.Unmarshal(s, dst)
+	}
+	return fmt.Errorf("不支持的类型 %T", src)
+}
+
+// StringMap 字符串键值对, 如构建参数
+type StringMap map[string]string
+
+// Value 实现 driver.Valuer
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return jsonValue(map[string]string(m))
+}
+
+// Scan 实现 sql.Scanner
+func (m *StringMap) Scan(src interface{}) error {
+	*m = StringMap{}
+	return jsonScan(src, (*map[string]string)(m))
+}
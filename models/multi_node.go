@@ -0,0 +1,54 @@
+package models
+
+// ParamMultiNode 多节点操作的公共参数, NodeIDs 为空表示所有节点
+type ParamMultiNode struct {
+	NodeIDs []int `json:"nodeIds"`
+	Timeout int   `json:"timeout"` // 单个节点的超时时间 (秒)
+}
+
+// ParamMultiSearch 跨节点搜索 Job 请求参数
+type ParamMultiSearch struct {
+	ParamMultiNode
+	Keyword string `json:"keyword" binding:"required"`
+	Regex   bool   `json:"regex"` // 为 true 时 Keyword 按正则匹配, 否则忽略大小写包含匹配
+}
+
+// ParamMultiBuild 跨节点批量构建请求参数
+type ParamMultiBuild struct {
+	ParamMultiNode
+	ViewID  string            `json:"viewId" binding:"required"`
+	JobName string            `json:"jobName"`
+	Params  map[string]string `json:"params"`
+}
+
+// ParamMultiStop 跨节点批量停止请求参数
+type ParamMultiStop struct {
+	ParamMultiNode
+	ViewID  string `json:"viewId" binding:"required"`
+	JobName string `json:"jobName"`
+}
+
+// NodeResult 单个节点的执行结果
+type NodeResult struct {
+	NodeID   int         `json:"node_id"`
+	NodeName string      `json:"node_name"`
+	Success  bool        `json:"success"`
+	Error    string      `json:"error,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// JobSearchResult 跨节点搜索命中的 Job
+type JobSearchResult struct {
+	NodeID   int    `json:"node_id"`
+	NodeName string `json:"node_name"`
+	Path     string `json:"path"` // 含目录的完整路径, 如 GMB/GmbClient
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Color    string `json:"color"`
+}
+
+// MultiSearchResult 跨节点搜索的合并结果
+type MultiSearchResult struct {
+	Jobs  []JobSearchResult `json:"jobs"`
+	Nodes []NodeResult      `json:"nodes"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// 通知事件类型
+const (
+	NotifyEventFailure      = "failure"       // 构建失败
+	NotifyEventRecovery     = "recovery"      // 失败后恢复
+	NotifyEventLongDuration = "long_duration" // 构建耗时超过阈值
+)
+
+// StringList 字符串列表, 以 JSON 形式存储在数据库中
+type StringList []string
+
+// Value 实现 driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return jsonValue([]string(l))
+}
+
+// Scan 实现 sql.Scanner
+func (l *StringList) Scan(src interface{}) error {
+	*l = StringList{}
+	return jsonScan(src, (*[]string)(l))
+}
+
+// NotifyRule 通知规则
+// NodeID 为 0 表示所有节点, JobPattern 为 Job 路径 (目录/Job) 的通配符, 为空表示所有 Job
+type NotifyRule struct {
+	ID                int64      `db:"id" json:"id"`
+	Name              string     `db:"name" json:"name" binding:"required"`
+	NodeID            int        `db:"node_id" json:"node_id"`
+	JobPattern        string     `db:"job_pattern" json:"job_pattern"`
+	Events            StringList `db:"events" json:"events" binding:"required,min=1"`
+	DurationThreshold int64      `db:"duration_threshold" json:"duration_threshold"` // long_duration 的阈值 (秒)
+	Channel           string     `db:"channel" json:"channel" binding:"required"`    // webhook / email / dingtalk / wecom / feishu
+	Target            string     `db:"target" json:"target" binding:"required"`      // Webhook 地址或以逗号分隔的邮箱
+	Secret            string     `db:"secret" json:"secret"`                         // 机器人加签密钥, 只写, 更新时为空表示不修改
+	Template          string     `db:"template" json:"template"`                     // 消息模板 (text/template), 为空使用默认模板
+	Enabled           bool       `db:"enabled" json:"enabled"`
+	CreateTime        string     `db:"create_time" json:"create_time"`
+	UpdateTime        string     `db:"update_time" json:"update_time"`
+}
+
+// MarshalJSON 输出通知规则时不包含加签密钥
+func (r NotifyRule) MarshalJSON() ([]byte, error) {
+	type rule NotifyRule
+	return json.Marshal(struct {
+		rule
+		Secret string `json:"secret,omitempty"`
+	}{rule: rule(r)})
+}
+
+// NotifyLog 通知发送记录
+type NotifyLog struct {
+	ID          int64  `db:"id" json:"id"`
+	RuleID      int64  `db:"rule_id" json:"rule_id"`
+	Event       string `db:"event" json:"event"`
+	NodeID      int    `db:"node_id" json:"node_id"`
+	JobPath     string `db:"job_path" json:"job_path"`
+	BuildNumber int64  `db:"build_number" json:"build_number"`
+	Channel     string `db:"channel" json:"channel"`
+	Status      string `db:"status" json:"status"` // success / failed
+	Attempts    int    `db:"attempts" json:"attempts"`
+	Error       string `db:"error" json:"error"`
+	Content     string `db:"content" json:"content"`
+	CreateTime  string `db:"create_time" json:"create_time"`
+}
+
+// JobBuildStatus 后端记录的 Job 最近一次构建结果, 用于判断状态变化
+type JobBuildStatus struct {
+	NodeID      int    `db:"node_id"`
+	ViewID      string `db:"view_id"`
+	JobName     string `db:"job_name"`
+	BuildNumber int64  `db:"build_number"`
+	Result      string `db:"result"`
+	UpdateTime  string `db:"update_time"`
+}
+
+// BuildEvent 构建结束事件
+type BuildEvent struct {
+	NodeID         int    `json:"node_id"`
+	NodeName       string `json:"node_name"`
+	ViewID         string `json:"view_id"`
+	JobName        string `json:"job_name"`
+	JobPath        string `json:"job_path"`
+	BuildNumber    int64  `json:"build_number"`
+	Phase          string `json:"phase,omitempty"` // 仅 Webhook 推送的事件携带
+	Result         string `json:"result"`
+	PreviousResult string `json:"previous_result"`
+	Duration       int64  `json:"duration"` // 毫秒
+	URL            string `json:"url"`
+	Event          string `json:"event"`
+	Time           string `json:"time"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // 定义请求的参数结构体
 
 // ParamSignUp 注册请求参数
@@ -16,16 +18,26 @@ type ParamLogin struct {
 }
 
 type ServerNode struct {
-	ID         int    `db:"id" json:"id"`
-	Name       string `db:"name" json:"name" binding:"required"`
-	Host       string `db:"host" json:"host" binding:"required"`
-	Port       string `db:"port" json:"port"`
-	Account    string `db:"account" json:"account" binding:"required"`
-	Password   string `db:"password" json:"password" binding:"required"`
-	Status     bool   `db:"status" json:"status"`
-	Remark     string `db:"remark" json:"remark"`
-	CreateTime string `db:"create_time" json:"create_time"`
-	UpdateTime string `db:"update_time" json:"update_time"`
+	ID            int    `db:"id" json:"id"`
+	Name          string `db:"name" json:"name" binding:"required"`
+	Host          string `db:"host" json:"host" binding:"required"`
+	Port          string `db:"port" json:"port"`
+	Account       string `db:"account" json:"account" binding:"required"`
+	Password      string `db:"password" json:"password"` // 只写, 更新时为空表示不修改, 不会在响应中返回
+	Status        bool   `db:"status" json:"status"`
+	Remark        string `db:"remark" json:"remark"`
+	WebhookSecret string `db:"webhook_secret" json:"-"` // 入站 Webhook 的共享密钥, 只在生成时返回一次
+	CreateTime    string `db:"create_time" json:"create_time"`
+	UpdateTime    string `db:"update_time" json:"update_time"`
+}
+
+// MarshalJSON 输出节点时不包含 Jenkins 密码
+func (n ServerNode) MarshalJSON() ([]byte, error) {
+	type node ServerNode
+	return json.Marshal(struct {
+		node
+		Password string `json:"password,omitempty"`
+	}{node: node(n)})
 }
 
 type NodeView struct {
@@ -40,11 +52,9 @@ type NodeView struct {
 	Type         string `db:"type" json:"type"`
 }
 
+// RequestData 按节点请求 Jenkins 的参数, 连接地址和账号由服务端根据节点 ID 查询
 type RequestData struct {
-	Host     string `json:"host"`
-	Port     string `json:"port"`
-	Account  string `json:"account"`
-	Password string `json:"password"`
+	NodeID string `json:"nodeId" binding:"required"`
 }
 
 type NodeViewT struct {
@@ -59,14 +69,14 @@ type NodeViewT struct {
 	CreateTime   string `json:"create_time"`
 }
 
+// RequestJobData 按节点请求 Job 的参数, 连接地址和账号由服务端根据节点 ID 查询
 type RequestJobData struct {
-	NodeID   string `form:"nodeId" binding:"required"`
-	ViewID   string `form:"viewId" binding:"required"`
-	Host     string `form:"host" binding:"required"`
-	Port     string `form:"port" binding:"required"`
-	Account  string `form:"account" binding:"required"`
-	Password string `form:"password" binding:"required"`
-	JobName  string `form:"jobname"`
+	NodeID  string `form:"nodeId" binding:"required"`
+	ViewID  string `form:"viewId" binding:"required"`
+	JobName string `form:"jobname"`
+
+	Mode       string `form:"mode"`       // 日志输出模式: text (默认, 原文) / plain / spans / html
+	Timestamps bool   `form:"timestamps"` // 是否附加时间戳, 需要 Jenkins 安装 Timestamper 插件
 }
 
 // Jenkins Job 数据结构
@@ -91,19 +101,11 @@ type JenkinsResponse struct {
 type StartJobRequest struct {
 	ViewID   string `json:"viewId" binding:"required"`
 	ViewName string `json:"viewName"`
-	NodeId   string `json:"nodeId"`
-	Host     string `json:"host" binding:"required"`
-	Port     string `json:"port" binding:"required"`
-	Account  string `json:"account" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	NodeId   string `json:"nodeId" binding:"required"`
 }
 
 type StopJobRequest struct {
 	ViewID   string `json:"viewId" binding:"required"`
 	ViewName string `json:"viewName"`
-	NodeId   string `json:"nodeId"`
-	Host     string `json:"host" binding:"required"`
-	Port     string `json:"port" binding:"required"`
-	Account  string `json:"account" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	NodeId   string `json:"nodeId" binding:"required"`
 }
@@ -0,0 +1,56 @@
+package models
+
+// 权限
+const (
+	PermView        = "view"         // 查看节点、Job、构建日志
+	PermBuild       = "build"        // 触发构建
+	PermStop        = "stop"         // 停止构建
+	PermDeleteBuild = "delete-build" // 删除构建
+	PermManageNode  = "manage-node"  // 管理节点、Job 配置、模板、通知
+	PermAdmin       = "admin"        // 拥有全部权限, 并可管理角色
+)
+
+// AdminRoleName 内置的超级管理员角色
+const AdminRoleName = "admin"
+
+// RolePermission 角色的一条授权
+// NodeID 为 0 表示所有节点; JobPattern 为 Job 路径 (目录/Job) 的通配符, 为空或 * 表示所有 Job, 以 /** 结尾表示目录下的所有 Job
+type RolePermission struct {
+	ID         int64  `db:"id" json:"id"`
+	RoleID     int64  `db:"role_id" json:"role_id"`
+	Permission string `db:"permission" json:"permission" binding:"required"`
+	NodeID     int    `db:"node_id" json:"node_id"`
+	JobPattern string `db:"job_pattern" json:"job_pattern"`
+	CreateTime string `db:"create_time" json:"create_time"`
+}
+
+// Role 角色
+type Role struct {
+	ID          int64            `db:"id" json:"id"`
+	Name        string           `db:"name" json:"name" binding:"required"`
+	Description string           `db:"description" json:"description"`
+	Require2FA  bool             `db:"require_2fa" json:"require_2fa"` // 拥有该角色的用户必须开启两步验证
+	Permissions []RolePermission `db:"-" json:"permissions" binding:"dive"`
+	CreateTime  string           `db:"create_time" json:"create_time"`
+	UpdateTime  string           `db:"update_time" json:"update_time"`
+}
+
+// Grant 用户通过角色获得的一条授权
+type Grant struct {
+	Permission string `db:"permission"`
+	NodeID     int    `db:"node_id"`
+	JobPattern string `db:"job_pattern"`
+}
+
+// ParamUserRoles 设置用户角色的请求参数
+type ParamUserRoles struct {
+	UserID  int64   `json:"userId,string" binding:"required"`
+	RoleIDs []int64 `json:"roleIds"`
+}
+
+// Resource 请求访问的资源, NodeID 为 0 表示不针对具体节点
+type Resource struct {
+	NodeID  int
+	ViewID  string
+	JobName string
+}
@@ -0,0 +1,50 @@
+package models
+
+import "database/sql/driver"
+
+// BlackoutWindow 禁止触发构建的时间窗口
+// 设置了 From/To 时为绝对时间段 (2006-01-02 15:04:05),
+// 否则为每天的 Start~End (15:04), Start 大于 End 表示跨零点, Weekdays 为空表示每天 (0 为周日)
+type BlackoutWindow struct {
+	From     string `json:"from,omitempty"`
+	To       string `json:"to,omitempty"`
+	Start    string `json:"start,omitempty"`
+	End      string `json:"end,omitempty"`
+	Weekdays []int  `json:"weekdays,omitempty"`
+}
+
+// BlackoutWindows 以 JSON 形式存储在数据库中
+type BlackoutWindows []BlackoutWindow
+
+// Value 实现 driver.Valuer
+func (w BlackoutWindows) Value() (driver.Value, error) {
+	if w == nil {
+		return "[]", nil
+	}
+	return jsonValue([]BlackoutWindow(w))
+}
+
+// Scan 实现 sql.Scanner
+func (w *BlackoutWindows) Scan(src interface{}) error {
+	*w = BlackoutWindows{}
+	return jsonScan(src, (*[]BlackoutWindow)(w))
+}
+
+// BuildSchedule 定时构建计划, CronExpr 与 RunAt 二选一
+type BuildSchedule struct {
+	ID          int64           `db:"id" json:"id"`
+	Name        string          `db:"name" json:"name" binding:"required"`
+	NodeID      int             `db:"node_id" json:"node_id" binding:"required"`
+	ViewID      string          `db:"view_id" json:"view_id" binding:"required"`
+	JobName     string          `db:"job_name" json:"job_name"`
+	Params      StringMap       `db:"params" json:"params"`
+	CronExpr    string          `db:"cron_expr" json:"cron_expr"` // 标准 5 段 cron 表达式
+	RunAt       string          `db:"run_at" json:"run_at"`       // 一次性触发时间 2006-01-02 15:04:05
+	Blackouts   BlackoutWindows `db:"blackouts" json:"blackouts"`
+	Enabled     bool            `db:"enabled" json:"enabled"`
+	NextRunTime string          `db:"next_run_time" json:"next_run_time"`
+	LastRunTime string          `db:"last_run_time" json:"last_run_time"`
+	LastResult  string          `db:"last_result" json:"last_result"`
+	CreateTime  string          `db:"create_time" json:"create_time"`
+	UpdateTime  string          `db:"update_time" json:"update_time"`
+}
@@ -0,0 +1,39 @@
+package models
+
+// RefreshToken 服务端保存的 refresh token, 只保存 token 的 SHA-256
+// 同一次登录轮换出的 token 属于同一个 FamilyID, 已轮换的 token 被再次使用时吊销整个 family
+type RefreshToken struct {
+	ID         int64  `db:"id"`
+	UserID     int64  `db:"user_id"`
+	TokenHash  string `db:"token_hash"`
+	FamilyID   string `db:"family_id"`
+	ExpiresAt  string `db:"expires_at"`
+	Revoked    bool   `db:"revoked"`
+	CreateTime string `db:"create_time"`
+}
+
+// TokenPair 登录或刷新后返回的 token
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	Expires      string `json:"expires"` // access token 过期时间, 格式 2006/01/02 15:04:05
+}
+
+// ParamRefreshToken 刷新 token 的请求参数
+type ParamRefreshToken struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// ParamLogout 注销的请求参数, All 为 true 时注销该用户的所有登录
+type ParamLogout struct {
+	RefreshToken string `json:"refreshToken"`
+	All          bool   `json:"all"`
+}
+
+// OIDCState OIDC 登录发起时保存的状态, 回调时按 state 取出并删除, 只能使用一次
+type OIDCState struct {
+	State        string `db:"state"`
+	Nonce        string `db:"nonce"`
+	CodeVerifier string `db:"code_verifier"`
+	ExpiresAt    string `db:"expires_at"`
+}
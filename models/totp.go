@@ -0,0 +1,51 @@
+package models
+
+// UserTOTP 用户的 TOTP 两步验证, 未验证前 Enabled 为 false
+// LastCounter 为最后一次使用的时间步, 同一个验证码不能重复使用
+type UserTOTP struct {
+	UserID      int64  `db:"user_id"`
+	Secret      string `db:"secret"`
+	Enabled     bool   `db:"enabled"`
+	LastCounter int64  `db:"last_counter"`
+	CreateTime  string `db:"create_time"`
+}
+
+// LoginChallenge 密码校验通过后等待两步验证的登录, 只保存 token 的 SHA-256
+// Enroll 为 true 时用户所属角色要求两步验证但尚未绑定, 需要先完成绑定
+type LoginChallenge struct {
+	TokenHash string `db:"token_hash"`
+	UserID    int64  `db:"user_id"`
+	Enroll    bool   `db:"enroll"`
+	Attempts  int    `db:"attempts"`
+	ExpiresAt string `db:"expires_at"`
+}
+
+// TOTPStatus 两步验证状态
+type TOTPStatus struct {
+	Enabled            bool `json:"enabled"`
+	Required           bool `json:"required"` // 所属角色要求两步验证
+	RecoveryCodesCount int  `json:"recoveryCodesCount"`
+}
+
+// TOTPEnrollment 绑定两步验证时返回的密钥和扫码地址
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"` // otpauth:// 地址, 前端生成二维码
+}
+
+// ParamTOTPCode 提交验证码的请求参数
+type ParamTOTPCode struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ParamLogin2FA 登录第二步的请求参数, Code 可以是验证码或恢复码
+type ParamLogin2FA struct {
+	Challenge string `json:"challenge" binding:"required"`
+	Code      string `json:"code"`
+}
+
+// ParamRoleRequire2FA 设置角色是否要求两步验证的请求参数
+type ParamRoleRequire2FA struct {
+	ID         int64 `json:"id" binding:"required"`
+	Require2FA bool  `json:"require_2fa"`
+}
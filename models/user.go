@@ -1,7 +1,59 @@
 package models
 
+// 用户来源
+const (
+	UserSourceLocal = "local" // 本地注册的用户
+	UserSourceLDAP  = "ldap"  // 首次通过 LDAP 登录时自动创建的用户, 密码不保存在本地
+	UserSourceOIDC  = "oidc"  // 首次通过 OIDC 单点登录时自动创建的用户
+)
+
 type User struct {
-	UserID   int64  `db:"user_id"`
-	Username string `db:"username"`
-	Password string `db:"password"`
+	UserID     int64  `db:"user_id" json:"userId,string"`
+	Username   string `db:"username" json:"username"`
+	Password   string `db:"password" json:"-"`
+	Source     string `db:"source" json:"source"`
+	Nickname   string `db:"nickname" json:"nickname"`
+	Avatar     string `db:"avatar" json:"avatar"`
+	Email      string `db:"email" json:"email"`
+	Gender     int    `db:"gender" json:"gender"` // 0 未知 1 男 2 女
+	Disabled   bool   `db:"disabled" json:"disabled"`
+	CreateTime string `db:"create_time" json:"createTime"`
+	UpdateTime string `db:"update_time" json:"updateTime"`
+}
+
+// ParamUpdateProfile 修改个人资料的请求参数
+type ParamUpdateProfile struct {
+	Nickname string `json:"nickname" binding:"max=64"`
+	Avatar   string `json:"avatar" binding:"max=255"`
+	Email    string `json:"email" binding:"omitempty,email,max=64"`
+	Gender   int    `json:"gender" binding:"oneof=0 1 2"`
+}
+
+// ParamChangePassword 修改密码的请求参数
+type ParamChangePassword struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+	RePassword  string `json:"rePassword" binding:"required,eqfield=NewPassword"`
+}
+
+// ParamAddUser 管理员新增用户的请求参数
+type ParamAddUser struct {
+	Username string  `json:"username" binding:"required,max=64"`
+	Password string  `json:"password" binding:"required"`
+	Nickname string  `json:"nickname" binding:"max=64"`
+	Email    string  `json:"email" binding:"omitempty,email,max=64"`
+	Gender   int     `json:"gender" binding:"oneof=0 1 2"`
+	RoleIDs  []int64 `json:"roleIds"`
+}
+
+// ParamUserStatus 启用/禁用用户的请求参数
+type ParamUserStatus struct {
+	UserID   int64 `json:"userId,string" binding:"required"`
+	Disabled bool  `json:"disabled"`
+}
+
+// ParamResetPassword 管理员重置密码的请求参数
+type ParamResetPassword struct {
+	UserID   int64  `json:"userId,string" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
@@ -0,0 +1,43 @@
+package models
+
+// 构建阶段 (与 Jenkins Notification 插件一致)
+const (
+	BuildPhaseQueued    = "QUEUED"
+	BuildPhaseStarted   = "STARTED"
+	BuildPhaseCompleted = "COMPLETED"
+	BuildPhaseFinalized = "FINALIZED"
+)
+
+// NotificationBuild Notification 插件负载中的构建信息
+type NotificationBuild struct {
+	FullURL    string                 `json:"full_url"`
+	Number     int64                  `json:"number"`
+	Phase      string                 `json:"phase"`
+	Status     string                 `json:"status"`
+	URL        string                 `json:"url"`
+	Duration   int64                  `json:"duration"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// WebhookPayload 入站 Webhook 负载
+// 兼容 Jenkins Notification 插件格式 (name/url/build) 和通用 JSON 格式 (view_id/job_name/...)
+type WebhookPayload struct {
+	// Notification 插件
+	Name  string             `json:"name"`
+	URL   string             `json:"url"` // Job 相对路径, 如 job/folder/job/name/
+	Build *NotificationBuild `json:"build"`
+
+	// 通用 JSON
+	ViewID      string `json:"view_id"`
+	JobName     string `json:"job_name"`
+	BuildNumber int64  `json:"build_number"`
+	Phase       string `json:"phase"`
+	Result      string `json:"result"`
+	Duration    int64  `json:"duration"` // 毫秒
+	BuildURL    string `json:"build_url"`
+}
+
+// ParamWebhookSecret 生成 Webhook 密钥的请求参数
+type ParamWebhookSecret struct {
+	NodeID int `json:"nodeId" binding:"required"`
+}
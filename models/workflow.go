@@ -0,0 +1,139 @@
+package models
+
+import "database/sql/driver"
+
+// 步骤类型
+const (
+	StepTypeJob  = "job"  // 触发 Jenkins Job 构建
+	StepTypeGate = "gate" // 人工审批
+)
+
+// 步骤执行条件, 基于所有上游步骤的结果
+const (
+	StepConditionSuccess = "success" // 上游全部成功 (默认)
+	StepConditionFailure = "failure" // 任一上游失败
+	StepConditionAlways  = "always"  // 上游结束即执行
+)
+
+// 工作流运行状态
+const (
+	WorkflowStatusRunning   = "running"
+	WorkflowStatusPaused    = "paused"
+	WorkflowStatusSuccess   = "success"
+	WorkflowStatusFailed    = "failed"
+	WorkflowStatusCancelled = "cancelled"
+)
+
+// 步骤运行状态
+const (
+	StepStatusPending   = "pending"
+	StepStatusRunning   = "running"
+	StepStatusWaiting   = "waiting" // 等待人工审批
+	StepStatusSuccess   = "success"
+	StepStatusFailed    = "failed"
+	StepStatusSkipped   = "skipped"
+	StepStatusCancelled = "cancelled"
+)
+
+// WorkflowStep 工作流中的一个步骤
+// Params 的值支持 text/template, 可引用运行参数 {{.params.X}} 和上游输出 {{.steps.<name>.number}}
+type WorkflowStep struct {
+	Name      string            `json:"name" binding:"required"`
+	Type      string            `json:"type"` // job / gate, 默认为 job
+	NodeID    int               `json:"node_id"`
+	ViewID    string            `json:"view_id"`
+	JobName   string            `json:"job_name"`
+	Params    map[string]string `json:"params"`
+	DependsOn []string          `json:"depends_on"`
+	Condition string            `json:"condition"`
+}
+
+// WorkflowSteps 以 JSON 形式存储在数据库中
+type WorkflowSteps []WorkflowStep
+
+// Value 实现 driver.Valuer
+func (s WorkflowSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	return jsonValue([]WorkflowStep(s))
+}
+
+// Scan 实现 sql.Scanner
+func (s *WorkflowSteps) Scan(src interface{}) error {
+	*s = WorkflowSteps{}
+	return jsonScan(src, (*[]WorkflowStep)(s))
+}
+
+// Workflow 工作流定义, 步骤之间构成有向无环图
+type Workflow struct {
+	ID          int64         `db:"id" json:"id"`
+	Name        string        `db:"name" json:"name" binding:"required"`
+	Description string        `db:"description" json:"description"`
+	Steps       WorkflowSteps `db:"steps" json:"steps" binding:"required,min=1,dive"`
+	CreateTime  string        `db:"create_time" json:"create_time"`
+	UpdateTime  string        `db:"update_time" json:"update_time"`
+}
+
+// StepState 步骤的运行状态
+type StepState struct {
+	Status      string            `json:"status"`
+	Params      map[string]string `json:"params,omitempty"` // 渲染后的构建参数
+	QueueID     int64             `json:"queue_id,omitempty"`
+	BuildNumber int64             `json:"build_number,omitempty"`
+	Result      string            `json:"result,omitempty"` // Jenkins 构建结果
+	Error       string            `json:"error,omitempty"`
+	Operator    int64             `json:"operator,omitempty"` // 审批人
+	StartTime   string            `json:"start_time,omitempty"`
+	EndTime     string            `json:"end_time,omitempty"`
+}
+
+// StepStates 以 JSON 形式存储在数据库中
+type StepStates map[string]*StepState
+
+// Value 实现 driver.Valuer
+func (s StepStates) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+	return jsonValue(map[string]*StepState(s))
+}
+
+// Scan 实现 sql.Scanner
+func (s *StepStates) Scan(src interface{}) error {
+	*s = StepStates{}
+	return jsonScan(src, (*map[string]*StepState)(s))
+}
+
+// WorkflowRun 工作流的一次运行, 启动时保存一份步骤定义的快照
+type WorkflowRun struct {
+	ID         int64         `db:"id" json:"id"`
+	WorkflowID int64         `db:"workflow_id" json:"workflow_id"`
+	Status     string        `db:"status" json:"status"`
+	Params     StringMap     `db:"params" json:"params"`
+	Steps      WorkflowSteps `db:"steps" json:"steps"`
+	States     StepStates    `db:"states" json:"states"`
+	CreateTime string        `db:"create_time" json:"create_time"`
+	UpdateTime string        `db:"update_time" json:"update_time"`
+	FinishTime string        `db:"finish_time" json:"finish_time"`
+	LockOwner  string        `db:"lock_owner" json:"-"` // 运行锁的持有者, 见 mysql.LockWorkflowRun
+	LockUntil  string        `db:"lock_until" json:"-"` // 运行锁的过期时间
+}
+
+// ParamWorkflowStart 启动工作流请求参数
+type ParamWorkflowStart struct {
+	WorkflowID int64             `json:"workflowId" binding:"required"`
+	Params     map[string]string `json:"params"`
+}
+
+// ParamWorkflowRun 暂停/恢复/取消运行请求参数
+type ParamWorkflowRun struct {
+	RunID int64 `json:"runId" binding:"required"`
+}
+
+// ParamWorkflowApprove 人工审批请求参数
+type ParamWorkflowApprove struct {
+	RunID   int64  `json:"runId" binding:"required"`
+	Step    string `json:"step" binding:"required"`
+	Approve bool   `json:"approve"`
+}
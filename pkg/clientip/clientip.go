@@ -0,0 +1,77 @@
+// Package clientip 获取请求的客户端 IP
+// 只有直接连接的地址属于配置的可信代理时, 才使用 X-Forwarded-For / X-Real-IP, 避免客户端伪造 IP 绕过按 IP 的限制
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var trusted []*net.IPNet
+
+// Init 设置可信代理, 支持单个 IP 和 CIDR, 为空时只使用连接的地址
+func Init(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("可信代理 [%s] 格式无效", p)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("可信代理 [%s] 格式无效: %v", p, err)
+		}
+		nets = append(nets, n)
+	}
+	trusted = nets
+	return nil
+}
+
+func isTrusted(ip net.IP) bool {
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromRequest 返回请求的客户端 IP
+// 连接来自可信代理时, 从右向左取 X-Forwarded-For 中第一个不可信的地址, 即最后一个可信代理看到的客户端
+func FromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(r.RemoteAddr)
+	}
+	remote := net.ParseIP(host)
+	if remote == nil || !isTrusted(remote) {
+		return host
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !isTrusted(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -0,0 +1,57 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		xff     string
+		realIP  string
+		want    string
+	}{
+		{"no proxies ignores headers", nil, "203.0.113.7:5000", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"untrusted remote ignores headers", []string{"10.0.0.0/8"}, "203.0.113.7:5000", "198.51.100.1", "", "203.0.113.7"},
+		{"trusted proxy", []string{"10.0.0.1"}, "10.0.0.1:5000", "198.51.100.1", "", "198.51.100.1"},
+		// 客户端自行添加的 X-Forwarded-For 在最左边, 只取最后一个可信代理追加的地址
+		{"spoofed prefix", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "1.2.3.4, 198.51.100.1", "", "198.51.100.1"},
+		{"proxy chain", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"all trusted", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"invalid hop", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "=HYPERLINK(1)", "", "10.0.0.1"},
+		{"real ip", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "", "198.51.100.9", "198.51.100.9"},
+		{"invalid real ip", []string{"10.0.0.0/8"}, "10.0.0.1:5000", "", "x", "10.0.0.1"},
+		{"ipv6", []string{"::1"}, "[::1]:5000", "2001:db8::1", "", "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			defer Init(nil)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := FromRequest(r); got != tt.want {
+				t.Errorf("FromRequest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitInvalid(t *testing.T) {
+	for _, p := range []string{"x", "10.0.0.0/33", ""} {
+		if err := Init([]string{p}); err == nil {
+			t.Errorf("Init(%q): want error", p)
+		}
+	}
+	Init(nil)
+}
@@ -0,0 +1,277 @@
+package console
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Jenkins ConsoleNote 以 ESC[8m 隐藏文本的形式嵌入日志: ESC[8mha:<base64>ESC[0m
+var consoleNotePattern = regexp.MustCompile(`\x1b\[8mha:[^\x1b]*\x1b\[0m`)
+
+var basicColors = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// Style 文本样式, 颜色为空表示默认颜色
+// 16 色使用颜色名 (如 red、bright-red), 256 色和真彩色使用 #rrggbb
+type Style struct {
+	FG        string `json:"fg,omitempty"`
+	BG        string `json:"bg,omitempty"`
+	Bold      bool   `json:"bold,omitempty"`
+	Faint     bool   `json:"faint,omitempty"`
+	Italic    bool   `json:"italic,omitempty"`
+	Underline bool   `json:"underline,omitempty"`
+}
+
+// Span 一段样式相同的文本
+type Span struct {
+	Text string `json:"text"`
+	Style
+}
+
+// RenderedLine 解析后的一行日志
+type RenderedLine struct {
+	Number    int    `json:"number"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Spans     []Span `json:"spans"`
+}
+
+// StripNotes 去掉 Jenkins ConsoleNote 注解
+func StripNotes(s string) string {
+	if !strings.Contains(s, "\x1b[8mha:") {
+		return s
+	}
+	return consoleNotePattern.ReplaceAllString(s, "")
+}
+
+// Render 将日志解析为带样式的行, timestamps 不为空时按行号附加时间戳
+// 样式在行与行之间延续, 与终端的行为一致
+func Render(text string, timestamps []string) []RenderedLine {
+	text = strings.TrimSuffix(StripNotes(text), "\n")
+	if text == "" {
+		return []RenderedLine{}
+	}
+	raw := strings.Split(text, "\n")
+	lines := make([]RenderedLine, 0, len(raw))
+	var style Style
+	for i, l := range raw {
+		rl := RenderedLine{Number: i + 1}
+		if i < len(timestamps) {
+			rl.Timestamp = timestamps[i]
+		}
+		rl.Spans, style = parseLine(strings.TrimSuffix(l, "\r"), style)
+		lines = append(lines, rl)
+	}
+	return lines
+}
+
+// parseLine 解析一行中的 SGR (Select Graphic Rendition) 序列, 其他 CSI 序列直接丢弃
+// 返回文本片段和行尾的样式
+func parseLine(line string, style Style) ([]Span, Style) {
+	spans := make([]Span, 0, 1)
+	emit := func(text string) {
+		if text == "" {
+			return
+		}
+		if n := len(spans); n > 0 && spans[n-1].Style == style {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{Text: text, Style: style})
+	}
+	pos := 0
+	for _, loc := range ansiPattern.FindAllStringSubmatchIndex(line, -1) {
+		emit(line[pos:loc[0]])
+		pos = loc[1]
+		if line[loc[4]:loc[5]] == "m" {
+			style = applySGR(style, line[loc[2]:loc[3]])
+		}
+	}
+	emit(strings.Replace(line[pos:], "\x1b", "", -1))
+	return spans, style
+}
+
+// applySGR 按 SGR 参数更新样式
+func applySGR(s Style, params string) Style {
+	if params == "" {
+		return Style{}
+	}
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		n, err := strconv.Atoi(codes[i])
+		if err != nil {
+			continue
+		}
+		switch {
+		case n == 0:
+			s = Style{}
+		case n == 1:
+			s.Bold = true
+		case n == 2:
+			s.Faint = true
+		case n == 3:
+			s.Italic = true
+		case n == 4:
+			s.Underline = true
+		case n == 22:
+			s.Bold, s.Faint = false, false
+		case n == 23:
+			s.Italic = false
+		case n == 24:
+			s.Underline = false
+		case n >= 30 && n <= 37:
+			s.FG = basicColors[n-30]
+		case n >= 90 && n <= 97:
+			s.FG = "bright-" + basicColors[n-90]
+		case n == 39:
+			s.FG = ""
+		case n >= 40 && n <= 47:
+			s.BG = basicColors[n-40]
+		case n >= 100 && n <= 107:
+			s.BG = "bright-" + basicColors[n-100]
+		case n == 49:
+			s.BG = ""
+		case n == 38 || n == 48:
+			color, used := extendedColor(codes[i+1:])
+			i += used
+			if n == 38 {
+				s.FG = color
+			} else {
+				s.BG = color
+			}
+		}
+	}
+	return s
+}
+
+// extendedColor 解析 38/48 之后的 5;n 或 2;r;g;b, 返回颜色和消耗的参数个数
+func extendedColor(args []string) (string, int) {
+	if len(args) == 0 {
+		return "", 0
+	}
+	switch args[0] {
+	case "5":
+		if len(args) < 2 {
+			return "", len(args)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > 255 {
+			return "", 2
+		}
+		return xterm256(n), 2
+	case "2":
+		if len(args) < 4 {
+			return "", len(args)
+		}
+		var rgb [3]int
+		for j := 0; j < 3; j++ {
+			v, err := strconv.Atoi(args[j+1])
+			if err != nil || v < 0 || v > 255 {
+				return "", 4
+			}
+			rgb[j] = v
+		}
+		return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), 4
+	}
+	return "", 1
+}
+
+// xterm256 将 256 色序号转换为颜色, 前 16 色使用颜色名
+func xterm256(n int) string {
+	switch {
+	case n < 8:
+		return basicColors[n]
+	case n < 16:
+		return "bright-" + basicColors[n-8]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	}
+	g := 8 + (n-232)*10
+	return fmt.Sprintf("#%02x%02x%02x", g, g, g)
+}
+
+// RenderHTML 将解析后的行渲染为 HTML, 文本均已转义
+// 16 色使用 ansi-fg-<color> / ansi-bg-<color> 类名, 其余颜色使用内联样式
+func RenderHTML(lines []RenderedLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		fmt.Fprintf(&b, `<div class="console-line" data-line="%d">`, l.Number)
+		if l.Timestamp != "" {
+			fmt.Fprintf(&b, `<span class="console-timestamp">%s</span> `, html.EscapeString(l.Timestamp))
+		}
+		for _, s := range l.Spans {
+			class, style := spanAttrs(s.Style)
+			if class == "" && style == "" {
+				b.WriteString(html.EscapeString(s.Text))
+				continue
+			}
+			b.WriteString("<span")
+			if class != "" {
+				fmt.Fprintf(&b, ` class="%s"`, class)
+			}
+			if style != "" {
+				fmt.Fprintf(&b, ` style="%s"`, style)
+			}
+			b.WriteString(">")
+			b.WriteString(html.EscapeString(s.Text))
+			b.WriteString("</span>")
+		}
+		b.WriteString("</div>\n")
+	}
+	return b.String()
+}
+
+func spanAttrs(s Style) (string, string) {
+	var classes, styles []string
+	addColor := func(kind, color string) {
+		switch {
+		case color == "":
+		case strings.HasPrefix(color, "#"):
+			prop := "color"
+			if kind == "bg" {
+				prop = "background-color"
+			}
+			styles = append(styles, prop+":"+color)
+		default:
+			classes = append(classes, "ansi-"+kind+"-"+color)
+		}
+	}
+	addColor("fg", s.FG)
+	addColor("bg", s.BG)
+	if s.Bold {
+		classes = append(classes, "ansi-bold")
+	}
+	if s.Faint {
+		classes = append(classes, "ansi-faint")
+	}
+	if s.Italic {
+		classes = append(classes, "ansi-italic")
+	}
+	if s.Underline {
+		classes = append(classes, "ansi-underline")
+	}
+	return strings.Join(classes, " "), strings.Join(styles, ";")
+}
+
+// PlainText 去掉 ConsoleNote 和 ANSI 序列, timestamps 不为空时在每行前加上时间戳
+func PlainText(text string, timestamps []string) string {
+	text = StripANSI(StripNotes(text))
+	if len(timestamps) == 0 {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		if i < len(timestamps) && timestamps[i] != "" {
+			lines[i] = "[" + timestamps[i] + "] " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,108 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplySGR(t *testing.T) {
+	bold := Style{Bold: true}
+	tests := []struct {
+		name   string
+		from   Style
+		params string
+		want   Style
+	}{
+		{"empty resets", Style{Bold: true, FG: "red"}, "", Style{}},
+		{"reset", Style{Bold: true, FG: "red"}, "0", Style{}},
+		{"bold red", Style{}, "1;31", Style{Bold: true, FG: "red"}},
+		{"bright fg and bg", Style{}, "91;44", Style{FG: "bright-red", BG: "blue"}},
+		{"bright bg", Style{}, "107", Style{BG: "bright-white"}},
+		{"attributes", Style{}, "2;3;4", Style{Faint: true, Italic: true, Underline: true}},
+		{"normal intensity", Style{Bold: true, Faint: true, Italic: true}, "22", Style{Italic: true}},
+		{"attributes off", Style{Italic: true, Underline: true}, "23;24", Style{}},
+		{"default colors", Style{FG: "red", BG: "blue", Bold: true}, "39;49", bold},
+		{"256 basic", Style{}, "38;5;1", Style{FG: "red"}},
+		{"256 bright", Style{}, "38;5;8", Style{FG: "bright-black"}},
+		{"256 cube", Style{}, "38;5;196", Style{FG: "#ff0000"}},
+		{"256 cube blue then bold", Style{}, "38;5;21;1", Style{FG: "#0000ff", Bold: true}},
+		{"256 grayscale", Style{}, "48;5;232", Style{BG: "#080808"}},
+		{"256 out of range", Style{FG: "red"}, "38;5;300", Style{}},
+		{"truecolor", Style{}, "48;2;1;2;255", Style{BG: "#0102ff"}},
+		{"truecolor then underline", Style{}, "38;2;10;20;30;4", Style{FG: "#0a141e", Underline: true}},
+		{"truecolor invalid", Style{}, "38;2;300;0;0;1", Style{Bold: true}},
+		{"truecolor truncated", Style{}, "38;2;1", Style{}},
+		{"extended without args", Style{}, "38", Style{}},
+		{"non numeric ignored", Style{}, "1;x;4", Style{Bold: true, Underline: true}},
+		{"unknown code ignored", bold, "5", bold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applySGR(tt.from, tt.params); got != tt.want {
+				t.Errorf("applySGR(%+v, %q) = %+v, want %+v", tt.from, tt.params, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	text := "\x1b[8mha:AAAAlQ==\x1b[0m\x1b[1;31mERROR\x1b[0m done\r\n" +
+		"\x1b[2K\x1b[32mgreen\n" +
+		"still green\x1b[0m plain\x1b\n"
+	got := Render(text, []string{"10:00:00.001", "10:00:00.002"})
+	want := []RenderedLine{
+		{Number: 1, Timestamp: "10:00:00.001", Spans: []Span{
+			{Text: "ERROR", Style: Style{Bold: true, FG: "red"}},
+			{Text: " done"},
+		}},
+		// 非 SGR 的 CSI 序列 (清除行) 被丢弃, 样式延续到下一行
+		{Number: 2, Timestamp: "10:00:00.002", Spans: []Span{{Text: "green", Style: Style{FG: "green"}}}},
+		{Number: 3, Spans: []Span{
+			{Text: "still green", Style: Style{FG: "green"}},
+			{Text: " plain"}, // 不完整的转义字符被去掉
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Render =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := Render("", nil); got == nil || len(got) != 0 {
+		t.Errorf("Render(empty) = %#v, want empty slice", got)
+	}
+	// 相邻的相同样式合并为一个片段
+	if got := Render("a\x1b[31m\x1b[31mb\x1b[1m\x1b[22mc", nil); !reflect.DeepEqual(got[0].Spans, []Span{
+		{Text: "a"}, {Text: "bc", Style: Style{FG: "red"}},
+	}) {
+		t.Errorf("spans = %+v", got[0].Spans)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	lines := Render("<b>&\x1b[31mred\x1b[0m \x1b[38;5;196;1mx\x1b[0m\n\x1b[48;2;0;0;255;4m\"q\"", []string{"<t>"})
+	want := `<div class="console-line" data-line="1"><span class="console-timestamp">&lt;t&gt;</span> ` +
+		`&lt;b&gt;&amp;<span class="ansi-fg-red">red</span> <span class="ansi-bold" style="color:#ff0000">x</span></div>` + "\n" +
+		`<div class="console-line" data-line="2"><span class="ansi-underline" style="background-color:#0000ff">&#34;q&#34;</span></div>` + "\n"
+	if got := RenderHTML(lines); got != want {
+		t.Errorf("RenderHTML =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	text := "\x1b[8mha:AAAA\x1b[0m[Pipeline] \x1b[32mok\x1b[0m\nline2\nline3"
+	tests := []struct {
+		name       string
+		timestamps []string
+		want       string
+	}{
+		{"without timestamps", nil, "[Pipeline] ok\nline2\nline3"},
+		{"with timestamps", []string{"10:00:00", "", "10:00:02", "extra"}, "[10:00:00] [Pipeline] ok\nline2\n[10:00:02] line3"},
+		{"fewer timestamps than lines", []string{"10:00:00"}, "[10:00:00] [Pipeline] ok\nline2\nline3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlainText(text, tt.timestamps); got != tt.want {
+				t.Errorf("PlainText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
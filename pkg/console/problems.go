@@ -0,0 +1,102 @@
+package console
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// 问题级别
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+const maxProblems = 1000 // 最多返回的问题数
+
+// Rule 问题提取规则, Tool 为规则所属的构建工具, 用于按工具筛选
+type Rule struct {
+	Name    string `json:"name"`
+	Tool    string `json:"tool"`
+	Level   string `json:"level"`
+	Pattern string `json:"pattern"`
+}
+
+// Problem 从日志中提取出的一条错误或警告
+type Problem struct {
+	Line    int    `json:"line"`
+	Level   string `json:"level"`
+	Tool    string `json:"tool"`
+	Rule    string `json:"rule"`
+	Text    string `json:"text"`
+	Context []Line `json:"context,omitempty"` // 问题行之后的若干行, 通常是堆栈或详细信息
+}
+
+// Summary 提取结果
+type Summary struct {
+	Errors    int       `json:"errors"`
+	Warnings  int       `json:"warnings"`
+	Truncated bool      `json:"truncated"`
+	Problems  []Problem `json:"problems"`
+}
+
+// DefaultRules 内置的常见构建工具规则
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "maven-error", Tool: "maven", Level: LevelError, Pattern: `^\[ERROR\]`},
+		{Name: "maven-warning", Tool: "maven", Level: LevelWarning, Pattern: `^\[WARNING\]`},
+		{Name: "maven-build-failure", Tool: "maven", Level: LevelError, Pattern: `^\[INFO\] BUILD FAILURE`},
+		{Name: "gradle-build-failed", Tool: "gradle", Level: LevelError, Pattern: `^FAILURE: Build failed`},
+		{Name: "gradle-task-failed", Tool: "gradle", Level: LevelError, Pattern: `^> Task \S+ FAILED`},
+		{Name: "javac-error", Tool: "gradle", Level: LevelError, Pattern: `^\S+\.(java|kt):\d+: error:|^e: `},
+		{Name: "javac-warning", Tool: "gradle", Level: LevelWarning, Pattern: `^\S+\.(java|kt):\d+: warning:|^w: `},
+		{Name: "npm-error", Tool: "npm", Level: LevelError, Pattern: `^npm (ERR!|error) `},
+		{Name: "npm-warning", Tool: "npm", Level: LevelWarning, Pattern: `^npm (WARN|warn) `},
+		{Name: "go-test-fail", Tool: "go", Level: LevelError, Pattern: `^\s*--- FAIL: `},
+		{Name: "go-package-fail", Tool: "go", Level: LevelError, Pattern: `^FAIL\s`},
+		{Name: "go-panic", Tool: "go", Level: LevelError, Pattern: `^panic: `},
+		{Name: "go-compile-error", Tool: "go", Level: LevelError, Pattern: `^\S+\.go:\d+:\d+: `},
+		{Name: "jenkins-error", Tool: "jenkins", Level: LevelError, Pattern: `^ERROR: `},
+		{Name: "jenkins-finished", Tool: "jenkins", Level: LevelError, Pattern: `^Finished: (FAILURE|UNSTABLE)`},
+	}
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Extract 按规则逐行提取问题, 每行只匹配第一条命中的规则, context 为每个问题附带的后续行数
+func Extract(text string, rules []Rule, context int) (*Summary, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("规则 [%s] 的表达式无效: %v", r.Name, err)
+		}
+		compiled = append(compiled, compiledRule{Rule: r, re: re})
+	}
+
+	s := &Summary{Problems: make([]Problem, 0)}
+	lines := SplitLines(text)
+	for i, l := range lines {
+		for _, r := range compiled {
+			if !r.re.MatchString(l) {
+				continue
+			}
+			if len(s.Problems) >= maxProblems {
+				s.Truncated = true
+				break
+			}
+			p := Problem{Line: i + 1, Level: r.Level, Tool: r.Tool, Rule: r.Name, Text: l}
+			_, p.Context = contextLines(lines, i, context)
+			s.Problems = append(s.Problems, p)
+			if r.Level == LevelWarning {
+				s.Warnings++
+			} else {
+				s.Errors++
+			}
+			break
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,117 @@
+package console
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultRules(t *testing.T) {
+	rules := DefaultRules()
+	tests := []struct {
+		line string
+		rule string // 空表示不应命中
+	}{
+		{"[ERROR] Failed to execute goal", "maven-error"},
+		{"\x1b[1;31m[ERROR]\x1b[m Failed to execute goal", "maven-error"},
+		{"[WARNING] Using platform encoding", "maven-warning"},
+		{"[INFO] BUILD FAILURE", "maven-build-failure"},
+		{"[INFO] BUILD SUCCESS", ""},
+		{" [ERROR] indented", ""},
+		{"FAILURE: Build failed with an exception.", "gradle-build-failed"},
+		{"> Task :app:compileJava FAILED", "gradle-task-failed"},
+		{"> Task :app:compileJava", ""},
+		{"src/Main.java:12: error: cannot find symbol", "javac-error"},
+		{"e: src/Main.kt: (3, 5): Unresolved reference", "javac-error"},
+		{"src/Main.java:3: warning: [deprecation] old()", "javac-warning"},
+		{"w: src/Main.kt: (1, 1): Parameter never used", "javac-warning"},
+		{"npm ERR! code ELIFECYCLE", "npm-error"},
+		{"npm error code 1", "npm-error"},
+		{"npm WARN deprecated request@2.88.2", "npm-warning"},
+		{"npm warn config production", "npm-warning"},
+		{"npm notice New major version", ""},
+		{"    --- FAIL: TestLogin (0.01s)", "go-test-fail"},
+		{"--- PASS: TestLogin (0.01s)", ""},
+		{"FAIL\tbluebell/logic\t0.215s", "go-package-fail"},
+		{"FAILED", ""},
+		{"panic: runtime error: index out of range", "go-panic"},
+		{"logic/jenkins.go:10:2: undefined: x", "go-compile-error"},
+		{"ERROR: script returned exit code 1", "jenkins-error"},
+		{"Finished: FAILURE", "jenkins-finished"},
+		{"Finished: UNSTABLE", "jenkins-finished"},
+		{"Finished: SUCCESS", ""},
+		{"INFO: no error here", ""},
+	}
+	for _, tt := range tests {
+		s, err := Extract(tt.line, rules, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if len(s.Problems) > 0 {
+			got = s.Problems[0].Rule
+		}
+		if got != tt.rule {
+			t.Errorf("%q matched %q, want %q", tt.line, got, tt.rule)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	text := "[INFO] start\n" +
+		"[WARNING] deprecated\n" +
+		"[ERROR] compile failed\n" +
+		"  at Main.java:1\r\n" +
+		"  at Main.java:2\n" +
+		"Finished: FAILURE\n"
+	s, err := Extract(text, DefaultRules(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Errors != 2 || s.Warnings != 1 || s.Truncated || len(s.Problems) != 3 {
+		t.Fatalf("summary = %+v", s)
+	}
+	p := s.Problems[1]
+	if p.Line != 3 || p.Level != LevelError || p.Tool != "maven" || p.Text != "[ERROR] compile failed" {
+		t.Errorf("problem = %+v", p)
+	}
+	// 只附带问题行之后的上下文
+	if len(p.Context) != 2 || p.Context[0] != (Line{4, "  at Main.java:1"}) || p.Context[1] != (Line{5, "  at Main.java:2"}) {
+		t.Errorf("context = %+v", p.Context)
+	}
+	if last := s.Problems[2]; last.Line != 6 || len(last.Context) != 0 {
+		t.Errorf("last problem = %+v", last)
+	}
+
+	// 每行只取第一条命中的规则
+	rules := []Rule{
+		{Name: "first", Level: LevelWarning, Pattern: "x"},
+		{Name: "second", Level: LevelError, Pattern: "x"},
+	}
+	s, err = Extract("x\nx", rules, 0)
+	if err != nil || len(s.Problems) != 2 || s.Problems[1].Rule != "first" || s.Warnings != 2 || s.Errors != 0 {
+		t.Errorf("first rule wins: %+v, %v", s, err)
+	}
+
+	s, err = Extract("", DefaultRules(), 0)
+	if err != nil || s.Problems == nil || len(s.Problems) != 0 {
+		t.Errorf("empty text: %+v, %v", s, err)
+	}
+}
+
+func TestExtractInvalidRule(t *testing.T) {
+	_, err := Extract("x", []Rule{{Name: "broken", Pattern: "("}}, 0)
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("err = %v, want error naming the rule", err)
+	}
+}
+
+func TestExtractTruncated(t *testing.T) {
+	text := strings.Repeat("[ERROR] x\n", maxProblems+5)
+	s, err := Extract(text, DefaultRules(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Problems) != maxProblems || s.Errors != maxProblems || !s.Truncated {
+		t.Errorf("problems = %d, errors = %d, truncated = %v", len(s.Problems), s.Errors, s.Truncated)
+	}
+}
@@ -0,0 +1,128 @@
+package console
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxMatches = 500 // 默认最多返回的匹配数
+	maxContext        = 20  // 上下文行数上限
+)
+
+// ansiPattern 匹配 CSI 转义序列, 分组为参数和结束字符
+var ansiPattern = regexp.MustCompile(`\x1b\[([0-9;?]*)([A-Za-z])`)
+
+// Line 日志中的一行, Number 从 1 开始
+type Line struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+}
+
+// Match 一处搜索结果
+// Ranges 为匹配片段在该行中的 [起始, 结束) 字符位置 (按 rune 计), 用于前端高亮
+type Match struct {
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Ranges [][2]int `json:"ranges"`
+	Before []Line   `json:"before,omitempty"`
+	After  []Line   `json:"after,omitempty"`
+}
+
+// SearchOptions 搜索选项
+type SearchOptions struct {
+	Query      string
+	Regex      bool // Query 是否为正则表达式
+	IgnoreCase bool
+	Context    int // 匹配行前后返回的上下文行数
+	MaxMatches int // 最多返回的匹配数, <=0 时使用默认值
+}
+
+// StripANSI 去掉 ANSI 转义序列
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// SplitLines 将日志拆分为行, 并去掉行尾的 \r、ConsoleNote 注解和 ANSI 转义序列
+func SplitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		lines[i] = StripANSI(StripNotes(strings.TrimSuffix(l, "\r")))
+	}
+	return lines
+}
+
+// Search 在日志中搜索, 返回匹配结果及是否因超过 MaxMatches 被截断
+func Search(text string, opt SearchOptions) ([]Match, bool, error) {
+	if opt.Query == "" {
+		return nil, false, errors.New("搜索内容不能为空")
+	}
+	pattern := opt.Query
+	if !opt.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opt.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, false, err
+	}
+	if opt.MaxMatches <= 0 {
+		opt.MaxMatches = defaultMaxMatches
+	}
+
+	lines := SplitLines(text)
+	matches := make([]Match, 0)
+	for i, l := range lines {
+		locs := re.FindAllStringIndex(l, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		if len(matches) >= opt.MaxMatches {
+			return matches, true, nil
+		}
+		m := Match{Line: i + 1, Text: l, Ranges: make([][2]int, 0, len(locs))}
+		for _, loc := range locs {
+			if loc[0] == loc[1] {
+				continue // 忽略空匹配
+			}
+			start := utf8.RuneCountInString(l[:loc[0]])
+			m.Ranges = append(m.Ranges, [2]int{start, start + utf8.RuneCountInString(l[loc[0]:loc[1]])})
+		}
+		if len(m.Ranges) == 0 {
+			continue
+		}
+		m.Before, m.After = contextLines(lines, i, opt.Context)
+		matches = append(matches, m)
+	}
+	return matches, false, nil
+}
+
+// contextLines 返回第 i 行 (从 0 开始) 前后各 n 行
+func contextLines(lines []string, i, n int) (before, after []Line) {
+	if n <= 0 {
+		return nil, nil
+	}
+	if n > maxContext {
+		n = maxContext
+	}
+	for j := i - n; j < i; j++ {
+		if j >= 0 {
+			before = append(before, Line{Number: j + 1, Text: lines[j]})
+		}
+	}
+	for j := i + 1; j <= i+n && j < len(lines); j++ {
+		after = append(after, Line{Number: j + 1, Text: lines[j]})
+	}
+	return before, after
+}
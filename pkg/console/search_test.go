@@ -0,0 +1,162 @@
+package console
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	text := "错误: error here ERROR\r\n" +
+		"a.b axb\n" +
+		"BUILD \x1b[31mFAIL\x1b[0mURE\n" +
+		"Fin\x1b[8mha:AAAA\x1b[0mished: SUCCESS\n" +
+		"foo\nbar\n"
+	tests := []struct {
+		name string
+		opt  SearchOptions
+		want []Match
+	}{
+		{"literal", SearchOptions{Query: "error"}, []Match{
+			{Line: 1, Text: "错误: error here ERROR", Ranges: [][2]int{{4, 9}}}, // 按 rune 计算位置
+		}},
+		{"ignore case", SearchOptions{Query: "error", IgnoreCase: true}, []Match{
+			{Line: 1, Text: "错误: error here ERROR", Ranges: [][2]int{{4, 9}, {15, 20}}},
+		}},
+		{"literal quotes meta", SearchOptions{Query: "a.b"}, []Match{
+			{Line: 2, Text: "a.b axb", Ranges: [][2]int{{0, 3}}},
+		}},
+		{"regex", SearchOptions{Query: `a.b`, Regex: true}, []Match{
+			{Line: 2, Text: "a.b axb", Ranges: [][2]int{{0, 3}, {4, 7}}},
+		}},
+		{"regex unicode", SearchOptions{Query: `误: \w+`, Regex: true}, []Match{
+			{Line: 1, Text: "错误: error here ERROR", Ranges: [][2]int{{1, 9}}},
+		}},
+		// 匹配内容被 ANSI 颜色或 ConsoleNote 分隔时仍能找到
+		{"split by ansi", SearchOptions{Query: "BUILD FAILURE"}, []Match{
+			{Line: 3, Text: "BUILD FAILURE", Ranges: [][2]int{{0, 13}}},
+		}},
+		{"split by console note", SearchOptions{Query: "Finished"}, []Match{
+			{Line: 4, Text: "Finished: SUCCESS", Ranges: [][2]int{{0, 8}}},
+		}},
+		// 逐行匹配, 不跨越行边界
+		{"no match across lines", SearchOptions{Query: `foo\nbar`, Regex: true}, []Match{}},
+		{"dot does not cross lines", SearchOptions{Query: `o.b`, Regex: true}, []Match{}},
+		{"crlf stripped", SearchOptions{Query: `ERROR$`, Regex: true}, []Match{
+			{Line: 1, Text: "错误: error here ERROR", Ranges: [][2]int{{15, 20}}},
+		}},
+		{"empty matches ignored", SearchOptions{Query: `z*`, Regex: true}, []Match{}},
+		{"escape sequences not searchable", SearchOptions{Query: "31m"}, []Match{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated, err := Search(text, tt.opt)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if truncated {
+				t.Error("unexpected truncated")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchInvalid(t *testing.T) {
+	if _, _, err := Search("x", SearchOptions{}); err == nil {
+		t.Error("empty query: want error")
+	}
+	if _, _, err := Search("x", SearchOptions{Query: "(", Regex: true}); err == nil {
+		t.Error("invalid regex: want error")
+	}
+	// 非正则模式下的特殊字符按字面匹配
+	if got, _, err := Search("f(x", SearchOptions{Query: "("}); err != nil || len(got) != 1 {
+		t.Errorf("literal ( = %+v, %v", got, err)
+	}
+}
+
+func TestSearchMaxMatches(t *testing.T) {
+	text := "hit 1\nmiss\nhit 2\nhit 3\n"
+	tests := []struct {
+		max       int
+		lines     []int
+		truncated bool
+	}{
+		{0, []int{1, 3, 4}, false},
+		{2, []int{1, 3}, true},
+		{3, []int{1, 3, 4}, false},
+	}
+	for _, tt := range tests {
+		got, truncated, err := Search(text, SearchOptions{Query: "hit", MaxMatches: tt.max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []int
+		for _, m := range got {
+			lines = append(lines, m.Line)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) || truncated != tt.truncated {
+			t.Errorf("MaxMatches %d: lines = %v, truncated = %v, want %v, %v", tt.max, lines, truncated, tt.lines, tt.truncated)
+		}
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	text := "a\nb\nc\nd\ne"
+	tests := []struct {
+		name    string
+		query   string
+		context int
+		before  []Line
+		after   []Line
+	}{
+		{"none", "c", 0, nil, nil},
+		{"one", "c", 1, []Line{{2, "b"}}, []Line{{4, "d"}}},
+		{"first line", "a", 2, nil, []Line{{2, "b"}, {3, "c"}}},
+		{"last line", "e", 2, []Line{{3, "c"}, {4, "d"}}, nil},
+		{"beyond text", "c", 10, []Line{{1, "a"}, {2, "b"}}, []Line{{4, "d"}, {5, "e"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Search(text, SearchOptions{Query: tt.query, Context: tt.context})
+			if err != nil || len(got) != 1 {
+				t.Fatalf("Search = %+v, %v", got, err)
+			}
+			if !reflect.DeepEqual(got[0].Before, tt.before) || !reflect.DeepEqual(got[0].After, tt.after) {
+				t.Errorf("context = %+v / %+v, want %+v / %+v", got[0].Before, got[0].After, tt.before, tt.after)
+			}
+		})
+	}
+
+	// 上下文行数不超过 maxContext
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	lines[50] = "hit"
+	got, _, _ := Search(strings.Join(lines, "\n"), SearchOptions{Query: "hit", Context: 1000})
+	if len(got) != 1 || len(got[0].Before) != maxContext || len(got[0].After) != maxContext {
+		t.Errorf("context not capped at %d", maxContext)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"\n", nil},
+		{"a", []string{"a"}},
+		{"a\r\nb\r\n", []string{"a", "b"}},
+		{"a\n\nb", []string{"a", "", "b"}},
+		{"\x1b[8mha:AAAA\x1b[0m\x1b[1;32mok\x1b[m\n\x1b[?25hdone", []string{"ok", "done"}},
+	}
+	for _, tt := range tests {
+		if got := SplitLines(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// op 表示一行的编辑操作
+type op struct {
+	kind byte // ' ' 相同, '-' 删除, '+' 新增
+	text string
+	a, b int // 该行在原文件/新文件中的行号 (从 0 开始)
+}
+
+// Unified 生成 a 到 b 的 unified diff, context 为每个 hunk 前后保留的上下文行数
+// 两段文本相同 (忽略换行符差异) 时返回空字符串
+func Unified(a, b, fromName, toName string, context int) string {
+	if a == b {
+		return ""
+	}
+	aLines := splitLines(a)
+	bLines := splitLines(b)
+	ops := editScript(aLines, bLines)
+	if !hasChange(ops) {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n", fromName)
+	fmt.Fprintf(&sb, "+++ %s\n", toName)
+
+	for i := 0; i < len(ops); {
+		// 找到下一处改动
+		for i < len(ops) && ops[i].kind == ' ' {
+			i++
+		}
+		if i >= len(ops) {
+			break
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// 向后扩展, 直到连续相同的行超过 2*context
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			same := 0
+			for end+same < len(ops) && ops[end+same].kind == ' ' {
+				same++
+			}
+			if end+same >= len(ops) || same > 2*context {
+				end += minInt(same, context)
+				break
+			}
+			end += same
+		}
+		writeHunk(&sb, ops[start:end])
+		i = end
+	}
+	return sb.String()
+}
+
+func hasChange(ops []op) bool {
+	for _, o := range ops {
+		if o.kind != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+func writeHunk(sb *strings.Builder, ops []op) {
+	aStart, bStart := -1, -1
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			if aStart < 0 {
+				aStart = o.a
+			}
+			aCount++
+		}
+		if o.kind != '-' {
+			if bStart < 0 {
+				bStart = o.b
+			}
+			bCount++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(aStart, aCount, ops[0].a), hunkRange(bStart, bCount, ops[0].b))
+	for _, o := range ops {
+		sb.WriteByte(o.kind)
+		sb.WriteString(o.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// hunkRange 按 unified 格式输出 "起始行,行数", 行数为 0 时起始行取前一行
+func hunkRange(start, count, fallback int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", fallback)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// editScript 计算 a 到 b 的最短逐行编辑序列
+// 先去掉公共前缀/后缀, 再用 Myers 的线性空间算法 (中间蛇形分治), 时间 O((n+m)·d), 空间 O(n+m)
+// d 为改动的行数, 配置文件通常只改动少量行
+func editScript(a, b []string) []op {
+	d := &differ{a: a, b: b, ops: make([]op, 0, len(a)+len(b))}
+	d.compare(0, len(a), 0, len(b))
+	return groupChanges(d.ops)
+}
+
+type differ struct {
+	a, b   []string
+	ops    []op
+	vf, vb []int
+}
+
+// compare 输出 a[a0:a1] 到 b[b0:b1] 的编辑序列
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.ops = append(d.ops, op{kind: ' ', text: d.a[a0], a: a0, b: b0})
+		a0++
+		b0++
+	}
+	suffix := 0
+	for a0 < a1-suffix && b0 < b1-suffix && d.a[a1-suffix-1] == d.b[b1-suffix-1] {
+		suffix++
+	}
+	a1 -= suffix
+	b1 -= suffix
+
+	switch {
+	case a0 == a1:
+		for j := b0; j < b1; j++ {
+			d.ops = append(d.ops, op{kind: '+', text: d.b[j], a: a0, b: j})
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			d.ops = append(d.ops, op{kind: '-', text: d.a[i], a: i, b: b0})
+		}
+	default:
+		x, y, u, v := d.middleSnake(a0, a1, b0, b1)
+		d.compare(a0, x, b0, y)
+		for ; x < u; x, y = x+1, y+1 {
+			d.ops = append(d.ops, op{kind: ' ', text: d.a[x], a: x, b: y})
+		}
+		d.compare(u, a1, v, b1)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, op{kind: ' ', text: d.a[a1+i], a: a1 + i, b: b1 + i})
+	}
+}
+
+// middleSnake 同时从两端搜索, 返回最短编辑路径中间的一段相同行 a[x:u] == b[y:v]
+// 调用方保证两段都非空且首尾行不同
+func (d *differ) middleSnake(a0, a1, b0, b1 int) (x, y, u, v int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta&1 != 0
+	max := (n + m + 1) / 2
+	off := max + 1
+	if size := 2*max + 3; len(d.vf) < size {
+		d.vf = make([]int, size)
+		d.vb = make([]int, size)
+	}
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+
+	for k := 0; k <= max; k++ {
+		// 正向: vf[off+i] 为对角线 i (x-y) 上走 k 步能到达的最远 x
+		for i := -k; i <= k; i += 2 {
+			var x int
+			if i == -k || (i != k && vf[off+i-1] < vf[off+i+1]) {
+				x = vf[off+i+1]
+			} else {
+				x = vf[off+i-1] + 1
+			}
+			y := x - i
+			sx, sy := x, y
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			vf[off+i] = x
+			if c := delta - i; odd && c >= -(k-1) && c <= k-1 && x+vb[off+c] >= n {
+				return a0 + sx, b0 + sy, a0 + x, b0 + y
+			}
+		}
+		// 反向: 在倒序的 a/b 上同样搜索, vb[off+c] 为距末尾的行数
+		for c := -k; c <= k; c += 2 {
+			var x int
+			if c == -k || (c != k && vb[off+c-1] < vb[off+c+1]) {
+				x = vb[off+c+1]
+			} else {
+				x = vb[off+c-1] + 1
+			}
+			y := x - c
+			sx, sy := x, y
+			for x < n && y < m && d.a[a1-x-1] == d.b[b1-y-1] {
+				x++
+				y++
+			}
+			vb[off+c] = x
+			if i := delta - c; !odd && i >= -k && i <= k && x+vf[off+i] >= n {
+				return a1 - x, b1 - y, a1 - sx, b1 - sy
+			}
+		}
+	}
+	// 不会到达: 最短编辑距离不超过 n+m
+	panic("diff: middle snake not found")
+}
+
+// groupChanges 将每段连续改动调整为先删除后新增, 与常见的 diff 输出一致
+func groupChanges(ops []op) []op {
+	out := make([]op, 0, len(ops))
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			out = append(out, ops[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(ops) && ops[j].kind != ' ' {
+			j++
+		}
+		aStart, bStart := ops[i].a, ops[i].b
+		aEnd := aStart
+		for _, o := range ops[i:j] {
+			if o.kind == '-' {
+				out = append(out, op{kind: '-', text: o.text, a: o.a, b: bStart})
+				aEnd = o.a + 1
+			}
+		}
+		for _, o := range ops[i:j] {
+			if o.kind == '+' {
+				out = append(out, op{kind: '+', text: o.text, a: aEnd, b: o.b})
+			}
+		}
+		i = j
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"same", "a\nb\n", "a\nb\n", ""},
+		{"crlf only", "a\r\nb\r\n", "a\nb\n", ""},
+		{
+			"change",
+			"a\nb\nc\nd\ne\n", "a\nb\nC\nd\ne\n",
+			"--- old\n+++ new\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+		},
+		{
+			"insert at start",
+			"a\nb\n", "x\na\nb\n",
+			"--- old\n+++ new\n@@ -1 +1,2 @@\n+x\n a\n",
+		},
+		{
+			"delete at end",
+			"a\nb\nc\n", "a\nb\n",
+			"--- old\n+++ new\n@@ -2,2 +2 @@\n b\n-c\n",
+		},
+		{
+			"from empty",
+			"", "a\nb\n",
+			"--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			"deletions before insertions",
+			"a\nb\nc\nd\n", "a\nx\ny\nd\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n a\n-b\n-c\n+x\n+y\n d\n",
+		},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n", "1\nX\n3\n4\n5\n6\nY\n8\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n 1\n-2\n+X\n 3\n@@ -6,3 +6,3 @@\n 6\n-7\n+Y\n 8\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified(tt.a, tt.b, "old", "new", 1); got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// lcsLen 以 O(n·m) 的动态规划计算最长公共子序列长度, 作为最短编辑距离的参照
+func lcsLen(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				cur[j] = prev[j+1] + 1
+			case prev[j] >= cur[j+1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j+1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[0]
+}
+
+// checkScript 校验编辑序列能从 a 还原出 b, 行号正确且改动行数最少
+func checkScript(t *testing.T, a, b []string) {
+	t.Helper()
+	ops := editScript(a, b)
+	var gotA, gotB []string
+	same := 0
+	for _, o := range ops {
+		if o.kind != '+' {
+			if o.a != len(gotA) || a[o.a] != o.text {
+				t.Fatalf("a=%q b=%q: bad op %+v", a, b, o)
+			}
+			gotA = append(gotA, o.text)
+		}
+		if o.kind != '-' {
+			if o.b != len(gotB) || b[o.b] != o.text {
+				t.Fatalf("a=%q b=%q: bad op %+v", a, b, o)
+			}
+			gotB = append(gotB, o.text)
+		}
+		if o.kind == ' ' {
+			same++
+		}
+	}
+	if len(gotA) != len(a) || len(gotB) != len(b) {
+		t.Fatalf("a=%q b=%q: script covers %d/%d lines", a, b, len(gotA), len(gotB))
+	}
+	if want := lcsLen(a, b); same != want {
+		t.Fatalf("a=%q b=%q: %d common lines, want %d", a, b, same, want)
+	}
+}
+
+func TestEditScriptMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	gen := func() []string {
+		lines := make([]string, rnd.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(3)))
+		}
+		return lines
+	}
+	for i := 0; i < 5000; i++ {
+		checkScript(t, gen(), gen())
+	}
+}
+
+// 大文件只改动少量行时不应按 n·m 分配内存
+func TestEditScriptLarge(t *testing.T) {
+	const n = 200000
+	a := make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("<line%d/>", i)
+	}
+	b := append([]string(nil), a...)
+	b[10] = "<changed/>"
+	b[n/2] = "<changed/>"
+	b = append(b[:n-10], append([]string{"<inserted/>"}, b[n-10:]...)...)
+
+	start := time.Now()
+	out := Unified(strings.Join(a, "\n"), strings.Join(b, "\n"), "old", "new", 3)
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Unified took %s", d)
+	}
+	if got := strings.Count(out, "@@ -"); got != 3 {
+		t.Errorf("hunks = %d, want 3\n%s", got, out)
+	}
+
+	// 完全不同的两段文本
+	for i := range b {
+		b[i] = "x" + b[i]
+	}
+	ops := editScript(a[:5000], b[:5000])
+	if len(ops) != 10000 {
+		t.Errorf("ops = %d, want 10000", len(ops))
+	}
+}
@@ -1,55 +1,219 @@
 package jwt
 
 import (
+	"bluebell/setting"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
 )
 
-const TokenExpireDuration = time.Hour * 2
+// TokenExpireDuration access token 默认的有效期, 过期后使用 refresh token 换取新的 access token
+const TokenExpireDuration = time.Minute * 30
 
-var mySecret = []byte("夏天夏天悄悄过去")
+const defaultIssuer = "bluebell"
+
+// key 一把签名/验签密钥, kid 写在 token 头部, 验签时按 kid 选择密钥
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // HMAC 为 []byte, RSA/ECDSA 为私钥; 只用于验签的密钥为 nil
+	verify interface{} // HMAC 为 []byte, RSA/ECDSA 为公钥
+}
+
+var (
+	signingKey *key
+	keys       = make(map[string]*key)
+	expire     = TokenExpireDuration
+	issuer     = defaultIssuer
+)
+
+var ErrorInvalidToken = errors.New("invalid token")
 
 // MyClaims 自定义声明结构体并内嵌jwt.StandardClaims
 // jwt包自带的jwt.StandardClaims只包含了官方字段
-// 我们这里需要额外记录一个username字段，所以要自定义结构体
+// 我们这里需要额外记录username和角色，所以要自定义结构体
 // 如果想要保存更多信息，都可以添加到这个结构体中
 type MyClaims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID   int64    `json:"user_id"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
 	jwt.StandardClaims
 }
 
-// GenToken 生成JWT
-func GenToken(userID int64, username string) (string, error) {
+// Init 按配置加载密钥
+// 未配置时只有开发模式 (mode 为 dev) 下生成随机的 HS256 密钥, 重启后已签发的 token 全部失效;
+// 其他模式下返回错误, 避免多实例各自生成密钥
+func Init(cfg *setting.JWTConfig, mode string) error {
+	signingKey, keys = nil, make(map[string]*key)
+	expire, issuer = TokenExpireDuration, defaultIssuer
+	if cfg == nil || len(cfg.Keys) == 0 {
+		if mode != "dev" {
+			return errors.New("jwt: 未配置签名密钥, 请在配置文件的 jwt.keys 中配置")
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		signingKey = &key{id: "default", method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+		keys[signingKey.id] = signingKey
+		zap.L().Warn("jwt: 未配置签名密钥, 使用随机生成的密钥, 重启后需要重新登录")
+		return nil
+	}
+
+	if cfg.AccessExpire > 0 {
+		expire = time.Duration(cfg.AccessExpire) * time.Minute
+	}
+	if cfg.Issuer != "" {
+		issuer = cfg.Issuer
+	}
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return errors.New("jwt: 密钥缺少 id")
+		}
+		if _, ok := keys[kc.ID]; ok {
+			return fmt.Errorf("jwt: 密钥 id [%s] 重复", kc.ID)
+		}
+		k, err := loadKey(kc)
+		if err != nil {
+			return fmt.Errorf("jwt: 加载密钥 [%s] 失败: %v", kc.ID, err)
+		}
+		keys[k.id] = k
+	}
+
+	k, ok := keys[cfg.SigningKey]
+	if !ok {
+		return fmt.Errorf("jwt: 签名密钥 [%s] 不存在", cfg.SigningKey)
+	}
+	if k.sign == nil {
+		return fmt.Errorf("jwt: 签名密钥 [%s] 缺少私钥", cfg.SigningKey)
+	}
+	signingKey = k
+	return nil
+}
+
+// loadKey 按算法加载密钥, RSA/ECDSA 只配置公钥时只用于验签 (已轮换下线的密钥)
+func loadKey(kc setting.JWTKey) (*key, error) {
+	method := jwt.GetSigningMethod(kc.Algorithm)
+	if method == nil || method == jwt.SigningMethodNone {
+		return nil, fmt.Errorf("不支持的算法 [%s]", kc.Algorithm)
+	}
+	k := &key{id: kc.ID, method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(kc.Secret) < 32 {
+			return nil, errors.New("HMAC 密钥长度至少 32 字节")
+		}
+		k.sign, k.verify = []byte(kc.Secret), []byte(kc.Secret)
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if kc.PrivateKey != "" {
+			pem, err := ioutil.ReadFile(kc.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.sign, k.verify = priv, &priv.PublicKey
+		}
+		if kc.PublicKey != "" {
+			pem, err := ioutil.ReadFile(kc.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if k.verify, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	case *jwt.SigningMethodECDSA:
+		if kc.PrivateKey != "" {
+			pem, err := ioutil.ReadFile(kc.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseECPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.sign, k.verify = priv, &priv.PublicKey
+		}
+		if kc.PublicKey != "" {
+			pem, err := ioutil.ReadFile(kc.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			if k.verify, err = jwt.ParseECPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("不支持的算法 [%s]", kc.Algorithm)
+	}
+	if k.verify == nil {
+		return nil, errors.New("需要配置 private_key 或 public_key")
+	}
+	return k, nil
+}
+
+// GenToken 生成JWT, 同时返回声明, 其中 Id (jti) 用于注销时吊销该 token
+func GenToken(userID int64, username string, roles []string) (string, *MyClaims, error) {
+	if signingKey == nil {
+		return "", nil, errors.New("jwt: 未初始化")
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", nil, err
+	}
 	// 创建一个我们自己的声明的数据
-	c := MyClaims{
-		userID,
-		"username", // 自定义字段
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(TokenExpireDuration).Unix(), // 过期时间
-			Issuer:    "bluebell",                                 // 签发人
+	c := &MyClaims{
+		UserID:   userID,
+		Username: username,
+		Roles:    roles,
+		StandardClaims: jwt.StandardClaims{
+			Id:        hex.EncodeToString(jti),
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(expire).Unix(), // 过期时间
+			Issuer:    issuer,                        // 签发人
 		},
 	}
-	// 使用指定的签名方法创建签名对象
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
-	// 使用指定的secret签名并获得完整的编码后的字符串token
-	return token.SignedString(mySecret)
+	// 使用指定的签名方法创建签名对象, 并在头部写入kid
+	token := jwt.NewWithClaims(signingKey.method, c)
+	token.Header["kid"] = signingKey.id
+	// 使用签名密钥签名并获得完整的编码后的字符串token
+	signed, err := token.SignedString(signingKey.sign)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, c, nil
 }
 
-// ParseToken 解析JWT
+// ParseToken 解析JWT, 按头部的kid选择验签密钥, 算法必须与密钥配置的一致, 签发人必须与配置的一致
 func ParseToken(tokenString string) (*MyClaims, error) {
 	// 解析token
 	var mc = new(MyClaims)
 	token, err := jwt.ParseWithClaims(tokenString, mc, func(token *jwt.Token) (i interface{}, err error) {
-		return mySecret, nil
+		kid, _ := token.Header["kid"].(string)
+		k, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid [%s]", kid)
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method [%s]", token.Method.Alg())
+		}
+		return k.verify, nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	if token.Valid { // 校验token
+	if token.Valid && mc.VerifyIssuer(issuer, true) { // 校验token
 		return mc, nil
 	}
-	return nil, errors.New("invalid token")
+	return nil, ErrorInvalidToken
 }
@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"bluebell/setting"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func testConfig(issuer string) *setting.JWTConfig {
+	return &setting.JWTConfig{
+		SigningKey: "k1",
+		Issuer:     issuer,
+		Keys:       []setting.JWTKey{{ID: "k1", Algorithm: "HS256", Secret: testSecret}},
+	}
+}
+
+func TestInitWithoutKeys(t *testing.T) {
+	for _, mode := range []string{"release", "debug", ""} {
+		if err := Init(nil, mode); err == nil {
+			t.Errorf("Init(nil, %q) succeeded, want error", mode)
+		}
+		if err := Init(&setting.JWTConfig{}, mode); err == nil {
+			t.Errorf("Init(empty, %q) succeeded, want error", mode)
+		}
+	}
+	if err := Init(nil, "dev"); err != nil {
+		t.Fatalf("Init(nil, dev): %v", err)
+	}
+	token, _, err := GenToken(1, "bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(token); err != nil {
+		t.Errorf("ParseToken: %v", err)
+	}
+}
+
+func TestParseTokenIssuer(t *testing.T) {
+	if err := Init(testConfig("bluebell-a"), "release"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := GenToken(1, "bob", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, err := ParseToken(token)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if mc.Username != "bob" || mc.Issuer != "bluebell-a" {
+		t.Errorf("claims = %+v", mc)
+	}
+
+	// 相同密钥的其他系统签发的 token 不能通过校验
+	sign := func(iss string) string {
+		c := &MyClaims{UserID: 1, Username: "bob", StandardClaims: jwt.StandardClaims{
+			Issuer:    iss,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}}
+		tk := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
+		tk.Header["kid"] = "k1"
+		s, err := tk.SignedString([]byte(testSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	for _, iss := range []string{"other", "", "bluebell"} {
+		if _, err := ParseToken(sign(iss)); err != ErrorInvalidToken {
+			t.Errorf("issuer %q: err = %v, want ErrorInvalidToken", iss, err)
+		}
+	}
+	if _, err := ParseToken(sign("bluebell-a")); err != nil {
+		t.Errorf("configured issuer: %v", err)
+	}
+
+	// 修改签发人后, 旧签发人的 token 失效
+	if err := Init(testConfig("bluebell-b"), "release"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseToken(token); err != ErrorInvalidToken {
+		t.Errorf("old issuer: err = %v, want ErrorInvalidToken", err)
+	}
+}
@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ObserveJenkins 记录一次 Jenkins 请求, nodeID 为节点 ID, path 为请求路径
+// code 为响应状态码, err 不为 nil 时表示网络错误
+func ObserveJenkins(nodeID int, method, path string, code int, d time.Duration, err error) {
+	node := nodeLabel(nodeID)
+	endpoint := JenkinsEndpoint(path)
+	jenkinsDuration.WithLabelValues(node, method, endpoint).Observe(d.Seconds())
+	switch {
+	case err != nil:
+		jenkinsErrors.WithLabelValues(node, method, endpoint, "error").Inc()
+	case code >= 400:
+		jenkinsErrors.WithLabelValues(node, method, endpoint, strconv.Itoa(code)).Inc()
+	}
+}
+
+// jenkinsNameSegments 后一段为名称的路径段
+var jenkinsNameSegments = map[string]bool{
+	"job":      true,
+	"view":     true,
+	"computer": true,
+	"user":     true,
+	"node":     true,
+}
+
+// JenkinsEndpoint 将 Jenkins 请求路径归一化为接口名, 避免 Job 名和构建编号导致标签过多
+// 如 /job/app/job/web/12/consoleText 归一化为 /job/:name/job/:name/:number/consoleText
+// 后一段为 api 时视为接口本身, 如 /computer/api/json
+func JenkinsEndpoint(path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) == 1 && segs[0] == "" {
+		return "/"
+	}
+	for i := 0; i < len(segs); i++ {
+		switch {
+		case jenkinsNameSegments[segs[i]] && i+1 < len(segs) && segs[i+1] != "api":
+			i++
+			segs[i] = ":name"
+		case isNumber(segs[i]):
+			segs[i] = ":number"
+		}
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// JenkinsNodeStats 一个节点的 Jenkins 状态
+type JenkinsNodeStats struct {
+	NodeID         int
+	Name           string
+	Node           string // Jenkins 地址 (host:port)
+	Up             bool   // 本次采集是否成功, 失败时其余字段无效
+	QueueLength    int
+	BusyExecutors  int
+	TotalExecutors int
+}
+
+var (
+	jenkinsLabels = []string{"node_id", "node_name", "node"}
+
+	jenkinsUpDesc = prometheus.NewDesc(namespace+"_jenkins_up",
+		"最近一次采集 Jenkins 状态是否成功", jenkinsLabels, nil)
+	jenkinsQueueDesc = prometheus.NewDesc(namespace+"_jenkins_queue_length",
+		"Jenkins 构建队列中的任务数", jenkinsLabels, nil)
+	jenkinsBusyDesc = prometheus.NewDesc(namespace+"_jenkins_busy_executors",
+		"Jenkins 正在执行构建的执行器数", jenkinsLabels, nil)
+	jenkinsTotalDesc = prometheus.NewDesc(namespace+"_jenkins_total_executors",
+		"Jenkins 执行器总数", jenkinsLabels, nil)
+)
+
+// jenkinsCollector 输出最近一次采集的各节点 Jenkins 状态, 已删除的节点不再输出
+type jenkinsCollector struct {
+	mu    sync.RWMutex
+	stats []JenkinsNodeStats
+}
+
+var nodeCollector = &jenkinsCollector{}
+
+func init() {
+	prometheus.MustRegister(nodeCollector)
+}
+
+// SetJenkinsNodes 更新各节点的 Jenkins 状态
+func SetJenkinsNodes(stats []JenkinsNodeStats) {
+	nodeCollector.mu.Lock()
+	nodeCollector.stats = stats
+	nodeCollector.mu.Unlock()
+}
+
+func (c *jenkinsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jenkinsUpDesc
+	ch <- jenkinsQueueDesc
+	ch <- jenkinsBusyDesc
+	ch <- jenkinsTotalDesc
+}
+
+func (c *jenkinsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, s := range c.stats {
+		labels := []string{strconv.Itoa(s.NodeID), s.Name, s.Node}
+		up := 0.0
+		if s.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(jenkinsUpDesc, prometheus.GaugeValue, up, labels...)
+		if !s.Up {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(jenkinsQueueDesc, prometheus.GaugeValue, float64(s.QueueLength), labels...)
+		ch <- prometheus.MustNewConstMetric(jenkinsBusyDesc, prometheus.GaugeValue, float64(s.BusyExecutors), labels...)
+		ch <- prometheus.MustNewConstMetric(jenkinsTotalDesc, prometheus.GaugeValue, float64(s.TotalExecutors), labels...)
+	}
+}
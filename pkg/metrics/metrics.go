@@ -0,0 +1,117 @@
+// Package metrics 定义后端的 Prometheus 指标: HTTP 请求、Jenkins 调用、数据库查询和构建触发
+// 指标注册在默认的 Registry 中, 与 Go 运行时和进程指标一起通过 Handler 暴露
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bluebell"
+
+// 构建触发来源
+const (
+	SourceManual    = "manual"     // 页面手动触发
+	SourceMultiNode = "multi_node" // 跨节点批量触发
+	SourceSchedule  = "schedule"   // 定时构建
+	SourceWorkflow  = "workflow"   // 工作流步骤
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP 请求数, 按方法、路由和状态码统计",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP 请求耗时",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	jenkinsDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "jenkins_request_duration_seconds",
+		Help:      "请求 Jenkins 的耗时, 按节点和接口统计",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"node_id", "method", "endpoint"})
+
+	jenkinsErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jenkins_request_errors_total",
+		Help:      "请求 Jenkins 失败的次数, code 为响应状态码 (>= 400), 网络错误为 error",
+	}, []string{"node_id", "method", "endpoint", "code"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "数据库语句耗时, 按语句类型统计",
+		Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"operation"})
+
+	dbErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "数据库语句执行失败的次数",
+	}, []string{"operation"})
+
+	buildTriggers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "build_triggers_total",
+		Help:      "触发构建的次数, 按节点、来源和结果统计",
+	}, []string{"node_id", "source", "result"})
+)
+
+// Handler 返回输出指标的 http.Handler
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// GinMetrics 统计 HTTP 请求数和耗时, 与 logger.GinLogger 一起使用
+// 路由使用注册时的模板 (如 /server/node/:id), 未匹配的请求记为 unmatched, 避免路径参数导致标签过多
+func GinMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveDB 记录一次数据库语句的耗时和结果
+func ObserveDB(operation string, d time.Duration, err error) {
+	dbDuration.WithLabelValues(operation).Observe(d.Seconds())
+	if err != nil {
+		dbErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// BuildTriggered 记录一次构建触发, nodeID 为节点 ID
+func BuildTriggered(nodeID int, source string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	buildTriggers.WithLabelValues(nodeLabel(nodeID), source, result).Inc()
+}
+
+// nodeLabel 节点 ID 标签, 与 jenkins_up 等指标的 node_id 一致, 未知节点为空
+func nodeLabel(nodeID int) string {
+	if nodeID <= 0 {
+		return ""
+	}
+	return strconv.Itoa(nodeID)
+}
@@ -0,0 +1,270 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// 通知渠道
+const (
+	ChannelWebhook  = "webhook"  // 通用 Webhook, POST JSON
+	ChannelEmail    = "email"    // SMTP 邮件
+	ChannelDingTalk = "dingtalk" // 钉钉机器人
+	ChannelWeCom    = "wecom"    // 企业微信机器人
+	ChannelFeishu   = "feishu"   // 飞书机器人
+)
+
+// client 发送 Webhook 和机器人消息, 连接时拒绝内网地址 (包括域名解析和重定向的结果), 防止通过通知规则访问内网服务
+// 不使用环境变量中的代理, 否则实际连接的是代理地址, 无法校验目标地址
+var client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+					return ErrInternalTarget
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// ErrInternalTarget 通知地址指向内网地址
+var ErrInternalTarget = errors.New("通知地址不能指向内网、回环或链路本地地址")
+
+// allowInternal 为 true 时允许内网地址, 仅用于测试
+var allowInternal = false
+
+var internalNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, s := range []string{
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/3",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+	} {
+		_, n, _ := net.ParseCIDR(s)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isInternalIP(ip net.IP) bool {
+	if allowInternal {
+		return false
+	}
+	for _, n := range internalNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTarget 校验 Webhook / 机器人的地址: 只允许 http(s), 不能指向内网地址
+// 域名能解析时检查解析结果; 解析失败时放行, 发送时连接前还会再次检查
+func ValidateTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("通知地址无效, 需要以 http:// 或 https:// 开头")
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if isInternalIP(ip) {
+			return ErrInternalTarget
+		}
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrInternalTarget
+	}
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range addrs {
+		if isInternalIP(ip) {
+			return ErrInternalTarget
+		}
+	}
+	return nil
+}
+
+// errorMessageLimit 错误信息中保留的机器人接口返回信息的最大长度
+const errorMessageLimit = 64
+
+func truncate(s string) string {
+	if len(s) <= errorMessageLimit {
+		return s
+	}
+	n := errorMessageLimit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// SMTPConfig 邮件发送配置
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Message 待发送的消息
+type Message struct {
+	Title   string
+	Content string
+	Data    interface{} // 通用 Webhook 附带的结构化数据
+}
+
+// Send 通过指定渠道发送消息, target 为 Webhook 地址或以逗号分隔的收件人
+func Send(channel, target, secret string, smtpCfg *SMTPConfig, msg *Message) error {
+	switch channel {
+	case ChannelWebhook:
+		return postJSON(target, map[string]interface{}{
+			"title":   msg.Title,
+			"content": msg.Content,
+			"data":    msg.Data,
+		})
+	case ChannelEmail:
+		return sendEmail(smtpCfg, target, msg)
+	case ChannelDingTalk:
+		return sendDingTalk(target, secret, msg)
+	case ChannelWeCom:
+		return postJSON(target, map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": msg.Content},
+		})
+	case ChannelFeishu:
+		return sendFeishu(target, secret, msg)
+	}
+	return fmt.Errorf("不支持的通知渠道 [%s]", channel)
+}
+
+// postJSON 发送 JSON 请求, 并检查机器人接口返回的错误码
+func postJSON(target string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(b))
+	if err != nil {
+		if errors.Is(err, ErrInternalTarget) {
+			return ErrInternalTarget
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	// 响应内容来自用户配置的地址, 错误中只保留状态码和截断后的错误信息
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("请求失败，状态码：%d", resp.StatusCode)
+	}
+
+	// 钉钉/企业微信返回 errcode, 飞书返回 code, 非 0 表示失败
+	var res struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(body, &res) != nil {
+		return nil
+	}
+	if res.ErrCode != nil && *res.ErrCode != 0 {
+		return fmt.Errorf("errcode: %d, errmsg: %s", *res.ErrCode, truncate(res.ErrMsg))
+	}
+	if res.Code != nil && *res.Code != 0 {
+		return fmt.Errorf("code: %d, msg: %s", *res.Code, truncate(res.Msg))
+	}
+	return nil
+}
+
+// sendDingTalk 钉钉机器人, 配置了加签密钥时在 URL 上附加 timestamp 和 sign
+func sendDingTalk(target, secret string, msg *Message) error {
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().UnixNano()/1e6, 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "\n" + secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target = fmt.Sprintf("%s%stimestamp=%s&sign=%s", target, sep, timestamp, sign)
+	}
+	return postJSON(target, map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": msg.Content},
+	})
+}
+
+// sendFeishu 飞书机器人, 配置了签名校验密钥时在请求体中附加 timestamp 和 sign
+func sendFeishu(target, secret string, msg *Message) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": msg.Content},
+	}
+	if secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(target, payload)
+}
+
+func sendEmail(cfg *SMTPConfig, target string, msg *Message) error {
+	if cfg == nil || cfg.Host == "" {
+		return fmt.Errorf("未配置 SMTP")
+	}
+	var to []string
+	for _, addr := range strings.Split(target, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("收件人为空")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(msg.Title)))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	buf.WriteString(msg.Content)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+	return smtp.SendMail(addr, auth, cfg.From, to, buf.Bytes())
+}
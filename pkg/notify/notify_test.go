@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		target string
+		ok     bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://[2606:2800:220:1::]/hook", true},
+		{"ftp://93.184.216.34/hook", false},
+		{"not a url", false},
+		{"http://", false},
+		{"http://127.0.0.1:8080/admin", false},
+		{"http://localhost/hook", false},
+		{"http://api.localhost/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://172.20.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://100.64.0.1/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		err := ValidateTarget(tt.target)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateTarget(%q) = %v, want ok = %v", tt.target, err, tt.ok)
+		}
+	}
+}
+
+func TestPostJSONBlocksInternal(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("internal target should not be reached")
+	}))
+	defer ts.Close()
+
+	if err := postJSON(ts.URL, map[string]string{}); !errors.Is(err, ErrInternalTarget) {
+		t.Fatalf("postJSON to loopback = %v, want ErrInternalTarget", err)
+	}
+}
+
+func TestPostJSONRedirectToInternal(t *testing.T) {
+	// 外部地址重定向到内网时, 连接阶段同样会被拒绝
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect target should not be reached")
+	}))
+	defer ts.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(ts.URL, http.StatusFound))
+	defer redirect.Close()
+
+	allowInternal = true
+	// 只放行重定向服务所在的第一次连接, 之后恢复检查
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		allowInternal = false
+		return nil
+	}
+	defer func() {
+		allowInternal = false
+		client.CheckRedirect = nil
+	}()
+
+	if err := postJSON(redirect.URL, map[string]string{}); !errors.Is(err, ErrInternalTarget) {
+		t.Fatalf("postJSON following redirect = %v, want ErrInternalTarget", err)
+	}
+}
+
+func TestPostJSONErrorOmitsBody(t *testing.T) {
+	allowInternal = true
+	defer func() { allowInternal = false }()
+
+	secret := strings.Repeat("internal-secret ", 20)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(secret))
+	}))
+	defer ts.Close()
+
+	err := postJSON(ts.URL, map[string]string{})
+	if err == nil || strings.Contains(err.Error(), "internal-secret") || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("postJSON error = %v, want status code only", err)
+	}
+
+	ts2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errcode": 1, "errmsg": "` + secret + `"}`))
+	}))
+	defer ts2.Close()
+	err = postJSON(ts2.URL, map[string]string{})
+	if err == nil || len(err.Error()) > errorMessageLimit+40 {
+		t.Fatalf("postJSON errmsg = %v, want truncated message", err)
+	}
+}
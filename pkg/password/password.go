@@ -0,0 +1,129 @@
+// Package password 提供密码哈希与校验
+// 新密码使用 argon2id, 以 PHC 字符串格式存储: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+// 校验时兼容 bcrypt ($2a$ / $2b$ / $2y$) 以及早期版本遗留的明文和 MD5 密码, 按保存的格式只使用其中一种方式校验
+package password
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2id 参数, 修改后旧哈希会在下次登录时按新参数重新生成
+const (
+	argonMemory  = 64 * 1024 // KiB
+	argonTime    = 3
+	argonThreads = 2
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+// legacySalt 早期版本 MD5 加密使用的固定盐
+const legacySalt = "liwenzhou.com"
+
+var ErrInvalidHash = errors.New("密码哈希格式无效")
+
+var b64 = base64.RawStdEncoding
+
+// Hash 使用 argon2id 生成密码哈希
+func Hash(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify 校验密码, 返回是否匹配, 以及匹配时是否需要按当前算法和参数重新生成哈希
+func Verify(password, encoded string) (ok bool, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, false, nil
+		}
+		return err == nil, true, err
+	case strings.HasPrefix(encoded, "$"):
+		return false, false, ErrInvalidHash
+	case encoded == "":
+		return false, false, nil
+	case isLegacyMD5(encoded):
+		// 早期版本遗留的 MD5, 只按 MD5 校验, 不能直接提交数据库中的值登录
+		return equal(legacyMD5(password), encoded), true, nil
+	}
+	// 早期版本遗留的明文
+	return equal(password, encoded), true, nil
+}
+
+// IsLegacy 判断保存的密码是否为早期版本遗留的明文或 MD5
+func IsLegacy(encoded string) bool {
+	return encoded != "" && !strings.HasPrefix(encoded, "$")
+}
+
+func verifyArgon2id(password, encoded string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrInvalidHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, ErrInvalidHash
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrInvalidHash
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrInvalidHash
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	rehash := memory != argonMemory || time != argonTime || threads != argonThreads ||
+		len(salt) != argonSaltLen || len(key) != argonKeyLen
+	return true, rehash, nil
+}
+
+// legacyMD5 早期版本的 MD5 加密, 与原 dao/mysql.encryptPassword 的结果一致
+// 即 hex(密码) + hex(md5(legacySalt)), 因此以固定的 legacyMD5Suffix 结尾
+func legacyMD5(password string) string {
+	h := md5.New()
+	h.Write([]byte(legacySalt))
+	return hex.EncodeToString(h.Sum([]byte(password)))
+}
+
+var legacyMD5Suffix = legacyMD5("")
+
+// isLegacyMD5 根据格式判断保存的是早期的 MD5 还是明文
+func isLegacyMD5(encoded string) bool {
+	if len(encoded) <= len(legacyMD5Suffix) || !strings.HasSuffix(encoded, legacyMD5Suffix) {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil && strings.ToLower(encoded) == encoded
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,135 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashVerify(t *testing.T) {
+	h, err := Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(h, "$argon2id$") {
+		t.Fatalf("Hash = %s, want argon2id", h)
+	}
+	if ok, rehash, err := Verify("s3cret", h); !ok || rehash || err != nil {
+		t.Errorf("Verify(correct) = %v, %v, %v, want true, false, nil", ok, rehash, err)
+	}
+	if ok, _, err := Verify("wrong", h); ok || err != nil {
+		t.Errorf("Verify(wrong) = %v, %v, want false, nil", ok, err)
+	}
+	if h2, _ := Hash("s3cret"); h2 == h {
+		t.Error("Hash reuses the salt")
+	}
+
+	// 参数与当前不同的旧哈希仍可校验, 但需要重新生成
+	weak := "$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$" +
+		b64.EncodeToString(argon2.IDKey([]byte("s3cret"), []byte("saltsaltsaltsalt"), 1, 1024, 1, 32))
+	if ok, rehash, err := Verify("s3cret", weak); !ok || !rehash || err != nil {
+		t.Errorf("Verify(weak params) = %v, %v, %v, want true, true, nil", ok, rehash, err)
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	b, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash, err := Verify("s3cret", string(b)); !ok || !rehash || err != nil {
+		t.Errorf("Verify(correct) = %v, %v, %v, want true, true, nil", ok, rehash, err)
+	}
+	if ok, _, err := Verify("wrong", string(b)); ok || err != nil {
+		t.Errorf("Verify(wrong) = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=65536,t=3,p=2$salt",
+		"$argon2id$v=18$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=x$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=65536,t=3,p=2$!!$aGFzaA",
+		"$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$",
+		"$1$md5crypt$",
+	} {
+		if ok, _, err := Verify("s3cret", encoded); ok || err != ErrInvalidHash {
+			t.Errorf("Verify(%q) = %v, %v, want false, ErrInvalidHash", encoded, ok, err)
+		}
+	}
+}
+
+func TestVerifyLegacy(t *testing.T) {
+	md5 := legacyMD5("s3cret")
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		ok       bool
+	}{
+		{"md5", "s3cret", md5, true},
+		{"md5 wrong", "wrong", md5, false},
+		// 不能直接提交数据库中保存的 MD5 值
+		{"md5 pass-the-hash", md5, md5, false},
+		{"plaintext", "admin123", "admin123", true},
+		{"plaintext wrong", "admin", "admin123", false},
+		{"uppercase hex is plaintext", strings.ToUpper(md5), strings.ToUpper(md5), true},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash, err := Verify(tt.password, tt.encoded)
+			if ok != tt.ok || err != nil {
+				t.Errorf("Verify = %v, %v, want %v, nil", ok, err, tt.ok)
+			}
+			if ok && !rehash {
+				t.Error("legacy password should be rehashed")
+			}
+		})
+	}
+}
+
+func TestIsLegacyMD5(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    bool
+	}{
+		{legacyMD5("s3cret"), true},
+		{legacyMD5("密码"), true},
+		{legacyMD5Suffix, false},
+		{"0" + legacyMD5Suffix, false},
+		{"zz" + legacyMD5Suffix, false},
+		{strings.ToUpper(legacyMD5("s3cret")), false},
+		{"admin123", false},
+	}
+	for _, tt := range tests {
+		if got := isLegacyMD5(tt.encoded); got != tt.want {
+			t.Errorf("isLegacyMD5(%q) = %v, want %v", tt.encoded, got, tt.want)
+		}
+	}
+}
+
+func TestIsLegacy(t *testing.T) {
+	hash, err := Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		encoded string
+		want    bool
+	}{
+		{"admin123", true},
+		{legacyMD5("s3cret"), true},
+		{hash, false},
+		{"$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsLegacy(tt.encoded); got != tt.want {
+			t.Errorf("IsLegacy(%q) = %v, want %v", tt.encoded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量, 密钥为 ASCII "12345678901234567890"
+// RFC 给出 8 位密码, 6 位密码即其后 6 位
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		counter := Counter(time.Unix(tt.unix, 0))
+		got, err := Code(rfcSecret, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; got != want {
+			t.Errorf("Code(T=%d) = %s, want %s", tt.unix, got, want)
+		}
+		// 小写和带填充的密钥也能解析
+		if lower, _ := Code(strings.ToLower(rfcSecret)+"====", counter); lower != got {
+			t.Errorf("Code with lowercase padded secret = %s, want %s", lower, got)
+		}
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("invalid secret: want error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+	code := func(c int64) string {
+		s, _ := Code(rfcSecret, c)
+		return s
+	}
+	tests := []struct {
+		name    string
+		code    string
+		skew    int
+		counter int64
+		ok      bool
+	}{
+		{"current", code(counter), 1, counter, true},
+		{"with spaces", " " + code(counter) + " ", 1, counter, true},
+		{"previous step", code(counter - 1), 1, counter - 1, true},
+		{"next step", code(counter + 1), 1, counter + 1, true},
+		{"outside skew", code(counter - 2), 1, 0, false},
+		{"no skew", code(counter - 1), 0, 0, false},
+		{"wrong", "000000", 1, 0, false},
+		{"too short", code(counter)[:5], 1, 0, false},
+		{"too long", code(counter) + "0", 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.ok || got != tt.counter {
+				t.Errorf("Validate = %d, %v, want %d, %v", got, ok, tt.counter, tt.ok)
+			}
+		})
+	}
+}
+
+// 同一验证码在有效期内始终返回相同的时间步, 调用方记录已使用的时间步即可拒绝重放
+func TestValidateReplayCounter(t *testing.T) {
+	start := time.Unix(1234567890, 0) // 恰好是一个时间步的开始
+	c := Counter(start)
+	code := mustCode(t, c)
+	for _, d := range []time.Duration{0, 10 * time.Second, 29 * time.Second, 45 * time.Second} {
+		if got, ok := Validate(rfcSecret, code, start.Add(d), 1); !ok || got != c {
+			t.Errorf("+%v: Validate = %d, %v, want %d, true", d, got, ok, c)
+		}
+	}
+	if _, ok := Validate(rfcSecret, code, start.Add(3*Period*time.Second), 1); ok {
+		t.Error("code accepted after the skew window")
+	}
+	// 下一时间步的验证码返回更大的时间步
+	if got, ok := Validate(rfcSecret, mustCode(t, c+1), start, 1); !ok || got != c+1 {
+		t.Errorf("next step: Validate = %d, %v, want %d, true", got, ok, c+1)
+	}
+}
+
+func mustCode(t *testing.T, counter int64) string {
+	t.Helper()
+	s, err := Code(rfcSecret, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if a == b || len(a) != 32 {
+		t.Errorf("secrets %q, %q", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Errorf("generated secret not usable: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("blue bell", "alice@example.com", "ABC")
+	want := "otpauth://totp/blue%20bell:alice@example.com?algorithm=SHA1&digits=6&issuer=blue+bell&period=30&secret=ABC"
+	if got != want {
+		t.Errorf("URI =\n%s\nwant\n%s", got, want)
+	}
+}
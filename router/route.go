@@ -4,33 +4,92 @@ import (
 	"bluebell/controller"
 	"bluebell/logger"
 	"bluebell/middlewares"
+	"bluebell/pkg/metrics"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// publicRoutes 公开接口白名单 (方法 + 路由), 不在其中的接口都需要登录
+var publicRoutes = map[string]bool{
+	"POST /signup":                         true,
+	"POST /login":                          true,
+	"POST /refresh":                        true,
+	"POST /login/2fa":                      true, // 使用密码登录后返回的 challenge 完成两步验证
+	"POST /login/2fa/enroll":               true, // 角色要求两步验证但尚未绑定时, 使用 challenge 绑定
+	"GET /oidc/login":                      true,
+	"GET /oidc/callback":                   true,
+	"GET /health":                          true,
+	"GET /metrics":                         true, // Prometheus 抓取, 校验 metrics.token, 未配置时不输出指标
+	"POST /server/webhook/jenkins/:nodeId": true, // Jenkins 推送构建事件, 使用节点密钥签名校验
+}
+
 func SetupRouter(mode string) *gin.Engine {
 	if mode == gin.ReleaseMode {
 		gin.SetMode(gin.ReleaseMode) // gin设置成发布模式
 	}
 	r := gin.New()
-	r.Use(logger.GinLogger(), logger.GinRecovery(true))
+	r.Use(logger.GinRequestID(), logger.GinLogger(), metrics.GinMetrics(), logger.GinRecovery(true), middlewares.AuthMiddleware(publicRoutes), middlewares.AuditMiddleware())
 
 	// 注册
 	r.POST("/signup", controller.SignUpHandler)
 	// 登录
 	r.POST("/login", controller.LoginHandler)
+	// 登录第二步: 两步验证
+	r.POST("/login/2fa", controller.Login2FAHandler)
+	r.POST("/login/2fa/enroll", controller.LoginEnrollTOTPHandler)
+	// 刷新 token
+	r.POST("/refresh", controller.RefreshTokenHandler)
+	// OIDC 单点登录
+	r.GET("/oidc/login", controller.OIDCLoginHandler)
+	r.GET("/oidc/callback", controller.OIDCCallbackHandler)
+	// 注销
+	r.POST("/logout", controller.LogoutHandler)
+	// 健康检查
+	r.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+	// Prometheus 指标
+	r.GET("/metrics", controller.MetricsHandler)
+
+	// 当前登录用户
+	meGroup := r.Group("/me")
+	{
+		meGroup.GET("", controller.GetMeHandler)                   // 个人信息
+		meGroup.PUT("", controller.UpdateMeHandler)                // 修改个人资料
+		meGroup.PUT("/password", controller.ChangePasswordHandler) // 修改密码
 
-	r.GET("/ping", middlewares.JWTAuthMiddleware(), func(c *gin.Context) {
-		// 如果是登录的用户,判断请求头中是否有 有效的JWT  ？
+		// 两步验证
+		meGroup.GET("/2fa", controller.GetTOTPStatusHandler)
+		meGroup.POST("/2fa/enroll", controller.EnrollTOTPHandler)
+		meGroup.POST("/2fa/activate", controller.ActivateTOTPHandler)
+		meGroup.POST("/2fa/disable", controller.DisableTOTPHandler)
+		meGroup.POST("/2fa/recovery_codes", controller.RegenerateRecoveryCodesHandler)
+	}
+
+	// 个人访问令牌, 供脚本和 CI 使用
+	apiTokenGroup := r.Group("/api_token")
+	{
+		apiTokenGroup.POST("", controller.AddAPIToken)          // 创建
+		apiTokenGroup.GET("", controller.GetAPITokens)          // 获取
+		apiTokenGroup.DELETE("/:id", controller.DeleteAPIToken) // 吊销
+	}
+
+	r.GET("/ping", func(c *gin.Context) {
+		// 登录的用户才能访问, 用于校验 JWT 是否有效
 		c.JSON(http.StatusOK, gin.H{
 			"msg": "ok",
 		})
 	})
 
+	// /server 接口按角色校验对节点和 Job 的权限
+	server := r.Group("/server", middlewares.RBACMiddleware())
+
 	// 节点管理
 	//r.GET("/server/nodes", controller.GetNameServerNodes)
-	serverNodeGroup := r.Group("/server/node")
+	serverNodeGroup := server.Group("/node")
 	{
 		serverNodeGroup.POST("", controller.AddServerNode)          // 新增
 		serverNodeGroup.GET("", controller.GetServerNodes)          // 获取
@@ -38,24 +97,24 @@ func SetupRouter(mode string) *gin.Engine {
 		serverNodeGroup.DELETE("/:id", controller.DeleteServerNode) // 删除
 	}
 
-	serverNodeGroup = r.Group("/server/node_view")
+	serverNodeGroup = server.Group("/node_view")
 	{
 		serverNodeGroup.POST("/get/view", controller.GetNodeViews)
 	}
 
-	serverNodeGroup = r.Group("/server/view")
+	serverNodeGroup = server.Group("/view")
 	{
 		serverNodeGroup.POST("/get", controller.GetNodeViewsT)
 	}
 
-	serverNodeGroup = r.Group("/server/view_jobs")
+	serverNodeGroup = server.Group("/view_jobs")
 	{
 		serverNodeGroup.POST("/get/job", controller.GetNodeJobsT)
 		serverNodeGroup.POST("/start/job", controller.StartNodeJobsT)
 		serverNodeGroup.POST("/stop/job", controller.StopNodeJobsT)
 	}
 
-	serverNodeGroup = r.Group("/server/view_console")
+	serverNodeGroup = server.Group("/view_console")
 	{
 		serverNodeGroup.POST("/get", controller.GetNodeConsole)
 		serverNodeGroup.POST("/pipeline/overview", controller.GetConsolePipeOverview)
@@ -63,6 +122,139 @@ func SetupRouter(mode string) *gin.Engine {
 		serverNodeGroup.POST("/build/previous", controller.ConsoleBuildPrevious)
 		serverNodeGroup.POST("/build/next", controller.ConsoleBuildNext)
 		serverNodeGroup.DELETE("/build/delete", controller.ConsoleBuildDelete)
+		serverNodeGroup.POST("/search", controller.SearchConsole)        // 日志搜索
+		serverNodeGroup.POST("/problems", controller.GetConsoleProblems) // 错误/警告提取
+		serverNodeGroup.GET("/rules", controller.GetConsoleRules)        // 提取规则
+	}
+
+	// 构建日志归档
+	serverNodeGroup = server.Group("/console_archive")
+	{
+		serverNodeGroup.POST("", controller.ArchiveConsole)             // 手动归档
+		serverNodeGroup.GET("", controller.GetConsoleArchives)          // 获取
+		serverNodeGroup.GET("/:id", controller.GetConsoleArchive)       // 获取日志内容
+		serverNodeGroup.DELETE("/:id", controller.DeleteConsoleArchive) // 删除
+	}
+
+	// Job 配置 (config.xml) 及版本历史
+	serverNodeGroup = server.Group("/job_config")
+	{
+		serverNodeGroup.POST("/get", controller.GetJobConfig)
+		serverNodeGroup.POST("/update", controller.UpdateJobConfig)
+		serverNodeGroup.POST("/versions", controller.GetJobConfigVersions)
+		serverNodeGroup.GET("/version/:id", controller.GetJobConfigVersion)
+		serverNodeGroup.POST("/diff", controller.DiffJobConfig)
+		serverNodeGroup.POST("/rollback", controller.RollbackJobConfig)
+	}
+
+	// Job 模板
+	serverNodeGroup = server.Group("/job_template")
+	{
+		serverNodeGroup.POST("", controller.AddJobTemplate)          // 新增
+		serverNodeGroup.GET("", controller.GetJobTemplates)          // 获取
+		serverNodeGroup.GET("/:id", controller.GetJobTemplate)       // 获取单个
+		serverNodeGroup.PUT("", controller.UpdateJobTemplate)        // 更新
+		serverNodeGroup.DELETE("/:id", controller.DeleteJobTemplate) // 删除
+		serverNodeGroup.POST("/render", controller.RenderJobTemplate)
+		serverNodeGroup.POST("/apply", controller.ApplyJobTemplate)
+	}
+
+	// 多节点批量操作
+	serverNodeGroup = server.Group("/multi_node")
+	{
+		serverNodeGroup.POST("/search", controller.MultiSearchJobs)
+		serverNodeGroup.POST("/build", controller.MultiBuildJobs)
+		serverNodeGroup.POST("/stop", controller.MultiStopJobs)
+	}
+
+	// 定时构建计划
+	serverNodeGroup = server.Group("/schedule")
+	{
+		serverNodeGroup.POST("", controller.AddSchedule)          // 新增
+		serverNodeGroup.GET("", controller.GetSchedules)          // 获取
+		serverNodeGroup.GET("/:id", controller.GetSchedule)       // 获取单个
+		serverNodeGroup.PUT("", controller.UpdateSchedule)        // 更新
+		serverNodeGroup.DELETE("/:id", controller.DeleteSchedule) // 删除
+	}
+
+	// 工作流 (多 Job 编排)
+	serverNodeGroup = server.Group("/workflow")
+	{
+		serverNodeGroup.POST("", controller.AddWorkflow)          // 新增
+		serverNodeGroup.GET("", controller.GetWorkflows)          // 获取
+		serverNodeGroup.GET("/:id", controller.GetWorkflow)       // 获取单个
+		serverNodeGroup.PUT("", controller.UpdateWorkflow)        // 更新
+		serverNodeGroup.DELETE("/:id", controller.DeleteWorkflow) // 删除
+		serverNodeGroup.POST("/start", controller.StartWorkflow)  // 启动
+	}
+
+	serverNodeGroup = server.Group("/workflow_run")
+	{
+		serverNodeGroup.GET("", controller.GetWorkflowRuns)
+		serverNodeGroup.GET("/:id", controller.GetWorkflowRun)
+		serverNodeGroup.POST("/pause", controller.PauseWorkflowRun)
+		serverNodeGroup.POST("/resume", controller.ResumeWorkflowRun)
+		serverNodeGroup.POST("/cancel", controller.CancelWorkflowRun)
+		serverNodeGroup.POST("/approve", controller.ApproveWorkflowStep)
+	}
+
+	// 构建通知
+	serverNodeGroup = server.Group("/notify_rule")
+	{
+		serverNodeGroup.POST("", controller.AddNotifyRule)          // 新增
+		serverNodeGroup.GET("", controller.GetNotifyRules)          // 获取
+		serverNodeGroup.GET("/:id", controller.GetNotifyRule)       // 获取单个
+		serverNodeGroup.PUT("", controller.UpdateNotifyRule)        // 更新
+		serverNodeGroup.DELETE("/:id", controller.DeleteNotifyRule) // 删除
+		serverNodeGroup.POST("/test", controller.TestNotifyRule)    // 发送测试通知
+	}
+
+	serverNodeGroup = server.Group("/notify_log")
+	{
+		serverNodeGroup.GET("", controller.GetNotifyLogs)
+	}
+
+	// 入站 Webhook, Jenkins 推送构建事件
+	serverNodeGroup = server.Group("/webhook")
+	{
+		serverNodeGroup.POST("/jenkins/:nodeId", controller.JenkinsWebhook) // 接收构建事件 (公开接口)
+		serverNodeGroup.POST("/secret", controller.GenerateWebhookSecret)   // 生成节点密钥
+		serverNodeGroup.GET("/events", controller.BuildEventStream)         // 订阅构建事件 (SSE)
+	}
+
+	// 角色与权限
+	serverNodeGroup = server.Group("/rbac/role")
+	{
+		serverNodeGroup.POST("", controller.AddRole)              // 新增
+		serverNodeGroup.GET("", controller.GetRoles)              // 获取
+		serverNodeGroup.GET("/:id", controller.GetRole)           // 获取单个
+		serverNodeGroup.PUT("", controller.UpdateRole)            // 更新
+		serverNodeGroup.DELETE("/:id", controller.DeleteRole)     // 删除
+		serverNodeGroup.PUT("/2fa", controller.SetRoleRequire2FA) // 设置是否要求两步验证
+	}
+
+	// 用户管理
+	serverNodeGroup = server.Group("/user")
+	{
+		serverNodeGroup.GET("", controller.GetUsers)                     // 获取
+		serverNodeGroup.POST("", controller.AddUser)                     // 新增
+		serverNodeGroup.PUT("/status", controller.SetUserStatus)         // 启用/禁用
+		serverNodeGroup.PUT("/password", controller.ResetUserPassword)   // 重置密码
+		serverNodeGroup.DELETE("/:userId", controller.DeleteUser)        // 删除
+		serverNodeGroup.DELETE("/:userId/2fa", controller.ResetUserTOTP) // 重置两步验证
+	}
+
+	// 审计日志
+	serverNodeGroup = server.Group("/audit")
+	{
+		serverNodeGroup.GET("", controller.GetAuditLogs)
+		serverNodeGroup.GET("/export", controller.ExportAuditLogs) // 导出 csv / json
+	}
+
+	serverNodeGroup = server.Group("/rbac/user_role")
+	{
+		serverNodeGroup.PUT("", controller.SetUserRoles)         // 设置用户角色
+		serverNodeGroup.GET("/:userId", controller.GetUserRoles) // 获取用户角色
 	}
 
 	r.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"bluebell/controller"
+	"bluebell/setting"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var paramPattern = regexp.MustCompile(`[:*][^/]+`)
+
+func setupTestRouter(t *testing.T, conf *setting.AppConfig) *gin.Engine {
+	t.Helper()
+	old := setting.Conf
+	setting.Conf = conf
+	t.Cleanup(func() { setting.Conf = old })
+	gin.SetMode(gin.TestMode)
+	return SetupRouter(gin.TestMode)
+}
+
+// 不在白名单中的接口, 不携带 token 时都应返回 CodeNeedLogin
+func TestRoutesRequireLogin(t *testing.T) {
+	r := setupTestRouter(t, &setting.AppConfig{})
+	for _, route := range r.Routes() {
+		key := route.Method + " " + route.Path
+		if publicRoutes[key] {
+			continue
+		}
+		req := httptest.NewRequest(route.Method, paramPattern.ReplaceAllString(route.Path, "1"), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var res controller.ResponseData
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: 响应不是 JSON: %q", key, w.Body.String())
+			continue
+		}
+		if res.Code != controller.CodeNeedLogin {
+			t.Errorf("%s: code = %d, want %d", key, res.Code, controller.CodeNeedLogin)
+		}
+	}
+}
+
+// 白名单中的接口都必须已注册, 避免路由改名后白名单残留
+func TestPublicRoutesRegistered(t *testing.T) {
+	r := setupTestRouter(t, &setting.AppConfig{})
+	registered := make(map[string]bool)
+	for _, route := range r.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for key := range publicRoutes {
+		if !registered[key] {
+			t.Errorf("白名单中的 %s 没有注册", key)
+		}
+	}
+}
+
+func TestMetricsRequiresToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   *setting.MetricsConfig
+		header string
+		want   int
+	}{
+		{"未配置 token", nil, "", http.StatusNotFound},
+		{"token 为空", &setting.MetricsConfig{}, "Bearer ", http.StatusNotFound},
+		{"未携带 token", &setting.MetricsConfig{Token: "secret"}, "", http.StatusUnauthorized},
+		{"token 错误", &setting.MetricsConfig{Token: "secret"}, "Bearer wrong", http.StatusUnauthorized},
+		{"token 正确", &setting.MetricsConfig{Token: "secret"}, "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupTestRouter(t, &setting.AppConfig{MetricsConfig: tt.conf})
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -17,9 +17,19 @@ type AppConfig struct {
 	MachineID int64  `mapstructure:"machine_id"`
 	Port      int    `mapstructure:"port"`
 
-	*LogConfig   `mapstructure:"log"`
-	*MySQLConfig `mapstructure:"mysql"`
-	*RedisConfig `mapstructure:"redis"`
+	AdminUsers     []string `mapstructure:"admin_users"`     // 启动时授予管理员角色的用户名, 注册和外部登录不会自动产生管理员
+	TrustedProxies []string `mapstructure:"trusted_proxies"` // 可信的反向代理 (IP 或 CIDR), 只有来自这些地址的请求才使用 X-Forwarded-For
+
+	*LogConfig     `mapstructure:"log"`
+	*MySQLConfig   `mapstructure:"mysql"`
+	*RedisConfig   `mapstructure:"redis"`
+	*SMTPConfig    `mapstructure:"smtp"`
+	*ArchiveConfig `mapstructure:"archive"`
+	*JWTConfig     `mapstructure:"jwt"`
+	*AuthConfig    `mapstructure:"auth"`
+	*MetricsConfig `mapstructure:"metrics"`
+
+	ConsoleRules []ConsoleRule `mapstructure:"console_rules"`
 }
 
 type MySQLConfig struct {
@@ -41,12 +51,110 @@ type RedisConfig struct {
 	MinIdleConns int    `mapstructure:"min_idle_conns"`
 }
 
+// SMTPConfig 邮件通知使用的 SMTP 配置
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// ArchiveConfig 构建日志归档配置, 未配置时不归档
+type ArchiveConfig struct {
+	Storage   string `mapstructure:"storage"` // local / s3
+	Dir       string `mapstructure:"dir"`     // local 存储目录
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Prefix    string `mapstructure:"prefix"`
+}
+
+// JWTConfig JWT 签名配置, 只有 dev 模式下允许不配置, 此时使用随机生成的 HS256 密钥
+// 轮换密钥时新增一把密钥并改为 signing_key, 旧密钥保留到已签发的 token 过期后再删除
+type JWTConfig struct {
+	SigningKey    string   `mapstructure:"signing_key"`    // 用于签名的密钥 id
+	Issuer        string   `mapstructure:"issuer"`         // 签发人
+	AccessExpire  int      `mapstructure:"access_expire"`  // access token 有效期, 单位分钟, 默认 30
+	RefreshExpire int      `mapstructure:"refresh_expire"` // refresh token 有效期, 单位小时, 默认 168
+	Keys          []JWTKey `mapstructure:"keys"`
+}
+
+// JWTKey 一把签名/验签密钥
+type JWTKey struct {
+	ID         string `mapstructure:"id"`          // 写入 token 头部的 kid
+	Algorithm  string `mapstructure:"algorithm"`   // HS256/HS384/HS512/RS256/RS384/RS512/PS256/ES256/ES384/ES512
+	Secret     string `mapstructure:"secret"`      // HMAC 密钥, 至少 32 字节
+	PrivateKey string `mapstructure:"private_key"` // RSA/ECDSA 私钥 PEM 文件路径, 只用于验签的密钥可不配置
+	PublicKey  string `mapstructure:"public_key"`  // RSA/ECDSA 公钥 PEM 文件路径, 配置了私钥时可不配置
+}
+
+// AuthConfig 登录认证配置, 未配置时只使用本地用户
+type AuthConfig struct {
+	Providers   []string `mapstructure:"providers"` // 按顺序尝试的认证方式: local / ldap
+	*LDAPConfig `mapstructure:"ldap"`
+	*OIDCConfig `mapstructure:"oidc"` // 单点登录, 配置 issuer 后启用
+}
+
+// LDAPConfig LDAP / Active Directory 认证配置
+type LDAPConfig struct {
+	URL                string      `mapstructure:"url"` // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool        `mapstructure:"start_tls"`
+	InsecureSkipVerify bool        `mapstructure:"insecure_skip_verify"`
+	Timeout            int         `mapstructure:"timeout"`       // 单位秒, 默认 10
+	BindDN             string      `mapstructure:"bind_dn"`       // 用于查询用户的服务账号
+	BindPassword       string      `mapstructure:"bind_password"` // 服务账号密码
+	BaseDN             string      `mapstructure:"base_dn"`
+	UserFilter         string      `mapstructure:"user_filter"`     // %s 替换为转义后的用户名, 如 (uid=%s), AD 为 (sAMAccountName=%s)
+	GroupAttribute     string      `mapstructure:"group_attribute"` // 用户所属组的属性, 默认 memberOf
+	GroupRoles         []GroupRole `mapstructure:"group_roles"`
+}
+
+// OIDCConfig OIDC 单点登录配置, 使用授权码 + PKCE 流程
+type OIDCConfig struct {
+	Issuer        string      `mapstructure:"issuer"` // 用于获取 /.well-known/openid-configuration
+	ClientID      string      `mapstructure:"client_id"`
+	ClientSecret  string      `mapstructure:"client_secret"`  // 公共客户端可以不配置
+	RedirectURL   string      `mapstructure:"redirect_url"`   // 本服务的回调地址, 如 https://devops.example.com/oidc/callback
+	Scopes        []string    `mapstructure:"scopes"`         // 默认 openid profile email
+	UsernameClaim string      `mapstructure:"username_claim"` // 首次登录时作为用户名和昵称的声明, 默认 preferred_username; 用户按 iss + sub 关联
+	GroupsClaim   string      `mapstructure:"groups_claim"`   // 用户所属组的声明, 默认 groups
+	GroupRoles    []GroupRole `mapstructure:"group_roles"`
+	FrontendURL   string      `mapstructure:"frontend_url"` // 登录成功后跳转的前端地址, token 放在 URL 的 fragment 中; 未配置时直接返回 JSON
+	Timeout       int         `mapstructure:"timeout"`      // 请求 IdP 的超时时间, 单位秒, 默认 10
+}
+
+// GroupRole LDAP / OIDC 组与角色的映射, 配置后每次登录按所属组同步用户角色
+type GroupRole struct {
+	Group string `mapstructure:"group"` // LDAP 为组的 DN 或 CN, OIDC 为组声明中的值, 不区分大小写
+	Role  string `mapstructure:"role"`  // 角色名
+}
+
+// MetricsConfig Prometheus 指标配置, 未配置 token 时不输出 /metrics, 每 30 秒采集一次各节点的 Jenkins 状态
+type MetricsConfig struct {
+	Token           string `mapstructure:"token"`            // 抓取 /metrics 需要携带 Authorization: Bearer <token>, 为空时不输出指标
+	JenkinsInterval int    `mapstructure:"jenkins_interval"` // 采集各节点队列长度和执行器的间隔, 单位秒, 默认 30, 小于 0 时不采集
+}
+
+// ConsoleRule 自定义的构建日志问题提取规则, 与内置规则一起生效
+type ConsoleRule struct {
+	Name    string `mapstructure:"name"`
+	Tool    string `mapstructure:"tool"`
+	Level   string `mapstructure:"level"` // error / warning
+	Pattern string `mapstructure:"pattern"`
+}
+
 type LogConfig struct {
 	Level      string `mapstructure:"level"`
 	Filename   string `mapstructure:"filename"`
 	MaxSize    int    `mapstructure:"max_size"`
 	MaxAge     int    `mapstructure:"max_age"`
 	MaxBackups int    `mapstructure:"max_backups"`
+
+	// RedactKeys 日志和审计详情中额外需要脱敏的键, 默认已包含 password / secret / token / authorization 等
+	RedactKeys []string `mapstructure:"redact_keys"`
 }
 
 func Init(filePath string) (err error) {